@@ -1,47 +1,36 @@
 package client
 
 import (
-	"context"
 	"fmt"
-	"log"
 	"testing"
 
 	"github.com/roma-glushko/testcontainers-envtest/go"
-	"github.com/testcontainers/testcontainers-go"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 func TestExample(t *testing.T) {
-	ctx := context.Background()
+	ctx := t.Context()
 
-	// Start an envtest container
-	container, err := envtest.Run(ctx)
-	if err != nil {
-		log.Fatalf("failed to start envtest container: %v", err)
-	}
-	defer func() {
-		if err := testcontainers.TerminateContainer(container); err != nil {
-			log.Printf("failed to terminate container: %v", err)
-		}
-	}()
+	// Start an envtest container, terminated when the test finishes
+	container := envtest.RunForTest(t)
 
 	// Get a REST config for the Kubernetes client
 	cfg, err := container.RESTConfig(ctx)
 	if err != nil {
-		log.Fatalf("failed to get REST config: %v", err)
+		t.Fatalf("failed to get REST config: %v", err)
 	}
 
 	// Create a Kubernetes clientset
 	clientset, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		log.Fatalf("failed to create kubernetes client: %v", err)
+		t.Fatalf("failed to create kubernetes client: %v", err)
 	}
 
 	// List namespaces
 	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		log.Fatalf("failed to list namespaces: %v", err)
+		t.Fatalf("failed to list namespaces: %v", err)
 	}
 
 	fmt.Printf("Found %d namespaces\n", len(namespaces.Items))
@@ -52,22 +41,14 @@ func TestExampleWithVersion(t *testing.T) {
 	ctx := t.Context()
 
 	// Start an envtest container with a specific Kubernetes version
-	container, err := envtest.Run(ctx,
+	container := envtest.RunForTest(t,
 		envtest.WithKubernetesVersion("1.35.0"),
 	)
-	if err != nil {
-		log.Fatalf("failed to start envtest container: %v", err)
-	}
-	defer func() {
-		if err := testcontainers.TerminateContainer(container); err != nil {
-			log.Printf("failed to terminate container: %v", err)
-		}
-	}()
 
 	// Get the API server URL
 	url, err := container.APIServerURL(ctx)
 	if err != nil {
-		log.Fatalf("failed to get API server URL: %v", err)
+		t.Fatalf("failed to get API server URL: %v", err)
 	}
 
 	fmt.Printf("Kubernetes version: %s\n", container.KubernetesVersion())
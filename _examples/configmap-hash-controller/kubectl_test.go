@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubectlAgainstEnvtest(t *testing.T) {
+	kubectl, err := exec.LookPath("kubectl")
+	if err != nil {
+		t.Skip("kubectl is not installed")
+	}
+
+	ctx := t.Context()
+
+	k8s := envtest.RunForTest(t)
+
+	// Tools like kubectl, helm or kuttl take a kubeconfig file instead of a rest.Config
+	kubeconfigPath, err := k8s.WriteKubeconfig(ctx, t.TempDir())
+	require.NoError(t, err)
+
+	cmd := exec.CommandContext(ctx, kubectl, "create", "configmap", "from-kubectl", "--from-literal=key1=value1")
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath)
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	cmd = exec.CommandContext(ctx, kubectl, "get", "configmap", "from-kubectl", "-o", "jsonpath={.data.key1}")
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath)
+
+	out, err = cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "value1", strings.TrimSpace(string(out)))
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"testing"
@@ -10,7 +9,6 @@ import (
 	envtest "github.com/roma-glushko/testcontainers-envtest/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/testcontainers/testcontainers-go"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -20,13 +18,8 @@ import (
 func TestConfigMapHashController(t *testing.T) {
 	ctx := t.Context()
 
-	k8s, err := envtest.Run(ctx)
-	require.NoError(t, err)
-
-	defer func() {
-		err := testcontainers.TerminateContainer(k8s)
-		require.NoError(t, err)
-	}()
+	// Termination is registered before StartManager, so the manager is stopped before the container is terminated
+	k8s := envtest.RunForTest(t)
 
 	// Get REST config from container
 	restConfig, err := k8s.RESTConfig(ctx)
@@ -44,20 +37,8 @@ func TestConfigMapHashController(t *testing.T) {
 
 	require.NoError(t, err)
 
-	// Start manager in background
-	mgrCtx, mgrCancel := context.WithCancel(ctx)
-	defer mgrCancel()
-
-	go func() {
-		if err := mgr.Start(mgrCtx); err != nil {
-			t.Logf("Manager stopped: %v", err)
-		}
-	}()
-
-	// Wait for cache to sync
-	require.Eventually(t, func() bool {
-		return mgr.GetCache().WaitForCacheSync(ctx)
-	}, 30*time.Second, 100*time.Millisecond, "Cache failed to sync")
+	// Start manager in background and wait for its cache to sync
+	envtest.StartManager(t, mgr)
 
 	k8sClient := mgr.GetClient()
 
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const touchedAnnotation = "configmap-hash-controller.example.io/touched"
+
+// touchingReconciler is a regressed reconciler performing one extra update per ConfigMap
+type touchingReconciler struct {
+	*ConfigMapHashReconciler
+}
+
+func (r *touchingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if result, err := r.ConfigMapHashReconciler.Reconcile(ctx, req); err != nil {
+		return result, err
+	}
+
+	var configMap corev1.ConfigMap
+	if err := r.Get(ctx, req.NamespacedName, &configMap); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if _, ok := configMap.Annotations[touchedAnnotation]; ok || len(GetHashAnnotationKeys(configMap.Annotations)) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	configMap.Annotations[touchedAnnotation] = "true"
+
+	return ctrl.Result{}, r.Update(ctx, &configMap)
+}
+
+// fakeT records assertion failures instead of failing the test
+type fakeT struct {
+	testing.TB
+
+	failures []string
+}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func TestConfigMapHashControllerRecording(t *testing.T) {
+	ctx := t.Context()
+
+	k8s := envtest.RunForTest(t, envtest.WithAuditPolicy(envtest.RecordingAuditPolicy))
+
+	adminConfig, err := k8s.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	admin, err := client.New(adminConfig, client.Options{})
+	require.NoError(t, err)
+
+	// record runs the reconciler as the per-test identity and creates a watched ConfigMap as the admin,
+	// so only the reconciler mutations are recorded
+	record := func(t *testing.T, wrap func(*ConfigMapHashReconciler) reconcile.Reconciler) *envtest.Recording {
+		restConfig, err := k8s.RESTConfigForTest(t)
+		require.NoError(t, err)
+
+		mgr, err := ctrl.NewManager(restConfig, ctrl.Options{Metrics: metricsserver.Options{BindAddress: "0"}})
+		require.NoError(t, err)
+
+		reconciler := &ConfigMapHashReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()}
+
+		err = ctrl.NewControllerManagedBy(mgr).For(&corev1.ConfigMap{}).Complete(wrap(reconciler))
+		require.NoError(t, err)
+
+		envtest.StartManager(t, mgr)
+
+		recording, err := k8s.StartRecording(ctx, t)
+		require.NoError(t, err)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "recorded",
+				Namespace: "default",
+				Labels:    map[string]string{WatchLabel: "true"},
+			},
+			Data: map[string]string{"key1": "value1"},
+		}
+		require.NoError(t, admin.Create(ctx, configMap))
+
+		t.Cleanup(func() {
+			require.NoError(t, client.IgnoreNotFound(admin.Delete(context.WithoutCancel(ctx), configMap)))
+		})
+
+		require.Eventually(t, func() bool {
+			var cm corev1.ConfigMap
+			if err := admin.Get(ctx, types.NamespacedName{Name: "recorded", Namespace: "default"}, &cm); err != nil {
+				return false
+			}
+
+			return len(GetHashAnnotationKeys(cm.Annotations)) == 1
+		}, 10*time.Second, 50*time.Millisecond, "ConfigMap was not reconciled")
+
+		return recording
+	}
+
+	t.Run("matches golden", func(t *testing.T) {
+		recording := record(t, func(r *ConfigMapHashReconciler) reconcile.Reconciler { return r })
+
+		recording.AssertMatchesGolden(t, "testdata/recording.golden.yaml")
+	})
+
+	t.Run("detects extra update", func(t *testing.T) {
+		recording := record(t, func(r *ConfigMapHashReconciler) reconcile.Reconciler { return &touchingReconciler{r} })
+
+		ft := &fakeT{TB: t}
+		recording.AssertMatchesGolden(ft, "testdata/recording.golden.yaml")
+
+		require.Len(t, ft.failures, 1)
+		require.Contains(t, ft.failures[0], touchedAnnotation)
+	})
+}
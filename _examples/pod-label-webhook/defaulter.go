@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// TeamLabel is the label that Pods are defaulted with
+	TeamLabel = "pod-label-webhook.example.io/team"
+	// DefaultTeam is the TeamLabel value of Pods that do not set it
+	DefaultTeam = "platform"
+)
+
+// PodLabelDefaulter is a mutating webhook that labels Pods with their owning team
+type PodLabelDefaulter struct{}
+
+// Default sets the TeamLabel of Pods that do not have one
+func (d *PodLabelDefaulter) Default(ctx context.Context, pod *corev1.Pod) error {
+	if _, ok := pod.Labels[TeamLabel]; ok {
+		return nil
+	}
+
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+
+	pod.Labels[TeamLabel] = DefaultTeam
+
+	ctrl.LoggerFrom(ctx).Info("Defaulted Pod team", "name", pod.Name, "team", DefaultTeam)
+
+	return nil
+}
+
+// SetupWebhookWithManager registers the webhook with the Manager, served at /mutate--v1-pod
+func (d *PodLabelDefaulter) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &corev1.Pod{}).
+		WithDefaulter(d).
+		Complete()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func TestPodLabelDefaulter(t *testing.T) {
+	ctx := t.Context()
+
+	// The webhook configurations are pointed at the webhook server of the manager below
+	// Termination is registered before StartManager, so the manager is stopped before the container is terminated
+	k8s := envtest.RunForTest(t, envtest.WithWebhooks("config/webhook"))
+
+	restConfig, err := k8s.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	// Serve the webhooks where the API server calls them, with the certificate the injected CA issued
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		WebhookServer: webhook.NewServer(k8s.WebhookOptions().ManagerOptions()),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, (&PodLabelDefaulter{}).SetupWebhookWithManager(mgr))
+
+	envtest.StartManager(t, mgr)
+
+	// The webhook server starts listening asynchronously
+	require.Eventually(t, func() bool {
+		return mgr.GetWebhookServer().StartedChecker()(nil) == nil
+	}, 10*time.Second, 100*time.Millisecond, "webhook server did not start")
+
+	k8sClient := mgr.GetClient()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+		},
+	}
+
+	// Mutating webhooks apply synchronously, the created Pod is already defaulted
+	require.NoError(t, k8sClient.Create(ctx, pod))
+	require.Equal(t, DefaultTeam, pod.Labels[TeamLabel])
+
+	labeled := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "labeled",
+			Namespace: "default",
+			Labels:    map[string]string{TeamLabel: "payments"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, labeled))
+	require.Equal(t, "payments", labeled.Labels[TeamLabel])
+}
@@ -4,6 +4,12 @@ set -e
 # Configuration
 ENVTEST_BIN_DIR="/usr/local/bin/envtest"
 ETCD_PORT="${ETCD_PORT:-2379}"
+# Address etcd listens on, all interfaces with WithEtcdExposed
+ETCD_LISTEN_ADDRESS="${ENVTEST_ETCD_LISTEN_ADDRESS:-127.0.0.1}"
+# Skip fsync in etcd, set by WithEtcdInMemory as its data dir is a tmpfs anyway
+ETCD_UNSAFE_NO_FSYNC="${ENVTEST_ETCD_UNSAFE_NO_FSYNC:-false}"
+# Backend quota of etcd in bytes, set by WithEtcdQuotaBytes, etcd's 2GiB by default
+ETCD_QUOTA_BACKEND_BYTES="${ENVTEST_ETCD_QUOTA_BACKEND_BYTES:-}"
 API_SERVER_PORT="${API_SERVER_PORT:-6443}"
 KUBECONFIG_PATH="${KUBECONFIG_PATH:-/tmp/kubeconfig}"
 DATA_DIR="/tmp/envtest"
@@ -11,6 +17,8 @@ CERTS_CONF_DIR="/etc/envtest/certs"
 
 # Create data directory
 mkdir -p "${DATA_DIR}"
+# Hold files left by a container stopped mid-restart would keep the processes down
+rm -f "${DATA_DIR}"/*.hold
 
 # Detect OS and architecture
 OS=$(uname -s | tr '[:upper:]' '[:lower:]')
@@ -32,6 +40,9 @@ echo "Using envtest binaries from: ${BINARY_DIR}"
 
 ETCD_BINARY="${BINARY_DIR}/etcd"
 APISERVER_BINARY="${BINARY_DIR}/kube-apiserver"
+CONTROLLER_MANAGER_BINARY="${BINARY_DIR}/kube-controller-manager"
+SCHEDULER_BINARY="${BINARY_DIR}/kube-scheduler"
+KWOK_BINARY="${BINARY_DIR}/kwok"
 
 # Verify binaries exist
 if [ ! -x "${ETCD_BINARY}" ]; then
@@ -44,6 +55,34 @@ if [ ! -x "${APISERVER_BINARY}" ]; then
     exit 1
 fi
 
+# kube-controller-manager runs with WithControllerManager only
+CONTROLLER_MANAGER="${ENVTEST_CONTROLLER_MANAGER:-false}"
+CONTROLLERS="${ENVTEST_CONTROLLERS:-*}"
+CONTROLLER_MANAGER_PORT=10257
+
+if [ "${CONTROLLER_MANAGER}" = "true" ] && [ ! -x "${CONTROLLER_MANAGER_BINARY}" ]; then
+    echo "ERROR: kube-controller-manager binary not found or not executable at ${CONTROLLER_MANAGER_BINARY}"
+    exit 1
+fi
+
+# kube-scheduler runs with WithScheduler only
+SCHEDULER="${ENVTEST_SCHEDULER:-false}"
+SCHEDULER_PORT=10259
+
+if [ "${SCHEDULER}" = "true" ] && [ ! -x "${SCHEDULER_BINARY}" ]; then
+    echo "ERROR: kube-scheduler binary not found or not executable at ${SCHEDULER_BINARY}"
+    exit 1
+fi
+
+# kwok runs with WithKWOK only, simulating the kubelets of nodes annotated with kwok.x-k8s.io/node=fake
+KWOK="${ENVTEST_KWOK:-false}"
+KWOK_PORT=10247
+
+if [ "${KWOK}" = "true" ] && [ ! -x "${KWOK_BINARY}" ]; then
+    echo "ERROR: kwok binary not found or not executable at ${KWOK_BINARY}"
+    exit 1
+fi
+
 # Generate certificates for the API server
 CERT_START=$(awk '{print $1}' /proc/uptime)
 echo "Generating certificates..."
@@ -56,12 +95,35 @@ openssl req -x509 -new -nodes -key "${DATA_DIR}/certs/ca.key" \
     -days 365 -out "${DATA_DIR}/certs/ca.crt" \
     -config "${CERTS_CONF_DIR}/ca.conf" 2>/dev/null
 
+# The client CA is a copy, so it can be rotated without touching the CA that signs the serving certificate
+cp "${DATA_DIR}/certs/ca.crt" "${DATA_DIR}/certs/client-ca.crt"
+
+# Extra SANs of the API server certificate, e.g. network aliases (comma-separated DNS names or IPs)
+APISERVER_CERT_CONF="${CERTS_CONF_DIR}/apiserver.conf"
+if [ -n "${ENVTEST_CERT_SANS:-}" ]; then
+    APISERVER_CERT_CONF="${DATA_DIR}/certs/apiserver.conf"
+    cp "${CERTS_CONF_DIR}/apiserver.conf" "${APISERVER_CERT_CONF}"
+
+    DNS_INDEX=5
+    IP_INDEX=3
+    IFS=',' read -ra EXTRA_SANS <<< "${ENVTEST_CERT_SANS}"
+    for SAN in "${EXTRA_SANS[@]}"; do
+        if [[ "${SAN}" =~ ^[0-9.]+$ || "${SAN}" == *:* ]]; then
+            echo "IP.${IP_INDEX} = ${SAN}" >> "${APISERVER_CERT_CONF}"
+            IP_INDEX=$((IP_INDEX + 1))
+        else
+            echo "DNS.${DNS_INDEX} = ${SAN}" >> "${APISERVER_CERT_CONF}"
+            DNS_INDEX=$((DNS_INDEX + 1))
+        fi
+    done
+fi
+
 # Generate API server certificate
 openssl genrsa -out "${DATA_DIR}/certs/apiserver.key" 2048 2>/dev/null
 openssl req -new -key "${DATA_DIR}/certs/apiserver.key" \
     -subj "/CN=kube-apiserver" \
     -out "${DATA_DIR}/certs/apiserver.csr" \
-    -config "${CERTS_CONF_DIR}/apiserver.conf" 2>/dev/null
+    -config "${APISERVER_CERT_CONF}" 2>/dev/null
 
 openssl x509 -req -in "${DATA_DIR}/certs/apiserver.csr" \
     -CA "${DATA_DIR}/certs/ca.crt" \
@@ -70,7 +132,7 @@ openssl x509 -req -in "${DATA_DIR}/certs/apiserver.csr" \
     -out "${DATA_DIR}/certs/apiserver.crt" \
     -days 365 \
     -extensions v3_req \
-    -extfile "${CERTS_CONF_DIR}/apiserver.conf" 2>/dev/null
+    -extfile "${APISERVER_CERT_CONF}" 2>/dev/null
 
 # Generate client certificate for kubeconfig
 openssl genrsa -out "${DATA_DIR}/certs/client.key" 2048 2>/dev/null
@@ -92,19 +154,44 @@ CERT_ELAPSED=$(awk "BEGIN {printf \"%.2f\", $CERT_END - $CERT_START}")
 echo "Certificates generated successfully in ${CERT_ELAPSED}s"
 
 # Start etcd in the background
+ETCD_PID_FILE="${DATA_DIR}/etcd.pid"
+# While this file exists, an exited etcd is not restarted (see Snapshot and Restore)
+ETCD_HOLD_FILE="${DATA_DIR}/etcd.hold"
+
+ETCD_EXTRA_ARGS=()
+# --unsafe-no-fsync is only known to etcd 3.5 and later
+if [ "${ETCD_UNSAFE_NO_FSYNC}" = "true" ] && "${ETCD_BINARY}" --help 2>&1 | grep -q -- "--unsafe-no-fsync"; then
+    ETCD_EXTRA_ARGS+=(--unsafe-no-fsync)
+fi
+if [ -n "${ETCD_QUOTA_BACKEND_BYTES}" ]; then
+    ETCD_EXTRA_ARGS+=(--quota-backend-bytes="${ETCD_QUOTA_BACKEND_BYTES}")
+fi
+# Extra etcd flags of WithEtcdFlags, one per line
+while IFS= read -r flag; do
+    if [ -n "${flag}" ]; then
+        ETCD_EXTRA_ARGS+=("${flag}")
+    fi
+done <<< "${ENVTEST_ETCD_FLAGS:-}"
+
+start_etcd() {
+    "${ETCD_BINARY}" \
+        --data-dir="${DATA_DIR}/etcd" \
+        --listen-client-urls="http://${ETCD_LISTEN_ADDRESS}:${ETCD_PORT}" \
+        --advertise-client-urls="http://127.0.0.1:${ETCD_PORT}" \
+        --listen-peer-urls="http://127.0.0.1:2380" \
+        --initial-advertise-peer-urls="http://127.0.0.1:2380" \
+        --initial-cluster="default=http://127.0.0.1:2380" \
+        --log-level=error \
+        "${ETCD_EXTRA_ARGS[@]}" \
+        &
+
+    ETCD_PID=$!
+    echo "${ETCD_PID}" > "${ETCD_PID_FILE}"
+}
+
 ETCD_START=$(awk '{print $1}' /proc/uptime)
 echo "Starting etcd on port ${ETCD_PORT}..."
-"${ETCD_BINARY}" \
-    --data-dir="${DATA_DIR}/etcd" \
-    --listen-client-urls="http://127.0.0.1:${ETCD_PORT}" \
-    --advertise-client-urls="http://127.0.0.1:${ETCD_PORT}" \
-    --listen-peer-urls="http://127.0.0.1:2380" \
-    --initial-advertise-peer-urls="http://127.0.0.1:2380" \
-    --initial-cluster="default=http://127.0.0.1:2380" \
-    --log-level=error \
-    &
-
-ETCD_PID=$!
+start_etcd
 
 # Wait for etcd to be ready
 echo "Waiting for etcd to be ready..."
@@ -122,32 +209,54 @@ for i in {1..30}; do
     sleep 0.1
 done
 
-# Start kube-apiserver
+# Start kube-apiserver (any arguments passed to the container are forwarded as extra flags)
+APISERVER_PID_FILE="${DATA_DIR}/apiserver.pid"
+# While this file exists, an exited kube-apiserver is not restarted (see ShutdownAPIServer)
+APISERVER_HOLD_FILE="${DATA_DIR}/apiserver.hold"
+
+# Admission plugins disabled by default, overridden when WithEnableAdmissionPlugins enables one of them
+DISABLE_ADMISSION_PLUGINS="${ENVTEST_DISABLE_ADMISSION_PLUGINS-ServiceAccount}"
+
+# Authorization modes, overridden by WithAuthorizationModes
+AUTHORIZATION_MODES="${ENVTEST_AUTHORIZATION_MODES:-RBAC}"
+
+# ServiceAccount token issuer and signing key, overridden by WithServiceAccountIssuer and WithServiceAccountSigningKey
+SERVICE_ACCOUNT_ISSUER="${ENVTEST_SERVICE_ACCOUNT_ISSUER:-https://kubernetes.default.svc}"
+SERVICE_ACCOUNT_KEY_FILE="${ENVTEST_SERVICE_ACCOUNT_KEY_FILE:-${DATA_DIR}/certs/apiserver.key}"
+
+start_apiserver() {
+    "${APISERVER_BINARY}" \
+        --etcd-servers="http://127.0.0.1:${ETCD_PORT}" \
+        --bind-address=0.0.0.0 \
+        --secure-port="${API_SERVER_PORT}" \
+        --tls-cert-file="${DATA_DIR}/certs/apiserver.crt" \
+        --tls-private-key-file="${DATA_DIR}/certs/apiserver.key" \
+        --client-ca-file="${DATA_DIR}/certs/client-ca.crt" \
+        --service-account-key-file="${SERVICE_ACCOUNT_KEY_FILE}" \
+        --service-account-signing-key-file="${SERVICE_ACCOUNT_KEY_FILE}" \
+        --service-account-issuer="${SERVICE_ACCOUNT_ISSUER}" \
+        --authorization-mode="${AUTHORIZATION_MODES}" \
+        --allow-privileged=true \
+        --disable-admission-plugins="${DISABLE_ADMISSION_PLUGINS}" \
+        --service-cluster-ip-range=10.0.0.0/24 \
+        --v=0 \
+        "$@" \
+        &
+
+    APISERVER_PID=$!
+    echo "${APISERVER_PID}" > "${APISERVER_PID_FILE}"
+}
+
 APISERVER_START=$(awk '{print $1}' /proc/uptime)
 echo "Starting kube-apiserver on port ${API_SERVER_PORT}..."
-"${APISERVER_BINARY}" \
-    --etcd-servers="http://127.0.0.1:${ETCD_PORT}" \
-    --bind-address=0.0.0.0 \
-    --secure-port="${API_SERVER_PORT}" \
-    --tls-cert-file="${DATA_DIR}/certs/apiserver.crt" \
-    --tls-private-key-file="${DATA_DIR}/certs/apiserver.key" \
-    --client-ca-file="${DATA_DIR}/certs/ca.crt" \
-    --service-account-key-file="${DATA_DIR}/certs/apiserver.key" \
-    --service-account-signing-key-file="${DATA_DIR}/certs/apiserver.key" \
-    --service-account-issuer="https://kubernetes.default.svc" \
-    --authorization-mode=RBAC \
-    --allow-privileged=true \
-    --disable-admission-plugins=ServiceAccount \
-    --service-cluster-ip-range=10.0.0.0/24 \
-    --v=0 \
-    &
-
-APISERVER_PID=$!
+start_apiserver "$@"
 
 # Wait for API server to be ready
 echo "Waiting for kube-apiserver to be ready..."
 for i in {1..60}; do
-    if curl -sk "https://localhost:${API_SERVER_PORT}/healthz" | grep -q "ok"; then
+    # Authenticated, as anonymous requests may be disabled (see WithAnonymousAuth)
+    if curl -sk --cert "${DATA_DIR}/certs/client.crt" --key "${DATA_DIR}/certs/client.key" \
+        "https://localhost:${API_SERVER_PORT}/healthz" | grep -q "ok"; then
         APISERVER_END=$(awk '{print $1}' /proc/uptime)
         APISERVER_ELAPSED=$(awk "BEGIN {printf \"%.2f\", $APISERVER_END - $APISERVER_START}")
         echo "kube-apiserver is ready in ${APISERVER_ELAPSED}s"
@@ -194,6 +303,110 @@ cp "${DATA_DIR}/certs/ca.crt" /tmp/ca.crt
 cp "${DATA_DIR}/certs/client.crt" /tmp/client.crt
 cp "${DATA_DIR}/certs/client.key" /tmp/client.key
 
+# Start kube-controller-manager against the API server with the admin kubeconfig
+CONTROLLER_MANAGER_PID=""
+if [ "${CONTROLLER_MANAGER}" = "true" ]; then
+    CONTROLLER_MANAGER_START=$(awk '{print $1}' /proc/uptime)
+    echo "Starting kube-controller-manager with controllers ${CONTROLLERS}..."
+    "${CONTROLLER_MANAGER_BINARY}" \
+        --kubeconfig="${KUBECONFIG_PATH}" \
+        --authentication-kubeconfig="${KUBECONFIG_PATH}" \
+        --authorization-kubeconfig="${KUBECONFIG_PATH}" \
+        --bind-address=127.0.0.1 \
+        --secure-port="${CONTROLLER_MANAGER_PORT}" \
+        --controllers="${CONTROLLERS}" \
+        --service-account-private-key-file="${SERVICE_ACCOUNT_KEY_FILE}" \
+        --root-ca-file="${DATA_DIR}/certs/ca.crt" \
+        --cluster-signing-cert-file="${DATA_DIR}/certs/ca.crt" \
+        --cluster-signing-key-file="${DATA_DIR}/certs/ca.key" \
+        --use-service-account-credentials=false \
+        --leader-elect=false \
+        --v=0 \
+        &
+
+    CONTROLLER_MANAGER_PID=$!
+
+    echo "Waiting for kube-controller-manager to be ready..."
+    for i in {1..300}; do
+        if curl -sk "https://127.0.0.1:${CONTROLLER_MANAGER_PORT}/healthz" | grep -q "ok"; then
+            CONTROLLER_MANAGER_END=$(awk '{print $1}' /proc/uptime)
+            CONTROLLER_MANAGER_ELAPSED=$(awk "BEGIN {printf \"%.2f\", $CONTROLLER_MANAGER_END - $CONTROLLER_MANAGER_START}")
+            echo "kube-controller-manager is ready in ${CONTROLLER_MANAGER_ELAPSED}s"
+            break
+        fi
+        if [ $i -eq 300 ]; then
+            echo "ERROR: kube-controller-manager failed to start"
+            exit 1
+        fi
+        sleep 0.1
+    done
+fi
+
+# Start kube-scheduler against the API server with the admin kubeconfig
+SCHEDULER_PID=""
+if [ "${SCHEDULER}" = "true" ]; then
+    SCHEDULER_START=$(awk '{print $1}' /proc/uptime)
+    echo "Starting kube-scheduler..."
+    "${SCHEDULER_BINARY}" \
+        --kubeconfig="${KUBECONFIG_PATH}" \
+        --authentication-kubeconfig="${KUBECONFIG_PATH}" \
+        --authorization-kubeconfig="${KUBECONFIG_PATH}" \
+        --bind-address=127.0.0.1 \
+        --secure-port="${SCHEDULER_PORT}" \
+        --leader-elect=false \
+        --v=0 \
+        &
+
+    SCHEDULER_PID=$!
+
+    echo "Waiting for kube-scheduler to be ready..."
+    for i in {1..300}; do
+        if curl -sk "https://127.0.0.1:${SCHEDULER_PORT}/healthz" | grep -q "ok"; then
+            SCHEDULER_END=$(awk '{print $1}' /proc/uptime)
+            SCHEDULER_ELAPSED=$(awk "BEGIN {printf \"%.2f\", $SCHEDULER_END - $SCHEDULER_START}")
+            echo "kube-scheduler is ready in ${SCHEDULER_ELAPSED}s"
+            break
+        fi
+        if [ $i -eq 300 ]; then
+            echo "ERROR: kube-scheduler failed to start"
+            exit 1
+        fi
+        sleep 0.1
+    done
+fi
+
+# Start kwok against the API server with the admin kubeconfig, using its default node and pod stages
+KWOK_PID=""
+if [ "${KWOK}" = "true" ]; then
+    KWOK_START=$(awk '{print $1}' /proc/uptime)
+    echo "Starting kwok..."
+    "${KWOK_BINARY}" \
+        --kubeconfig="${KUBECONFIG_PATH}" \
+        --manage-all-nodes=false \
+        --manage-nodes-with-annotation-selector=kwok.x-k8s.io/node=fake \
+        --cidr=10.244.0.0/16 \
+        --node-ip=10.0.0.1 \
+        --server-address="127.0.0.1:${KWOK_PORT}" \
+        &
+
+    KWOK_PID=$!
+
+    echo "Waiting for kwok to be ready..."
+    for i in {1..300}; do
+        if curl -s "http://127.0.0.1:${KWOK_PORT}/healthz" | grep -q "ok"; then
+            KWOK_END=$(awk '{print $1}' /proc/uptime)
+            KWOK_ELAPSED=$(awk "BEGIN {printf \"%.2f\", $KWOK_END - $KWOK_START}")
+            echo "kwok is ready in ${KWOK_ELAPSED}s"
+            break
+        fi
+        if [ $i -eq 300 ]; then
+            echo "ERROR: kwok failed to start"
+            exit 1
+        fi
+        sleep 0.1
+    done
+fi
+
 echo ""
 echo "============================================"
 echo "Envtest is ready!"
@@ -202,8 +415,25 @@ echo "Kubeconfig: ${KUBECONFIG_PATH}"
 echo "============================================"
 echo ""
 
-# Handle shutdown gracefully
-trap 'echo "Shutting down..."; kill $APISERVER_PID $ETCD_PID 2>/dev/null; exit 0' SIGTERM SIGINT
+# Handle shutdown gracefully, waiting for kube-apiserver to flush its buffered audit events before stopping etcd
+trap 'echo "Shutting down..."; [ -n "$CONTROLLER_MANAGER_PID" ] && kill $CONTROLLER_MANAGER_PID 2>/dev/null; [ -n "$SCHEDULER_PID" ] && kill $SCHEDULER_PID 2>/dev/null; [ -n "$KWOK_PID" ] && kill $KWOK_PID 2>/dev/null; kill $APISERVER_PID 2>/dev/null; wait $APISERVER_PID 2>/dev/null; kill $ETCD_PID 2>/dev/null; exit 0' SIGTERM SIGINT
+
+# Keep the container running, restarting kube-apiserver whenever it exits unless it is held
+while true; do
+    APISERVER_EXIT=0
+    wait $APISERVER_PID || APISERVER_EXIT=$?
+
+    echo "kube-apiserver exited with code ${APISERVER_EXIT}"
 
-# Keep the container running
-wait $APISERVER_PID
+    while [ -f "${APISERVER_HOLD_FILE}" ]; do
+        # etcd may be stopped while kube-apiserver is held, e.g. to copy its data dir
+        if ! kill -0 "${ETCD_PID}" 2>/dev/null && [ ! -f "${ETCD_HOLD_FILE}" ]; then
+            echo "Restarting etcd..."
+            start_etcd
+        fi
+        sleep 0.1
+    done
+
+    echo "Restarting kube-apiserver..."
+    start_apiserver "$@"
+done
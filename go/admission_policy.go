@@ -0,0 +1,326 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// canaryLabel marks the dry-run objects used to probe admission policy enforcement
+	canaryLabel = "envtest.testcontainers.org/canary"
+
+	// policyReadyTimeout bounds how long to wait for an admission policy to be enforced
+	policyReadyTimeout = 30 * time.Second
+
+	// policyCleanupTimeout bounds how long the policy cleanup may take
+	policyCleanupTimeout = 30 * time.Second
+)
+
+// ApplyAdmissionPolicy creates (or updates) a ValidatingAdmissionPolicy and its binding and waits until
+// the API server enforces them. Policies are loaded asynchronously, so enforcement is confirmed with a
+// canary policy created afterwards: once the canary denies a dry-run probe, earlier policies are loaded too.
+// The returned cleanup func deletes the policy and the binding.
+func (c *EnvtestContainer) ApplyAdmissionPolicy(
+	ctx context.Context,
+	policyYAML, bindingYAML string,
+) (cleanup func() error, err error) {
+	policy := &admissionregistrationv1.ValidatingAdmissionPolicy{}
+	if err := yaml.UnmarshalStrict([]byte(policyYAML), policy); err != nil {
+		return nil, fmt.Errorf("failed to decode ValidatingAdmissionPolicy: %w", err)
+	}
+
+	binding := &admissionregistrationv1.ValidatingAdmissionPolicyBinding{}
+	if err := yaml.UnmarshalStrict([]byte(bindingYAML), binding); err != nil {
+		return nil, fmt.Errorf("failed to decode ValidatingAdmissionPolicyBinding: %w", err)
+	}
+
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup = func() error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), policyCleanupTimeout)
+		defer cancel()
+
+		return c.retry(ctx, "delete admission policy", func(ctx context.Context) error {
+			return deleteAll(ctx, cl, binding, policy)
+		})
+	}
+
+	err = c.retry(ctx, "apply ValidatingAdmissionPolicy", func(ctx context.Context) error {
+		return createOrUpdate(ctx, cl, policy)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply ValidatingAdmissionPolicy %s: %w", policy.Name, err)
+	}
+
+	err = c.retry(ctx, "apply ValidatingAdmissionPolicyBinding", func(ctx context.Context) error {
+		return createOrUpdate(ctx, cl, binding)
+	})
+	if err != nil {
+		return cleanup, fmt.Errorf(
+			"failed to apply ValidatingAdmissionPolicyBinding %s: %w",
+			binding.Name,
+			err,
+		)
+	}
+
+	if err := waitForPolicyObserved(ctx, cl, policy); err != nil {
+		return cleanup, err
+	}
+
+	if err := c.waitForPolicyEnforcement(ctx, cl); err != nil {
+		return cleanup, fmt.Errorf(
+			"ValidatingAdmissionPolicy %s is not enforced: %w",
+			policy.Name,
+			err,
+		)
+	}
+
+	return cleanup, nil
+}
+
+// AssertDeniedByPolicy asserts that creating the object (or updating it, when it has a resourceVersion)
+// is denied by the named ValidatingAdmissionPolicy with one of the policy's reasons and messages.
+// The request is sent as a dry run, so nothing is persisted. Type-checking warnings of the policy
+// are included in the failure message.
+func (c *EnvtestContainer) AssertDeniedByPolicy(
+	t testing.TB,
+	ctx context.Context,
+	obj client.Object,
+	policyName string,
+) {
+	t.Helper()
+
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	policy := &admissionregistrationv1.ValidatingAdmissionPolicy{}
+	if err := cl.Get(ctx, client.ObjectKey{Name: policyName}, policy); err != nil {
+		t.Fatalf("failed to get ValidatingAdmissionPolicy %s: %v", policyName, err)
+	}
+
+	probe, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		t.Fatalf("unexpected object type %T", obj)
+	}
+
+	if probe.GetResourceVersion() == "" {
+		err = cl.Create(ctx, probe, client.DryRunAll)
+	} else {
+		err = cl.Update(ctx, probe, client.DryRunAll)
+	}
+
+	if msg := checkPolicyDenial(err, policy); msg != "" {
+		t.Errorf("%s%s", msg, policyWarnings(policy))
+	}
+}
+
+// checkPolicyDenial describes why err is not a denial by the given policy, or returns "" if it is
+func checkPolicyDenial(
+	err error,
+	policy *admissionregistrationv1.ValidatingAdmissionPolicy,
+) string {
+	if err == nil {
+		return fmt.Sprintf(
+			"expected request to be denied by ValidatingAdmissionPolicy '%s', but it was admitted",
+			policy.Name,
+		)
+	}
+
+	if !strings.Contains(err.Error(), fmt.Sprintf("ValidatingAdmissionPolicy '%s'", policy.Name)) {
+		return fmt.Sprintf(
+			"expected request to be denied by ValidatingAdmissionPolicy '%s', got: %v",
+			policy.Name,
+			err,
+		)
+	}
+
+	reasons := make([]metav1.StatusReason, 0, len(policy.Spec.Validations))
+	messages := make([]string, 0, len(policy.Spec.Validations))
+
+	for _, validation := range policy.Spec.Validations {
+		reasons = append(reasons, ptr.Deref(validation.Reason, metav1.StatusReasonInvalid))
+
+		if validation.Message != "" {
+			messages = append(messages, validation.Message)
+		}
+	}
+
+	reason := apierrors.ReasonForError(err)
+	if !slices.Contains(reasons, reason) {
+		return fmt.Sprintf(
+			"expected denial reason to be one of %v, got %q: %v",
+			reasons,
+			reason,
+			err,
+		)
+	}
+
+	// Validations with a messageExpression or without a message cannot be matched verbatim
+	matched := slices.ContainsFunc(messages, func(message string) bool {
+		return strings.Contains(err.Error(), message)
+	})
+	if len(messages) > 0 && !matched {
+		return fmt.Sprintf("expected denial message to contain one of %q, got: %v", messages, err)
+	}
+
+	return ""
+}
+
+// policyWarnings formats the type-checking warnings of a policy for failure messages
+func policyWarnings(policy *admissionregistrationv1.ValidatingAdmissionPolicy) string {
+	if policy.Status.TypeChecking == nil ||
+		len(policy.Status.TypeChecking.ExpressionWarnings) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("\ntype-checking warnings:")
+
+	for _, warning := range policy.Status.TypeChecking.ExpressionWarnings {
+		fmt.Fprintf(&sb, "\n  %s: %s", warning.FieldRef, warning.Warning)
+	}
+
+	return sb.String()
+}
+
+// waitForPolicyObserved waits until the type-checking controller has processed the current policy generation
+func waitForPolicyObserved(
+	ctx context.Context,
+	cl client.Client,
+	policy *admissionregistrationv1.ValidatingAdmissionPolicy,
+) error {
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, policyReadyTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			if err := cl.Get(ctx, client.ObjectKeyFromObject(policy), policy); err != nil {
+				return false, err
+			}
+
+			return policy.Status.ObservedGeneration >= policy.Generation, nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"ValidatingAdmissionPolicy %s status was not observed: %w%s",
+			policy.Name,
+			err,
+			policyWarnings(policy),
+		)
+	}
+
+	return nil
+}
+
+// waitForPolicyEnforcement installs a canary policy that denies labeled ConfigMaps and probes it with
+// dry-run creates until it takes effect, then removes it
+func (c *EnvtestContainer) waitForPolicyEnforcement(ctx context.Context, cl client.Client) error {
+	name := "envtest-canary-" + utilrand.String(6)
+
+	canary := &admissionregistrationv1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: admissionregistrationv1.ValidatingAdmissionPolicySpec{
+			MatchConstraints: &admissionregistrationv1.MatchResources{
+				ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{{
+					RuleWithOperations: admissionregistrationv1.RuleWithOperations{
+						Operations: []admissionregistrationv1.OperationType{
+							admissionregistrationv1.Create,
+						},
+						Rule: admissionregistrationv1.Rule{
+							APIGroups:   []string{""},
+							APIVersions: []string{"v1"},
+							Resources:   []string{"configmaps"},
+						},
+					},
+				}},
+			},
+			Validations: []admissionregistrationv1.Validation{
+				{Expression: "false", Message: "canary"},
+			},
+		},
+	}
+
+	canaryBinding := &admissionregistrationv1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: admissionregistrationv1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: name,
+			ValidationActions: []admissionregistrationv1.ValidationAction{
+				admissionregistrationv1.Deny,
+			},
+			MatchResources: &admissionregistrationv1.MatchResources{
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{canaryLabel: name},
+				},
+			},
+		},
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), policyCleanupTimeout)
+		defer cancel()
+
+		_ = c.retry(ctx, "delete canary policy", func(ctx context.Context) error {
+			return deleteAll(ctx, cl, canaryBinding, canary)
+		})
+	}()
+
+	err := c.retry(ctx, "create canary policy", func(ctx context.Context) error {
+		return createOrUpdate(ctx, cl, canary)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create canary policy: %w", err)
+	}
+
+	err = c.retry(ctx, "create canary policy binding", func(ctx context.Context) error {
+		return createOrUpdate(ctx, cl, canaryBinding)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create canary policy binding: %w", err)
+	}
+
+	probe := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + "-",
+			Namespace:    metav1.NamespaceDefault,
+			Labels:       map[string]string{canaryLabel: name},
+		},
+	}
+
+	return wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, policyReadyTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			err := cl.Create(ctx, probe.DeepCopy(), client.DryRunAll)
+
+			// Any policy denial proves that policies created before the canary are loaded
+			return err != nil && strings.Contains(err.Error(), "ValidatingAdmissionPolicy"), nil
+		},
+	)
+}
+
+// deleteAll deletes the given objects in order, ignoring the ones that do not exist
+func deleteAll(ctx context.Context, cl client.Client, objs ...client.Object) error {
+	for _, obj := range objs {
+		if err := cl.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %s: %w", obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package envtest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// policyDenial builds the error the API server returns when a ValidatingAdmissionPolicy denies a request
+func policyDenial(reason metav1.StatusReason, message string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusUnprocessableEntity,
+		Reason:  reason,
+		Message: "configmaps \"app\" is forbidden: " + message,
+	}}
+}
+
+func TestCheckPolicyDenial(t *testing.T) {
+	policy := &admissionregistrationv1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "require-team"},
+		Spec: admissionregistrationv1.ValidatingAdmissionPolicySpec{
+			Validations: []admissionregistrationv1.Validation{
+				{Expression: "has(object.metadata.labels.team)", Message: "team label is required"},
+				{Expression: "object.metadata.name != 'forbidden'", Reason: ptr.To(metav1.StatusReasonForbidden)},
+			},
+		},
+	}
+
+	denied := "ValidatingAdmissionPolicy 'require-team' with binding 'require-team' denied request: "
+
+	tests := []struct {
+		name    string
+		err     error
+		wantMsg string
+	}{
+		{
+			name: "denied by policy",
+			err:  policyDenial(metav1.StatusReasonInvalid, denied+"team label is required"),
+		},
+		{
+			name: "denied with custom reason",
+			err:  policyDenial(metav1.StatusReasonForbidden, denied+"team label is required"),
+		},
+		{
+			name:    "admitted",
+			wantMsg: "but it was admitted",
+		},
+		{
+			name:    "denied by another policy",
+			err:     policyDenial(metav1.StatusReasonInvalid, "ValidatingAdmissionPolicy 'other' denied request"),
+			wantMsg: "expected request to be denied by ValidatingAdmissionPolicy 'require-team', got",
+		},
+		{
+			name:    "unexpected reason",
+			err:     policyDenial(metav1.StatusReasonBadRequest, denied+"team label is required"),
+			wantMsg: "expected denial reason",
+		},
+		{
+			name:    "unexpected message",
+			err:     policyDenial(metav1.StatusReasonInvalid, denied+"failed expression"),
+			wantMsg: "expected denial message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := checkPolicyDenial(tt.err, policy)
+
+			if tt.wantMsg == "" {
+				require.Empty(t, msg)
+
+				return
+			}
+
+			require.Contains(t, msg, tt.wantMsg)
+		})
+	}
+}
+
+func TestPolicyWarnings(t *testing.T) {
+	policy := &admissionregistrationv1.ValidatingAdmissionPolicy{}
+	require.Empty(t, policyWarnings(policy))
+
+	policy.Status.TypeChecking = &admissionregistrationv1.TypeChecking{
+		ExpressionWarnings: []admissionregistrationv1.ExpressionWarning{
+			{FieldRef: "spec.validations[0].expression", Warning: "undefined field 'tean'"},
+		},
+	}
+
+	require.Equal(t, "\ntype-checking warnings:\n  spec.validations[0].expression: undefined field 'tean'", policyWarnings(policy))
+}
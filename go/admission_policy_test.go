@@ -0,0 +1,99 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const requireTeamPolicy = `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: require-team
+spec:
+  failurePolicy: Fail
+  matchConstraints:
+    resourceRules:
+    - apiGroups: [""]
+      apiVersions: ["v1"]
+      operations: ["CREATE", "UPDATE"]
+      resources: ["configmaps"]
+  validations:
+  - expression: "has(object.metadata.labels) && 'team' in object.metadata.labels"
+    message: "team label is required"
+    reason: Forbidden
+`
+
+const requireTeamBinding = `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: require-team
+spec:
+  policyName: require-team
+  validationActions: [Deny]
+  matchResources:
+    namespaceSelector:
+      matchLabels:
+        kubernetes.io/metadata.name: default
+`
+
+func TestEnvtestContainerAdmissionPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	cleanup, err := c.ApplyAdmissionPolicy(ctx, requireTeamPolicy, requireTeamBinding)
+	require.NoError(t, err)
+
+	unlabeled := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"},
+	}
+
+	// The policy is enforced as soon as ApplyAdmissionPolicy returns, without waiting for it to propagate
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, unlabeled, metav1.CreateOptions{})
+	require.True(t, apierrors.IsForbidden(err), "expected Forbidden, got %v", err)
+	require.ErrorContains(t, err, "team label is required")
+
+	c.AssertDeniedByPolicy(t, ctx, unlabeled, "require-team")
+
+	labeled := unlabeled.DeepCopy()
+	labeled.Name = "labeled"
+	labeled.Labels = map[string]string{"team": "platform"}
+
+	labeled, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, labeled, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Dropping the label on update is denied as well
+	labeled.Labels = nil
+	c.AssertDeniedByPolicy(t, ctx, labeled, "require-team")
+
+	require.NoError(t, cleanup())
+
+	require.Eventually(t, func() bool {
+		_, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, unlabeled, metav1.CreateOptions{})
+
+		return err == nil
+	}, 30*time.Second, 100*time.Millisecond, "policy was not removed")
+}
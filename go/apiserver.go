@@ -0,0 +1,158 @@
+package envtest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/roma-glushko/testcontainers-envtest/go/waitk8s"
+	"github.com/testcontainers/testcontainers-go"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultReadyTimeout is how long WaitForReady waits when no timeout is given
+	DefaultReadyTimeout = 60 * time.Second
+
+	// apiServerPIDPath is where the entrypoint records the PID of the running kube-apiserver
+	apiServerPIDPath = "/tmp/envtest/apiserver.pid"
+
+	// apiServerHoldPath keeps the entrypoint from restarting an exited kube-apiserver while it exists
+	apiServerHoldPath = "/tmp/envtest/apiserver.hold"
+)
+
+// apiServerErrorLine matches the klog error lines and the final error kube-apiserver prints when it fails to start
+var apiServerErrorLine = regexp.MustCompile(`^(E\d{4} |Error: )`)
+
+// apiServerStartupErrors returns the errors kube-apiserver logged in a container that did not become ready,
+// so they can be surfaced instead of a bare readiness timeout
+func apiServerStartupErrors(ctx context.Context, container testcontainers.Container) []string {
+	logs, err := container.Logs(ctx)
+	if err != nil {
+		return nil
+	}
+
+	defer func() { _ = logs.Close() }()
+
+	return parseStartupErrors(logs)
+}
+
+// parseStartupErrors returns the distinct error lines of the container logs in order
+func parseStartupErrors(logs io.Reader) []string {
+	var errs []string
+
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if apiServerErrorLine.MatchString(line) && !slices.Contains(errs, line) {
+			errs = append(errs, line)
+		}
+	}
+
+	return errs
+}
+
+// ShutdownAPIServer stops the kube-apiserver process while etcd keeps running, until RestartAPIServer is called.
+// A graceful shutdown sends SIGTERM and returns right away, so the shutdown sequence can be observed:
+// /readyz fails during the WithShutdownDelay window while /livez and in-flight requests (e.g. watches)
+// are still served, then the server drains and exits. Otherwise, the process is killed with SIGKILL.
+func (c *EnvtestContainer) ShutdownAPIServer(ctx context.Context, graceful bool) error {
+	signal := "KILL"
+	if graceful {
+		signal = "TERM"
+	}
+
+	script := fmt.Sprintf(
+		`touch %s && kill -%s "$(cat %s)"`,
+		apiServerHoldPath,
+		signal,
+		apiServerPIDPath,
+	)
+
+	if _, err := c.execOutput(ctx, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to shut down kube-apiserver: %w", err)
+	}
+
+	return nil
+}
+
+// RestartAPIServer restarts the kube-apiserver process, gracefully stopping it first if it is running,
+// and waits until the new process is ready. etcd data and the mapped port are kept,
+// so existing clients reconnect to the restarted server.
+func (c *EnvtestContainer) RestartAPIServer(ctx context.Context) error {
+	oldPID, err := c.apiServerPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf(
+		`rm -f %s && (kill -TERM %s 2>/dev/null || true)`,
+		apiServerHoldPath,
+		oldPID,
+	)
+
+	if _, err := c.execOutput(ctx, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to restart kube-apiserver: %w", err)
+	}
+
+	return c.waitForAPIServerRestart(ctx, oldPID)
+}
+
+// waitForAPIServerRestart waits until the entrypoint started a new kube-apiserver process, within DefaultReadyTimeout,
+// and it is ready
+func (c *EnvtestContainer) waitForAPIServerRestart(ctx context.Context, oldPID string) error {
+	var newPID string
+
+	// The entrypoint may never restart it, e.g. while Snapshot or Restore holds etcd
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, DefaultReadyTimeout, false,
+		func(ctx context.Context) (bool, error) {
+			pid, err := c.apiServerPID(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			newPID = pid
+
+			return pid != oldPID, nil
+		})
+	if err != nil {
+		return fmt.Errorf("kube-apiserver was not restarted: %w", err)
+	}
+
+	if err := c.WaitForReady(ctx, DefaultReadyTimeout); err != nil {
+		return err
+	}
+
+	c.events.emit(EventAPIServerRestarted, "pid "+newPID)
+
+	return nil
+}
+
+// WaitForReady waits until the API server answers /readyz with 200, e.g. after RestartAPIServer or Unpause.
+// The error includes the output of the failing readiness checks.
+func (c *EnvtestContainer) WaitForReady(ctx context.Context, timeout time.Duration) error {
+	strategy := waitk8s.ForAPIServer(DefaultAPIServerPort+"/tcp",
+		waitk8s.WithKubeconfigFromContainer(KubeconfigPath),
+		waitk8s.WithStartupTimeout(timeout),
+		waitk8s.WithVerboseOnFailure(),
+	)
+
+	return strategy.WaitUntilReady(ctx, c)
+}
+
+// apiServerPID returns the PID of the last started kube-apiserver process
+func (c *EnvtestContainer) apiServerPID(ctx context.Context) (string, error) {
+	output, err := c.execOutput(ctx, "cat", apiServerPIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read kube-apiserver PID: %w", err)
+	}
+
+	return strings.TrimSpace(output), nil
+}
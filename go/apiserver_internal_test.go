@@ -0,0 +1,26 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStartupErrors(t *testing.T) {
+	logs := `Starting kube-apiserver on port 6443...
+I1014 10:00:00.000000      42 options.go:228] external host was not specified, using 172.17.0.2
+E1014 10:00:00.100000      42 run.go:72] "command failed" err="error while parsing file: secret is not of the expected length"
+Error: error while parsing file: secret is not of the expected length
+E1014 10:00:00.100000      42 run.go:72] "command failed" err="error while parsing file: secret is not of the expected length"
+Waiting for kube-apiserver to be ready...
+ERROR: kube-apiserver failed to start
+`
+
+	require.Equal(t, []string{
+		`E1014 10:00:00.100000      42 run.go:72] "command failed" err="error while parsing file: secret is not of the expected length"`,
+		"Error: error while parsing file: secret is not of the expected length",
+	}, parseStartupErrors(strings.NewReader(logs)))
+
+	require.Empty(t, parseStartupErrors(strings.NewReader("Envtest is ready!\n")))
+}
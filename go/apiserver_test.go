@@ -0,0 +1,458 @@
+package envtest_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestEnvtestContainerGracefulAPIServerShutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	opts := append(getEnvtestOptions(), envtest.WithShutdownDelay(10*time.Second))
+
+	c, err := envtest.Run(ctx, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	healthy := func(path string) bool {
+		_, err := clientset.Discovery().RESTClient().Get().AbsPath(path).DoRaw(ctx)
+
+		return err == nil
+	}
+
+	watcher, err := clientset.CoreV1().ConfigMaps("default").Watch(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+
+	defer watcher.Stop()
+
+	require.NoError(t, c.ShutdownAPIServer(ctx, true))
+
+	// /readyz flips first while /livez and the in-flight watch are still served
+	require.Eventually(t, func() bool {
+		return !healthy("/readyz")
+	}, 5*time.Second, 50*time.Millisecond, "readyz did not fail during the shutdown delay")
+
+	require.True(t, healthy("/livez"), "livez failed before the shutdown delay elapsed")
+
+	select {
+	case _, ok := <-watcher.ResultChan():
+		require.True(t, ok, "watch was closed during the shutdown delay")
+	default:
+	}
+
+	// Once the delay elapsed, the server drains and exits
+	require.Eventually(t, func() bool {
+		return !healthy("/livez")
+	}, 30*time.Second, 100*time.Millisecond, "livez did not fail after the shutdown delay")
+
+	// The API server stays down until it is restarted
+	time.Sleep(time.Second)
+	require.False(t, healthy("/readyz"))
+
+	require.NoError(t, c.RestartAPIServer(ctx))
+
+	_, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+}
+
+func TestEnvtestContainerKillAPIServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, c.ShutdownAPIServer(ctx, false))
+
+	require.Eventually(t, func() bool {
+		_, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+
+		return err != nil
+	}, 10*time.Second, 50*time.Millisecond, "API server was not killed")
+
+	require.Error(t, c.WaitForReady(ctx, time.Second))
+
+	require.NoError(t, c.RestartAPIServer(ctx))
+	require.NoError(t, c.WaitForReady(ctx, envtest.DefaultReadyTimeout))
+
+	_, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+}
+
+func TestEnvtestContainerRestartAPIServerInformer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace("default"))
+	configMaps := factory.Core().V1().ConfigMaps().Lister()
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		require.True(t, synced, "%v informer did not sync", typ)
+	}
+
+	apiServerURL, err := c.APIServerURL(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, c.RestartAPIServer(ctx))
+
+	// The port is kept, so the rest.Config from before the restart is still valid
+	restartedURL, err := c.APIServerURL(ctx)
+	require.NoError(t, err)
+	require.Equal(t, apiServerURL, restartedURL)
+
+	// The informer rewatches the restarted server and sees objects created after the restart
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "after-restart", Namespace: "default"}}
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := configMaps.ConfigMaps("default").Get("after-restart")
+
+		return err == nil
+	}, 30*time.Second, 100*time.Millisecond, "the informer did not recover after the restart")
+}
+
+func TestEnvtestContainerWithAPIServerFlags(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	opts := append(getEnvtestOptions(),
+		envtest.WithAPIServerFlags(map[string]string{"max-requests-inflight": "123"}),
+		envtest.WithAPIServerArg("--v", "2"),
+	)
+
+	c, err := envtest.Run(ctx, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	// The flags reach kube-apiserver, after the defaults of the container
+	exitCode, reader, err := c.Exec(ctx, []string{"sh", "-c", `tr '\0' ' ' < /proc/$(cat /tmp/envtest/apiserver.pid)/cmdline`},
+		tcexec.Multiplexed())
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+
+	cmdline, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Contains(t, string(cmdline), "--max-requests-inflight=123")
+
+	// and are applied, as read back from the flags endpoint
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	verbosity, err := clientset.Discovery().RESTClient().Get().AbsPath("/debug/flags/v").DoRaw(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "2", strings.TrimSpace(string(verbosity)))
+
+	_, err = envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithAPIServerArg("max-requests-inflight", "123"),
+		envtest.WithAPIServerArg("max-requests-inflight", "456"),
+	)...)
+	require.ErrorContains(t, err, "conflicting values for kube-apiserver flag --max-requests-inflight")
+}
+
+func TestEnvtestContainerWithFeatureGates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// Beta APIs are off by default, and MutatingAdmissionPolicy is served only with its gate
+	opts := append(getEnvtestOptions(),
+		envtest.WithFeatureGates(map[string]bool{"MutatingAdmissionPolicy": true}),
+		envtest.WithRuntimeConfig(map[string]bool{"admissionregistration.k8s.io/v1beta1": true}),
+	)
+
+	c, err := envtest.Run(ctx, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	resources, err := clientset.Discovery().ServerResourcesForGroupVersion("admissionregistration.k8s.io/v1beta1")
+	require.NoError(t, err)
+
+	var names []string
+	for _, resource := range resources.APIResources {
+		names = append(names, resource.Name)
+	}
+
+	require.Contains(t, names, "mutatingadmissionpolicies")
+}
+
+func TestEnvtestContainerWithRuntimeConfigDisabledGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithRuntimeConfig(map[string]bool{"batch/v1": false}))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	groups, err := clientset.Discovery().ServerGroups()
+	require.NoError(t, err)
+
+	for _, group := range groups.Groups {
+		require.NotEqual(t, "batch", group.Name, "batch/v1 is still discoverable")
+	}
+
+	_, err = clientset.BatchV1().Jobs("default").List(ctx, metav1.ListOptions{})
+	require.True(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+
+	// The core APIs keep working
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "still-served"}}
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+// podWithoutServiceAccount is a pod in a fresh namespace, which has no default ServiceAccount in envtest
+func podWithoutServiceAccount(t *testing.T, ctx context.Context, clientset *kubernetes.Clientset) error {
+	t.Helper()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "admission-"}}
+	ns, err := clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-service-account"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "busybox"}}},
+	}
+	_, err = clientset.CoreV1().Pods(ns.Name).Create(ctx, pod, metav1.CreateOptions{})
+
+	return err
+}
+
+func TestEnvtestContainerWithDisableAdmissionPlugins(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithDisableAdmissionPlugins("ServiceAccount", "DefaultStorageClass"),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, podWithoutServiceAccount(t, ctx, clientset))
+}
+
+func TestEnvtestContainerWithEnableAdmissionPlugins(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithEnableAdmissionPlugins("ServiceAccount"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// Without a controller creating it, the default ServiceAccount the plugin requires does not exist
+	require.ErrorContains(t, podWithoutServiceAccount(t, ctx, clientset), `serviceaccount "default" not found`)
+}
+
+func TestEnvtestContainerWithConflictingAdmissionPlugins(t *testing.T) {
+	_, err := envtest.Run(t.Context(), append(getEnvtestOptions(),
+		envtest.WithEnableAdmissionPlugins("PodSecurity"),
+		envtest.WithDisableAdmissionPlugins("PodSecurity"),
+	)...)
+	require.EqualError(t, err, "admission plugin PodSecurity is both enabled and disabled")
+}
+
+func TestEnvtestContainerWithMaxRequestsInflight(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// The admin user is exempt from the limit, so requests are made with a token instead
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithMaxRequestsInflight(1),
+		// Rejects excess requests instead of queueing them
+		envtest.WithAPIPriorityAndFairness(false),
+		envtest.WithTokenAuth([]envtest.TokenUser{{Token: "load-token", Username: "load"}}),
+		envtest.WithAuthorizationModes("AlwaysAllow"),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	kubeconfig, err := c.KubeconfigForToken(ctx, "load-token")
+	require.NoError(t, err)
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	require.NoError(t, err)
+
+	cfg.QPS, cfg.Burst = 1000, 1000
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		var (
+			wg       sync.WaitGroup
+			rejected atomic.Int32
+		)
+
+		for range 50 {
+			wg.Go(func() {
+				// client-go retries 429 responses by default
+				err := clientset.CoreV1().RESTClient().Get().Namespace("default").Resource("configmaps").MaxRetries(0).Do(ctx).Error()
+				if apierrors.IsTooManyRequests(err) {
+					rejected.Add(1)
+				}
+			})
+		}
+
+		wg.Wait()
+
+		return rejected.Load() > 0
+	}, 30*time.Second, 100*time.Millisecond, "no concurrent list was rejected with 429")
+}
+
+func TestEnvtestContainerWithWatchCacheDisabled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithWatchCache(false))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	// The flag reaches kube-apiserver instead of being dropped by the entrypoint
+	exitCode, reader, err := c.Exec(ctx, []string{"sh", "-c", `tr '\0' ' ' < /proc/$(cat /tmp/envtest/apiserver.pid)/cmdline`},
+		tcexec.Multiplexed())
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+
+	cmdline, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Contains(t, string(cmdline), "--watch-cache=false")
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// Informers list and watch from etcd
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace("default"))
+	configMaps := factory.Core().V1().ConfigMaps().Lister()
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		require.True(t, synced, "%v informer did not sync", typ)
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "uncached", Namespace: "default"}, Data: map[string]string{"v": "1"}}
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cm.Data["v"] = "2"
+	_, err = clientset.CoreV1().ConfigMaps("default").Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		got, err := configMaps.ConfigMaps("default").Get("uncached")
+
+		return err == nil && got.Data["v"] == "2"
+	}, 10*time.Second, 100*time.Millisecond, "the informer did not observe the update")
+}
@@ -0,0 +1,235 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultFieldManager is the field manager Apply uses unless WithFieldManager is given
+const DefaultFieldManager = "testcontainers-envtest"
+
+var (
+	// namespaceGroupKind and crdGroupKind are applied before the other objects of a manifest
+	namespaceGroupKind = schema.GroupKind{Kind: "Namespace"}
+	crdGroupKind       = schema.GroupKind{
+		Group: apiextensionsv1.GroupName,
+		Kind:  "CustomResourceDefinition",
+	}
+)
+
+// applyConfig holds the configuration for Apply
+type applyConfig struct {
+	fieldManager   string
+	forceConflicts bool
+	namespace      string
+}
+
+// ApplyOption is a functional option for configuring Apply
+type ApplyOption func(*applyConfig)
+
+// WithFieldManager sets the field manager owning the applied fields (default: DefaultFieldManager)
+func WithFieldManager(name string) ApplyOption {
+	return func(c *applyConfig) {
+		c.fieldManager = name
+	}
+}
+
+// WithForceConflicts takes over fields owned by other field managers instead of failing with a conflict
+func WithForceConflicts() ApplyOption {
+	return func(c *applyConfig) {
+		c.forceConflicts = true
+	}
+}
+
+// WithApplyNamespace sets the namespace of namespaced objects that do not specify one (default: "default")
+func WithApplyNamespace(namespace string) ApplyOption {
+	return func(c *applyConfig) {
+		c.namespace = namespace
+	}
+}
+
+// manifestDocument is an object of a multi-document manifest together with where it comes from
+type manifestDocument struct {
+	source string
+	index  int
+	obj    *unstructured.Unstructured
+}
+
+// String identifies the document in errors
+func (d manifestDocument) String() string {
+	return fmt.Sprintf("%s document %d", d.source, d.index)
+}
+
+// Apply server-side applies the objects of a multi-document YAML or JSON manifest.
+// Namespaces and CustomResourceDefinitions are applied first, and the CRDs are waited for to be established,
+// so custom resources can be applied together with their CRDs; the other objects follow in document order,
+// RBAC objects first.
+func (c *EnvtestContainer) Apply(ctx context.Context, manifests []byte, opts ...ApplyOption) error {
+	cfg := newApplyConfig(opts...)
+
+	docs, err := decodeManifest("manifest", manifests)
+	if err != nil {
+		return err
+	}
+
+	return c.applyManifests(ctx, docs, cfg)
+}
+
+// newApplyConfig returns the Apply configuration with the given options
+func newApplyConfig(opts ...ApplyOption) *applyConfig {
+	cfg := &applyConfig{fieldManager: DefaultFieldManager, namespace: metav1.NamespaceDefault}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// applyPriority returns when to apply objects of the given kind, lower first:
+// Namespaces and CRDs, which the other objects may depend on, then RBAC, then everything else
+func applyPriority(gk schema.GroupKind) int {
+	switch gk {
+	case namespaceGroupKind:
+		return 0
+	case crdGroupKind:
+		return 1
+	}
+
+	if gk.Group == "" && gk.Kind == "ServiceAccount" || gk.Group == rbacv1.GroupName {
+		return 2
+	}
+
+	return 3
+}
+
+// sortByApplyPriority returns the documents ordered by applyPriority, keeping the order of documents of equal priority
+func sortByApplyPriority(docs []manifestDocument) []manifestDocument {
+	docs = slices.Clone(docs)
+	slices.SortStableFunc(docs, func(a, b manifestDocument) int {
+		aGK, bGK := a.obj.GroupVersionKind().GroupKind(), b.obj.GroupVersionKind().GroupKind()
+
+		return applyPriority(aGK) - applyPriority(bGK)
+	})
+
+	return docs
+}
+
+// applyManifests applies the documents by kind priority, waiting for the CRDs to be established before
+// applying the objects that may be custom resources
+func (c *EnvtestContainer) applyManifests(
+	ctx context.Context,
+	docs []manifestDocument,
+	cfg *applyConfig,
+) error {
+	docs = sortByApplyPriority(docs)
+
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	var crdNames []string
+
+	// The documents up to the last CRD
+	split := 0
+
+	for i, doc := range docs {
+		if doc.obj.GroupVersionKind().GroupKind() == crdGroupKind {
+			crdNames = append(crdNames, doc.obj.GetName())
+			split = i + 1
+		}
+	}
+
+	if err := c.applyDocuments(ctx, cl, docs[:split], cfg); err != nil {
+		return err
+	}
+
+	if len(crdNames) > 0 {
+		crdClient, err := c.apiExtensionsClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := waitForCRDs(ctx, crdClient, crdNames, crdEstablishTimeout); err != nil {
+			return err
+		}
+	}
+
+	return c.applyDocuments(ctx, cl, docs[split:], cfg)
+}
+
+// applyDocuments server-side applies the documents in order
+func (c *EnvtestContainer) applyDocuments(
+	ctx context.Context,
+	cl client.Client,
+	docs []manifestDocument,
+	cfg *applyConfig,
+) error {
+	applyOpts := []client.ApplyOption{client.FieldOwner(cfg.fieldManager)}
+	if cfg.forceConflicts {
+		applyOpts = append(applyOpts, client.ForceOwnership)
+	}
+
+	for _, doc := range docs {
+		namespaced, err := cl.IsObjectNamespaced(doc.obj)
+		if err != nil {
+			return fmt.Errorf("%s is a %s of %s unknown to the API server: %w",
+				doc, doc.obj.GetKind(), doc.obj.GetAPIVersion(), err)
+		}
+
+		if namespaced && doc.obj.GetNamespace() == "" {
+			doc.obj.SetNamespace(cfg.namespace)
+		}
+
+		err = c.retry(ctx, "apply manifest", func(ctx context.Context) error {
+			return cl.Apply(ctx, client.ApplyConfigurationFromUnstructured(doc.obj), applyOpts...)
+		})
+		if err != nil {
+			return fmt.Errorf(
+				"failed to apply %s (%s %s): %w",
+				doc,
+				doc.obj.GetKind(),
+				doc.obj.GetName(),
+				err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// decodeManifest decodes the objects of a multi-document manifest read from source, skipping empty documents
+func decodeManifest(source string, manifest []byte) ([]manifestDocument, error) {
+	var docs []manifestDocument
+
+	err := decodeDocuments(
+		source,
+		bytes.NewReader(manifest),
+		func(i int, obj *unstructured.Unstructured) error {
+			doc := manifestDocument{source: source, index: i, obj: obj}
+
+			if obj.GetKind() == "" || obj.GetAPIVersion() == "" || obj.GetName() == "" {
+				return fmt.Errorf("%s has no apiVersion, kind or name", doc)
+			}
+
+			docs = append(docs, doc)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
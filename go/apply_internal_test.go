@@ -0,0 +1,79 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeManifest(t *testing.T) {
+	docs, err := decodeManifest("manifest", []byte(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apps
+---
+# Only a comment
+---
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "settings", "namespace": "apps"}}
+---
+`))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	require.Equal(t, 0, docs[0].index)
+	require.Equal(t, namespaceGroupKind, docs[0].obj.GroupVersionKind().GroupKind())
+	require.Equal(t, 2, docs[1].index)
+	require.Equal(t, "settings", docs[1].obj.GetName())
+
+	_, err = decodeManifest("manifest", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\nkind: ConfigMap\n"))
+	require.EqualError(t, err, "manifest document 1 has no apiVersion, kind or name")
+
+	_, err = decodeManifest("manifest", []byte("apiVersion: v1\nkind: [ConfigMap\n"))
+	require.ErrorContains(t, err, "failed to decode manifest document 0")
+}
+
+func TestApplyOptions(t *testing.T) {
+	cfg := &applyConfig{}
+
+	WithFieldManager("my-controller")(cfg)
+	WithForceConflicts()(cfg)
+	WithApplyNamespace("apps")(cfg)
+
+	require.Equal(t, &applyConfig{fieldManager: "my-controller", forceConflicts: true, namespace: "apps"}, cfg)
+}
+
+func TestSortByApplyPriority(t *testing.T) {
+	docs, err := decodeManifest("manifest", []byte(`
+apiVersion: crds.example.com/v1
+kind: Widget
+metadata: {name: blue}
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata: {name: reader}
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata: {name: widgets.crds.example.com}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata: {name: app}
+---
+apiVersion: v1
+kind: Namespace
+metadata: {name: apps}
+`))
+	require.NoError(t, err)
+
+	var names []string
+	for _, doc := range sortByApplyPriority(docs) {
+		names = append(names, doc.obj.GetName())
+	}
+
+	require.Equal(t, []string{"apps", "widgets.crds.example.com", "reader", "app", "blue", "settings"}, names)
+}
@@ -0,0 +1,138 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyManifest lists a custom resource before its CRD and a ConfigMap before its namespace
+const applyManifest = `apiVersion: apply.example.com/v1
+kind: Gizmo
+metadata:
+  name: first
+  namespace: apply-test
+spec:
+  size: 3
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: apply-test
+data:
+  mode: fast
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaulted
+data:
+  mode: slow
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gizmos.apply.example.com
+spec:
+  group: apply.example.com
+  names:
+    kind: Gizmo
+    listKind: GizmoList
+    plural: gizmos
+    singular: gizmo
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apply-test
+`
+
+func TestEnvtestContainerApply(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, c.Apply(ctx, []byte(applyManifest)))
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "apply-test", Name: "settings"}, cm))
+	require.Equal(t, "fast", cm.Data["mode"])
+	require.Equal(t, envtest.DefaultFieldManager, cm.ManagedFields[0].Manager)
+
+	// Namespaced objects without a namespace go to the default one
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "defaulted"}, cm))
+
+	gizmo := &unstructured.Unstructured{}
+	gizmo.SetAPIVersion("apply.example.com/v1")
+	gizmo.SetKind("Gizmo")
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "apply-test", Name: "first"}, gizmo))
+
+	size, _, err := unstructured.NestedInt64(gizmo.Object, "spec", "size")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), size)
+
+	// Applying is idempotent
+	require.NoError(t, c.Apply(ctx, []byte(applyManifest)))
+
+	// Fields owned by another manager conflict unless forced
+	change := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: apply-test
+data:
+  mode: safe
+`)
+
+	err = c.Apply(ctx, change, envtest.WithFieldManager("other"))
+	require.Error(t, err)
+	require.True(t, apierrors.IsConflict(err), "expected a conflict, got %v", err)
+
+	require.NoError(t, c.Apply(ctx, change, envtest.WithFieldManager("other"), envtest.WithForceConflicts()))
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "apply-test", Name: "settings"}, cm))
+	require.Equal(t, "safe", cm.Data["mode"])
+
+	err = c.Apply(ctx, []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: unknown-kinds
+---
+apiVersion: unknown.example.com/v1
+kind: Doohickey
+metadata:
+  name: first
+`))
+	require.ErrorContains(t, err, "manifest document 1 is a Doohickey of unknown.example.com/v1 unknown to the API server")
+}
@@ -0,0 +1,145 @@
+package envtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+const (
+	// AuditPolicyPath is the path to the audit policy inside the container
+	AuditPolicyPath = "/etc/envtest/audit-policy.yaml"
+
+	// AuditLogPath is the path to the JSON-lines audit log inside the container
+	AuditLogPath = "/tmp/envtest/audit.log"
+)
+
+// auditFilter selects the audit events returned by GetAuditEvents
+type auditFilter struct {
+	verbs     []string
+	users     []string
+	resources []string
+}
+
+// AuditEventOption is a functional option for filtering the events returned by GetAuditEvents
+type AuditEventOption func(*auditFilter)
+
+// WithAuditVerbs keeps the events of requests with one of the given verbs, e.g. "create" or "watch"
+func WithAuditVerbs(verbs ...string) AuditEventOption {
+	return func(f *auditFilter) {
+		f.verbs = append(f.verbs, verbs...)
+	}
+}
+
+// WithAuditUsers keeps the events of requests made or impersonated by one of the given users
+func WithAuditUsers(names ...string) AuditEventOption {
+	return func(f *auditFilter) {
+		f.users = append(f.users, names...)
+	}
+}
+
+// WithAuditResources keeps the events of requests for one of the given resources, e.g. "configmaps"
+func WithAuditResources(resources ...string) AuditEventOption {
+	return func(f *auditFilter) {
+		f.resources = append(f.resources, resources...)
+	}
+}
+
+// matches reports whether the event passes every filter that is set
+func (f *auditFilter) matches(event *auditv1.Event) bool {
+	if len(f.verbs) > 0 && !slices.Contains(f.verbs, event.Verb) {
+		return false
+	}
+
+	impersonated := event.ImpersonatedUser != nil &&
+		slices.Contains(f.users, event.ImpersonatedUser.Username)
+	if len(f.users) > 0 && !slices.Contains(f.users, event.User.Username) && !impersonated {
+		return false
+	}
+
+	if len(f.resources) > 0 &&
+		(event.ObjectRef == nil || !slices.Contains(f.resources, event.ObjectRef.Resource)) {
+		return false
+	}
+
+	return true
+}
+
+// GetAuditEvents returns the events recorded in the API server audit log, optionally filtered.
+// Audit logging has to be enabled with WithAuditPolicy.
+func (c *EnvtestContainer) GetAuditEvents(
+	ctx context.Context,
+	opts ...AuditEventOption,
+) ([]auditv1.Event, error) {
+	filter := &auditFilter{}
+
+	for _, opt := range opts {
+		opt(filter)
+	}
+
+	reader, err := c.CopyFileFromContainer(ctx, AuditLogPath)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to copy audit log from container (is WithAuditPolicy set?): %w",
+			err,
+		)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	events, err := parseAuditEvents(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audit log: %w", err)
+	}
+
+	return slices.DeleteFunc(
+		events,
+		func(event auditv1.Event) bool { return !filter.matches(&event) },
+	), nil
+}
+
+// parseAuditEvents decodes a JSON-lines audit log. The last line may be partial
+// when the log is copied while the API server writes to it, and is skipped then.
+func parseAuditEvents(r io.Reader) ([]auditv1.Event, error) {
+	var (
+		events []auditv1.Event
+		// partial is the decoding error of the previous line, only tolerated if it is the last one
+		partial error
+	)
+
+	scanner := bufio.NewScanner(r)
+	// Request and response bodies can make single events large
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if partial != nil {
+			return nil, partial
+		}
+
+		var event auditv1.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			partial = err
+
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
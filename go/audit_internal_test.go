@@ -0,0 +1,92 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+func TestParseAuditEvents(t *testing.T) {
+	log := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a1","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/default/configmaps","verb":"create","user":{"username":"admin","groups":["system:masters"]},"objectRef":{"resource":"configmaps","namespace":"default","name":"app","apiVersion":"v1"},"responseStatus":{"code":201}}
+
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a2","stage":"ResponseComplete","requestURI":"/api/v1/namespaces","verb":"list","user":{"username":"test:TestFoo"}}
+`
+
+	events, err := parseAuditEvents(strings.NewReader(log))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	require.Equal(t, "create", events[0].Verb)
+	require.Equal(t, auditv1.StageResponseComplete, events[0].Stage)
+	require.Equal(t, "admin", events[0].User.Username)
+	require.Equal(t, "configmaps", events[0].ObjectRef.Resource)
+	require.Equal(t, "app", events[0].ObjectRef.Name)
+	require.Equal(t, "test:TestFoo", events[1].User.Username)
+}
+
+func TestParseAuditEventsPartialLastLine(t *testing.T) {
+	complete := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","auditID":"a1","stage":"ResponseComplete","verb":"get"}`
+
+	// The API server was still writing the last event when the log was copied
+	events, err := parseAuditEvents(strings.NewReader(complete + "\n" + `{"kind":"Event","apiVersion":"au`))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "get", events[0].Verb)
+
+	// Malformed lines before the last one are errors
+	_, err = parseAuditEvents(strings.NewReader(`{"kind":` + "\n" + complete + "\n"))
+	require.Error(t, err)
+}
+
+func TestAuditFilter(t *testing.T) {
+	create := auditv1.Event{
+		Verb:      "create",
+		User:      authenticationv1.UserInfo{Username: "admin"},
+		ObjectRef: &auditv1.ObjectReference{Resource: "configmaps"},
+	}
+	impersonated := auditv1.Event{
+		Verb:             "list",
+		User:             authenticationv1.UserInfo{Username: "admin"},
+		ImpersonatedUser: &authenticationv1.UserInfo{Username: "test:TestFoo"},
+		ObjectRef:        &auditv1.ObjectReference{Resource: "secrets"},
+	}
+	nonResource := auditv1.Event{Verb: "get", User: authenticationv1.UserInfo{Username: "system:anonymous"}}
+
+	tests := []struct {
+		name string
+		opts []AuditEventOption
+		want []auditv1.Event
+	}{
+		{name: "no filter", want: []auditv1.Event{create, impersonated, nonResource}},
+		{name: "verbs", opts: []AuditEventOption{WithAuditVerbs("create", "get")}, want: []auditv1.Event{create, nonResource}},
+		{name: "impersonated user", opts: []AuditEventOption{WithAuditUsers("test:TestFoo")}, want: []auditv1.Event{impersonated}},
+		{name: "resources", opts: []AuditEventOption{WithAuditResources("configmaps", "secrets")}, want: []auditv1.Event{create, impersonated}},
+		{
+			name: "combined",
+			opts: []AuditEventOption{WithAuditUsers("admin"), WithAuditResources("secrets")},
+			want: []auditv1.Event{impersonated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &auditFilter{}
+			for _, opt := range tt.opts {
+				opt(filter)
+			}
+
+			var got []auditv1.Event
+
+			for _, event := range []auditv1.Event{create, impersonated, nonResource} {
+				if filter.matches(&event) {
+					got = append(got, event)
+				}
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnvtestContainerGetAuditEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithAuditPolicy([]byte(metadataAuditPolicy)))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "audited", Namespace: "default"}}
+	require.NoError(t, cl.Create(ctx, cm))
+
+	// Events are written once the response is complete, possibly after the client got it
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		events, err := c.GetAuditEvents(ctx, envtest.WithAuditVerbs("create"), envtest.WithAuditResources("configmaps"))
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+
+		for _, event := range events {
+			require.Equal(t, "create", event.Verb)
+			require.Equal(t, "configmaps", event.ObjectRef.Resource)
+		}
+
+		require.Equal(t, "audited", events[len(events)-1].ObjectRef.Name)
+		require.Equal(t, "default", events[len(events)-1].ObjectRef.Namespace)
+	}, 10*time.Second, 200*time.Millisecond)
+
+	// Requests of other users are filtered out
+	events, err := c.GetAuditEvents(ctx, envtest.WithAuditUsers("system:nobody"))
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
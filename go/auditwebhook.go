@@ -0,0 +1,165 @@
+package envtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/testcontainers/testcontainers-go"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+const (
+	// AuditWebhookConfigPath is the path to the kubeconfig of the audit webhook of WithAuditWebhook inside the container
+	AuditWebhookConfigPath = "/etc/envtest/audit-webhook.yaml"
+
+	// auditWebhookPath is the path the audit webhook server receives event batches on
+	auditWebhookPath = "/audit"
+
+	// auditWebhookBatchMaxWait is how long the API server buffers audit events before sending a batch,
+	// 30s by default, lowered so events arrive while the test runs
+	auditWebhookBatchMaxWait = 200 * time.Millisecond
+
+	// auditEventBufferSize is how many audit events are kept for a consumer that does not keep up
+	auditEventBufferSize = 1024
+)
+
+// auditWebhookServer receives the event batches of the API server audit webhook backend on the test host,
+// and delivers the events on a channel without ever blocking the API server
+type auditWebhookServer struct {
+	port   int
+	pki    *certs.WebhookPKI
+	server *http.Server
+
+	mu      sync.Mutex
+	ch      chan auditv1.Event
+	closed  bool
+	dropped int
+}
+
+// newAuditWebhookServer picks the host port of the server and generates its serving certificate
+func newAuditWebhookServer() (*auditWebhookServer, error) {
+	port, err := freeHostPort()
+	if err != nil {
+		return nil, err
+	}
+
+	pki, err := certs.NewWebhookPKI(testcontainers.HostInternal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditWebhookServer{
+		port: port,
+		pki:  pki,
+		ch:   make(chan auditv1.Event, auditEventBufferSize),
+	}, nil
+}
+
+// kubeconfig returns the webhook kubeconfig pointing the API server at the server
+func (s *auditWebhookServer) kubeconfig() ([]byte, error) {
+	return HostWebhookKubeconfig(s.port, auditWebhookPath, s.pki.CACert)
+}
+
+// start serves the audit webhook on all interfaces, as the container reaches the host by its gateway IP
+func (s *auditWebhookServer) start() error {
+	tlsConfig, err := s.pki.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(webhookServingHost, strconv.Itoa(s.port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen for audit events: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+auditWebhookPath, s.serveEvents)
+
+	s.server = &http.Server{Handler: mux, TLSConfig: tlsConfig, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() { _ = s.server.ServeTLS(listener, "", "") }()
+
+	return nil
+}
+
+// serveEvents delivers the events of a batch in order
+func (s *auditWebhookServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	var events auditv1.EventList
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode audit events: %v", err), http.StatusBadRequest)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events.Items {
+		if s.closed {
+			break
+		}
+
+		select {
+		case s.ch <- event:
+		default:
+			s.dropped++
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// droppedEvents returns the number of events dropped because the channel was full
+func (s *auditWebhookServer) droppedEvents() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// stop waits for the batches being received to be delivered, then closes the channel, once
+func (s *auditWebhookServer) stop(ctx context.Context) {
+	if s.server != nil {
+		if err := s.server.Shutdown(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			_ = s.server.Close()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// AuditEvents returns the channel the events of the audit webhook of WithAuditWebhook are delivered on,
+// as the API server sends them in batches. Every call returns the same channel, so events are received by one
+// consumer. Receiving never blocks the API server: the channel buffers up to 1024 events and further ones are
+// dropped (see DroppedAuditEvents) until it is drained. The channel is closed once Terminate delivered the events
+// the API server flushed on shutdown. It is nil without WithAuditWebhook.
+func (c *EnvtestContainer) AuditEvents() <-chan auditv1.Event {
+	if c.auditWebhook == nil {
+		return nil
+	}
+
+	return c.auditWebhook.ch
+}
+
+// DroppedAuditEvents returns the number of audit events dropped because the AuditEvents channel was full
+func (c *EnvtestContainer) DroppedAuditEvents() int {
+	if c.auditWebhook == nil {
+		return 0
+	}
+
+	return c.auditWebhook.droppedEvents()
+}
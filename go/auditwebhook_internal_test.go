@@ -0,0 +1,108 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestWithAuditWebhook(t *testing.T) {
+	cfg := &config{}
+
+	WithAuditPolicy([]byte("log policy"))(cfg)
+	WithAuditWebhook([]byte("webhook policy"))(cfg)
+
+	require.True(t, cfg.hostAccessEnabled())
+	require.Equal(t, []byte("webhook policy"), cfg.auditPolicy)
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--audit-policy-file=" + AuditPolicyPath,
+		"--audit-log-path=" + AuditLogPath,
+		"--audit-webhook-config-file=" + AuditWebhookConfigPath,
+		"--audit-webhook-mode=batch",
+		"--audit-webhook-version=audit.k8s.io/v1",
+		"--audit-webhook-batch-max-wait=200ms",
+	}, cfg.apiServerArgs())
+
+	WithAPIServerArg("audit-webhook-mode", "blocking")(cfg)
+	require.ErrorContains(t, cfg.checkAPIServerFlags(), "WithAuditWebhook")
+}
+
+// postAuditEvents sends a batch of events with the given audit IDs to the server like the API server does
+func postAuditEvents(t *testing.T, s *auditWebhookServer, ids ...string) {
+	t.Helper()
+
+	events := auditv1.EventList{}
+	for _, id := range ids {
+		events.Items = append(events.Items, auditv1.Event{AuditID: types.UID(id), Verb: "create"})
+	}
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: s.pki.CertPool()}}}
+
+	url := fmt.Sprintf("https://127.0.0.1:%d%s", s.port, auditWebhookPath)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	defer func() { _ = resp.Body.Close() }()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAuditWebhookServer(t *testing.T) {
+	s, err := newAuditWebhookServer()
+	require.NoError(t, err)
+	require.NoError(t, s.start())
+
+	kubeconfig, err := s.kubeconfig()
+	require.NoError(t, err)
+
+	apiConfig, err := clientcmd.Load(kubeconfig)
+	require.NoError(t, err)
+	require.Equal(t, hostURL("https", s.port, auditWebhookPath), apiConfig.Clusters["webhook"].Server)
+
+	postAuditEvents(t, s, "a", "b")
+	postAuditEvents(t, s, "c")
+
+	s.stop(context.Background())
+
+	var ids []types.UID
+	for event := range s.ch {
+		ids = append(ids, event.AuditID)
+	}
+
+	require.Equal(t, []types.UID{"a", "b", "c"}, ids)
+	require.Zero(t, s.droppedEvents())
+
+	// Stopping again is a no-op
+	s.stop(context.Background())
+}
+
+func TestAuditWebhookServerDropsWhenFull(t *testing.T) {
+	s, err := newAuditWebhookServer()
+	require.NoError(t, err)
+	require.NoError(t, s.start())
+
+	defer s.stop(context.Background())
+
+	ids := make([]string, auditEventBufferSize+3)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+
+	postAuditEvents(t, s, ids...)
+
+	require.Len(t, s.ch, auditEventBufferSize)
+	require.Equal(t, 3, s.droppedEvents())
+}
@@ -0,0 +1,64 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnvtestContainerWithAuditWebhook(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithAuditWebhook([]byte(metadataAuditPolicy)))...)
+	require.NoError(t, err)
+
+	terminated := false
+
+	defer func() {
+		if !terminated {
+			require.NoError(t, c.Terminate(context.WithoutCancel(ctx)))
+		}
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	names := []string{"first", "second"}
+	for _, name := range names {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		require.NoError(t, cl.Create(ctx, cm))
+	}
+
+	// The creates arrive in order while the container runs
+	var created []string
+
+	for len(created) < len(names) {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("received the audit events of %v only: %v", created, ctx.Err())
+		case event, ok := <-c.AuditEvents():
+			require.True(t, ok, "audit events channel closed")
+
+			if event.Verb == "create" && event.ObjectRef != nil && event.ObjectRef.Resource == "configmaps" {
+				created = append(created, event.ObjectRef.Name)
+			}
+		}
+	}
+
+	require.Equal(t, names, created)
+
+	// The channel is closed after the events flushed on shutdown are delivered
+	require.NoError(t, c.Terminate(context.WithoutCancel(ctx)))
+	terminated = true
+
+	for range c.AuditEvents() {
+	}
+
+	require.Zero(t, c.DroppedAuditEvents())
+}
@@ -0,0 +1,77 @@
+package envtest
+
+import (
+	"errors"
+	"fmt"
+
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	apiserverv1beta1 "k8s.io/apiserver/pkg/apis/apiserver/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// AuthenticationConfigPath is the path to the authentication configuration of WithAuthenticationConfig inside the container
+const AuthenticationConfigPath = "/etc/envtest/authentication-config.yaml"
+
+// authenticationConfigVersions are the AuthenticationConfiguration API versions, and the Kubernetes versions serving them
+var authenticationConfigVersions = map[string]string{
+	apiserverv1beta1.ConfigSchemeGroupVersion.String(): "1.30.0",
+	apiserverv1.SchemeGroupVersion.String():            "1.34.0",
+}
+
+// checkAuthenticationConfig validates that the configuration decodes into a known AuthenticationConfiguration
+// API version, and returns the Kubernetes version it requires
+func checkAuthenticationConfig(configYAML []byte) (string, error) {
+	var typeMeta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+
+	if err := yaml.Unmarshal(configYAML, &typeMeta); err != nil {
+		return "", fmt.Errorf("failed to decode AuthenticationConfiguration: %w", err)
+	}
+
+	if typeMeta.Kind != "AuthenticationConfiguration" {
+		return "", fmt.Errorf(
+			"authentication config is a %q, not an AuthenticationConfiguration",
+			typeMeta.Kind,
+		)
+	}
+
+	minVersion, ok := authenticationConfigVersions[typeMeta.APIVersion]
+	if !ok {
+		return "", fmt.Errorf(
+			"unknown AuthenticationConfiguration API version %q",
+			typeMeta.APIVersion,
+		)
+	}
+
+	var config any = &apiserverv1beta1.AuthenticationConfiguration{}
+	if typeMeta.APIVersion == apiserverv1.SchemeGroupVersion.String() {
+		config = &apiserverv1.AuthenticationConfiguration{}
+	}
+
+	if err := yaml.UnmarshalStrict(configYAML, config); err != nil {
+		return "", fmt.Errorf("failed to decode AuthenticationConfiguration: %w", err)
+	}
+
+	return minVersion, nil
+}
+
+// checkAuthentication rejects authentication options kube-apiserver would fail to start with
+func (c *config) checkAuthentication() error {
+	if c.authnConfig == nil {
+		return nil
+	}
+
+	if c.oidc != nil {
+		return errors.New("WithAuthenticationConfig cannot be combined with WithOIDC, " +
+			"configure the issuer in it instead")
+	}
+
+	minVersion, err := checkAuthenticationConfig(c.authnConfig)
+	if err != nil {
+		return err
+	}
+
+	return c.requireKubernetesVersion("WithAuthenticationConfig", minVersion)
+}
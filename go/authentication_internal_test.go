@@ -0,0 +1,87 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const jwtAuthenticationConfig = `apiVersion: apiserver.config.k8s.io/v1beta1
+kind: AuthenticationConfiguration
+jwt:
+- issuer:
+    url: https://issuer.example.com
+    audiences: ["envtest"]
+  claimMappings:
+    username:
+      expression: "'oidc:' + claims.sub"
+`
+
+func TestWithAuthenticationConfig(t *testing.T) {
+	cfg := &config{kubernetesVersion: DefaultKubernetesVersion}
+
+	WithAuthenticationConfig([]byte(jwtAuthenticationConfig))(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--authentication-config=" + AuthenticationConfigPath}, cfg.apiServerArgs())
+
+	WithOIDC(OIDCOptions{Port: 5556, ClientID: "envtest"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		"WithAuthenticationConfig cannot be combined with WithOIDC, configure the issuer in it instead")
+}
+
+func TestCheckAuthenticationConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		minVersion string
+		err        string
+	}{
+		{name: "v1beta1", config: jwtAuthenticationConfig, minVersion: "1.30.0"},
+		{
+			name:       "v1",
+			config:     "apiVersion: apiserver.config.k8s.io/v1\nkind: AuthenticationConfiguration\njwt: []\n",
+			minVersion: "1.34.0",
+		},
+		{
+			name:   "unknown field",
+			config: "apiVersion: apiserver.config.k8s.io/v1beta1\nkind: AuthenticationConfiguration\nissuers: []\n",
+			err:    `failed to decode AuthenticationConfiguration: error unmarshaling JSON: while decoding JSON: json: unknown field "issuers"`,
+		},
+		{
+			name:   "kind",
+			config: "apiVersion: apiserver.config.k8s.io/v1beta1\nkind: AuthorizationConfiguration\n",
+			err:    `authentication config is a "AuthorizationConfiguration", not an AuthenticationConfiguration`,
+		},
+		{
+			name:   "version",
+			config: "apiVersion: apiserver.config.k8s.io/v1alpha1\nkind: AuthenticationConfiguration\n",
+			err:    `unknown AuthenticationConfiguration API version "apiserver.config.k8s.io/v1alpha1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minVersion, err := checkAuthenticationConfig([]byte(tt.config))
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.minVersion, minVersion)
+		})
+	}
+}
+
+func TestRequireKubernetesVersion(t *testing.T) {
+	cfg := &config{kubernetesVersion: "1.34.1"}
+
+	require.NoError(t, cfg.requireKubernetesVersion("WithFoo", "1.30.0"))
+	require.NoError(t, cfg.requireKubernetesVersion("WithFoo", "1.34.0"))
+	require.EqualError(t, cfg.requireKubernetesVersion("WithFoo", "1.35.0"), "WithFoo requires Kubernetes 1.35.0 or later, got 1.34.1")
+
+	cfg.kubernetesVersion = "latest"
+	require.ErrorContains(t, cfg.requireKubernetesVersion("WithFoo", "1.30.0"), `invalid Kubernetes version "latest"`)
+}
@@ -0,0 +1,67 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestEnvtestContainerAuthenticationConfigRequiresVersion(t *testing.T) {
+	config := []byte(`apiVersion: apiserver.config.k8s.io/v1beta1
+kind: AuthenticationConfiguration
+jwt:
+- issuer:
+    url: https://issuer.example.com
+    audiences: ["envtest"]
+  claimMappings:
+    username:
+      claim: sub
+      prefix: "oidc:"
+`)
+
+	// Rejected before a container is started
+	_, err := envtest.Run(t.Context(),
+		envtest.WithKubernetesVersion("1.28.0"),
+		envtest.WithAuthenticationConfig(config),
+	)
+	require.EqualError(t, err, "WithAuthenticationConfig requires Kubernetes 1.30.0 or later, got 1.28.0")
+}
+
+func TestEnvtestContainerWithAnonymousAuthDisabled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// Run waits for readiness with credentials, so it succeeds without anonymous access
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithAnonymousAuth(false))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+
+	anonymous, err := kubernetes.NewForConfig(rest.AnonymousClientConfig(cfg))
+	require.NoError(t, err)
+
+	for _, path := range []string{"/healthz", "/version"} {
+		err := anonymous.CoreV1().RESTClient().Get().AbsPath(path).Do(ctx).Error()
+		require.True(t, apierrors.IsUnauthorized(err), "expected 401 for %s, got %v", path, err)
+	}
+}
@@ -0,0 +1,109 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// readOnlyServiceAccountClient returns a clientset authenticated as a ServiceAccount bound to a Role
+// allowing to read ConfigMaps in the default namespace only
+func readOnlyServiceAccountClient(ctx context.Context, t *testing.T, c *envtest.EnvtestContainer) *kubernetes.Clientset {
+	t.Helper()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	admin, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "read-only", Namespace: "default"}}
+	_, err = admin.CoreV1().ServiceAccounts("default").Create(ctx, sa, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "configmap-reader", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "list", "watch"},
+		}},
+	}
+	_, err = admin.RbacV1().Roles("default").Create(ctx, role, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-only", Namespace: "default"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: sa.Name, Namespace: sa.Namespace}},
+	}
+	_, err = admin.RbacV1().RoleBindings("default").Create(ctx, binding, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	token, err := admin.CoreV1().ServiceAccounts("default").CreateToken(ctx, sa.Name, &authenticationv1.TokenRequest{}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	saConfig := rest.AnonymousClientConfig(cfg)
+	saConfig.BearerToken = token.Status.Token
+
+	clientset, err := kubernetes.NewForConfig(saConfig)
+	require.NoError(t, err)
+
+	return clientset
+}
+
+func TestEnvtestContainerRBACAuthorization(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithAuthorizationModes("RBAC"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	clientset := readOnlyServiceAccountClient(ctx, t, c)
+
+	_, err = clientset.CoreV1().ConfigMaps("default").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "out-of-scope"}}
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{})
+	require.True(t, apierrors.IsForbidden(err), "expected Forbidden, got %v", err)
+
+	_, err = clientset.CoreV1().Secrets("default").List(ctx, metav1.ListOptions{})
+	require.True(t, apierrors.IsForbidden(err), "expected Forbidden, got %v", err)
+}
+
+func TestEnvtestContainerAlwaysAllowAuthorization(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithAuthorizationModes("AlwaysAllow"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	clientset := readOnlyServiceAccountClient(ctx, t, c)
+
+	// The Role is not enforced
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "allowed"}}
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
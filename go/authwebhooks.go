@@ -0,0 +1,48 @@
+package envtest
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// AuthenticationWebhookConfigPath is the path to the kubeconfig of WithAuthenticationWebhook inside the container
+	AuthenticationWebhookConfigPath = "/etc/envtest/authentication-webhook.yaml"
+
+	// AuthorizationWebhookConfigPath is the path to the kubeconfig of WithAuthorizationWebhook inside the container
+	AuthorizationWebhookConfigPath = "/etc/envtest/authorization-webhook.yaml"
+)
+
+// HostWebhookKubeconfig returns a webhook kubeconfig for WithAuthenticationWebhook or WithAuthorizationWebhook
+// pointing the API server at a webhook server listening on the given port of the test host.
+// caBundle is the PEM-encoded CA of the server certificate, e.g. certs.WebhookPKI.CACert,
+// which has to be valid for HostAlias.
+func HostWebhookKubeconfig(port int, path string, caBundle []byte) ([]byte, error) {
+	apiConfig := clientcmdapi.NewConfig()
+	apiConfig.Clusters["webhook"] = &clientcmdapi.Cluster{
+		Server:                   hostURL("https", port, path),
+		CertificateAuthorityData: caBundle,
+	}
+	apiConfig.AuthInfos["apiserver"] = &clientcmdapi.AuthInfo{}
+	apiConfig.Contexts["webhook"] = &clientcmdapi.Context{Cluster: "webhook", AuthInfo: "apiserver"}
+	apiConfig.CurrentContext = "webhook"
+
+	kubeconfig, err := clientcmd.Write(*apiConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook kubeconfig: %w", err)
+	}
+
+	return kubeconfig, nil
+}
+
+// authorizationModes returns the authorization modes to run the API server with, nil for the container default.
+// The webhook authorizer is consulted for the requests RBAC has no opinion on.
+func (c *config) authorizationModes() []string {
+	if len(c.authzModes) == 0 && c.authzWebhook != nil {
+		return []string{"RBAC", "Webhook"}
+	}
+
+	return c.authzModes
+}
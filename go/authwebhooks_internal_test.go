@@ -0,0 +1,49 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestHostWebhookKubeconfig(t *testing.T) {
+	kubeconfig, err := HostWebhookKubeconfig(9443, "/authorize", []byte("ca"))
+	require.NoError(t, err)
+
+	apiConfig, err := clientcmd.Load(kubeconfig)
+	require.NoError(t, err)
+
+	cluster := apiConfig.Clusters[apiConfig.Contexts[apiConfig.CurrentContext].Cluster]
+	require.Equal(t, "https://host.testcontainers.internal:9443/authorize", cluster.Server)
+	require.Equal(t, []byte("ca"), cluster.CertificateAuthorityData)
+}
+
+func TestWithAuthWebhooks(t *testing.T) {
+	cfg := &config{}
+
+	WithAuthenticationWebhook([]byte("authn"))(cfg)
+	WithAuthorizationWebhook([]byte("authz"))(cfg)
+
+	require.True(t, cfg.hostAccessEnabled())
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"RBAC", "Webhook"}, cfg.authorizationModes())
+	require.Equal(t, []string{
+		"--authentication-token-webhook-config-file=" + AuthenticationWebhookConfigPath,
+		"--authentication-token-webhook-version=v1",
+		"--authorization-webhook-config-file=" + AuthorizationWebhookConfigPath,
+		"--authorization-webhook-version=v1",
+	}, cfg.apiServerArgs())
+
+	WithAuthorizationModes("Webhook")(cfg)
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"Webhook"}, cfg.authorizationModes())
+
+	cfg = &config{}
+	WithAuthorizationWebhook([]byte("authz"))(cfg)
+	WithAuthorizationModes("RBAC")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "WithAuthorizationWebhook requires the Webhook authorization mode")
+
+	// The container default has no webhook
+	require.Empty(t, (&config{}).authorizationModes())
+}
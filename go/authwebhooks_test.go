@@ -0,0 +1,130 @@
+package envtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// stubAuthWebhooks authenticates the token "stub-token" as stub-user, and authorizes it to read ConfigMaps only
+func stubAuthWebhooks() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/authenticate", func(w http.ResponseWriter, r *http.Request) {
+		review := &authenticationv1.TokenReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if review.Spec.Token == "stub-token" {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: "stub-user", Groups: []string{"stub-group"}},
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	})
+
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		review := &authorizationv1.SubjectAccessReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		attrs := review.Spec.ResourceAttributes
+		if review.Spec.User == "stub-user" && attrs != nil && attrs.Resource == "configmaps" && attrs.Verb == "list" {
+			review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: true}
+		} else {
+			review.Status = authorizationv1.SubjectAccessReviewStatus{Denied: true, Reason: "denied by the stub authorizer"}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	})
+
+	return mux
+}
+
+func TestEnvtestContainerWithAuthWebhooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	require.NoError(t, err)
+
+	pki, err := certs.NewWebhookPKI(testcontainers.HostInternal)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, pki.WriteDir(dir))
+
+	srv := &http.Server{Handler: stubAuthWebhooks(), ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		_ = srv.ServeTLS(listener, filepath.Join(dir, certs.CertFileName), filepath.Join(dir, certs.KeyFileName))
+	}()
+
+	defer func() { _ = srv.Close() }()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	authn, err := envtest.HostWebhookKubeconfig(port, "/authenticate", pki.CACert)
+	require.NoError(t, err)
+
+	authz, err := envtest.HostWebhookKubeconfig(port, "/authorize", pki.CACert)
+	require.NoError(t, err)
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithAuthenticationWebhook(authn),
+		envtest.WithAuthorizationWebhook(authz),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	kubeconfig, err := c.KubeconfigForToken(ctx, "stub-token")
+	require.NoError(t, err)
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "stub-user", review.Status.UserInfo.Username)
+	require.Contains(t, review.Status.UserInfo.Groups, "stub-group")
+
+	// RBAC has no opinion on the user, so the stub decides
+	_, err = clientset.CoreV1().ConfigMaps("default").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().Secrets("default").List(ctx, metav1.ListOptions{})
+	require.True(t, apierrors.IsForbidden(err), "expected Forbidden, got %v", err)
+	require.ErrorContains(t, err, "denied by the stub authorizer")
+}
@@ -0,0 +1,190 @@
+// Package certs generates throwaway TLS material for webhook servers that run on the test host
+// and are called by the envtest API server
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// CertFileName is the serving certificate file name controller-runtime webhook servers look for
+	CertFileName = "tls.crt"
+
+	// KeyFileName is the serving key file name controller-runtime webhook servers look for
+	KeyFileName = "tls.key"
+
+	// CAFileName is the CA bundle file name
+	CAFileName = "ca.crt"
+
+	// validity is how long the generated certificates are valid for
+	validity = 24 * time.Hour
+)
+
+// DefaultHosts are always included in the serving certificate SANs: the name the container
+// reaches the host by, and the loopback addresses for clients on the host itself
+var DefaultHosts = []string{testcontainers.HostInternal, "localhost", "127.0.0.1", "::1"}
+
+// WebhookPKI is a self-signed CA and a serving certificate issued by it
+type WebhookPKI struct {
+	// CACert is the PEM-encoded CA certificate to put into caBundle fields
+	CACert []byte
+	// ServerCert is the PEM-encoded serving certificate
+	ServerCert []byte
+	// ServerKey is the PEM-encoded serving private key
+	ServerKey []byte
+	// Hosts are the DNS names and IP addresses the serving certificate is valid for
+	Hosts []string
+}
+
+// NewWebhookPKI generates a CA and a serving certificate valid for DefaultHosts and the given hosts.
+// Hosts that parse as IP addresses become IP SANs, the rest become DNS SANs.
+func NewWebhookPKI(hosts ...string) (*WebhookPKI, error) {
+	allHosts := slices.Clone(DefaultHosts)
+
+	for _, host := range hosts {
+		if !slices.Contains(allHosts, host) {
+			allHosts = append(allHosts, host)
+		}
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "envtest-webhook-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(
+		rand.Reader,
+		caTemplate,
+		caTemplate,
+		&caKey.PublicKey,
+		caKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serving key: %w", err)
+	}
+
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: allHosts[0]},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, host := range allHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+
+	serverDER, err := x509.CreateCertificate(
+		rand.Reader,
+		serverTemplate,
+		caCert,
+		&serverKey.PublicKey,
+		caKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create serving certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(serverKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal serving key: %w", err)
+	}
+
+	return &WebhookPKI{
+		CACert:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		ServerCert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER}),
+		ServerKey:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		Hosts:      allHosts,
+	}, nil
+}
+
+// TLSConfig returns a server TLS config that serves the generated certificate,
+// ready for httptest.Server.TLS or a controller-runtime webhook server
+func (p *WebhookPKI) TLSConfig() (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(p.ServerCert, p.ServerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// CertPool returns a pool trusting the generated CA, for clients on the host
+func (p *WebhookPKI) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(p.CACert)
+
+	return pool
+}
+
+// WriteDir writes tls.crt, tls.key and ca.crt into dir, creating it if needed.
+// The directory can be used as a controller-runtime webhook server CertDir.
+func (p *WebhookPKI) WriteDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cert dir: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}{
+		{CertFileName, p.ServerCert, 0o644},
+		{KeyFileName, p.ServerKey, 0o600},
+		{CAFileName, p.CACert, 0o644},
+	}
+
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.data, f.mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package certs_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/require"
+)
+
+func parseCert(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func TestNewWebhookPKIChain(t *testing.T) {
+	pki, err := certs.NewWebhookPKI("webhook.example.com", "10.1.2.3")
+	require.NoError(t, err)
+
+	ca := parseCert(t, pki.CACert)
+	require.True(t, ca.IsCA)
+
+	server := parseCert(t, pki.ServerCert)
+	require.False(t, server.IsCA)
+
+	hosts := []string{"host.testcontainers.internal", "localhost", "127.0.0.1", "::1", "webhook.example.com", "10.1.2.3"}
+	require.Equal(t, hosts, pki.Hosts)
+
+	for _, host := range hosts {
+		_, err := server.Verify(x509.VerifyOptions{
+			DNSName:   host,
+			Roots:     pki.CertPool(),
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		require.NoError(t, err, host)
+	}
+
+	require.Error(t, server.VerifyHostname("other.example.com"))
+
+	other, err := certs.NewWebhookPKI()
+	require.NoError(t, err)
+
+	_, err = server.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: other.CertPool()})
+	require.Error(t, err, "certificate must not be trusted by an unrelated CA")
+}
+
+func TestWebhookPKITLSConfig(t *testing.T) {
+	pki, err := certs.NewWebhookPKI()
+	require.NoError(t, err)
+
+	tlsConfig, err := pki.TLSConfig()
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pki.CertPool(), MinVersion: tls.VersionTLS12},
+	}}
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestWebhookPKIWriteDir(t *testing.T) {
+	pki, err := certs.NewWebhookPKI()
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "certs")
+	require.NoError(t, pki.WriteDir(dir))
+
+	_, err = tls.LoadX509KeyPair(filepath.Join(dir, certs.CertFileName), filepath.Join(dir, certs.KeyFileName))
+	require.NoError(t, err)
+
+	ca, err := os.ReadFile(filepath.Join(dir, certs.CAFileName))
+	require.NoError(t, err)
+	require.Equal(t, pki.CACert, ca)
+}
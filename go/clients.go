@@ -0,0 +1,196 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// clientset returns a typed Kubernetes clientset for the envtest API server
+func (c *EnvtestContainer) clientset(ctx context.Context) (*kubernetes.Clientset, error) {
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes clientset: %w", err)
+	}
+
+	return clientset, nil
+}
+
+// GetDynamicClient returns a dynamic client built from RESTConfig,
+// for working with custom resources and other objects without compiled types
+func (c *EnvtestContainer) GetDynamicClient(ctx context.Context) (dynamic.Interface, error) {
+	client, err := c.dynamicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// GetDiscoveryClient returns a discovery client for the served API groups, resources and server version
+func (c *EnvtestContainer) GetDiscoveryClient(
+	ctx context.Context,
+) (discovery.DiscoveryInterface, error) {
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	return client, nil
+}
+
+// ServerVersion returns the version reported by the API server. Unlike KubernetesVersion,
+// which echoes WithKubernetesVersion, it reflects the binaries actually baked into the image.
+func (c *EnvtestContainer) ServerVersion(ctx context.Context) (*version.Info, error) {
+	client, err := c.GetDiscoveryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := client.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	return info, nil
+}
+
+// GetRESTMapper returns a RESTMapper resolving kinds to resources through cached discovery.
+// API groups are discovered on first use and rediscovered when a kind is not found,
+// so kinds of CRDs installed after the mapper was created resolve too.
+func (c *EnvtestContainer) GetRESTMapper(ctx context.Context) (meta.RESTMapper, error) {
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create REST mapper: %w", err)
+	}
+
+	return mapper, nil
+}
+
+// dynamicClient returns a dynamic client for the envtest API server
+func (c *EnvtestContainer) dynamicClient(ctx context.Context) (*dynamic.DynamicClient, error) {
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	return client, nil
+}
+
+// GetAPIExtensionsClient returns an apiextensions clientset for managing CustomResourceDefinitions
+func (c *EnvtestContainer) GetAPIExtensionsClient(
+	ctx context.Context,
+) (apiextensionsclientset.Interface, error) {
+	clientset, err := c.apiExtensionsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientset, nil
+}
+
+// apiExtensionsClient returns an apiextensions clientset for managing CRDs
+func (c *EnvtestContainer) apiExtensionsClient(
+	ctx context.Context,
+) (*apiextensionsclientset.Clientset, error) {
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := apiextensionsclientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create apiextensions clientset: %w", err)
+	}
+
+	return clientset, nil
+}
+
+// GetClient returns a non-cached controller-runtime client for the given scheme,
+// or for the built-in types if scheme is nil
+func (c *EnvtestContainer) GetClient(
+	ctx context.Context,
+	scheme *runtime.Scheme,
+) (client.Client, error) {
+	if scheme == nil {
+		scheme = clientgoscheme.Scheme
+	}
+
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cl, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create controller-runtime client: %w", err)
+	}
+
+	return cl, nil
+}
+
+// controllerClient returns a non-cached controller-runtime client that knows the built-in types
+func (c *EnvtestContainer) controllerClient(ctx context.Context) (client.Client, error) {
+	return c.GetClient(ctx, nil)
+}
+
+// createOrUpdate creates the object or, if it already exists, replaces it with the given state
+func createOrUpdate(ctx context.Context, cl client.Client, obj client.Object) error {
+	// A previous attempt may have left the resourceVersion of the existing object behind
+	obj.SetResourceVersion("")
+
+	err := cl.Create(ctx, obj)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+
+	return cl.Update(ctx, obj)
+}
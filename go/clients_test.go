@@ -0,0 +1,217 @@
+package envtest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerDynamicClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	crdClient, err := apiextensionsclientset.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = crdClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, scalerCRD(), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	dyn, err := c.GetDynamicClient(ctx)
+	require.NoError(t, err)
+
+	scalers := dyn.Resource(schema.GroupVersionResource{
+		Group:    "schema.example.com",
+		Version:  "v1",
+		Resource: "scalers",
+	}).Namespace("default")
+
+	obj := scaler("dynamic", map[string]any{"size": "large", "maxReplicas": int64(5)})
+
+	require.Eventually(t, func() bool {
+		_, err := scalers.Create(ctx, obj, metav1.CreateOptions{})
+
+		return err == nil
+	}, 30*time.Second, 100*time.Millisecond, "CRD was not served in time")
+
+	list, err := scalers.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "dynamic", list.Items[0].GetName())
+
+	size, _, err := unstructured.NestedString(list.Items[0].Object, "spec", "size")
+	require.NoError(t, err)
+	require.Equal(t, "large", size)
+}
+
+func TestEnvtestContainerGetClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	for name, scheme := range map[string]*runtime.Scheme{"default scheme": nil, "custom scheme": scheme} {
+		t.Run(name, func(t *testing.T) {
+			cl, err := c.GetClient(ctx, scheme)
+			require.NoError(t, err)
+
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: strings.ReplaceAll(name, " ", "-"), Namespace: "default"},
+				Data:       map[string]string{"key1": "value1"},
+			}
+			require.NoError(t, cl.Create(ctx, configMap))
+
+			var got corev1.ConfigMap
+			require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(configMap), &got))
+			require.Equal(t, "value1", got.Data["key1"])
+		})
+	}
+}
+
+func TestEnvtestContainerServerVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	inspect, err := c.Inspect(ctx)
+	require.NoError(t, err)
+
+	// The Kubernetes version the image was built for
+	var imageVersion string
+
+	for _, env := range inspect.Config.Env {
+		if v, ok := strings.CutPrefix(env, "KUBERNETES_VERSION="); ok {
+			imageVersion = v
+		}
+	}
+
+	require.NotEmpty(t, imageVersion, "image does not set KUBERNETES_VERSION")
+
+	info, err := c.ServerVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "v"+imageVersion, info.GitVersion)
+
+	discoveryClient, err := c.GetDiscoveryClient(ctx)
+	require.NoError(t, err)
+
+	resources, err := discoveryClient.ServerResourcesForGroupVersion("v1")
+	require.NoError(t, err)
+	require.NotEmpty(t, resources.APIResources)
+}
+
+func TestEnvtestContainerAPIExtensionsClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	crdClient, err := c.GetAPIExtensionsClient(ctx)
+	require.NoError(t, err)
+
+	crds := crdClient.ApiextensionsV1().CustomResourceDefinitions()
+
+	_, err = crds.Create(ctx, scalerCRD(), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		crd, err := crds.Get(ctx, scalerCRD().Name, metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type == apiextensionsv1.Established {
+				return cond.Status == apiextensionsv1.ConditionTrue
+			}
+		}
+
+		return false
+	}, 30*time.Second, 100*time.Millisecond, "CRD was not established in time")
+}
+
+func TestEnvtestContainerRESTMapper(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	mapper, err := c.GetRESTMapper(ctx)
+	require.NoError(t, err)
+
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	require.NoError(t, err)
+	require.Equal(t, "deployments", mapping.Resource.Resource)
+
+	scalerKind := schema.GroupKind{Group: "schema.example.com", Kind: "Scaler"}
+
+	_, err = mapper.RESTMapping(scalerKind)
+	require.True(t, meta.IsNoMatchError(err), "unexpected error: %v", err)
+
+	crdClient, err := c.GetAPIExtensionsClient(ctx)
+	require.NoError(t, err)
+
+	_, err = crdClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, scalerCRD(), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// The same mapper picks up the new kind once the CRD is served
+	require.Eventually(t, func() bool {
+		mapping, err = mapper.RESTMapping(scalerKind, "v1")
+
+		return err == nil
+	}, 30*time.Second, 100*time.Millisecond, "CRD kind was not resolved in time")
+
+	require.Equal(t, "scalers", mapping.Resource.Resource)
+	require.Equal(t, meta.RESTScopeNameNamespace, mapping.Scope.Name())
+}
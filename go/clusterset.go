@@ -0,0 +1,133 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+)
+
+// DefaultClusterAliasPrefix is the prefix of the network aliases of ClusterSet members
+const DefaultClusterAliasPrefix = "cluster"
+
+// clusterSetConfig holds the configuration for RunClusterSet
+type clusterSetConfig struct {
+	aliasPrefix string
+	opts        []Option
+}
+
+// ClusterSetOption is a functional option for configuring RunClusterSet
+type ClusterSetOption func(*clusterSetConfig)
+
+// WithClusterOptions sets the options every member of the ClusterSet is started with
+func WithClusterOptions(opts ...Option) ClusterSetOption {
+	return func(c *clusterSetConfig) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// WithClusterAliasPrefix sets the prefix of the member network aliases (default: "cluster"),
+// member i is reachable from the network as <prefix>-<i>
+func WithClusterAliasPrefix(prefix string) ClusterSetOption {
+	return func(c *clusterSetConfig) {
+		c.aliasPrefix = prefix
+	}
+}
+
+// ClusterSet is a group of envtest clusters attached to one Docker network, so they can reach each other
+type ClusterSet struct {
+	// Network is the Docker network shared by the clusters
+	Network *testcontainers.DockerNetwork
+	// Clusters are the member clusters, in the order of their aliases
+	Clusters []*EnvtestContainer
+}
+
+// RunClusterSet starts n envtest clusters in parallel on a new shared Docker network.
+// Each cluster is reachable from the others under its network alias (see Alias) with a serving
+// certificate valid for it, so credentials of one cluster (see NetworkKubeconfig) can be handed to
+// controllers or clients running against another. If a cluster fails to start, the others are terminated.
+func RunClusterSet(ctx context.Context, n int, opts ...ClusterSetOption) (*ClusterSet, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("cluster set needs at least one cluster, got %d", n)
+	}
+
+	cfg := &clusterSetConfig{aliasPrefix: DefaultClusterAliasPrefix}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	nw, err := network.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster set network: %w", err)
+	}
+
+	set := &ClusterSet{Network: nw, Clusters: make([]*EnvtestContainer, n)}
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+
+	for i := range n {
+		wg.Go(func() {
+			alias := clusterAlias(cfg.aliasPrefix, i)
+			memberOpts := append(append([]Option{}, cfg.opts...), WithNetwork(nw.Name, alias))
+
+			set.Clusters[i], errs[i] = Run(ctx, memberOpts...)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("failed to start cluster %s: %w", alias, errs[i])
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, errors.Join(err, set.Terminate(context.WithoutCancel(ctx)))
+	}
+
+	return set, nil
+}
+
+// Alias returns the network alias of cluster i
+func (s *ClusterSet) Alias(i int) string {
+	return s.Clusters[i].networkAliases[0]
+}
+
+// NetworkKubeconfig returns the kubeconfig of cluster i addressing it by its network alias,
+// for use from inside the other clusters of the set or other containers on the network
+func (s *ClusterSet) NetworkKubeconfig(ctx context.Context, i int) (string, error) {
+	return s.Clusters[i].Kubeconfig(ctx, WithNetworkAddress())
+}
+
+// Terminate terminates all clusters, even if some fail to, and then removes the network
+func (s *ClusterSet) Terminate(ctx context.Context) error {
+	var errs []error
+
+	for _, cluster := range s.Clusters {
+		if cluster == nil {
+			continue
+		}
+
+		if err := cluster.Terminate(ctx); err != nil {
+			errs = append(
+				errs,
+				fmt.Errorf("failed to terminate cluster %s: %w", cluster.networkAliases[0], err),
+			)
+		}
+	}
+
+	if err := s.Network.Remove(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove cluster set network: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// clusterAlias returns the network alias of the i-th member of a cluster set
+func clusterAlias(prefix string, i int) string {
+	return prefix + "-" + strconv.Itoa(i)
+}
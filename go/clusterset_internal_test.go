@@ -0,0 +1,40 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunClusterSetRequiresClusters(t *testing.T) {
+	_, err := RunClusterSet(t.Context(), 0)
+	require.ErrorContains(t, err, "at least one cluster")
+}
+
+func TestClusterSetOptions(t *testing.T) {
+	cfg := &clusterSetConfig{aliasPrefix: DefaultClusterAliasPrefix}
+
+	for _, opt := range []ClusterSetOption{
+		WithClusterOptions(WithNoRetries()),
+		WithClusterOptions(WithHostAccess()),
+		WithClusterAliasPrefix("spoke"),
+	} {
+		opt(cfg)
+	}
+
+	require.Len(t, cfg.opts, 2)
+	require.Equal(t, "spoke-1", clusterAlias(cfg.aliasPrefix, 1))
+}
+
+func TestKubeconfigServerURLNetworkAddress(t *testing.T) {
+	cfg := &kubeconfigConfig{networkAddress: true}
+
+	_, err := (&EnvtestContainer{}).kubeconfigServerURL(t.Context(), cfg)
+	require.ErrorContains(t, err, "WithNetwork")
+
+	c := &EnvtestContainer{network: "envtest", networkAliases: []string{"cluster-0", "hub"}}
+
+	url, err := c.kubeconfigServerURL(t.Context(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://cluster-0:6443", url)
+}
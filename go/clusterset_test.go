@@ -0,0 +1,88 @@
+package envtest_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerClusterSet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	set, err := envtest.RunClusterSet(ctx, 2, envtest.WithClusterOptions(getEnvtestOptions()...))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, set.Terminate(context.Background()))
+	}()
+
+	hub, spoke := set.Clusters[0], set.Clusters[1]
+	require.Equal(t, "cluster-1", set.Alias(1))
+
+	hubCfg, err := hub.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	hubClient, err := client.New(hubCfg, client.Options{})
+	require.NoError(t, err)
+
+	// Register the spoke in the hub, the way multi-cluster controllers receive credentials
+	spokeKubeconfig, err := set.NetworkKubeconfig(ctx, 1)
+	require.NoError(t, err)
+	require.Contains(t, spokeKubeconfig, "server: https://cluster-1:6443")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "spoke-kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{"kubeconfig": []byte(spokeKubeconfig)},
+	}
+	require.NoError(t, hubClient.Create(ctx, secret))
+
+	stored := &corev1.Secret{}
+	require.NoError(t, hubClient.Get(ctx, client.ObjectKeyFromObject(secret), stored))
+
+	spokeCfg, err := clientcmd.RESTConfigFromKubeConfig(stored.Data["kubeconfig"])
+	require.NoError(t, err)
+
+	// The alias only resolves inside the network: route it to the mapped port while
+	// still verifying the serving certificate against the alias
+	spokeURL, err := spoke.APIServerURL(ctx)
+	require.NoError(t, err)
+
+	spokeAddr := strings.TrimPrefix(spokeURL, "https://")
+	dialer := &net.Dialer{}
+	spokeCfg.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, spokeAddr)
+	}
+
+	spokeClient, err := client.New(spokeCfg, client.Options{})
+	require.NoError(t, err)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "from-hub"}}
+	require.NoError(t, spokeClient.Create(ctx, ns))
+	require.NoError(t, hubClient.Get(ctx, client.ObjectKey{Name: "default"}, &corev1.Namespace{}))
+
+	err = hubClient.Get(ctx, client.ObjectKeyFromObject(ns), &corev1.Namespace{})
+	require.Error(t, err, "clusters must not share state")
+
+	// The clusters reach each other over the network
+	livez := "https://" + set.Alias(1) + ":6443/livez"
+
+	code, reader, err := hub.Exec(ctx, []string{"curl", "-sSk", "--max-time", "5", livez}, tcexec.Multiplexed())
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, 0, code, string(out))
+	require.Equal(t, "ok", string(out))
+}
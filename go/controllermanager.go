@@ -0,0 +1,41 @@
+package envtest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	// controllerManagerHealthz is the health check kube-controller-manager serves inside the container
+	controllerManagerHealthz = "https://127.0.0.1:10257/healthz"
+
+	// componentStartupTimeout bounds how long Run waits for a component next to the API server to become healthy
+	componentStartupTimeout = time.Minute
+)
+
+// checkControllers rejects malformed controller names of WithControllers
+func (c *config) checkControllers() error {
+	for _, name := range c.controllers {
+		if name == "" ||
+			strings.ContainsFunc(
+				name,
+				func(r rune) bool { return r == ',' || unicode.IsSpace(r) },
+			) {
+			return fmt.Errorf("invalid controller name %q", name)
+		}
+	}
+
+	return nil
+}
+
+// forComponentHealthz waits up to timeout for a component running next to the API server to report healthy
+// on the healthz URL, polled from inside the container
+func forComponentHealthz(healthz string, timeout time.Duration) wait.Strategy {
+	return wait.ForExec([]string{"curl", "-skf", healthz}).
+		WithStartupTimeout(timeout).
+		WithPollInterval(100 * time.Millisecond)
+}
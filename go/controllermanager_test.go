@@ -0,0 +1,105 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerWithControllerManager(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithControllerManager())...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kcm-test"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// The serviceaccount controller creates the default ServiceAccount of new namespaces
+	require.EventuallyWithT(t, func(collect *assert.CollectT) {
+		_, err := clientset.CoreV1().ServiceAccounts("kcm-test").Get(ctx, "default", metav1.GetOptions{})
+		assert.NoError(collect, err)
+	}, time.Minute, 200*time.Millisecond)
+
+	_, err = clientset.CoreV1().ConfigMaps("kcm-test").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config"},
+		Data:       map[string]string{"key": "value"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, clientset.CoreV1().Namespaces().Delete(ctx, "kcm-test", metav1.DeleteOptions{}))
+
+	// The namespace controller empties the namespace and removes its finalizer
+	require.EventuallyWithT(t, func(collect *assert.CollectT) {
+		_, err := clientset.CoreV1().Namespaces().Get(ctx, "kcm-test", metav1.GetOptions{})
+		assert.True(collect, apierrors.IsNotFound(err), "namespace still present: %v", err)
+	}, 2*time.Minute, 500*time.Millisecond)
+}
+
+func TestEnvtestContainerWithControllers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithControllers("garbagecollector"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	owner, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "owner"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "dependent",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: owner.Name, UID: owner.UID},
+			},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, clientset.CoreV1().ConfigMaps("default").Delete(ctx, "owner", metav1.DeleteOptions{}))
+
+	require.EventuallyWithT(t, func(collect *assert.CollectT) {
+		_, err := clientset.CoreV1().ConfigMaps("default").Get(ctx, "dependent", metav1.GetOptions{})
+		assert.True(collect, apierrors.IsNotFound(err), "dependent still present: %v", err)
+	}, 2*time.Minute, 500*time.Millisecond)
+
+	// Only the garbage collector runs, so new namespaces get no default ServiceAccount
+	_, err = clientset.CoreV1().ServiceAccounts("default").Get(ctx, "default", metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err), "unexpected default ServiceAccount: %v", err)
+}
@@ -0,0 +1,504 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// crdEstablishTimeout bounds how long CRDs installed by WithCRDs or InstallCRDs may take to be established by default
+const crdEstablishTimeout = 30 * time.Second
+
+// crdManifest is a CRD together with the file it was read from
+type crdManifest struct {
+	file string
+	crd  *apiextensionsv1.CustomResourceDefinition
+}
+
+// readCRDs reads the CRDs in the given files and directories. Directories are read non-recursively,
+// taking their .yaml, .yml and .json files in lexical order, the same way as controller-runtime envtest.
+func readCRDs(paths []string) ([]crdManifest, error) {
+	var crds []crdManifest
+
+	for _, path := range paths {
+		files, err := manifestFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			manifests, err := readCRDFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			crds = append(crds, manifests...)
+		}
+	}
+
+	return crds, nil
+}
+
+// manifestFiles returns the manifest files of a path: the file itself, or the .yaml, .yml and .json files of a directory
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests: %w", err)
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests: %w", err)
+	}
+
+	var files []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() &&
+			slices.Contains([]string{".yaml", ".yml", ".json"}, filepath.Ext(entry.Name())) {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// readCRDFile decodes the CRDs of a possibly multi-document manifest file
+func readCRDFile(file string) ([]crdManifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRDs: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return decodeCRDs(file, f)
+}
+
+// decodeCRDs decodes the CRDs of a possibly multi-document manifest read from source
+func decodeCRDs(source string, r io.Reader) ([]crdManifest, error) {
+	var crds []crdManifest
+
+	err := decodeDocuments(source, r, func(i int, doc *unstructured.Unstructured) error {
+		if doc.GetKind() != "CustomResourceDefinition" {
+			return fmt.Errorf(
+				"%s document %d is a %s, not a CustomResourceDefinition",
+				source,
+				i,
+				doc.GetKind(),
+			)
+		}
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(doc.Object, crd)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s document %d: %w", source, i, err)
+		}
+
+		crds = append(crds, crdManifest{file: source, crd: crd})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return crds, nil
+}
+
+// CRDInstallOptions are the CustomResourceDefinitions handled by InstallCRDs and UninstallCRDs
+type CRDInstallOptions struct {
+	// Paths are CRD manifest files or directories, read as for WithCRDs
+	Paths []string
+	// CRDs are CRD objects to handle in addition to the ones read from Paths
+	CRDs []*apiextensionsv1.CustomResourceDefinition
+	// MaxTime bounds how long to wait for the CRDs to be established or removed (default: 30s)
+	MaxTime time.Duration
+	// ConversionWebhook points the webhook conversion of the CRDs to a server on the test host.
+	// The container has to be started with WithHostAccess.
+	ConversionWebhook *CRDConversionWebhook
+}
+
+// CRDConversionWebhook is a conversion webhook server running on the test host, e.g. in the controller process
+type CRDConversionWebhook struct {
+	// Port is the port the webhook server listens on
+	Port int
+	// Path is the conversion endpoint (default: "/convert", as served by controller-runtime)
+	Path string
+	// CABundle is the PEM-encoded CA of the webhook serving certificate, e.g. certs.WebhookPKI.CACert
+	CABundle []byte
+}
+
+// apply rewrites the client config of the CRDs with the Webhook conversion strategy,
+// which usually references an in-cluster Service, to the webhook server on the host.
+// The CRDs are copied, so objects given by the caller are not modified.
+func (w *CRDConversionWebhook) apply(crds []crdManifest) []crdManifest {
+	path := w.Path
+	if path == "" {
+		path = "/convert"
+	}
+
+	url := hostURL("https", w.Port, path)
+	rewritten := make([]crdManifest, 0, len(crds))
+
+	for _, m := range crds {
+		if conversion := m.crd.Spec.Conversion; conversion != nil &&
+			conversion.Strategy == apiextensionsv1.WebhookConverter {
+			m.crd = m.crd.DeepCopy()
+
+			webhook := m.crd.Spec.Conversion.Webhook
+			if webhook == nil {
+				webhook = &apiextensionsv1.WebhookConversion{}
+				m.crd.Spec.Conversion.Webhook = webhook
+			}
+
+			webhook.ClientConfig = &apiextensionsv1.WebhookClientConfig{
+				URL:      &url,
+				CABundle: w.CABundle,
+			}
+
+			if len(webhook.ConversionReviewVersions) == 0 {
+				webhook.ConversionReviewVersions = []string{"v1"}
+			}
+		}
+
+		rewritten = append(rewritten, m)
+	}
+
+	return rewritten
+}
+
+// manifests returns the CRDs of the options together with where they come from
+func (o CRDInstallOptions) manifests() ([]crdManifest, error) {
+	crds, err := readCRDs(o.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, crd := range o.CRDs {
+		crds = append(crds, crdManifest{file: "CRDInstallOptions.CRDs", crd: crd})
+	}
+
+	if o.ConversionWebhook != nil {
+		crds = o.ConversionWebhook.apply(crds)
+	}
+
+	return crds, nil
+}
+
+// maxTime returns MaxTime or its default
+func (o CRDInstallOptions) maxTime() time.Duration {
+	if o.MaxTime > 0 {
+		return o.MaxTime
+	}
+
+	return crdEstablishTimeout
+}
+
+// InstallCRDs installs or, if they exist, updates the CRDs in place, e.g. to test an operator upgrade,
+// and waits until each of them is established and all its served versions are discoverable.
+// It returns the installed CRDs as stored by the API server.
+func (c *EnvtestContainer) InstallCRDs(
+	ctx context.Context,
+	opts CRDInstallOptions,
+) ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	crds, err := opts.manifests()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.installCRDs(ctx, crds, opts.maxTime())
+}
+
+// UninstallCRDs deletes the CRDs, along with all their custom resources, and waits until they are gone.
+// CRDs that do not exist are skipped.
+func (c *EnvtestContainer) UninstallCRDs(ctx context.Context, opts CRDInstallOptions) error {
+	crds, err := opts.manifests()
+	if err != nil {
+		return err
+	}
+
+	client, err := c.apiExtensionsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	api := client.ApiextensionsV1().CustomResourceDefinitions()
+
+	for _, m := range crds {
+		err := c.retry(ctx, "uninstall CRD", func(ctx context.Context) error {
+			err := api.Delete(ctx, m.crd.Name, metav1.DeleteOptions{})
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to uninstall CRD %s from %s: %w", m.crd.Name, m.file, err)
+		}
+	}
+
+	for _, m := range crds {
+		err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, opts.maxTime(), true,
+			func(ctx context.Context) (bool, error) {
+				_, err := api.Get(ctx, m.crd.Name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					return true, nil
+				}
+
+				return false, err
+			})
+		if err != nil {
+			return fmt.Errorf("CRD %s from %s was not removed: %w", m.crd.Name, m.file, err)
+		}
+	}
+
+	return nil
+}
+
+// installCRDs creates or updates the CRDs and waits until all of them are established and served
+func (c *EnvtestContainer) installCRDs(
+	ctx context.Context,
+	crds []crdManifest,
+	timeout time.Duration,
+) ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	client, err := c.apiExtensionsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range crds {
+		err := c.retry(ctx, "install CRD", func(ctx context.Context) error {
+			return createOrUpdateCRD(ctx, client, m.crd)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to install CRD %s from %s: %w", m.crd.Name, m.file, err)
+		}
+	}
+
+	names := make([]string, 0, len(crds))
+	for _, m := range crds {
+		names = append(names, m.crd.Name)
+	}
+
+	return waitForCRDs(ctx, client, names, timeout)
+}
+
+// WaitForCRDsEstablished waits until the named CRDs are established and all their served versions are discoverable.
+// If that takes longer than timeout, the error lists the failing status conditions of each pending CRD,
+// e.g. NonStructuralSchema, instead of leaving the test hanging until its context is done.
+func (c *EnvtestContainer) WaitForCRDsEstablished(
+	ctx context.Context,
+	names []string,
+	timeout time.Duration,
+) error {
+	client, err := c.apiExtensionsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForCRDs(ctx, client, names, timeout)
+
+	return err
+}
+
+// waitForCRDs waits for the named CRDs as WaitForCRDsEstablished does and returns them in the same order
+func waitForCRDs(
+	ctx context.Context,
+	client apiextensionsclientset.Interface,
+	names []string,
+	timeout time.Duration,
+) ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	api := client.ApiextensionsV1().CustomResourceDefinitions()
+
+	// The last seen state of each CRD, nil while it does not exist
+	crds := make(map[string]*apiextensionsv1.CustomResourceDefinition, len(names))
+	pending := slices.Clone(names)
+
+	err := wait.PollUntilContextTimeout(
+		ctx,
+		100*time.Millisecond,
+		timeout,
+		true,
+		func(ctx context.Context) (bool, error) {
+			var remaining []string
+
+			for _, name := range pending {
+				crd, err := api.Get(ctx, name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					remaining = append(remaining, name)
+
+					continue
+				}
+
+				if err != nil {
+					return false, err
+				}
+
+				crds[name] = crd
+
+				established, err := crdEstablished(crd)
+				if err != nil {
+					return false, fmt.Errorf("CRD %s: %w", name, err)
+				}
+
+				if !established || !crdServed(client, crd) {
+					remaining = append(remaining, name)
+				}
+			}
+
+			pending = remaining
+
+			return len(pending) == 0, nil
+		},
+	)
+	if wait.Interrupted(err) {
+		details := make([]string, 0, len(pending))
+		for _, name := range pending {
+			details = append(details, name+": "+crdPendingReason(crds[name]))
+		}
+
+		return nil, fmt.Errorf(
+			"CRDs were not established within %s (%s): %w",
+			timeout,
+			strings.Join(details, "; "),
+			err,
+		)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	established := make([]*apiextensionsv1.CustomResourceDefinition, 0, len(names))
+	for _, name := range names {
+		established = append(established, crds[name])
+	}
+
+	return established, nil
+}
+
+// crdPendingReason describes why the CRD is not usable yet from its status conditions
+func crdPendingReason(crd *apiextensionsv1.CustomResourceDefinition) string {
+	if crd == nil {
+		return "not found"
+	}
+
+	var problems []string
+
+	for _, cond := range crd.Status.Conditions {
+		var failing bool
+
+		switch cond.Type {
+		case apiextensionsv1.NonStructuralSchema, apiextensionsv1.Terminating:
+			failing = cond.Status == apiextensionsv1.ConditionTrue
+		case apiextensionsv1.Established, apiextensionsv1.NamesAccepted:
+			failing = cond.Status != apiextensionsv1.ConditionTrue
+		default:
+			continue
+		}
+
+		if failing {
+			problems = append(
+				problems,
+				fmt.Sprintf("%s=%s %s: %s", cond.Type, cond.Status, cond.Reason, cond.Message),
+			)
+		}
+	}
+
+	switch {
+	case len(problems) > 0:
+		return strings.Join(problems, ", ")
+	case len(crd.Status.Conditions) == 0:
+		return "no status conditions"
+	default:
+		return "served versions are not discoverable"
+	}
+}
+
+// createOrUpdateCRD creates the CRD or replaces the spec of the existing one
+func createOrUpdateCRD(
+	ctx context.Context,
+	client apiextensionsclientset.Interface,
+	crd *apiextensionsv1.CustomResourceDefinition,
+) error {
+	api := client.ApiextensionsV1().CustomResourceDefinitions()
+
+	_, err := api.Create(ctx, crd, metav1.CreateOptions{})
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing, err := api.Get(ctx, crd.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// The given object is not modified, it may be reused by the caller
+	updated := crd.DeepCopy()
+	updated.ResourceVersion = existing.ResourceVersion
+
+	_, err = api.Update(ctx, updated, metav1.UpdateOptions{})
+
+	return err
+}
+
+// crdServed reports whether all served versions of the CRD are discoverable, which lags a bit behind
+// the Established condition when versions are added to an existing CRD
+func crdServed(
+	client apiextensionsclientset.Interface,
+	crd *apiextensionsv1.CustomResourceDefinition,
+) bool {
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+
+		resources, err := client.Discovery().
+			ServerResourcesForGroupVersion(crd.Spec.Group + "/" + version.Name)
+		if err != nil {
+			return false
+		}
+
+		if !slices.ContainsFunc(resources.APIResources, func(r metav1.APIResource) bool {
+			return r.Name == crd.Spec.Names.Plural
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// crdEstablished reports whether the CRD is established, failing if its names were rejected
+func crdEstablished(crd *apiextensionsv1.CustomResourceDefinition) (bool, error) {
+	for _, cond := range crd.Status.Conditions {
+		switch status := cond.Status; {
+		case cond.Type == apiextensionsv1.NamesAccepted && status == apiextensionsv1.ConditionFalse:
+			return false, fmt.Errorf("names not accepted: %s", cond.Message)
+		case cond.Type == apiextensionsv1.Established && status == apiextensionsv1.ConditionTrue:
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
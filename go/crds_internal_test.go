@@ -0,0 +1,157 @@
+package envtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestReadCRDs(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a manifest"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "things.json"), []byte(`{
+  "apiVersion": "apiextensions.k8s.io/v1",
+  "kind": "CustomResourceDefinition",
+  "metadata": {"name": "things.crds.example.com"}
+}`), 0o644))
+
+	crds, err := readCRDs([]string{"testdata/crds", dir})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(crds))
+	for _, m := range crds {
+		names = append(names, m.crd.Name)
+	}
+
+	require.Equal(t, []string{"widgets.crds.example.com", "gadgets.crds.example.com", "things.crds.example.com"}, names)
+	require.Equal(t, filepath.Join("testdata", "crds", "widgets.yaml"), crds[0].file)
+	require.Equal(t, "Widget", crds[0].crd.Spec.Names.Kind)
+
+	crds, err = readCRDs([]string{"testdata/crds/widgets.yaml"})
+	require.NoError(t, err)
+	require.Len(t, crds, 2)
+}
+
+func TestReadCRDsErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "configmap.yaml")
+
+	require.NoError(t, os.WriteFile(file, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"), 0o644))
+
+	_, err := readCRDs([]string{file})
+	require.ErrorContains(t, err, file+" document 0 is a ConfigMap")
+
+	_, err = readCRDs([]string{filepath.Join(dir, "missing")})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCRDEstablished(t *testing.T) {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+
+	established, err := crdEstablished(crd)
+	require.NoError(t, err)
+	require.False(t, established)
+
+	crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+		{Type: apiextensionsv1.NamesAccepted, Status: apiextensionsv1.ConditionTrue},
+		{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue},
+	}
+
+	established, err = crdEstablished(crd)
+	require.NoError(t, err)
+	require.True(t, established)
+
+	crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+		{Type: apiextensionsv1.NamesAccepted, Status: apiextensionsv1.ConditionFalse, Message: "plural is already in use"},
+	}
+
+	_, err = crdEstablished(crd)
+	require.ErrorContains(t, err, "plural is already in use")
+}
+
+func TestCRDInstallOptions(t *testing.T) {
+	extra := &apiextensionsv1.CustomResourceDefinition{}
+	extra.Name = "things.crds.example.com"
+
+	opts := CRDInstallOptions{Paths: []string{"testdata/crds/widgets.yaml"}, CRDs: []*apiextensionsv1.CustomResourceDefinition{extra}}
+
+	crds, err := opts.manifests()
+	require.NoError(t, err)
+	require.Len(t, crds, 3)
+	require.Same(t, extra, crds[2].crd)
+	require.Equal(t, "CRDInstallOptions.CRDs", crds[2].file)
+
+	require.Equal(t, crdEstablishTimeout, opts.maxTime())
+
+	opts.MaxTime = time.Minute
+	require.Equal(t, time.Minute, opts.maxTime())
+}
+
+func TestCRDPendingReason(t *testing.T) {
+	require.Equal(t, "not found", crdPendingReason(nil))
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.Equal(t, "no status conditions", crdPendingReason(crd))
+
+	crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+		{Type: apiextensionsv1.NamesAccepted, Status: apiextensionsv1.ConditionTrue},
+		{
+			Type:    apiextensionsv1.NonStructuralSchema,
+			Status:  apiextensionsv1.ConditionTrue,
+			Reason:  "Violations",
+			Message: "spec.versions[0].schema.openAPIV3Schema.type: Required value",
+		},
+		{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionFalse, Reason: "Installing", Message: "the initial names have been accepted"},
+	}
+
+	reason := crdPendingReason(crd)
+	require.Contains(t, reason, "NonStructuralSchema=True Violations: spec.versions[0].schema.openAPIV3Schema.type: Required value")
+	require.Contains(t, reason, "Established=False Installing: the initial names have been accepted")
+	require.NotContains(t, reason, "NamesAccepted")
+
+	crd.Status.Conditions = crd.Status.Conditions[:1]
+	require.Equal(t, "served versions are not discoverable", crdPendingReason(crd))
+}
+
+func TestCRDConversionWebhook(t *testing.T) {
+	path := "/convert"
+
+	converted := &apiextensionsv1.CustomResourceDefinition{}
+	converted.Name = "things.crds.example.com"
+	converted.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{
+		Strategy: apiextensionsv1.WebhookConverter,
+		Webhook: &apiextensionsv1.WebhookConversion{
+			ClientConfig: &apiextensionsv1.WebhookClientConfig{
+				Service: &apiextensionsv1.ServiceReference{Namespace: "system", Name: "webhook-service", Path: &path},
+			},
+		},
+	}
+
+	plain := &apiextensionsv1.CustomResourceDefinition{}
+	plain.Name = "widgets.crds.example.com"
+
+	webhook := &CRDConversionWebhook{Port: 9443, CABundle: []byte("ca")}
+	crds := webhook.apply([]crdManifest{{file: "things.yaml", crd: converted}, {file: "widgets.yaml", crd: plain}})
+
+	require.Len(t, crds, 2)
+	require.Equal(t, "things.yaml", crds[0].file)
+	require.Same(t, plain, crds[1].crd)
+
+	got := crds[0].crd.Spec.Conversion.Webhook
+	require.Equal(t, &apiextensionsv1.WebhookClientConfig{URL: ptr.To(hostURL("https", 9443, "/convert")), CABundle: []byte("ca")}, got.ClientConfig)
+	require.Equal(t, []string{"v1"}, got.ConversionReviewVersions)
+
+	// The given CRD is left as is
+	require.NotNil(t, converted.Spec.Conversion.Webhook.ClientConfig.Service)
+	require.Empty(t, converted.Spec.Conversion.Webhook.ConversionReviewVersions)
+
+	webhook.Path = "/convert-things"
+	crds = webhook.apply([]crdManifest{{crd: converted}})
+	require.Equal(t, hostURL("https", 9443, "/convert-things"), *crds[0].crd.Spec.Conversion.Webhook.ClientConfig.URL)
+}
@@ -0,0 +1,392 @@
+package envtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerWithCRDs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithCRDs("testdata/crds"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	// The CRDs are established when Run returns, so custom resources can be created right away
+	widget := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"color": "blue"}}}
+	widget.SetAPIVersion("crds.example.com/v1")
+	widget.SetKind("Widget")
+	widget.SetName("blue")
+	widget.SetNamespace("default")
+
+	require.NoError(t, cl.Create(ctx, widget))
+
+	gadget := &unstructured.Unstructured{}
+	gadget.SetAPIVersion("crds.example.com/v1")
+	gadget.SetKind("Gadget")
+	gadget.SetName("cluster-scoped")
+
+	require.NoError(t, cl.Create(ctx, gadget))
+
+	got := &unstructured.Unstructured{}
+	got.SetAPIVersion("crds.example.com/v1")
+	got.SetKind("Widget")
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(widget), got))
+
+	color, _, err := unstructured.NestedString(got.Object, "spec", "color")
+	require.NoError(t, err)
+	require.Equal(t, "blue", color)
+}
+
+func TestEnvtestContainerWithInvalidCRD(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// Served versions need a schema
+	file := filepath.Join(t.TempDir(), "broken.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: brokens.crds.example.com
+spec:
+  group: crds.example.com
+  names:
+    kind: Broken
+    plural: brokens
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+`), 0o644))
+
+	t.Run("terminated", func(t *testing.T) {
+		before := testContainerIDs(ctx, t)
+
+		_, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithCRDs(file))...)
+		require.ErrorContains(t, err, file)
+		require.ErrorContains(t, err, "schema")
+
+		// The container started before the CRD was rejected, Run terminates it
+		for id := range testContainerIDs(ctx, t) {
+			require.Contains(t, before, id, "container %s should be terminated", id)
+		}
+	})
+
+	t.Run("reused", func(t *testing.T) {
+		before := testContainerIDs(ctx, t)
+
+		opts := append(getEnvtestOptions(), envtest.WithReuse("envtest-invalid-crd"), envtest.WithCRDs(file))
+
+		_, err := envtest.Run(ctx, opts...)
+		require.ErrorContains(t, err, "schema")
+
+		// The container is shared with other packages, so it is left running
+		var started []string
+
+		for id := range testContainerIDs(ctx, t) {
+			if !before[id] {
+				started = append(started, id)
+			}
+		}
+
+		require.Len(t, started, 1)
+
+		cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+		require.NoError(t, err)
+
+		defer cli.Close()
+
+		inspect, err := cli.ContainerInspect(ctx, started[0])
+		require.NoError(t, err)
+		require.True(t, inspect.State.Running, "reused container should keep running")
+
+		err = cli.ContainerRemove(ctx, started[0], container.RemoveOptions{Force: true, RemoveVolumes: true})
+		require.NoError(t, err)
+	})
+}
+
+// testContainerIDs lists the containers started by testcontainers, except the reaper
+func testContainerIDs(ctx context.Context, t *testing.T) map[string]bool {
+	t.Helper()
+
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	require.NoError(t, err)
+
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "org.testcontainers.lang=go")),
+	})
+	require.NoError(t, err)
+
+	ids := map[string]bool{}
+
+	for _, c := range containers {
+		if c.Labels["org.testcontainers.ryuk"] == "true" || c.Labels["org.testcontainers.reaper"] == "true" {
+			continue
+		}
+
+		ids[c.ID] = true
+	}
+
+	return ids
+}
+
+func TestEnvtestContainerInstallCRDs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	crds, err := c.InstallCRDs(ctx, envtest.CRDInstallOptions{
+		Paths: []string{"testdata/crds"},
+		CRDs:  []*apiextensionsv1.CustomResourceDefinition{scalerCRD()},
+	})
+	require.NoError(t, err)
+	require.Len(t, crds, 3)
+	require.NotEmpty(t, crds[2].ResourceVersion)
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, cl.Create(ctx, scaler("v1", map[string]any{"size": "small"})))
+
+	// Re-installing updates the CRD in place, here serving an additional version
+	upgraded := scalerCRD()
+	v2 := *upgraded.Spec.Versions[0].DeepCopy()
+	v2.Name = "v2"
+	v2.Storage = false
+	upgraded.Spec.Versions = append(upgraded.Spec.Versions, v2)
+
+	crds, err = c.InstallCRDs(ctx, envtest.CRDInstallOptions{CRDs: []*apiextensionsv1.CustomResourceDefinition{upgraded}})
+	require.NoError(t, err)
+	require.Len(t, crds[0].Spec.Versions, 2)
+	require.Empty(t, upgraded.ResourceVersion, "the given CRD was modified")
+
+	// The new version is served right away and the existing resource survived the update
+	got := &unstructured.Unstructured{}
+	got.SetAPIVersion("schema.example.com/v2")
+	got.SetKind("Scaler")
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "v1"}, got))
+
+	err = c.UninstallCRDs(ctx, envtest.CRDInstallOptions{
+		Paths: []string{"testdata/crds"},
+		CRDs:  []*apiextensionsv1.CustomResourceDefinition{upgraded},
+	})
+	require.NoError(t, err)
+
+	crdClient, err := c.GetAPIExtensionsClient(ctx)
+	require.NoError(t, err)
+
+	_, err = crdClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, upgraded.Name, metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err), "CRD was not removed: %v", err)
+
+	err = c.WaitForCRDsEstablished(ctx, []string{upgraded.Name}, time.Second)
+	require.ErrorContains(t, err, upgraded.Name+": not found")
+
+	// Uninstalling CRDs that are already gone is not an error
+	require.NoError(t, c.UninstallCRDs(ctx, envtest.CRDInstallOptions{Paths: []string{"testdata/crds"}}))
+}
+
+// thingCRD returns a CRD serving v1alpha1 and v1beta1, converted by a webhook behind an in-cluster Service
+// as generated by kubebuilder
+func thingCRD() *apiextensionsv1.CustomResourceDefinition {
+	version := func(name string, storage bool) apiextensionsv1.CustomResourceDefinitionVersion {
+		return apiextensionsv1.CustomResourceDefinitionVersion{
+			Name:    name,
+			Served:  true,
+			Storage: storage,
+			Schema: &apiextensionsv1.CustomResourceValidation{
+				OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"spec": {Type: "object", XPreserveUnknownFields: ptr.To(true)},
+					},
+				},
+			},
+		}
+	}
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "things.conversion.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "conversion.example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:   "things",
+				Singular: "thing",
+				Kind:     "Thing",
+				ListKind: "ThingList",
+			},
+			Scope:    apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{version("v1alpha1", true), version("v1beta1", false)},
+			Conversion: &apiextensionsv1.CustomResourceConversion{
+				Strategy: apiextensionsv1.WebhookConverter,
+				Webhook: &apiextensionsv1.WebhookConversion{
+					ClientConfig: &apiextensionsv1.WebhookClientConfig{
+						Service: &apiextensionsv1.ServiceReference{
+							Namespace: "system",
+							Name:      "webhook-service",
+							Path:      ptr.To("/convert"),
+						},
+					},
+					ConversionReviewVersions: []string{"v1"},
+				},
+			},
+		},
+	}
+}
+
+// convertThing renames spec.name in v1alpha1 to spec.displayName in v1beta1 and back
+func convertThing(w http.ResponseWriter, r *http.Request) {
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	from, to := "name", "displayName"
+	if review.Request.DesiredAPIVersion == "conversion.example.com/v1alpha1" {
+		from, to = to, from
+	}
+
+	review.Response = &apiextensionsv1.ConversionResponse{
+		UID:    review.Request.UID,
+		Result: metav1.Status{Status: metav1.StatusSuccess},
+	}
+
+	for _, raw := range review.Request.Objects {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		obj.SetAPIVersion(review.Request.DesiredAPIVersion)
+
+		if value, found, _ := unstructured.NestedString(obj.Object, "spec", from); found {
+			unstructured.RemoveNestedField(obj.Object, "spec", from)
+			_ = unstructured.SetNestedField(obj.Object, value, "spec", to)
+		}
+
+		converted, err := obj.MarshalJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		review.Response.ConvertedObjects = append(review.Response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(review)
+}
+
+func TestEnvtestContainerCRDConversionWebhook(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	pki, err := certs.NewWebhookPKI()
+	require.NoError(t, err)
+
+	tlsConfig, err := pki.TLSConfig()
+	require.NoError(t, err)
+
+	// Listen on all interfaces, the container reaches the host via the bridge gateway
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", convertThing)
+
+	srv := &http.Server{Handler: mux, TLSConfig: tlsConfig, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() { _ = srv.ServeTLS(listener, "", "") }()
+
+	defer func() { _ = srv.Close() }()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithHostAccess())...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	crd := thingCRD()
+
+	crds, err := c.InstallCRDs(ctx, envtest.CRDInstallOptions{
+		CRDs: []*apiextensionsv1.CustomResourceDefinition{crd},
+		ConversionWebhook: &envtest.CRDConversionWebhook{
+			Port:     listener.Addr().(*net.TCPAddr).Port,
+			CABundle: pki.CACert,
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, crds[0].Spec.Conversion.Webhook.ClientConfig.URL)
+	require.Nil(t, crds[0].Spec.Conversion.Webhook.ClientConfig.Service)
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	thing := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{"name": "gizmo"}}}
+	thing.SetAPIVersion("conversion.example.com/v1alpha1")
+	thing.SetKind("Thing")
+	thing.SetName("gizmo")
+	thing.SetNamespace("default")
+
+	require.NoError(t, cl.Create(ctx, thing))
+
+	// Reading the stored v1alpha1 object as v1beta1 goes through the webhook on the host
+	got := &unstructured.Unstructured{}
+	got.SetAPIVersion("conversion.example.com/v1beta1")
+	got.SetKind("Thing")
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(thing), got))
+
+	displayName, _, err := unstructured.NestedString(got.Object, "spec", "displayName")
+	require.NoError(t, err)
+	require.Equal(t, "gizmo", displayName)
+}
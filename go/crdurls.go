@@ -0,0 +1,180 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// crdCacheSubdir is where downloaded CRD manifests are cached, under os.UserCacheDir
+const crdCacheSubdir = "testcontainers-envtest/crds"
+
+// HTTPStatusError is returned when downloading a CRD manifest fails with a non-200 response
+type HTTPStatusError struct {
+	// URL is the manifest URL
+	URL string
+	// StatusCode is the HTTP response status code
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf(
+		"failed to download %s: %d %s",
+		e.URL,
+		e.StatusCode,
+		http.StatusText(e.StatusCode),
+	)
+}
+
+// crdCacheDir returns the directory caching downloaded CRD manifests, or "" if there is no user cache directory
+func crdCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, crdCacheSubdir)
+}
+
+// downloadCRDs downloads and decodes the CRD manifests at the given URLs. Manifests are cached in cacheDir
+// together with their ETag and only downloaded again when changed; the cached copy is used when the server
+// cannot be reached. An empty cacheDir disables caching.
+func downloadCRDs(
+	ctx context.Context,
+	httpClient *http.Client,
+	cacheDir string,
+	urls []string,
+) ([]crdManifest, error) {
+	var crds []crdManifest
+
+	for _, url := range urls {
+		manifest, err := downloadCRDManifest(ctx, httpClient, cacheDir, url)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests, err := decodeCRDs(url, bytes.NewReader(manifest))
+		if err != nil {
+			return nil, err
+		}
+
+		crds = append(crds, manifests...)
+	}
+
+	return crds, nil
+}
+
+// downloadCRDManifest returns the manifest at url, revalidating the cached copy if there is one
+func downloadCRDManifest(
+	ctx context.Context,
+	httpClient *http.Client,
+	cacheDir, url string,
+) ([]byte, error) {
+	var (
+		manifestPath, etagPath, etag string
+		cached                       []byte
+	)
+
+	if cacheDir != "" {
+		sum := sha256.Sum256([]byte(url))
+		key := hex.EncodeToString(sum[:])
+		manifestPath = filepath.Join(cacheDir, key+".yaml")
+		etagPath = filepath.Join(cacheDir, key+".etag")
+
+		// A cached manifest is only used together with its ETag
+		if content, err := os.ReadFile(manifestPath); err == nil {
+			if tag, err := os.ReadFile(etagPath); err == nil {
+				cached, etag = content, string(tag)
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	if cached != nil {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// Offline runs fall back to the cache, unless the caller gave up
+		if cached != nil && ctx.Err() == nil {
+			return cached, nil
+		}
+
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	manifest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	if etag := resp.Header.Get("ETag"); manifestPath != "" && etag != "" {
+		// Caching is best effort, a read-only cache directory should not fail the test
+		_ = cacheCRDManifest(cacheDir, manifestPath, etagPath, manifest, etag)
+	}
+
+	return manifest, nil
+}
+
+// cacheCRDManifest stores a downloaded manifest and its ETag, replacing the files atomically
+// so that concurrent test processes never read a partial manifest
+func cacheCRDManifest(cacheDir, manifestPath, etagPath string, manifest []byte, etag string) error {
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return err
+	}
+
+	// The ETag is removed first, so an interrupted update leaves no stale pair behind
+	if err := os.Remove(etagPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := writeFileAtomic(cacheDir, manifestPath, manifest); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(cacheDir, etagPath, []byte(etag))
+}
+
+// writeFileAtomic writes a file through a temporary file in dir renamed over path
+func writeFileAtomic(dir, path string, content []byte) error {
+	file, err := os.CreateTemp(dir, filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+
+	return err
+}
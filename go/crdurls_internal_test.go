@@ -0,0 +1,99 @@
+package envtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// crdServer serves the test CRDs with an ETag, counting full downloads
+func crdServer(t *testing.T, downloads *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	manifest, err := os.ReadFile("testdata/crds/widgets.yaml")
+	require.NoError(t, err)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/widgets.yaml":
+			w.Header().Set("ETag", `"v1"`)
+
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+
+				return
+			}
+
+			downloads.Add(1)
+
+			_, _ = w.Write(manifest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestDownloadCRDs(t *testing.T) {
+	var downloads atomic.Int32
+
+	srv := crdServer(t, &downloads)
+	cacheDir := t.TempDir()
+	url := srv.URL + "/widgets.yaml"
+
+	crds, err := downloadCRDs(t.Context(), srv.Client(), cacheDir, []string{url})
+	require.NoError(t, err)
+	require.Len(t, crds, 2)
+	require.Equal(t, "widgets.crds.example.com", crds[0].crd.Name)
+	require.Equal(t, url, crds[0].file)
+
+	// The cached copy is revalidated instead of downloaded again
+	crds, err = downloadCRDs(t.Context(), srv.Client(), cacheDir, []string{url})
+	require.NoError(t, err)
+	require.Len(t, crds, 2)
+	require.Equal(t, int32(1), downloads.Load())
+
+	// Offline runs use the cache
+	srv.Close()
+
+	crds, err = downloadCRDs(t.Context(), srv.Client(), cacheDir, []string{url})
+	require.NoError(t, err)
+	require.Len(t, crds, 2)
+
+	// Unless the download was canceled
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err = downloadCRDs(ctx, srv.Client(), cacheDir, []string{url})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDownloadCRDsErrors(t *testing.T) {
+	var downloads atomic.Int32
+
+	srv := crdServer(t, &downloads)
+	url := srv.URL + "/missing.yaml"
+
+	_, err := downloadCRDs(t.Context(), srv.Client(), t.TempDir(), []string{url})
+
+	var statusErr *HTTPStatusError
+
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	require.ErrorContains(t, err, url)
+
+	// Without a cache, every run downloads
+	for range 2 {
+		_, err = downloadCRDs(t.Context(), srv.Client(), "", []string{srv.URL + "/widgets.yaml"})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, int32(2), downloads.Load())
+}
@@ -0,0 +1,402 @@
+package envtest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// certsDir is where the entrypoint generates the API server certificates
+	certsDir = "/tmp/envtest/certs"
+
+	// clientCAPath is the --client-ca-file of the API server, which reloads it when it changes.
+	// It starts as a copy of the CA generated at startup, which also signs the serving certificate.
+	clientCAPath = certsDir + "/client-ca.crt"
+
+	// startupCAPath is the CA generated at startup
+	startupCAPath = certsDir + "/ca.crt"
+
+	// startupCAKeyPath is the key of the CA generated at startup
+	startupCAKeyPath = certsDir + "/ca.key"
+
+	// adminCertValidity matches the validity of the certificates generated by the entrypoint
+	adminCertValidity = 365 * 24 * time.Hour
+
+	// credentialsReloadTimeout bounds how long the API server may take to reload its client CA
+	credentialsReloadTimeout = 90 * time.Second
+)
+
+var (
+	// adminCertPaths are the container files holding the admin client certificate
+	adminCertPaths = []string{certsDir + "/client.crt", "/tmp/client.crt"}
+
+	// adminKeyPaths are the container files holding the admin client key
+	adminKeyPaths = []string{certsDir + "/client.key", "/tmp/client.key"}
+)
+
+// credentialsConfig holds the configuration for RegenerateAdminCredentials
+type credentialsConfig struct {
+	newClientCA bool
+}
+
+// CredentialsOption is a functional option for configuring RegenerateAdminCredentials
+type CredentialsOption func(*credentialsConfig)
+
+// WithNewClientCA replaces the client CA trusted by the API server, so certificates issued before are rejected.
+// The serving certificate, and so the CA clients verify the server with, is left as is. The components running
+// in the container, i.e. WithControllerManager, WithScheduler and WithKWOK, keep the admin certificate they
+// started with and so are rejected as well until the container is restarted.
+func WithNewClientCA() CredentialsOption {
+	return func(c *credentialsConfig) {
+		c.newClientCA = true
+	}
+}
+
+// clientCA is the CA signing admin client certificates
+type clientCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// RegenerateAdminCredentials mints a new admin client certificate and updates the kubeconfig in the container,
+// so Kubeconfig, RESTConfig and the helpers built on them use the new credentials from now on.
+// The previous kubeconfig is retained for PreviousRESTConfig and OldCredentialsRejected.
+// Without WithNewClientCA the previous certificate stays valid, as the API server has no revocation.
+// It returns once the API server accepts the new credentials.
+func (c *EnvtestContainer) RegenerateAdminCredentials(
+	ctx context.Context,
+	opts ...CredentialsOption,
+) error {
+	cfg := &credentialsConfig{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	previous, err := c.Kubeconfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current kubeconfig: %w", err)
+	}
+
+	raw, err := c.readContainerFile(ctx, KubeconfigPath)
+	if err != nil {
+		return err
+	}
+
+	apiConfig, err := clientcmd.Load(raw)
+	if err != nil {
+		return fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	ca, err := c.currentClientCA(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cfg.newClientCA {
+		var caPEM []byte
+
+		ca, caPEM, err = newClientCA()
+		if err != nil {
+			return err
+		}
+
+		if err := c.CopyToContainer(ctx, caPEM, clientCAPath, 0o644); err != nil {
+			return fmt.Errorf("failed to write client CA: %w", err)
+		}
+	}
+
+	certPEM, keyPEM, err := newAdminCert(ca)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range apiConfig.AuthInfos {
+		user.ClientCertificateData = certPEM
+		user.ClientKeyData = keyPEM
+	}
+
+	kubeconfig, err := clientcmd.Write(*apiConfig)
+	if err != nil {
+		return fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+
+	for _, path := range adminCertPaths {
+		if err := c.CopyToContainer(ctx, certPEM, path, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	for _, path := range adminKeyPaths {
+		if err := c.CopyToContainer(ctx, keyPEM, path, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	if err := c.CopyToContainer(ctx, kubeconfig, KubeconfigPath, 0o644); err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+
+	c.invalidateKubeconfig()
+
+	c.mu.Lock()
+	c.previousKubeconfig = previous
+	c.clientCA = ca
+	c.mu.Unlock()
+
+	return c.waitForCredentials(ctx)
+}
+
+// PreviousRESTConfig returns a *rest.Config with the admin credentials replaced by the last
+// RegenerateAdminCredentials call, for asserting that they no longer work
+func (c *EnvtestContainer) PreviousRESTConfig() (*rest.Config, error) {
+	c.mu.Lock()
+	previous := c.previousKubeconfig
+	c.mu.Unlock()
+
+	if previous == "" {
+		return nil, errors.New("admin credentials were not regenerated")
+	}
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(previous))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse previous kubeconfig: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// OldCredentialsRejected reports whether the API server refuses to authenticate the admin credentials
+// replaced by the last RegenerateAdminCredentials call
+func (c *EnvtestContainer) OldCredentialsRejected(ctx context.Context) (bool, error) {
+	cfg, err := c.PreviousRESTConfig()
+	if err != nil {
+		return false, err
+	}
+
+	accepted, err := credentialsAccepted(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+
+	return !accepted, nil
+}
+
+// waitForCredentials waits until the API server has reloaded the client CA and accepts the current credentials
+func (c *EnvtestContainer) waitForCredentials(ctx context.Context) error {
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, 500*time.Millisecond, credentialsReloadTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			return credentialsAccepted(ctx, cfg)
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("API server did not accept the regenerated admin credentials: %w", err)
+	}
+
+	return nil
+}
+
+// currentClientCA returns the CA of the last rotation or, before any rotation since the container started,
+// the one generated at startup
+func (c *EnvtestContainer) currentClientCA(ctx context.Context) (*clientCA, error) {
+	c.mu.Lock()
+	ca := c.clientCA
+	c.mu.Unlock()
+
+	if ca != nil {
+		return ca, nil
+	}
+
+	certPEM, err := c.readContainerFile(ctx, startupCAPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := c.readContainerFile(ctx, startupCAKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseClientCA(certPEM, keyPEM)
+}
+
+// resetCredentials forgets the rotated credentials after a restart, which generates a new CA and admin certificate
+func (c *EnvtestContainer) resetCredentials() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.previousKubeconfig, c.clientCA = "", nil
+}
+
+// credentialsAccepted makes an authenticated request and reports whether the credentials were accepted
+func credentialsAccepted(ctx context.Context, cfg *rest.Config) (bool, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return false, fmt.Errorf("failed to create kubernetes clientset: %w", err)
+	}
+
+	_, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 1})
+	if err == nil {
+		return true, nil
+	}
+
+	if credentialsRejection(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// credentialsRejection reports whether the error is an authentication failure rather than an outage
+func credentialsRejection(err error) bool {
+	var certErr *tls.CertificateVerificationError
+
+	return apierrors.IsUnauthorized(err) || errors.As(err, &certErr) ||
+		strings.Contains(err.Error(), "remote error: tls")
+}
+
+// parseClientCA decodes a PEM-encoded CA certificate and its private key
+func parseClientCA(certPEM, keyPEM []byte) (*clientCA, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, errors.New("client CA certificate is not PEM-encoded")
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client CA certificate: %w", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("client CA key is not PEM-encoded")
+	}
+
+	key, err := parsePrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client CA key: %w", err)
+	}
+
+	return &clientCA{cert: cert, key: key}, nil
+}
+
+// parsePrivateKey decodes a PKCS#8, PKCS#1 or SEC 1 private key
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return x509.ParseECPrivateKey(der)
+}
+
+// newClientCA generates a self-signed client CA and returns it together with its PEM-encoded certificate
+func newClientCA() (*clientCA, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate client CA key: %w", err)
+	}
+
+	template, err := certTemplate(pkix.Name{CommonName: "envtest-client-ca"})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create client CA certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse client CA certificate: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	return &clientCA{cert: cert, key: key}, certPEM, nil
+}
+
+// newAdminCert issues a system:masters client certificate and returns it with its key, PEM-encoded
+func newAdminCert(ca *clientCA) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate admin key: %w", err)
+	}
+
+	template, err := certTemplate(
+		pkix.Name{CommonName: "admin", Organization: []string{"system:masters"}},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create admin certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode admin key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// certTemplate returns a certificate template with a random serial number
+func certTemplate(subject pkix.Name) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(adminCertValidity),
+	}, nil
+}
@@ -0,0 +1,143 @@
+package envtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewAdminCert(t *testing.T) {
+	ca, caPEM, err := newClientCA()
+	require.NoError(t, err)
+
+	parsed, err := parseClientCA(caPEM, mustPEM(t, "PRIVATE KEY", mustPKCS8(t, ca.key)))
+	require.NoError(t, err)
+	require.True(t, parsed.cert.IsCA)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	first, _, err := newAdminCert(ca)
+	require.NoError(t, err)
+
+	second, keyPEM, err := newAdminCert(parsed)
+	require.NoError(t, err)
+
+	firstCert := parsePEMCert(t, first)
+	secondCert := parsePEMCert(t, second)
+
+	require.NotEqual(t, firstCert.SerialNumber, secondCert.SerialNumber)
+	require.Equal(t, "admin", secondCert.Subject.CommonName)
+	require.Equal(t, []string{"system:masters"}, secondCert.Subject.Organization)
+
+	_, err = secondCert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(keyPEM)
+	require.NotNil(t, block)
+
+	_, err = parsePrivateKey(block.Bytes)
+	require.NoError(t, err)
+
+	// A certificate issued by another CA does not verify
+	other, _, err := newClientCA()
+	require.NoError(t, err)
+
+	foreign, _, err := newAdminCert(other)
+	require.NoError(t, err)
+
+	_, err = parsePEMCert(t, foreign).Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	require.Error(t, err)
+}
+
+func TestParsePrivateKeyFormats(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// openssl genrsa emits PKCS#8 on OpenSSL 3 and PKCS#1 before
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	for name, der := range map[string][]byte{
+		"pkcs8": pkcs8,
+		"pkcs1": x509.MarshalPKCS1PrivateKey(key),
+	} {
+		signer, err := parsePrivateKey(der)
+		require.NoError(t, err, name)
+		require.Equal(t, key.Public(), signer.Public(), name)
+	}
+
+	_, err = parsePrivateKey([]byte("garbage"))
+	require.Error(t, err)
+}
+
+func TestParseClientCAInvalid(t *testing.T) {
+	_, err := parseClientCA([]byte("not pem"), nil)
+	require.ErrorContains(t, err, "certificate is not PEM-encoded")
+
+	_, caPEM, err := newClientCA()
+	require.NoError(t, err)
+
+	_, err = parseClientCA(caPEM, []byte("not pem"))
+	require.ErrorContains(t, err, "key is not PEM-encoded")
+}
+
+func TestCredentialsRejection(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unauthorized", err: apierrors.NewUnauthorized("Unauthorized"), want: true},
+		{name: "tls alert", err: errors.New("Get \"https://127.0.0.1:6443/api\": remote error: tls: bad certificate"), want: true},
+		{name: "forbidden", err: apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", errors.New("denied"))},
+		{name: "connection refused", err: fmt.Errorf("dial tcp: %w", errors.New("connection refused"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, credentialsRejection(tt.err))
+		})
+	}
+}
+
+func parsePEMCert(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func mustPKCS8(t *testing.T, key any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return der
+}
+
+func mustPEM(t *testing.T, blockType string, der []byte) []byte {
+	t.Helper()
+
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
@@ -0,0 +1,80 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerRegenerateAdminCredentials(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	_, err = c.OldCredentialsRejected(ctx)
+	require.Error(t, err, "nothing to compare against before a rotation")
+
+	// Without a new client CA the old certificate keeps working
+	require.NoError(t, c.RegenerateAdminCredentials(ctx))
+
+	rejected, err := c.OldCredentialsRejected(ctx)
+	require.NoError(t, err)
+	require.False(t, rejected)
+
+	require.NoError(t, c.RegenerateAdminCredentials(ctx, envtest.WithNewClientCA()))
+
+	oldCfg, err := c.PreviousRESTConfig()
+	require.NoError(t, err)
+
+	oldClient, err := kubernetes.NewForConfig(oldCfg)
+	require.NoError(t, err)
+
+	_, err = oldClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.True(t, apierrors.IsUnauthorized(err), "expected 401, got %v", err)
+
+	rejected, err = c.OldCredentialsRejected(ctx)
+	require.NoError(t, err)
+	require.True(t, rejected)
+
+	// Configs handed out after the rotation carry the new credentials
+	newCfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, oldCfg.CertData, newCfg.CertData)
+
+	newClient, err := kubernetes.NewForConfig(newCfg)
+	require.NoError(t, err)
+
+	_, err = newClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+
+	// A restart generates a new CA, which the next rotation has to sign with
+	require.NoError(t, c.Restart(ctx))
+
+	_, err = c.PreviousRESTConfig()
+	require.Error(t, err, "the rotation is forgotten on restart")
+
+	require.NoError(t, c.RegenerateAdminCredentials(ctx))
+
+	restartedCfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	restartedClient, err := kubernetes.NewForConfig(restartedCfg)
+	require.NoError(t, err)
+
+	_, err = restartedClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+}
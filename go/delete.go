@@ -0,0 +1,192 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// deleteConfig holds the configuration for Delete
+type deleteConfig struct {
+	namespace    string
+	failNotFound bool
+	waitTimeout  time.Duration
+}
+
+// DeleteOption is a functional option for configuring Delete
+type DeleteOption func(*deleteConfig)
+
+// WithDeleteNamespace sets the namespace of namespaced objects that do not specify one (default: "default"),
+// like WithApplyNamespace for Apply
+func WithDeleteNamespace(namespace string) DeleteOption {
+	return func(c *deleteConfig) {
+		c.namespace = namespace
+	}
+}
+
+// WithFailOnNotFound fails Delete on objects that do not exist instead of skipping them
+func WithFailOnNotFound() DeleteOption {
+	return func(c *deleteConfig) {
+		c.failNotFound = true
+	}
+}
+
+// WithWaitForDeletion waits up to timeout for the deleted objects to be gone, e.g. for their finalizers to run
+func WithWaitForDeletion(timeout time.Duration) DeleteOption {
+	return func(c *deleteConfig) {
+		c.waitTimeout = timeout
+	}
+}
+
+// Delete deletes the objects of a multi-document YAML or JSON manifest in the reverse order of Apply,
+// so custom resources go before their CRDs and Namespaces last. Objects that do not exist are skipped
+// unless WithFailOnNotFound is given. Envtest runs no namespace controller unless WithControllerManager is given,
+// so waiting for the deletion of Namespaces with WithWaitForDeletion times out otherwise, see ForceDeleteNamespace.
+func (c *EnvtestContainer) Delete(
+	ctx context.Context,
+	manifests []byte,
+	opts ...DeleteOption,
+) error {
+	cfg := &deleteConfig{namespace: metav1.NamespaceDefault}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	docs, err := decodeManifest("manifest", manifests)
+	if err != nil {
+		return err
+	}
+
+	docs = sortByApplyPriority(docs)
+	slices.Reverse(docs)
+
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	var deleted []manifestDocument
+
+	for _, doc := range docs {
+		ok, err := c.deleteDocument(ctx, cl, doc, cfg)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			deleted = append(deleted, doc)
+		}
+	}
+
+	if cfg.waitTimeout <= 0 {
+		return nil
+	}
+
+	return waitForDeletion(ctx, cl, deleted, cfg.waitTimeout)
+}
+
+// deleteDocument deletes the object of a document, reporting whether it existed
+func (c *EnvtestContainer) deleteDocument(
+	ctx context.Context,
+	cl client.Client,
+	doc manifestDocument,
+	cfg *deleteConfig,
+) (bool, error) {
+	namespaced, err := cl.IsObjectNamespaced(doc.obj)
+	if err != nil {
+		// Objects of a deleted CRD are gone with it
+		if meta.IsNoMatchError(err) && !cfg.failNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%s is a %s of %s unknown to the API server: %w",
+			doc, doc.obj.GetKind(), doc.obj.GetAPIVersion(), err)
+	}
+
+	if namespaced && doc.obj.GetNamespace() == "" {
+		doc.obj.SetNamespace(cfg.namespace)
+	}
+
+	err = c.retry(ctx, "delete manifest", func(ctx context.Context) error {
+		return cl.Delete(ctx, doc.obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	})
+
+	switch {
+	case apierrors.IsNotFound(err) && !cfg.failNotFound:
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf(
+			"failed to delete %s (%s %s): %w",
+			doc,
+			doc.obj.GetKind(),
+			doc.obj.GetName(),
+			err,
+		)
+	}
+
+	return true, nil
+}
+
+// waitForDeletion polls until the objects of the documents are gone
+func waitForDeletion(
+	ctx context.Context,
+	cl client.Client,
+	docs []manifestDocument,
+	timeout time.Duration,
+) error {
+	var pending []string
+
+	err := wait.PollUntilContextTimeout(
+		ctx,
+		100*time.Millisecond,
+		timeout,
+		true,
+		func(ctx context.Context) (bool, error) {
+			pending = pending[:0]
+
+			for _, doc := range docs {
+				obj := &unstructured.Unstructured{}
+				obj.SetGroupVersionKind(doc.obj.GroupVersionKind())
+
+				err := cl.Get(ctx, client.ObjectKeyFromObject(doc.obj), obj)
+
+				switch {
+				case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+					continue
+				case err != nil:
+					return false, err
+				}
+
+				pending = append(
+					pending,
+					fmt.Sprintf("%s %s", doc.obj.GetKind(), client.ObjectKeyFromObject(doc.obj)),
+				)
+			}
+
+			return len(pending) == 0, nil
+		},
+	)
+	if wait.Interrupted(err) {
+		return fmt.Errorf(
+			"objects were not deleted within %s: %s",
+			timeout,
+			strings.Join(pending, ", "),
+		)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to wait for deletion: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package envtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteOptions(t *testing.T) {
+	cfg := &deleteConfig{}
+
+	WithDeleteNamespace("apps")(cfg)
+	WithFailOnNotFound()(cfg)
+	WithWaitForDeletion(10 * time.Second)(cfg)
+
+	require.Equal(t, &deleteConfig{namespace: "apps", failNotFound: true, waitTimeout: 10 * time.Second}, cfg)
+}
@@ -0,0 +1,108 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// deleteManifest is a fixture set of a CRD, one of its custom resources and a ConfigMap
+const deleteManifest = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: sprockets.delete.example.com
+spec:
+  group: delete.example.com
+  names:
+    kind: Sprocket
+    listKind: SprocketList
+    plural: sprockets
+    singular: sprocket
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+---
+apiVersion: delete.example.com/v1
+kind: Sprocket
+metadata:
+  name: first
+spec:
+  teeth: 12
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: sprocket-settings
+data:
+  mode: fast
+`
+
+func TestEnvtestContainerDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	settings := client.ObjectKey{Namespace: "default", Name: "sprocket-settings"}
+
+	// The same fixtures can be installed and removed repeatedly
+	for range 2 {
+		require.NoError(t, c.Apply(ctx, []byte(deleteManifest)))
+		require.NoError(t, cl.Get(ctx, settings, &corev1.ConfigMap{}))
+
+		require.NoError(t, c.Delete(ctx, []byte(deleteManifest), envtest.WithWaitForDeletion(30*time.Second)))
+
+		err := cl.Get(ctx, settings, &corev1.ConfigMap{})
+		require.True(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+	}
+
+	// Objects that are already gone are skipped unless asked otherwise
+	require.NoError(t, c.Delete(ctx, []byte(deleteManifest)))
+	require.ErrorContains(t, c.Delete(ctx, []byte(deleteManifest), envtest.WithFailOnNotFound()), "manifest document")
+
+	// Waiting covers the finalizers run by controllers
+	finalized := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: finalized
+  finalizers:
+  - delete.example.com/cleanup
+`)
+	require.NoError(t, c.Apply(ctx, finalized))
+
+	err = c.Delete(ctx, finalized, envtest.WithWaitForDeletion(time.Second))
+	require.ErrorContains(t, err, "objects were not deleted within 1s: ConfigMap default/finalized")
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "finalized"}, cm))
+
+	cm.Finalizers = nil
+	require.NoError(t, cl.Update(ctx, cm))
+
+	require.NoError(t, c.Delete(ctx, finalized, envtest.WithWaitForDeletion(10*time.Second)))
+}
@@ -0,0 +1,134 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	// deprecatedAPIsMetric is set to 1 for every deprecated API version that received a request.
+	// It is not exposed by API servers older than 1.19.
+	deprecatedAPIsMetric = "apiserver_requested_deprecated_apis"
+
+	// requestTotalMetric counts requests per group/version/resource, among other labels
+	requestTotalMetric = "apiserver_request_total"
+)
+
+// DeprecatedAPIUse describes a deprecated API that was requested since the API server started
+type DeprecatedAPIUse struct {
+	Group          string
+	Version        string
+	Resource       string
+	Subresource    string
+	RemovedRelease string
+	RequestCount   int64
+}
+
+// String returns the API as group/version/resource (version/resource for the core group),
+// followed by /subresource when the request targeted one
+func (u DeprecatedAPIUse) String() string {
+	parts := make([]string, 0, 4)
+
+	if u.Group != "" {
+		parts = append(parts, u.Group)
+	}
+
+	parts = append(parts, u.Version, u.Resource)
+
+	if u.Subresource != "" {
+		parts = append(parts, u.Subresource)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// GetDeprecatedAPIUsage reports the deprecated APIs that were requested since the API server started,
+// along with the release they are removed in and the number of requests they received.
+// API servers that do not expose the apiserver_requested_deprecated_apis metric yield an empty report.
+func (c *EnvtestContainer) GetDeprecatedAPIUsage(ctx context.Context) ([]DeprecatedAPIUse, error) {
+	snapshot, err := c.GetMetrics(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deprecated API usage: %w", err)
+	}
+
+	return deprecatedAPIUsage(snapshot), nil
+}
+
+// AssertNoDeprecatedAPIUse fails the test if any deprecated API was requested.
+// Allowlist entries are matched against group/version/resource or group/version,
+// e.g. "flowcontrol.apiserver.k8s.io/v1beta3" or "networking.k8s.io/v1beta1/ipaddresses".
+func (c *EnvtestContainer) AssertNoDeprecatedAPIUse(t testing.TB, allowlist ...string) {
+	t.Helper()
+
+	uses, err := c.GetDeprecatedAPIUsage(t.Context())
+	if err != nil {
+		t.Fatalf("failed to check deprecated API usage: %v", err)
+	}
+
+	for _, use := range uses {
+		if deprecatedAPIAllowed(use, allowlist) {
+			continue
+		}
+
+		t.Errorf(
+			"deprecated API %s (removed in %s) was requested %d time(s)",
+			use,
+			use.RemovedRelease,
+			use.RequestCount,
+		)
+	}
+}
+
+// deprecatedAPIUsage joins the deprecated API metric with request counts
+func deprecatedAPIUsage(snapshot MetricsSnapshot) []DeprecatedAPIUse {
+	uses := make([]DeprecatedAPIUse, 0, len(snapshot[deprecatedAPIsMetric]))
+
+	for _, sample := range snapshot[deprecatedAPIsMetric] {
+		if sample.Value == 0 {
+			continue
+		}
+
+		gvr := map[string]string{
+			"group":       sample.Labels["group"],
+			"version":     sample.Labels["version"],
+			"resource":    sample.Labels["resource"],
+			"subresource": sample.Labels["subresource"],
+		}
+
+		uses = append(uses, DeprecatedAPIUse{
+			Group:          gvr["group"],
+			Version:        gvr["version"],
+			Resource:       gvr["resource"],
+			Subresource:    gvr["subresource"],
+			RemovedRelease: sample.Labels["removed_release"],
+			RequestCount:   int64(snapshot.Sum(requestTotalMetric, gvr)),
+		})
+	}
+
+	slices.SortFunc(uses, func(a, b DeprecatedAPIUse) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	return uses
+}
+
+// deprecatedAPIAllowed reports whether the deprecated API use matches any allowlist entry
+func deprecatedAPIAllowed(use DeprecatedAPIUse, allowlist []string) bool {
+	groupVersion := use.Version
+	if use.Group != "" {
+		groupVersion = use.Group + "/" + use.Version
+	}
+
+	gvr := groupVersion + "/" + use.Resource
+
+	for _, entry := range allowlist {
+		if entry == groupVersion || entry == gvr || entry == use.String() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecatedAPIUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics string
+		want    []DeprecatedAPIUse
+	}{
+		{
+			name: "joins request counts",
+			metrics: `# TYPE apiserver_requested_deprecated_apis gauge
+apiserver_requested_deprecated_apis{group="networking.k8s.io",removed_release="1.37",resource="ipaddresses",subresource="",version="v1beta1"} 1
+apiserver_requested_deprecated_apis{group="",removed_release="",resource="componentstatuses",subresource="",version="v1"} 1
+# TYPE apiserver_request_total counter
+apiserver_request_total{code="200",group="networking.k8s.io",resource="ipaddresses",subresource="",verb="LIST",version="v1beta1"} 2
+apiserver_request_total{code="404",group="networking.k8s.io",resource="ipaddresses",subresource="",verb="GET",version="v1beta1"} 1
+apiserver_request_total{code="200",group="networking.k8s.io",resource="ipaddresses",subresource="",verb="LIST",version="v1"} 7
+apiserver_request_total{code="200",group="",resource="componentstatuses",subresource="",verb="LIST",version="v1"} 4
+`,
+			want: []DeprecatedAPIUse{
+				{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ipaddresses", RemovedRelease: "1.37", RequestCount: 3},
+				{Version: "v1", Resource: "componentstatuses", RequestCount: 4},
+			},
+		},
+		{
+			name: "metric absent",
+			metrics: `# TYPE apiserver_request_total counter
+apiserver_request_total{code="200",group="",resource="namespaces",verb="LIST",version="v1"} 3
+`,
+			want: []DeprecatedAPIUse{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := parseMetrics(strings.NewReader(tt.metrics))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, deprecatedAPIUsage(snapshot))
+		})
+	}
+}
+
+func TestDeprecatedAPIUseString(t *testing.T) {
+	require.Equal(t, "networking.k8s.io/v1beta1/ipaddresses", DeprecatedAPIUse{
+		Group: "networking.k8s.io", Version: "v1beta1", Resource: "ipaddresses",
+	}.String())
+	require.Equal(t, "v1/componentstatuses", DeprecatedAPIUse{Version: "v1", Resource: "componentstatuses"}.String())
+	require.Equal(t, "v1/pods/eviction", DeprecatedAPIUse{Version: "v1", Resource: "pods", Subresource: "eviction"}.String())
+}
+
+func TestDeprecatedAPIAllowed(t *testing.T) {
+	use := DeprecatedAPIUse{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ipaddresses"}
+
+	require.True(t, deprecatedAPIAllowed(use, []string{"networking.k8s.io/v1beta1"}))
+	require.True(t, deprecatedAPIAllowed(use, []string{"networking.k8s.io/v1beta1/ipaddresses"}))
+	require.False(t, deprecatedAPIAllowed(use, []string{"networking.k8s.io/v1beta1/servicecidrs"}))
+	require.False(t, deprecatedAPIAllowed(use, []string{"networking.k8s.io"}))
+	require.False(t, deprecatedAPIAllowed(use, nil))
+}
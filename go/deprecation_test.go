@@ -0,0 +1,50 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerDeprecatedAPIUsage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	opts := append(getEnvtestOptions(),
+		// IPAddress v1beta1 is deprecated since 1.34 and disabled by default
+		envtest.WithRuntimeConfig(map[string]bool{"networking.k8s.io/v1beta1": true}),
+	)
+
+	c, err := envtest.Run(ctx, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err := clientset.CoreV1().RESTClient().Get().AbsPath("/apis/networking.k8s.io/v1beta1/ipaddresses").DoRaw(ctx)
+		require.NoError(t, err)
+	}
+
+	uses, err := c.GetDeprecatedAPIUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, uses, 1)
+	require.Equal(t, "networking.k8s.io/v1beta1/ipaddresses", uses[0].String())
+	require.Equal(t, "1.37", uses[0].RemovedRelease)
+	require.GreaterOrEqual(t, uses[0].RequestCount, int64(3))
+
+	c.AssertNoDeprecatedAPIUse(t, "networking.k8s.io/v1beta1")
+}
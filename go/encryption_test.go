@@ -0,0 +1,76 @@
+package envtest_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// aescbcEncryptionConfig encrypts Secrets with a 32-byte AES-CBC key
+const aescbcEncryptionConfig = `apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+- resources: ["secrets"]
+  providers:
+  - aescbc:
+      keys:
+      - name: key1
+        secret: MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=
+  - identity: {}
+`
+
+func TestEnvtestContainerWithEncryptionConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithEncryptionConfig([]byte(aescbcEncryptionConfig)))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "encrypted", Namespace: "default"},
+		StringData: map[string]string{"password": "plaintext-password"},
+	}
+	require.NoError(t, cl.Create(ctx, secret))
+
+	stored, err := c.EtcdGetKey(ctx, "/registry/secrets/default/encrypted")
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(stored, []byte("k8s:enc:aescbc:v1:key1:")), "secret is not encrypted: %q", stored)
+	require.NotContains(t, string(stored), "plaintext-password")
+
+	// Reads are decrypted transparently
+	read := &corev1.Secret{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(secret), read))
+	require.Equal(t, []byte("plaintext-password"), read.Data["password"])
+}
+
+func TestEnvtestContainerWithInvalidEncryptionConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// AES-CBC keys are 16, 24 or 32 bytes long
+	invalid := bytes.ReplaceAll([]byte(aescbcEncryptionConfig),
+		[]byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="), []byte("dG9vLXNob3J0"))
+
+	_, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithEncryptionConfig(invalid))...)
+	require.ErrorContains(t, err, "failed to start envtest container")
+
+	// The reason kube-apiserver did not start is surfaced
+	require.ErrorContains(t, err, "kube-apiserver: ")
+	require.ErrorContains(t, err, "secret is not of the expected length")
+}
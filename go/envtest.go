@@ -4,14 +4,23 @@
 package envtest
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 const (
@@ -26,12 +35,83 @@ const (
 
 	// KubeconfigPath is the path to the kubeconfig inside the container
 	KubeconfigPath = "/tmp/kubeconfig"
+
+	// EncryptionConfigPath is the path to the encryption configuration of WithEncryptionConfig inside the container
+	EncryptionConfigPath = "/etc/envtest/encryption-config.yaml"
+
+	// serviceAccountIssuerEnv overrides the service account issuer of the entrypoint, DefaultServiceAccountIssuer by default
+	serviceAccountIssuerEnv = "ENVTEST_SERVICE_ACCOUNT_ISSUER"
+
+	// serviceAccountKeyEnv overrides the key the entrypoint signs and verifies ServiceAccount tokens with,
+	// the API server certificate key by default
+	serviceAccountKeyEnv = "ENVTEST_SERVICE_ACCOUNT_KEY_FILE"
+
+	// etcdListenAddressEnv overrides the address etcd listens on in the entrypoint, 127.0.0.1 by default
+	etcdListenAddressEnv = "ENVTEST_ETCD_LISTEN_ADDRESS"
+
+	// etcdUnsafeNoFsyncEnv turns off fsync in the etcd of the entrypoint
+	etcdUnsafeNoFsyncEnv = "ENVTEST_ETCD_UNSAFE_NO_FSYNC"
+
+	// etcdQuotaBackendBytesEnv overrides the backend quota of the etcd of the entrypoint
+	etcdQuotaBackendBytesEnv = "ENVTEST_ETCD_QUOTA_BACKEND_BYTES"
+
+	// etcdFlagsEnv lists extra etcd flags for the entrypoint, one per line
+	etcdFlagsEnv = "ENVTEST_ETCD_FLAGS"
+
+	// controllerManagerEnv makes the entrypoint run kube-controller-manager
+	controllerManagerEnv = "ENVTEST_CONTROLLER_MANAGER"
+
+	// controllersEnv overrides the controllers of kube-controller-manager in the entrypoint, all by default
+	controllersEnv = "ENVTEST_CONTROLLERS"
+
+	// schedulerEnv makes the entrypoint run kube-scheduler
+	schedulerEnv = "ENVTEST_SCHEDULER"
+
+	// kwokEnv makes the entrypoint run kwok
+	kwokEnv = "ENVTEST_KWOK"
+
+	// certSANsEnv lists extra subject alternative names of the API server certificate for the entrypoint
+	certSANsEnv = "ENVTEST_CERT_SANS"
+
+	// disabledAdmissionPluginsEnv overrides the admission plugins the entrypoint disables by default
+	disabledAdmissionPluginsEnv = "ENVTEST_DISABLE_ADMISSION_PLUGINS"
+
+	// authorizationModesEnv overrides the authorization modes of the entrypoint, RBAC by default
+	authorizationModesEnv = "ENVTEST_AUTHORIZATION_MODES"
+
+	// abortRunTimeout bounds terminating the container when Run fails after starting it
+	abortRunTimeout = 30 * time.Second
 )
 
 // EnvtestContainer represents an envtest container instance
 type EnvtestContainer struct {
 	testcontainers.Container
 	kubernetesVersion string
+	noRetries         bool
+	etcdExposed       bool
+	kwok              bool
+	reuseName         string
+	sharedKey         string
+
+	mu                 sync.Mutex
+	terminateHooks     []func()
+	previousKubeconfig string
+	clientCA           *clientCA
+	paused             bool
+	network            string
+	networkAliases     []string
+	disconnected       bool
+	clusterName        string
+	contextName        string
+	events             *lifecycleEvents
+	webhookOptions     WebhookInstallOptions
+	auditWebhook       *auditWebhookServer
+	snapshots          int
+
+	// kubeconfigMu guards the kubeconfig and rest.Config cached for the mapped port
+	kubeconfigMu sync.Mutex
+	kubeconfig   *clientcmdapi.Config
+	restConfig   *rest.Config
 }
 
 // Run creates and starts an envtest container with the given options
@@ -45,79 +125,584 @@ func Run(ctx context.Context, opts ...Option) (*EnvtestContainer, error) {
 		opt(cfg)
 	}
 
+	if err := cfg.checkAPIServerFlags(); err != nil {
+		return nil, err
+	}
+
 	// If a specific kubernetes version is requested, use the versioned image tag
 	image := cfg.image
 	if cfg.kubernetesVersion != DefaultKubernetesVersion && cfg.image == DefaultImage {
 		image = "ghcr.io/roma-glushko/testcontainers-envtest:v" + cfg.kubernetesVersion
 	}
 
+	var hostConfigModifiers []func(*container.HostConfig)
+
+	// Forwarded host ports are reached through the testcontainers SSH tunnel instead of the gateway
+	if cfg.hostAccessEnabled() && len(cfg.hostAccessPorts) == 0 {
+		gateway, err := hostGateway(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve host gateway: %w", err)
+		}
+
+		hostConfigModifiers = append(hostConfigModifiers, withHostAlias(gateway))
+	}
+
+	var files []testcontainers.ContainerFile
+
+	if cfg.auditPolicy != nil {
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(cfg.auditPolicy),
+			ContainerFilePath: AuditPolicyPath,
+			FileMode:          0o644,
+		})
+	}
+
+	var auditWebhook *auditWebhookServer
+
+	if cfg.auditWebhook {
+		var err error
+		if auditWebhook, err = newAuditWebhookServer(); err != nil {
+			return nil, err
+		}
+
+		kubeconfig, err := auditWebhook.kubeconfig()
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(kubeconfig),
+			ContainerFilePath: AuditWebhookConfigPath,
+			FileMode:          0o644,
+		})
+	}
+
+	if cfg.encryptionConfig != nil {
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(cfg.encryptionConfig),
+			ContainerFilePath: EncryptionConfigPath,
+			FileMode:          0o600,
+		})
+	}
+
+	if len(cfg.tokenUsers) > 0 {
+		tokens, err := tokenAuthFile(cfg.tokenUsers)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(tokens),
+			ContainerFilePath: TokenAuthFilePath,
+			FileMode:          0o600,
+		})
+	}
+
+	if cfg.oidc != nil && len(cfg.oidc.CABundle) > 0 {
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(cfg.oidc.CABundle),
+			ContainerFilePath: OIDCCAPath,
+			FileMode:          0o644,
+		})
+	}
+
+	if cfg.authnConfig != nil {
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(cfg.authnConfig),
+			ContainerFilePath: AuthenticationConfigPath,
+			FileMode:          0o644,
+		})
+	}
+
+	if cfg.tracing != nil {
+		tracingConfig, err := cfg.tracing.configFile()
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(tracingConfig),
+			ContainerFilePath: TracingConfigPath,
+			FileMode:          0o644,
+		})
+	}
+
+	if cfg.saSigningKey != nil {
+		files = append(files, testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(cfg.saSigningKey),
+			ContainerFilePath: ServiceAccountSigningKeyPath,
+			FileMode:          0o600,
+		})
+	}
+
+	for path, kubeconfig := range map[string][]byte{
+		AuthenticationWebhookConfigPath: cfg.authnWebhook,
+		AuthorizationWebhookConfigPath:  cfg.authzWebhook,
+	} {
+		if kubeconfig != nil {
+			files = append(files, testcontainers.ContainerFile{
+				Reader:            bytes.NewReader(kubeconfig),
+				ContainerFilePath: path,
+				FileMode:          0o644,
+			})
+		}
+	}
+
+	// Read the CRDs before starting the container, so broken manifests fail fast
+	crds, err := readCRDs(cfg.crdPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.crdURLs) > 0 {
+		downloaded, err := downloadCRDs(ctx, http.DefaultClient, crdCacheDir(), cfg.crdURLs)
+		if err != nil {
+			return nil, err
+		}
+
+		crds = append(crds, downloaded...)
+	}
+
+	if cfg.crdConversionWebhook != nil {
+		crds = cfg.crdConversionWebhook.apply(crds)
+	}
+
+	webhooks, err := readWebhooks(cfg.webhookPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := readManifests(cfg.manifestPaths, cfg.manifestDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := renderKustomizations(cfg.kustomizeDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests = append(manifests, rendered...)
+
+	if cfg.webhookTarget != "" && cfg.network == "" {
+		return nil, errors.New(
+			"WithWebhookTarget requires WithNetwork to reach the webhook container",
+		)
+	}
+
+	// The webhook port on the host is picked upfront, so it can be forwarded
+	var hostWebhookPort int
+
+	if len(webhooks) > 0 && cfg.webhookTarget == "" {
+		if hostWebhookPort, err = freeHostPort(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &EnvtestContainer{
+		kubernetesVersion: cfg.kubernetesVersion,
+		noRetries:         cfg.noRetries,
+		etcdExposed:       cfg.etcdExposed,
+		kwok:              cfg.kwok,
+		reuseName:         cfg.reuseName,
+		network:           cfg.network,
+		networkAliases:    cfg.networkAliases,
+		clusterName:       cfg.clusterName,
+		contextName:       cfg.contextName,
+		events:            newLifecycleEvents(),
+		auditWebhook:      auditWebhook,
+	}
+
+	// A restarted container has new certificates and a new mapped port
+	invalidateKubeconfig := testcontainers.ContainerLifecycleHooks{
+		PostStarts: []testcontainers.ContainerHook{
+			func(context.Context, testcontainers.Container) error {
+				c.invalidateKubeconfig()
+				c.resetCredentials()
+
+				return nil
+			},
+		},
+	}
+
 	req := testcontainers.ContainerRequest{
 		Image:        image,
 		ExposedPorts: []string{DefaultAPIServerPort + "/tcp"},
-		WaitingFor: wait.ForAll(
-			wait.ForListeningPort(DefaultAPIServerPort+"/tcp"),
-			wait.ForLog("Envtest is ready!"),
-		),
+		// The entrypoint forwards its arguments to kube-apiserver
+		Cmd:        cfg.apiServerArgs(),
+		Files:      files,
+		WaitingFor: cfg.waitStrategy(),
+		HostConfigModifier: func(hc *container.HostConfig) {
+			for _, modify := range hostConfigModifiers {
+				modify(hc)
+			}
+		},
+		LifecycleHooks: []testcontainers.ContainerLifecycleHooks{
+			invalidateKubeconfig,
+			c.events.hooks(),
+		},
+	}
+
+	if cfg.etcdDataVolume != "" {
+		req.Mounts = testcontainers.ContainerMounts{
+			testcontainers.VolumeMount(
+				cfg.etcdDataVolume,
+				testcontainers.ContainerMountTarget(etcdDataDir),
+			),
+		}
+	}
+
+	if cfg.etcdInMemory {
+		req.Tmpfs = map[string]string{etcdDataDir: "rw"}
+	}
+
+	if cfg.etcdExposed {
+		req.ExposedPorts = append(req.ExposedPorts, DefaultEtcdPort+"/tcp")
+	}
+
+	if cfg.network != "" {
+		req.Networks = []string{cfg.network}
+		req.NetworkAliases = map[string][]string{cfg.network: cfg.networkAliases}
+	}
+
+	if len(cfg.logConsumers) > 0 {
+		req.LogConsumerCfg = &testcontainers.LogConsumerConfig{Consumers: cfg.logConsumers}
+	}
+
+	if len(cfg.hostAccessPorts) > 0 {
+		var auditWebhookPort int
+		if auditWebhook != nil {
+			auditWebhookPort = auditWebhook.port
+		}
+
+		req.HostAccessPorts = cfg.forwardedHostPorts(hostWebhookPort, auditWebhookPort)
+	}
+
+	req.Env = map[string]string{}
+
+	if sans := cfg.extraCertSANs(); len(sans) > 0 {
+		req.Env[certSANsEnv] = strings.Join(sans, ",")
+	}
+
+	if plugins, changed := cfg.containerDisabledAdmissionPlugins(); changed {
+		req.Env[disabledAdmissionPluginsEnv] = strings.Join(plugins, ",")
+	}
+
+	if modes := cfg.authorizationModes(); len(modes) > 0 {
+		req.Env[authorizationModesEnv] = strings.Join(modes, ",")
+	}
+
+	if cfg.etcdExposed {
+		req.Env[etcdListenAddressEnv] = "0.0.0.0"
+	}
+
+	if cfg.etcdInMemory {
+		req.Env[etcdUnsafeNoFsyncEnv] = "true"
+	}
+
+	if cfg.etcdQuotaBytes > 0 {
+		req.Env[etcdQuotaBackendBytesEnv] = strconv.FormatInt(cfg.etcdQuotaBytes, 10)
+	}
+
+	if args := cfg.etcdArgs(); len(args) > 0 {
+		req.Env[etcdFlagsEnv] = strings.Join(args, "\n")
+	}
+
+	if cfg.controllerManager {
+		req.Env[controllerManagerEnv] = "true"
+	}
+
+	if len(cfg.controllers) > 0 {
+		req.Env[controllersEnv] = strings.Join(cfg.controllers, ",")
+	}
+
+	if cfg.scheduler {
+		req.Env[schedulerEnv] = "true"
+	}
+
+	if cfg.kwok {
+		req.Env[kwokEnv] = "true"
+	}
+
+	if cfg.saIssuer != "" {
+		req.Env[serviceAccountIssuerEnv] = cfg.saIssuer
+	}
+
+	if cfg.saSigningKey != nil {
+		req.Env[serviceAccountKeyEnv] = ServiceAccountSigningKeyPath
+	}
+
+	// The audit webhook server has to be up before the API server sends the first events
+	if auditWebhook != nil {
+		if err := auditWebhook.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.reuseName != "" {
+		req.Name = cfg.reuseName
 	}
 
 	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
 		ContainerRequest: req,
 		Started:          true,
+		Reuse:            cfg.reuseName != "",
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to start envtest container: %w", err)
+		err = fmt.Errorf(
+			"failed to start envtest container: %w",
+			startupTimeoutError(err, cfg.startupTimeout),
+		)
+
+		// The container is returned when it started but did not become ready, e.g. kube-apiserver rejected its flags
+		if container != nil {
+			if reasons := apiServerStartupErrors(ctx, container); len(reasons) > 0 {
+				err = fmt.Errorf(
+					"%w\nkube-apiserver: %s",
+					err,
+					strings.Join(reasons, "\nkube-apiserver: "),
+				)
+			}
+
+			// A container of WithReuse is left running for the other packages sharing it, like abortRun does
+			if cfg.reuseName == "" {
+				terminateCtx, cancel := context.WithTimeout(
+					context.WithoutCancel(ctx),
+					abortRunTimeout,
+				)
+				_ = container.Terminate(terminateCtx)
+
+				cancel()
+			}
+		}
+
+		if auditWebhook != nil {
+			auditWebhook.stop(context.WithoutCancel(ctx))
+		}
+
+		return nil, err
 	}
 
-	return &EnvtestContainer{
-		Container:         container,
-		kubernetesVersion: cfg.kubernetesVersion,
-	}, nil
+	c.Container = container
+
+	target := webhookTarget{host: cfg.webhookTarget, port: cfg.webhookPort}
+	if target.host == "" {
+		target = webhookTarget{host: testcontainers.HostInternal, port: hostWebhookPort}
+	}
+
+	if err := c.setUp(ctx, crds, manifests, webhooks, target); err != nil {
+		return nil, c.abortRun(ctx, err)
+	}
+
+	return c, nil
 }
 
-// Kubeconfig returns the kubeconfig YAML content for connecting to the API server
-func (c *EnvtestContainer) Kubeconfig(ctx context.Context) (string, error) {
-	// Read the kubeconfig from the container
-	reader, err := c.CopyFileFromContainer(ctx, KubeconfigPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to copy kubeconfig from container: %w", err)
+// setUp installs what Run was given into the started container
+func (c *EnvtestContainer) setUp(
+	ctx context.Context,
+	crds []crdManifest,
+	manifests []manifestDocument,
+	webhooks []webhookManifest,
+	target webhookTarget,
+) error {
+	if c.reuseName != "" {
+		if err := c.checkReusedVersion(ctx); err != nil {
+			return err
+		}
 	}
 
-	defer func() { _ = reader.Close() }()
+	if len(crds) > 0 {
+		if _, err := c.installCRDs(ctx, crds, crdEstablishTimeout); err != nil {
+			return err
+		}
+	}
 
-	// Read all content
-	buf := make([]byte, 0, 4096)
+	// Fixtures are applied before the webhooks are installed, as their server is not running yet
+	if len(manifests) > 0 {
+		if err := c.applyManifests(ctx, manifests, newApplyConfig()); err != nil {
+			return err
+		}
+	}
 
-	tmp := make([]byte, 1024)
+	if len(webhooks) > 0 {
+		if err := c.setupWebhooks(ctx, webhooks, target); err != nil {
+			return err
+		}
 
-	for {
-		n, err := reader.Read(tmp)
-		if n > 0 {
-			buf = append(buf, tmp[:n]...)
+		if err := c.installWebhooks(ctx, webhooks); err != nil {
+			return err
 		}
+	}
 
-		if err != nil {
-			break
+	return nil
+}
+
+// abortRun terminates the container after Run failed to set it up, also when ctx is done already,
+// and returns the error of the setup. The container of WithReuse is left running for the packages sharing it.
+func (c *EnvtestContainer) abortRun(ctx context.Context, err error) error {
+	if c.reuseName != "" {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortRunTimeout)
+	defer cancel()
+
+	if termErr := c.Terminate(ctx); termErr != nil {
+		return fmt.Errorf("%w (failed to terminate the container: %v)", err, termErr)
+	}
+
+	return err
+}
+
+// Kubeconfig returns the kubeconfig YAML content for connecting to the API server.
+// By default the certificates are inlined (see WithFlatten); use WithCertFiles to reference them as files.
+func (c *EnvtestContainer) Kubeconfig(
+	ctx context.Context,
+	opts ...KubeconfigOption,
+) (string, error) {
+	cfg := &kubeconfigConfig{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	apiConfig, err := c.kubeconfigObject(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.certDir != "" {
+		if err := externalizeCerts(apiConfig, cfg); err != nil {
+			return "", err
 		}
 	}
 
+	kubeconfig, err := clientcmd.Write(*apiConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+
+	return string(kubeconfig), nil
+}
+
+// GetKubeconfigObject returns the parsed kubeconfig for connecting to the API server, with the certificates inlined,
+// e.g. to rename its context or merge it into another kubeconfig
+func (c *EnvtestContainer) GetKubeconfigObject(ctx context.Context) (*clientcmdapi.Config, error) {
+	return c.kubeconfigObject(ctx, &kubeconfigConfig{})
+}
+
+// kubeconfigObject reads the kubeconfig of the container and points it at the API server address for cfg
+func (c *EnvtestContainer) kubeconfigObject(
+	ctx context.Context,
+	cfg *kubeconfigConfig,
+) (*clientcmdapi.Config, error) {
+	if !cfg.networkAddress {
+		apiConfig, _, err := c.cachedKubeconfig(ctx)
+
+		return apiConfig, err
+	}
+
+	return c.loadKubeconfig(ctx, cfg)
+}
+
+// loadKubeconfig reads the kubeconfig from the container and points it at the API server address for cfg
+func (c *EnvtestContainer) loadKubeconfig(
+	ctx context.Context,
+	cfg *kubeconfigConfig,
+) (*clientcmdapi.Config, error) {
+	raw, err := c.readContainerFile(ctx, KubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+
 	// The kubeconfig has localhost as the server, we need to replace it
 	// with the actual container host and mapped port
-	host, err := c.Host(ctx)
+	serverURL, err := c.kubeconfigServerURL(ctx, cfg)
 	if err != nil {
-		return "", fmt.Errorf("failed to get container host: %w", err)
+		return nil, err
 	}
 
-	port, err := c.MappedPort(ctx, DefaultAPIServerPort+"/tcp")
+	apiConfig, err := rewriteKubeconfig(raw, serverURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to get mapped port: %w", err)
+		return nil, err
+	}
+
+	if err := renameKubeconfig(apiConfig, c.clusterName, c.contextName); err != nil {
+		return nil, err
+	}
+
+	return apiConfig, nil
+}
+
+// cachedKubeconfig returns copies of the kubeconfig for the mapped port and the rest.Config derived from it,
+// loading them from the container once until invalidateKubeconfig
+func (c *EnvtestContainer) cachedKubeconfig(
+	ctx context.Context,
+) (*clientcmdapi.Config, *rest.Config, error) {
+	c.kubeconfigMu.Lock()
+	defer c.kubeconfigMu.Unlock()
+
+	if c.kubeconfig == nil {
+		apiConfig, err := c.loadKubeconfig(ctx, &kubeconfigConfig{})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		clientConfig := clientcmd.NewDefaultClientConfig(*apiConfig, &clientcmd.ConfigOverrides{})
+
+		restConfig, err := clientConfig.ClientConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+		}
+
+		c.kubeconfig, c.restConfig = apiConfig, restConfig
+	}
+
+	return c.kubeconfig.DeepCopy(), rest.CopyConfig(c.restConfig), nil
+}
+
+// invalidateKubeconfig drops the cached kubeconfig after the container or its credentials changed
+func (c *EnvtestContainer) invalidateKubeconfig() {
+	c.kubeconfigMu.Lock()
+	defer c.kubeconfigMu.Unlock()
+
+	c.kubeconfig, c.restConfig = nil, nil
+}
+
+// rewriteKubeconfig parses a kubeconfig, YAML or JSON, and points its local clusters at serverURL
+func rewriteKubeconfig(raw []byte, serverURL string) (*clientcmdapi.Config, error) {
+	apiConfig, err := clientcmd.Load(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	// An empty or truncated file still parses, yielding a kubeconfig that fails later with a confusing error
+	if len(apiConfig.Clusters) == 0 || len(apiConfig.AuthInfos) == 0 {
+		return nil, errors.New("kubeconfig has no clusters or users")
 	}
 
-	// Parse and modify the kubeconfig
-	kubeconfig := string(buf)
-	// Replace the server URL
-	kubeconfig = replaceServerURL(kubeconfig, fmt.Sprintf("https://%s:%s", host, port.Port()))
+	setServerURL(apiConfig, serverURL)
 
-	return kubeconfig, nil
+	return apiConfig, nil
+}
+
+// kubeconfigServerURL returns the server URL of the kubeconfig: the mapped port on the container host,
+// or the network alias with WithNetworkAddress
+func (c *EnvtestContainer) kubeconfigServerURL(
+	ctx context.Context,
+	cfg *kubeconfigConfig,
+) (string, error) {
+	if !cfg.networkAddress {
+		return c.APIServerURL(ctx)
+	}
+
+	if c.network == "" || len(c.networkAliases) == 0 {
+		return "", errors.New(
+			"container has no network alias, start it WithNetwork to address it from the network",
+		)
+	}
+
+	return "https://" + net.JoinHostPort(c.networkAliases[0], DefaultAPIServerPort), nil
 }
 
 // APIServerURL returns the URL of the Kubernetes API server
@@ -137,18 +722,74 @@ func (c *EnvtestContainer) APIServerURL(ctx context.Context) (string, error) {
 
 // RESTConfig returns a *rest.Config configured for the envtest API server.
 // This config can be used with client-go or controller-runtime clients.
+// It authenticates as the admin user in the system:masters group, which is allowed everything
+// whatever the authorization modes.
+// The kubeconfig it is built from is read from the container once and cached until the container restarts.
 func (c *EnvtestContainer) RESTConfig(ctx context.Context) (*rest.Config, error) {
-	kubeconfig, err := c.Kubeconfig(ctx)
+	_, config, err := c.cachedKubeconfig(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
 
-	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	return config, nil
+}
+
+// Terminate stops the background helpers attached to the container (e.g. usage samplers),
+// unpauses it if needed and then terminates the container. The Events and AuditEvents channels are closed afterwards.
+func (c *EnvtestContainer) Terminate(
+	ctx context.Context,
+	opts ...testcontainers.TerminateOption,
+) error {
+	c.mu.Lock()
+	hooks := c.terminateHooks
+	c.terminateHooks = nil
+	c.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
 	}
 
-	return config, nil
+	c.unpauseIfPaused(ctx)
+
+	if err := c.Container.Terminate(ctx, opts...); err != nil {
+		return err
+	}
+
+	// The API server flushes its buffered audit events on shutdown
+	if c.auditWebhook != nil {
+		c.auditWebhook.stop(ctx)
+	}
+
+	c.events.emit(EventTerminated, "")
+	c.events.close()
+
+	return nil
+}
+
+// Restart stops the container and starts it again, waiting for the API server to be ready. The etcd data dir
+// is kept in the container filesystem, or in the volume of WithEtcdDataVolume, so objects survive the restart.
+// The entrypoint issues new certificates and the mapped ports may change, so the cached kubeconfig is dropped:
+// clients built from RESTConfig or Kubeconfig before the restart have to be rebuilt.
+func (c *EnvtestContainer) Restart(ctx context.Context) error {
+	c.unpauseIfPaused(ctx)
+
+	if err := c.Stop(ctx, nil); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	return nil
+}
+
+// onTerminate registers a hook run before the container is terminated
+func (c *EnvtestContainer) onTerminate(hook func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.terminateHooks = append(c.terminateHooks, hook)
 }
 
 // KubernetesVersion returns the Kubernetes version of the envtest container
@@ -156,26 +797,22 @@ func (c *EnvtestContainer) KubernetesVersion() string {
 	return c.kubernetesVersion
 }
 
-// replaceServerURL replaces the server URL in a kubeconfig string
-func replaceServerURL(kubeconfig, newURL string) string {
-	// Simple string replacement for the server URL
-	// The kubeconfig format has "server: https://localhost:PORT"
-	result := kubeconfig
-
-	for _, oldHost := range []string{"localhost", "127.0.0.1"} {
-		oldURL := fmt.Sprintf("server: https://%s:", oldHost)
-		if idx := strings.Index(result, oldURL); idx >= 0 {
-			// Find the end of the line
-			endIdx := idx + len(oldURL)
-			for endIdx < len(result) && result[endIdx] != '\n' && result[endIdx] != '\r' {
-				endIdx++
-			}
+// setServerURL points the clusters served by the local API server, i.e. on localhost, at newURL.
+// The serving certificate is still verified for the local name, as the container host of remote Docker daemons,
+// Podman machines or VMs is usually not among its SANs.
+func setServerURL(apiConfig *clientcmdapi.Config, newURL string) {
+	for _, cluster := range apiConfig.Clusters {
+		u, err := url.Parse(cluster.Server)
+		if err != nil {
+			continue
+		}
 
-			result = result[:idx] + "server: " + newURL + result[endIdx:]
+		if host := u.Hostname(); host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			cluster.Server = newURL
 
-			break
+			if cluster.TLSServerName == "" {
+				cluster.TLSServerName = host
+			}
 		}
 	}
-
-	return result
 }
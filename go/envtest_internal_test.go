@@ -1,80 +1,295 @@
 package envtest
 
 import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-func TestReplaceServerURL(t *testing.T) {
+func TestSetServerURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+		newURL string
+		want   string
+	}{
+		{
+			name:   "replace localhost URL",
+			server: "https://localhost:6443",
+			newURL: "https://192.168.1.100:32768",
+			want:   "https://192.168.1.100:32768",
+		},
+		{
+			name:   "replace 127.0.0.1 URL",
+			server: "https://127.0.0.1:6443",
+			newURL: "https://host.docker.internal:45678",
+			want:   "https://host.docker.internal:45678",
+		},
+		{
+			name:   "no match - different host",
+			server: "https://kubernetes.default:443",
+			newURL: "https://192.168.1.100:32768",
+			want:   "https://kubernetes.default:443",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiConfig := clientcmdapi.NewConfig()
+			apiConfig.Clusters["envtest"] = &clientcmdapi.Cluster{Server: tt.server}
+
+			setServerURL(apiConfig, tt.newURL)
+			require.Equal(t, tt.want, apiConfig.Clusters["envtest"].Server)
+
+			// Rewritten clusters keep verifying the certificate for the original name
+			if tt.want != tt.server {
+				require.Equal(t, strings.TrimSuffix(strings.TrimPrefix(tt.server, "https://"), ":6443"),
+					apiConfig.Clusters["envtest"].TLSServerName)
+			} else {
+				require.Empty(t, apiConfig.Clusters["envtest"].TLSServerName)
+			}
+		})
+	}
+}
+
+// testKubeconfigUser completes the YAML kubeconfigs of TestRewriteKubeconfig
+const testKubeconfigUser = `users:
+- name: admin
+  user:
+    token: secret
+`
+
+func TestRewriteKubeconfig(t *testing.T) {
+	const newURL = "https://192.168.1.100:32768"
+
 	tests := []struct {
 		name       string
 		kubeconfig string
-		newURL     string
-		want       string
+		want       map[string]string
 	}{
 		{
-			name: "replace localhost URL",
+			name: "quoted server",
 			kubeconfig: `apiVersion: v1
+kind: Config
 clusters:
-- cluster:
-    server: https://localhost:6443
-  name: envtest
-`,
-			newURL: "https://192.168.1.100:32768",
-			want: `apiVersion: v1
-clusters:
-- cluster:
-    server: https://192.168.1.100:32768
-  name: envtest
-`,
+- name: envtest
+  cluster:
+    server: "https://localhost:6443"
+` + testKubeconfigUser,
+			want: map[string]string{"envtest": newURL},
 		},
 		{
-			name: "replace 127.0.0.1 URL",
+			name: "different indentation",
 			kubeconfig: `apiVersion: v1
+kind: Config
 clusters:
-- cluster:
-    server: https://127.0.0.1:6443
-  name: envtest
-`,
-			newURL: "https://host.docker.internal:45678",
-			want: `apiVersion: v1
-clusters:
-- cluster:
-    server: https://host.docker.internal:45678
-  name: envtest
-`,
+    -   name: envtest
+        cluster:
+            server: 'https://127.0.0.1:6443'
+` + testKubeconfigUser,
+			want: map[string]string{"envtest": newURL},
 		},
 		{
-			name: "no match - different host",
+			name: "multiple clusters",
 			kubeconfig: `apiVersion: v1
+kind: Config
 clusters:
-- cluster:
-    server: https://kubernetes.default:443
-  name: envtest
-`,
-			newURL: "https://192.168.1.100:32768",
-			want: `apiVersion: v1
-clusters:
-- cluster:
+- name: envtest
+  cluster:
+    server: https://127.0.0.1:6443
+- name: envtest-ipv6
+  cluster:
+    server: https://[::1]:6443
+- name: remote
+  cluster:
     server: https://kubernetes.default:443
-  name: envtest
-`,
+` + testKubeconfigUser,
+			want: map[string]string{
+				"envtest":      newURL,
+				"envtest-ipv6": newURL,
+				"remote":       "https://kubernetes.default:443",
+			},
 		},
 		{
-			name:       "empty kubeconfig",
-			kubeconfig: "",
-			newURL:     "https://localhost:1234",
-			want:       "",
+			name: "JSON",
+			kubeconfig: `{
+  "apiVersion": "v1",
+  "kind": "Config",
+  "clusters": [{"name": "envtest", "cluster": {"server": "https://localhost:6443"}}],
+  "users": [{"name": "admin", "user": {"token": "secret"}}]
+}`,
+			want: map[string]string{"envtest": newURL},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := replaceServerURL(tt.kubeconfig, tt.newURL)
+			apiConfig, err := rewriteKubeconfig([]byte(tt.kubeconfig), newURL)
+			require.NoError(t, err)
+
+			got := make(map[string]string, len(apiConfig.Clusters))
+			for name, cluster := range apiConfig.Clusters {
+				got[name] = cluster.Server
+			}
+
 			require.Equal(t, tt.want, got)
 		})
 	}
+
+	_, err := rewriteKubeconfig([]byte("clusters: ["), newURL)
+	require.ErrorContains(t, err, "failed to parse kubeconfig")
+}
+
+// fakeContainer serves the kubeconfig of the container from memory, counting the copies
+type fakeContainer struct {
+	testcontainers.Container
+
+	kubeconfig io.Reader
+	copies     atomic.Int32
+}
+
+func (f *fakeContainer) CopyFileFromContainer(_ context.Context, path string) (io.ReadCloser, error) {
+	if path != KubeconfigPath {
+		return nil, os.ErrNotExist
+	}
+
+	f.copies.Add(1)
+
+	// Readers of strings are served again on every copy
+	if r, ok := f.kubeconfig.(*strings.Reader); ok {
+		_, _ = r.Seek(0, io.SeekStart)
+	}
+
+	return io.NopCloser(f.kubeconfig), nil
+}
+
+func (f *fakeContainer) Host(context.Context) (string, error) {
+	return "127.0.0.1", nil
+}
+
+func (f *fakeContainer) MappedPort(context.Context, nat.Port) (nat.Port, error) {
+	return "32768/tcp", nil
+}
+
+// failingReader returns its data and then fails
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
+
+// testCachedKubeconfig is a minimal kubeconfig of the container
+const testCachedKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: envtest
+  cluster:
+    server: https://localhost:6443
+contexts:
+- name: envtest
+  context:
+    cluster: envtest
+    user: admin
+current-context: envtest
+` + testKubeconfigUser
+
+func TestKubeconfigCopyFailures(t *testing.T) {
+	valid := testCachedKubeconfig
+
+	t.Run("valid", func(t *testing.T) {
+		c := &EnvtestContainer{Container: &fakeContainer{kubeconfig: strings.NewReader(valid)}}
+
+		kubeconfig, err := c.Kubeconfig(t.Context())
+		require.NoError(t, err)
+		require.Contains(t, kubeconfig, "server: https://127.0.0.1:32768")
+	})
+
+	t.Run("error mid-stream", func(t *testing.T) {
+		copyErr := errors.New("connection reset by peer")
+		reader := &failingReader{data: []byte(valid[:len(valid)/2]), err: copyErr}
+		c := &EnvtestContainer{Container: &fakeContainer{kubeconfig: reader}}
+
+		_, err := c.Kubeconfig(t.Context())
+		require.ErrorIs(t, err, copyErr)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		truncated := valid[:strings.Index(valid, "users:")]
+		c := &EnvtestContainer{Container: &fakeContainer{kubeconfig: strings.NewReader(truncated)}}
+
+		_, err := c.Kubeconfig(t.Context())
+		require.ErrorContains(t, err, "no clusters or users")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := &EnvtestContainer{Container: &fakeContainer{kubeconfig: strings.NewReader("")}}
+
+		_, err := c.RESTConfig(t.Context())
+		require.ErrorContains(t, err, "no clusters or users")
+	})
+}
+
+func TestRESTConfigCached(t *testing.T) {
+	fake := &fakeContainer{kubeconfig: strings.NewReader(testCachedKubeconfig)}
+	c := &EnvtestContainer{Container: fake}
+
+	var wg sync.WaitGroup
+
+	configs := make([]*rest.Config, 50)
+	errs := make([]error, len(configs))
+
+	for i := range configs {
+		wg.Go(func() {
+			configs[i], errs[i] = c.RESTConfig(t.Context())
+		})
+	}
+
+	wg.Wait()
+
+	for i := range configs {
+		require.NoError(t, errs[i])
+		require.Equal(t, "https://127.0.0.1:32768", configs[i].Host)
+	}
+
+	require.Equal(t, int32(1), fake.copies.Load())
+
+	// Callers get their own copies
+	configs[0].Host = "https://changed"
+
+	cfg, err := c.RESTConfig(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "https://127.0.0.1:32768", cfg.Host)
+
+	_, err = c.Kubeconfig(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), fake.copies.Load())
+
+	c.invalidateKubeconfig()
+
+	_, err = c.RESTConfig(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int32(2), fake.copies.Load())
 }
 
 func TestConstants(t *testing.T) {
@@ -3,6 +3,7 @@ package envtest_test
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/k3s"
+	"github.com/testcontainers/testcontainers-go/wait"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -111,6 +113,102 @@ func TestEnvtestContainerWithKubernetesVersion(t *testing.T) {
 	require.Equal(t, "1.35.0", container.KubernetesVersion())
 }
 
+func TestEnvtestContainerUsableAfterRun(t *testing.T) {
+	for name, opts := range map[string][]envtest.Option{
+		"readyz":      getEnvtestOptions(),
+		"legacy wait": append(getEnvtestOptions(), envtest.WithLegacyWait()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+			defer cancel()
+
+			c, err := envtest.Run(ctx, opts...)
+			require.NoError(t, err)
+
+			defer func() {
+				err := testcontainers.TerminateContainer(c)
+				require.NoError(t, err)
+			}()
+
+			// No sleeping or polling: reads and writes succeed right away
+			cl, err := c.GetClient(ctx, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, cl.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "right-away"}}))
+
+			var namespaces corev1.NamespaceList
+
+			require.NoError(t, cl.List(ctx, &namespaces))
+			require.NotEmpty(t, namespaces.Items)
+		})
+	}
+}
+
+func TestEnvtestContainerWithStartupTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	_, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithStartupTimeout(time.Millisecond))...)
+	require.ErrorContains(t, err, "startup timeout of 1ms")
+}
+
+func TestEnvtestContainerWithWaitStrategy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	opts := append(getEnvtestOptions(), envtest.WithWaitStrategy(wait.ForLog("Kubeconfig: /tmp/kubeconfig")))
+
+	c, err := envtest.Run(ctx, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	// The custom strategy only waited for the log line, the API server is ready by then too
+	require.NoError(t, c.WaitForReady(ctx, envtest.DefaultReadyTimeout))
+}
+
+func TestEnvtestContainerRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	volume := "envtest-etcd-" + strings.ToLower(strings.ReplaceAll(t.Name(), "/", "-"))
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithEtcdDataVolume(volume))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c, testcontainers.RemoveVolumes(volume))
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "survives-restart"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Restart(ctx))
+
+	// The restarted API server has new certificates and possibly a new port
+	cfg, err = c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err = kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	got, err := clientset.CoreV1().Namespaces().Get(ctx, "survives-restart", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "survives-restart", got.Name)
+}
+
 func BenchmarkContainerLifecycle(b *testing.B) {
 	opts := getEnvtestOptions()
 
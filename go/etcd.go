@@ -0,0 +1,345 @@
+package envtest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultEtcdPort is the client port of etcd inside the container, published by WithEtcdExposed
+	DefaultEtcdPort = "2379"
+
+	// etcdEndpoint is the client URL etcd listens on inside the container
+	etcdEndpoint = "http://127.0.0.1:" + DefaultEtcdPort
+
+	// etcdDialTimeout bounds how long GetEtcdClient waits for the connection
+	etcdDialTimeout = 5 * time.Second
+)
+
+// etcdMemberStatus is the subset of etcd's maintenance status response used by the module
+type etcdMemberStatus struct {
+	Version     string
+	Revision    int64
+	DBSize      int64
+	DBSizeInUse int64
+}
+
+// etcdKeyValue is a single key-value pair stored in etcd
+type etcdKeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// etcdRequest calls etcd's JSON gRPC gateway from inside the container
+func (c *EnvtestContainer) etcdRequest(ctx context.Context, path string, body any) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode etcd request: %w", err)
+	}
+
+	output, err := c.execOutput(
+		ctx,
+		"curl",
+		"-sS",
+		"--fail-with-body",
+		"-X",
+		"POST",
+		etcdEndpoint+path,
+		"-d",
+		string(payload),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("etcd request to %s failed: %w", path, err)
+	}
+
+	return []byte(output), nil
+}
+
+// GetEtcdMetrics scrapes the etcd /metrics endpoint from inside the container,
+// so it works without exposing etcd to the host
+func (c *EnvtestContainer) GetEtcdMetrics(ctx context.Context) (MetricsSnapshot, error) {
+	output, err := c.execOutput(ctx, "curl", "-sS", "--fail-with-body", etcdEndpoint+"/metrics")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape etcd metrics: %w", err)
+	}
+
+	return parseMetrics(strings.NewReader(output))
+}
+
+// etcdStatus returns the endpoint status of the embedded etcd member
+func (c *EnvtestContainer) etcdStatus(ctx context.Context) (etcdMemberStatus, error) {
+	raw, err := c.etcdRequest(ctx, "/v3/maintenance/status", struct{}{})
+	if err != nil {
+		return etcdMemberStatus{}, err
+	}
+
+	return parseEtcdStatus(raw)
+}
+
+// parseEtcdStatus decodes the gateway's status response, where 64-bit integers are JSON strings
+func parseEtcdStatus(raw []byte) (etcdMemberStatus, error) {
+	var resp struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		Version     string `json:"version"`
+		DBSize      string `json:"dbSize"`
+		DBSizeInUse string `json:"dbSizeInUse"`
+	}
+
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return etcdMemberStatus{}, fmt.Errorf("failed to decode etcd status: %w", err)
+	}
+
+	status := etcdMemberStatus{Version: resp.Version}
+
+	for _, field := range []struct {
+		value string
+		dest  *int64
+	}{
+		{resp.Header.Revision, &status.Revision},
+		{resp.DBSize, &status.DBSize},
+		{resp.DBSizeInUse, &status.DBSizeInUse},
+	} {
+		if field.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(field.value, 10, 64)
+		if err != nil {
+			return etcdMemberStatus{}, fmt.Errorf("failed to decode etcd status: %w", err)
+		}
+
+		*field.dest = n
+	}
+
+	return status, nil
+}
+
+// etcdRange returns all key-value pairs whose key starts with the given prefix
+func (c *EnvtestContainer) etcdRange(ctx context.Context, prefix string) ([]etcdKeyValue, error) {
+	return c.etcdKVRange(ctx, map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixRangeEnd([]byte(prefix))),
+	})
+}
+
+// etcdKVRange runs a range request of the gateway, e.g. for a single key without a range_end
+func (c *EnvtestContainer) etcdKVRange(
+	ctx context.Context,
+	request map[string]string,
+) ([]etcdKeyValue, error) {
+	raw, err := c.etcdRequest(ctx, "/v3/kv/range", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEtcdRange(raw)
+}
+
+// parseEtcdRange decodes the gateway's range response, where keys and values are base64 encoded
+func parseEtcdRange(raw []byte) ([]etcdKeyValue, error) {
+	var resp struct {
+		KVs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode etcd range response: %w", err)
+	}
+
+	kvs := make([]etcdKeyValue, 0, len(resp.KVs))
+
+	for _, kv := range resp.KVs {
+		kvs = append(kvs, etcdKeyValue{Key: string(kv.Key), Value: kv.Value})
+	}
+
+	return kvs, nil
+}
+
+// prefixRangeEnd returns the range end that selects every key with the given prefix
+func prefixRangeEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+
+			return end[:i+1]
+		}
+	}
+
+	// The prefix is all 0xff bytes, so select everything from it onwards
+	return []byte{0}
+}
+
+// EtcdGet returns the values stored under the keys with the given prefix as the API server persisted them,
+// e.g. /registry/configmaps/default/ for the ConfigMaps of the default namespace. It reads etcd from inside
+// the container, so it works without WithEtcdExposed.
+func (c *EnvtestContainer) EtcdGet(
+	ctx context.Context,
+	keyPrefix string,
+) (map[string][]byte, error) {
+	kvs, err := c.etcdRange(ctx, keyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from etcd: %w", keyPrefix, err)
+	}
+
+	values := make(map[string][]byte, len(kvs))
+
+	for _, kv := range kvs {
+		values[kv.Key] = kv.Value
+	}
+
+	return values, nil
+}
+
+// EtcdGetKey returns the value stored under the etcd key as the API server persisted it, like EtcdGet,
+// e.g. the encrypted form of /registry/secrets/default/app with WithEncryptionConfig
+func (c *EnvtestContainer) EtcdGetKey(ctx context.Context, key string) ([]byte, error) {
+	kvs, err := c.etcdKVRange(
+		ctx,
+		map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(key))},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from etcd: %w", key, err)
+	}
+
+	if len(kvs) == 0 {
+		return nil, fmt.Errorf("etcd key %s not found", key)
+	}
+
+	return kvs[0].Value, nil
+}
+
+// EtcdEndpoint returns the client URL of etcd published by WithEtcdExposed
+func (c *EnvtestContainer) EtcdEndpoint(ctx context.Context) (string, error) {
+	if !c.etcdExposed {
+		return "", errors.New("etcd is not exposed, start the container WithEtcdExposed")
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get container host: %w", err)
+	}
+
+	port, err := c.MappedPort(ctx, DefaultEtcdPort+"/tcp")
+	if err != nil {
+		return "", fmt.Errorf("failed to get mapped etcd port: %w", err)
+	}
+
+	return "http://" + net.JoinHostPort(host, port.Port()), nil
+}
+
+// GetEtcdClient returns an etcd v3 client connected to the etcd published by WithEtcdExposed.
+// etcd serves plain HTTP without client authentication inside the container, so no certificates are needed.
+// The caller closes the client.
+func (c *EnvtestContainer) GetEtcdClient(ctx context.Context) (*clientv3.Client, error) {
+	endpoint, err := c.EtcdEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{endpoint},
+		DialTimeout: etcdDialTimeout,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to etcd at %s: %w", endpoint, err)
+	}
+
+	return client, nil
+}
+
+// etcdAlarm is an alarm raised by an etcd member, e.g. NOSPACE once the backend quota is exceeded
+type etcdAlarm struct {
+	MemberID string `json:"memberID"`
+	Alarm    string `json:"alarm"`
+}
+
+// parseEtcdAlarms decodes the gateway's alarm response, which omits the list when no alarm is raised
+func parseEtcdAlarms(raw []byte) ([]etcdAlarm, error) {
+	var resp struct {
+		Alarms []etcdAlarm `json:"alarms"`
+	}
+
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode etcd alarms: %w", err)
+	}
+
+	return resp.Alarms, nil
+}
+
+// CompactEtcd discards the etcd revision history up to the current revision, so deleted and overwritten objects
+// stop taking space, like `etcdctl compact --physical`. Watches resuming from a compacted resource version get
+// a "too old resource version" error and have to relist. Run DefragEtcd afterwards to return the space to the disk.
+func (c *EnvtestContainer) CompactEtcd(ctx context.Context) error {
+	status, err := c.etcdStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to compact etcd: %w", err)
+	}
+
+	_, err = c.etcdRequest(
+		ctx,
+		"/v3/kv/compaction",
+		map[string]any{"revision": status.Revision, "physical": true},
+	)
+
+	// Nothing was written since the last compaction, e.g. the periodic one of the API server
+	if err != nil && !strings.Contains(err.Error(), "required revision has been compacted") {
+		return fmt.Errorf("failed to compact etcd: %w", err)
+	}
+
+	return nil
+}
+
+// DefragEtcd rewrites the etcd database without the space freed by CompactEtcd, like `etcdctl defrag`,
+// and clears the NOSPACE alarm raised once the quota of WithEtcdQuotaBytes was exceeded, so writes resume.
+// etcd blocks reads and writes while defragmenting.
+func (c *EnvtestContainer) DefragEtcd(ctx context.Context) error {
+	if _, err := c.etcdRequest(ctx, "/v3/maintenance/defragment", struct{}{}); err != nil {
+		return fmt.Errorf("failed to defragment etcd: %w", err)
+	}
+
+	raw, err := c.etcdRequest(ctx, "/v3/maintenance/alarm", map[string]string{"action": "GET"})
+	if err != nil {
+		return fmt.Errorf("failed to list etcd alarms: %w", err)
+	}
+
+	alarms, err := parseEtcdAlarms(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, alarm := range alarms {
+		if alarm.Alarm != "NOSPACE" {
+			continue
+		}
+
+		disarm := map[string]string{
+			"action":   "DEACTIVATE",
+			"memberID": alarm.MemberID,
+			"alarm":    alarm.Alarm,
+		}
+
+		if _, err := c.etcdRequest(ctx, "/v3/maintenance/alarm", disarm); err != nil {
+			return fmt.Errorf("failed to clear etcd NOSPACE alarm: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEtcdStatus(t *testing.T) {
+	raw := []byte(`{"header":{"cluster_id":"14841639068965178418","member_id":"10276657743932975437",` +
+		`"revision":"412","raft_term":"2"},"version":"3.5.21","dbSize":"2293760","leader":"10276657743932975437",` +
+		`"raftIndex":"431","raftTerm":"2","raftAppliedIndex":"431","dbSizeInUse":"1150976"}`)
+
+	status, err := parseEtcdStatus(raw)
+	require.NoError(t, err)
+	require.Equal(t, etcdMemberStatus{
+		Version:     "3.5.21",
+		Revision:    412,
+		DBSize:      2293760,
+		DBSizeInUse: 1150976,
+	}, status)
+}
+
+func TestParseEtcdStatusInvalid(t *testing.T) {
+	_, err := parseEtcdStatus([]byte(`{"dbSize":"lots"}`))
+	require.Error(t, err)
+
+	_, err = parseEtcdStatus([]byte(`not json`))
+	require.Error(t, err)
+}
+
+func TestParseEtcdRange(t *testing.T) {
+	raw := []byte(`{"header":{"revision":"12"},"kvs":[` +
+		`{"key":"L3JlZ2lzdHJ5L2EvYg==","create_revision":"5","mod_revision":"5","version":"1","value":"eyJraW5kIjoiQSJ9"}` +
+		`],"count":"1"}`)
+
+	kvs, err := parseEtcdRange(raw)
+	require.NoError(t, err)
+	require.Equal(t, []etcdKeyValue{{Key: "/registry/a/b", Value: []byte(`{"kind":"A"}`)}}, kvs)
+
+	kvs, err = parseEtcdRange([]byte(`{"header":{"revision":"12"}}`))
+	require.NoError(t, err)
+	require.Empty(t, kvs)
+}
+
+func TestPrefixRangeEnd(t *testing.T) {
+	require.Equal(t, []byte("/registry/b"), prefixRangeEnd([]byte("/registry/a")))
+	require.Equal(t, []byte("/registry0"), prefixRangeEnd([]byte("/registry/")))
+	require.Equal(t, []byte{'a', 0x01}, prefixRangeEnd([]byte{'a', 0x00, 0xff}))
+	require.Equal(t, []byte{0}, prefixRangeEnd([]byte{0xff, 0xff}))
+}
+
+func TestEtcdEndpointRequiresExposure(t *testing.T) {
+	c := &EnvtestContainer{}
+
+	_, err := c.EtcdEndpoint(t.Context())
+	require.EqualError(t, err, "etcd is not exposed, start the container WithEtcdExposed")
+
+	_, err = c.GetEtcdClient(t.Context())
+	require.EqualError(t, err, "etcd is not exposed, start the container WithEtcdExposed")
+}
+
+func TestParseEtcdAlarms(t *testing.T) {
+	alarms, err := parseEtcdAlarms([]byte(`{"header":{"revision":"12"},"alarms":[{"memberID":"10276657743932975437","alarm":"NOSPACE"}]}`))
+	require.NoError(t, err)
+	require.Equal(t, []etcdAlarm{{MemberID: "10276657743932975437", Alarm: "NOSPACE"}}, alarms)
+
+	alarms, err = parseEtcdAlarms([]byte(`{"header":{"revision":"12"}}`))
+	require.NoError(t, err)
+	require.Empty(t, alarms)
+
+	_, err = parseEtcdAlarms([]byte(`not json`))
+	require.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package envtest_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerEtcdMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	before, err := c.GetEtcdMetrics(ctx)
+	require.NoError(t, err)
+
+	for i := range 20 {
+		_, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("etcd-metrics-%d", i)},
+			Data:       map[string]string{"key": "value"},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	after, err := c.GetEtcdMetrics(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, 1.0, after.Sum("etcd_server_has_leader", nil))
+	require.Positive(t, after.Sum("etcd_mvcc_db_total_size_in_bytes", nil))
+
+	// Every write is committed to the backend and fsynced to the WAL
+	for _, name := range []string{
+		"etcd_disk_backend_commit_duration_seconds_count",
+		"etcd_disk_wal_fsync_duration_seconds_count",
+		"etcd_mvcc_put_total",
+	} {
+		require.Greater(t, after.Sum(name, nil), before.Sum(name, nil), name)
+	}
+
+	require.GreaterOrEqual(t, after.Sum("etcd_mvcc_put_total", nil)-before.Sum("etcd_mvcc_put_total", nil), 20.0)
+}
+
+func TestEnvtestContainerWithEtcdExposed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithEtcdExposed())...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "stored"},
+		Data:       map[string]string{"key": "stored-value"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	etcd, err := c.GetEtcdClient(ctx)
+	require.NoError(t, err)
+
+	defer func() { _ = etcd.Close() }()
+
+	resp, err := etcd.Get(ctx, "/registry/configmaps/default/stored")
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+	// The API server stores built-in resources as protobuf, so the data is visible as is
+	require.Contains(t, string(resp.Kvs[0].Value), "stored-value")
+
+	values, err := c.EtcdGet(ctx, "/registry/configmaps/default/")
+	require.NoError(t, err)
+	require.Contains(t, values, "/registry/configmaps/default/stored")
+	require.Equal(t, resp.Kvs[0].Value, values["/registry/configmaps/default/stored"])
+}
+
+func TestEnvtestContainerEtcdCompactAndDefrag(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Minute)
+	defer cancel()
+
+	// 200 rounds write 100MiB through a 32MiB backend, which only fits as the history is compacted
+	const (
+		quota     = 32 << 20
+		rounds    = 200
+		valueSize = 512 << 10
+	)
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithEtcdQuotaBytes(quota))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	value := strings.Repeat("x", valueSize)
+
+	for i := range rounds {
+		name := fmt.Sprintf("stress-%d", i)
+
+		_, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       map[string]string{"payload": value},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err, "round %d", i)
+
+		err = clientset.CoreV1().ConfigMaps("default").Delete(ctx, name, metav1.DeleteOptions{})
+		require.NoError(t, err, "round %d", i)
+
+		if i%20 == 19 {
+			require.NoError(t, c.CompactEtcd(ctx))
+			require.NoError(t, c.DefragEtcd(ctx))
+		}
+	}
+
+	stats, err := c.GetStorageStats(ctx)
+	require.NoError(t, err)
+	require.Less(t, stats.DBSize, int64(quota))
+
+	// Compacting again without new writes is a no-op
+	require.NoError(t, c.CompactEtcd(ctx))
+}
+
+func TestEnvtestContainerWithEtcdFlags(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithEtcdFlags(map[string]string{"max-request-bytes": strconv.Itoa(256 << 10)}),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// Well below the 1MiB ConfigMap limit of the API server, but above the etcd request limit
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "too-large"},
+		Data:       map[string]string{"payload": strings.Repeat("x", 512<<10)},
+	}, metav1.CreateOptions{})
+	require.ErrorContains(t, err, "request is too large")
+
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "small"},
+		Data:       map[string]string{"payload": "x"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+func BenchmarkEtcdInMemory(b *testing.B) {
+	for name, opts := range map[string][]envtest.Option{
+		"disk":   getEnvtestOptions(),
+		"memory": append(getEnvtestOptions(), envtest.WithEtcdInMemory()),
+	} {
+		b.Run(name, func(b *testing.B) {
+			ctx := b.Context()
+
+			c, err := envtest.Run(ctx, opts...)
+			require.NoError(b, err)
+
+			defer func() { _ = testcontainers.TerminateContainer(c) }()
+
+			cfg, err := c.RESTConfig(ctx)
+			require.NoError(b, err)
+
+			clientset, err := kubernetes.NewForConfig(cfg)
+			require.NoError(b, err)
+
+			i := 0
+
+			for b.Loop() {
+				_, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("bench-%d", i)},
+					Data:       map[string]string{"key": "value"},
+				}, metav1.CreateOptions{})
+				require.NoError(b, err)
+
+				i++
+			}
+
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "objects/s")
+		})
+	}
+}
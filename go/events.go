@@ -0,0 +1,158 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// eventBufferSize is how many lifecycle events are kept for a subscriber that does not keep up
+const eventBufferSize = 64
+
+// LifecycleEventType is a lifecycle transition of the container
+type LifecycleEventType string
+
+const (
+	// EventCreated is emitted when the container has been created
+	EventCreated LifecycleEventType = "created"
+
+	// EventStarted is emitted when the container has been (re)started, with the mapped API server port as detail
+	EventStarted LifecycleEventType = "started"
+
+	// EventReady is emitted when the API server has passed the readiness checks after a (re)start
+	EventReady LifecycleEventType = "ready"
+
+	// EventStopped is emitted when the container has been stopped
+	EventStopped LifecycleEventType = "stopped"
+
+	// EventAPIServerRestarted is emitted when RestartAPIServer brought the API server back,
+	// with the new kube-apiserver PID as detail
+	EventAPIServerRestarted LifecycleEventType = "apiserver-restarted"
+
+	// EventReset is emitted when Reset has wiped the state of the cluster
+	EventReset LifecycleEventType = "reset"
+
+	// EventTerminated is emitted when the container has been terminated, right before the channel is closed
+	EventTerminated LifecycleEventType = "terminated"
+)
+
+// LifecycleEvent is a lifecycle transition of the container
+type LifecycleEvent struct {
+	Type LifecycleEventType
+	Time time.Time
+	// Detail is optional information about the transition, e.g. the new mapped port
+	Detail string
+}
+
+// lifecycleEvents buffers lifecycle events without ever blocking the emitter
+type lifecycleEvents struct {
+	mu      sync.Mutex
+	ch      chan LifecycleEvent
+	closed  bool
+	dropped int
+}
+
+func newLifecycleEvents() *lifecycleEvents {
+	return &lifecycleEvents{ch: make(chan LifecycleEvent, eventBufferSize)}
+}
+
+// emit queues an event, dropping it if the buffer is full or the channel is closed
+func (e *lifecycleEvents) emit(eventType LifecycleEventType, detail string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	select {
+	case e.ch <- LifecycleEvent{Type: eventType, Time: time.Now(), Detail: detail}:
+	default:
+		e.dropped++
+	}
+}
+
+// close closes the channel, once
+func (e *lifecycleEvents) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed {
+		e.closed = true
+		close(e.ch)
+	}
+}
+
+// hooks emits the container lifecycle events testcontainers reports
+func (e *lifecycleEvents) hooks() testcontainers.ContainerLifecycleHooks {
+	emitter := func(eventType LifecycleEventType) testcontainers.ContainerHook {
+		return func(context.Context, testcontainers.Container) error {
+			e.emit(eventType, "")
+
+			return nil
+		}
+	}
+
+	return testcontainers.ContainerLifecycleHooks{
+		PostCreates: []testcontainers.ContainerHook{emitter(EventCreated)},
+		PostStarts: []testcontainers.ContainerHook{
+			func(ctx context.Context, ctr testcontainers.Container) error {
+				var detail string
+
+				if port, err := ctr.MappedPort(ctx, DefaultAPIServerPort+"/tcp"); err == nil {
+					detail = "apiserver port " + port.Port()
+				}
+
+				e.emit(EventStarted, detail)
+
+				return nil
+			},
+		},
+		PostReadies: []testcontainers.ContainerHook{emitter(EventReady)},
+		PostStops:   []testcontainers.ContainerHook{emitter(EventStopped)},
+	}
+}
+
+// Events returns the channel lifecycle events of the container are delivered on, starting with the ones of Run.
+// Every call returns the same channel, so events are received by one consumer. Emitting never blocks:
+// the channel buffers up to 64 events and further ones are dropped (see DroppedEvents) until it is drained.
+// The channel is closed after EventTerminated.
+func (c *EnvtestContainer) Events() <-chan LifecycleEvent {
+	return c.events.ch
+}
+
+// DroppedEvents returns the number of lifecycle events dropped because the Events channel was full
+func (c *EnvtestContainer) DroppedEvents() int {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	return c.events.dropped
+}
+
+// WaitForEvent receives from Events until an event of the given type arrives and returns it.
+// Events of other types received meanwhile are discarded.
+func (c *EnvtestContainer) WaitForEvent(
+	ctx context.Context,
+	eventType LifecycleEventType,
+) (LifecycleEvent, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return LifecycleEvent{}, fmt.Errorf("no %s event received: %w", eventType, ctx.Err())
+		case event, ok := <-c.events.ch:
+			if !ok {
+				return LifecycleEvent{}, errors.New(
+					"lifecycle events channel closed before a " + string(eventType) + " event",
+				)
+			}
+
+			if event.Type == eventType {
+				return event, nil
+			}
+		}
+	}
+}
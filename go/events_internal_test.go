@@ -0,0 +1,63 @@
+package envtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleEventsDropWhenFull(t *testing.T) {
+	events := newLifecycleEvents()
+
+	for range eventBufferSize + 3 {
+		events.emit(EventStarted, "")
+	}
+
+	c := &EnvtestContainer{events: events}
+
+	require.Len(t, c.Events(), eventBufferSize)
+	require.Equal(t, 3, c.DroppedEvents())
+}
+
+func TestLifecycleEventsCloseOnce(t *testing.T) {
+	events := newLifecycleEvents()
+	events.emit(EventTerminated, "")
+	events.close()
+
+	require.NotPanics(t, func() {
+		events.close()
+		events.emit(EventStarted, "")
+	})
+
+	event, ok := <-events.ch
+	require.True(t, ok)
+	require.Equal(t, EventTerminated, event.Type)
+
+	_, ok = <-events.ch
+	require.False(t, ok)
+}
+
+func TestWaitForEvent(t *testing.T) {
+	c := &EnvtestContainer{events: newLifecycleEvents()}
+
+	c.events.emit(EventStarted, "apiserver port 32768")
+	c.events.emit(EventReady, "")
+
+	event, err := c.WaitForEvent(t.Context(), EventReady)
+	require.NoError(t, err)
+	require.Equal(t, EventReady, event.Type)
+	require.WithinDuration(t, time.Now(), event.Time, time.Minute)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = c.WaitForEvent(ctx, EventStopped)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	c.events.close()
+
+	_, err = c.WaitForEvent(t.Context(), EventStopped)
+	require.ErrorContains(t, err, "closed")
+}
@@ -0,0 +1,74 @@
+package envtest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvtestContainerLifecycleEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	terminated := false
+
+	defer func() {
+		if !terminated {
+			require.NoError(t, c.Terminate(context.Background()))
+		}
+	}()
+
+	require.NoError(t, c.RestartAPIServer(ctx))
+
+	restarted, err := c.WaitForEvent(ctx, envtest.EventAPIServerRestarted)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(restarted.Detail, "pid "), restarted.Detail)
+
+	require.NoError(t, c.Terminate(ctx))
+
+	terminated = true
+
+	var types []envtest.LifecycleEventType
+
+	for event := range c.Events() {
+		types = append(types, event.Type)
+	}
+
+	require.Equal(t, []envtest.LifecycleEventType{envtest.EventStopped, envtest.EventTerminated}, types)
+	require.Zero(t, c.DroppedEvents())
+
+	// Terminating again must not close the channel twice
+	require.NotPanics(t, func() {
+		_ = c.Terminate(ctx)
+	})
+}
+
+func TestEnvtestContainerRunEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, c.Terminate(context.Background()))
+	}()
+
+	expected := []envtest.LifecycleEventType{envtest.EventCreated, envtest.EventStarted, envtest.EventReady}
+
+	for _, eventType := range expected {
+		event := <-c.Events()
+		require.Equal(t, eventType, event.Type)
+
+		if eventType == envtest.EventStarted {
+			require.True(t, strings.HasPrefix(event.Detail, "apiserver port "), event.Detail)
+		}
+	}
+}
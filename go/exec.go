@@ -0,0 +1,52 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+)
+
+// execOutput runs a command inside the container and returns its combined output.
+// A non-zero exit code is reported as an error carrying the command output.
+func (c *EnvtestContainer) execOutput(ctx context.Context, cmd ...string) (string, error) {
+	exitCode, reader, err := c.Exec(ctx, cmd, tcexec.Multiplexed())
+	if err != nil {
+		return "", fmt.Errorf("failed to exec %q in container: %w", cmd[0], err)
+	}
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q output: %w", cmd[0], err)
+	}
+
+	if exitCode != 0 {
+		return "", fmt.Errorf(
+			"%q exited with code %d: %s",
+			cmd[0],
+			exitCode,
+			strings.TrimSpace(string(output)),
+		)
+	}
+
+	return string(output), nil
+}
+
+// readContainerFile copies a file out of the container and returns its content
+func (c *EnvtestContainer) readContainerFile(ctx context.Context, path string) ([]byte, error) {
+	reader, err := c.CopyFileFromContainer(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container: %w", path, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,278 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// fixtureCleanupTimeout bounds how long deleting the fixtures of a test may take
+	fixtureCleanupTimeout = 30 * time.Second
+
+	// maxFixtureTestNameLen keeps names derived from TestName short enough for DNS labels
+	maxFixtureTestNameLen = 40
+)
+
+// fixtureConfig holds the configuration for LoadFixtures
+type fixtureConfig struct {
+	namespace string
+	funcs     template.FuncMap
+}
+
+// FixtureOption is a functional option for configuring LoadFixtures
+type FixtureOption func(*fixtureConfig)
+
+// WithDefaultNamespace sets the namespace of namespaced fixtures that do not specify one (default: "default")
+func WithDefaultNamespace(namespace string) FixtureOption {
+	return func(c *fixtureConfig) {
+		c.namespace = namespace
+	}
+}
+
+// WithFixtureFuncs makes additional functions available to the fixture templates
+func WithFixtureFuncs(funcs template.FuncMap) FixtureOption {
+	return func(c *fixtureConfig) {
+		maps.Copy(c.funcs, funcs)
+	}
+}
+
+// fixture is a rendered object together with its name in the FixtureSet
+type fixture struct {
+	name string
+	obj  *unstructured.Unstructured
+}
+
+// FixtureSet is the objects created by LoadFixtures
+type FixtureSet struct {
+	t        testing.TB
+	fixtures []fixture
+}
+
+// LoadFixtures renders every .yaml/.yml file under dir, in lexical path order, through text/template
+// and creates the objects they contain. Besides the data map, templates can use {{ .TestName }}
+// (the test name as a DNS label) and {{ .Suffix }} (random per call), so the same fixtures can be loaded
+// by parallel tests. The objects are deleted in reverse order when the test finishes.
+//
+// Each object is named after its file path relative to dir without the extension, e.g. "apps/web";
+// files with several documents name them "apps/web#0", "apps/web#1" and so on.
+func LoadFixtures(
+	t testing.TB,
+	c *EnvtestContainer,
+	dir string,
+	data map[string]any,
+	opts ...FixtureOption,
+) *FixtureSet {
+	t.Helper()
+
+	cfg := &fixtureConfig{namespace: metav1.NamespaceDefault, funcs: template.FuncMap{}}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	values := map[string]any{
+		"TestName": fixtureTestName(t.Name()),
+		"Suffix":   utilrand.String(5),
+	}
+
+	maps.Copy(values, data)
+
+	fixtures, err := renderFixtures(dir, values, cfg.funcs)
+	if err != nil {
+		t.Fatalf("failed to render fixtures: %v", err)
+	}
+
+	ctx := context.WithoutCancel(t.Context())
+
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	set := &FixtureSet{t: t}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(ctx, fixtureCleanupTimeout)
+		defer cancel()
+
+		for i := len(set.fixtures) - 1; i >= 0; i-- {
+			obj := set.fixtures[i].obj
+
+			err := c.retry(ctx, "delete fixture", func(ctx context.Context) error {
+				return client.IgnoreNotFound(cl.Delete(ctx, obj))
+			})
+			if err != nil {
+				t.Errorf("failed to delete fixture %s: %v", set.fixtures[i].name, err)
+			}
+		}
+	})
+
+	for _, f := range fixtures {
+		namespaced, err := cl.IsObjectNamespaced(f.obj)
+		if err != nil {
+			t.Fatalf("failed to resolve fixture %s: %v", f.name, err)
+		}
+
+		if namespaced && f.obj.GetNamespace() == "" {
+			f.obj.SetNamespace(cfg.namespace)
+		}
+
+		err = c.retry(ctx, "create fixture", func(ctx context.Context) error {
+			return createOrUpdate(ctx, cl, f.obj)
+		})
+		if err != nil {
+			t.Fatalf("failed to create fixture %s: %v", f.name, err)
+		}
+
+		set.fixtures = append(set.fixtures, f)
+	}
+
+	return set
+}
+
+// Get returns the key of the named fixture object, failing the test if there is none
+func (s *FixtureSet) Get(name string) client.ObjectKey {
+	s.t.Helper()
+
+	for _, f := range s.fixtures {
+		if f.name == name {
+			return client.ObjectKeyFromObject(f.obj)
+		}
+	}
+
+	s.t.Fatalf("no fixture named %q", name)
+
+	return client.ObjectKey{}
+}
+
+// renderFixtures renders the fixture files under dir in lexical path order
+func renderFixtures(dir string, data map[string]any, funcs template.FuncMap) ([]fixture, error) {
+	var fixtures []fixture
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read fixture: %w", err)
+		}
+
+		rendered, err := renderFixture(filepath.ToSlash(rel), content, data, funcs)
+		if err != nil {
+			return err
+		}
+
+		fixtures = append(fixtures, rendered...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+
+	return fixtures, nil
+}
+
+// renderFixture executes a fixture file template and decodes the objects it contains.
+// Template errors are reported as "template: <file>:<line>: ...".
+func renderFixture(
+	file string,
+	content []byte,
+	data map[string]any,
+	funcs template.FuncMap,
+) ([]fixture, error) {
+	tmpl, err := template.New(file).Option("missingkey=error").Funcs(funcs).Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	var objs []*unstructured.Unstructured
+
+	err = decodeDocuments("fixture "+file, &buf, func(i int, obj *unstructured.Unstructured) error {
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return fmt.Errorf("fixture %s document %d has no kind or name", file, i)
+		}
+
+		objs = append(objs, obj)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(file, filepath.Ext(file))
+	fixtures := make([]fixture, 0, len(objs))
+
+	for i, obj := range objs {
+		fixtureName := name
+		if len(objs) > 1 {
+			fixtureName += "#" + strconv.Itoa(i)
+		}
+
+		fixtures = append(fixtures, fixture{name: fixtureName, obj: obj})
+	}
+
+	return fixtures, nil
+}
+
+// fixtureTestName turns a test name into a lowercase DNS label fragment, hashing long names for uniqueness
+func fixtureTestName(testName string) string {
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, testName), "-")
+
+	if len(name) > maxFixtureTestNameLen {
+		sum := sha256.Sum256([]byte(testName))
+		suffix := "-" + hex.EncodeToString(sum[:])[:8]
+
+		name = strings.TrimRight(name[:maxFixtureTestNameLen-len(suffix)], "-") + suffix
+	}
+
+	return name
+}
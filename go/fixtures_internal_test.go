@@ -0,0 +1,119 @@
+package envtest
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderFixture(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-{{ .Suffix }}
+data:
+  replicas: "{{ .Replicas }}"
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-{{ .Suffix }}
+`)
+
+	fixtures, err := renderFixture("apps/web.yaml", content, map[string]any{"Suffix": "abcde", "Replicas": 3}, nil)
+	require.NoError(t, err)
+	require.Len(t, fixtures, 2)
+
+	require.Equal(t, "apps/web#0", fixtures[0].name)
+	require.Equal(t, "app-abcde", fixtures[0].obj.GetName())
+	require.Equal(t, map[string]any{"replicas": "3"}, fixtures[0].obj.Object["data"])
+
+	require.Equal(t, "apps/web#1", fixtures[1].name)
+	require.Equal(t, "Secret", fixtures[1].obj.GetKind())
+
+	single, err := renderFixture("ns.yml", []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns\n"), nil, nil)
+	require.NoError(t, err)
+	require.Len(t, single, 1)
+	require.Equal(t, "ns", single[0].name)
+}
+
+func TestRenderFixtureErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "parse error",
+			content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Name \n",
+			wantErr: "unclosed action started at apps/web.yaml:4",
+		},
+		{
+			name:    "missing key",
+			content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Missing }}\n",
+			wantErr: `template: apps/web.yaml:4:11: executing "apps/web.yaml" at <.Missing>`,
+		},
+		{
+			name:    "unknown function",
+			content: "apiVersion: v1\n\nkind: {{ kindOf }}\n",
+			wantErr: `template: apps/web.yaml:3: function "kindOf" not defined`,
+		},
+		{
+			name:    "invalid yaml",
+			content: "apiVersion: v1\nkind: [\n",
+			wantErr: "failed to decode fixture apps/web.yaml",
+		},
+		{
+			name:    "no name",
+			content: "apiVersion: v1\nkind: ConfigMap\n",
+			wantErr: "fixture apps/web.yaml document 0 has no kind or name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderFixture("apps/web.yaml", []byte(tt.content), map[string]any{"Name": "x"}, nil)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRenderFixtures(t *testing.T) {
+	funcs := template.FuncMap{"quote": strconv.Quote}
+
+	fixtures, err := renderFixtures(filepath.Join("testdata", "fixtures"), map[string]any{
+		"TestName": "test",
+		"Suffix":   "abcde",
+		"Replicas": 2,
+		"Password": "s3cr3t",
+	}, funcs)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(fixtures))
+	for _, f := range fixtures {
+		names = append(names, f.name)
+	}
+
+	require.Equal(t, []string{"00-namespace", "app/config#0", "app/config#1"}, names)
+	require.Equal(t, "test-abcde", fixtures[1].obj.GetNamespace())
+
+	_, err = renderFixtures(t.TempDir(), nil, funcs)
+	require.ErrorContains(t, err, "no fixtures found")
+
+	_, err = renderFixtures(filepath.Join(t.TempDir(), "missing"), nil, funcs)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFixtureTestName(t *testing.T) {
+	require.Equal(t, "testfixtures-parallel-run-1", fixtureTestName("TestFixtures/parallel_run_1"))
+
+	long := fixtureTestName("TestEnvtestContainerFixtures/a_very_long_subtest_name_that_goes_on_and_on")
+	require.LessOrEqual(t, len(long), maxFixtureTestNameLen)
+	require.Regexp(t, `^testenvtestcontainerfixtures-a-[0-9a-f]{8}$`, long)
+	require.NotEqual(t, long, fixtureTestName("TestEnvtestContainerFixtures/a_very_long_subtest_name_that_goes_on_and_on_2"))
+}
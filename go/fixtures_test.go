@@ -0,0 +1,81 @@
+package envtest_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"text/template"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerFixtures(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	})
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	cl, err := client.New(cfg, client.Options{})
+	require.NoError(t, err)
+
+	keys := make(chan client.ObjectKey, 2)
+
+	t.Run("loads", func(t *testing.T) {
+		for i, replicas := range []int{1, 3} {
+			t.Run("run "+strconv.Itoa(i), func(t *testing.T) {
+				t.Parallel()
+
+				set := envtest.LoadFixtures(t, c, "testdata/fixtures",
+					map[string]any{"Replicas": replicas, "Password": "s3cr3t"},
+					envtest.WithFixtureFuncs(template.FuncMap{"quote": strconv.Quote}),
+				)
+
+				key := set.Get("app/config#0")
+
+				cm := &corev1.ConfigMap{}
+				require.NoError(t, cl.Get(ctx, key, cm))
+				require.Equal(t, strconv.Itoa(replicas), cm.Data["replicas"])
+
+				secret := &corev1.Secret{}
+				require.NoError(t, cl.Get(ctx, set.Get("app/config#1"), secret))
+				require.Equal(t, key.Namespace, secret.Namespace)
+
+				ns := &corev1.Namespace{}
+				require.NoError(t, cl.Get(ctx, set.Get("00-namespace"), ns))
+				require.Equal(t, key.Namespace, ns.Name)
+
+				keys <- key
+			})
+		}
+	})
+
+	close(keys)
+
+	// Both runs used their own namespace and cleaned up after themselves
+	var namespaces []string
+
+	for key := range keys {
+		namespaces = append(namespaces, key.Namespace)
+
+		err := cl.Get(ctx, key, &corev1.ConfigMap{})
+		require.True(t, apierrors.IsNotFound(err), "fixture %s was not deleted: %v", key, err)
+	}
+
+	require.Len(t, namespaces, 2)
+	require.NotEqual(t, namespaces[0], namespaces[1])
+}
@@ -0,0 +1,68 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	flowcontrolv1 "k8s.io/api/flowcontrol/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerWithFlowSchema(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// The admin user is matched by the exempt FlowSchema, so requests are made with a token instead
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithAPIPriorityAndFairness(true),
+		envtest.WithManifests("testdata/flowcontrol/load.yaml"),
+		envtest.WithTokenAuth([]envtest.TokenUser{{Token: "load-token", Username: "load"}}),
+		envtest.WithAuthorizationModes("AlwaysAllow"),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	// The manifests are applied by the time Run returns
+	flowSchema := &flowcontrolv1.FlowSchema{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "load-test"}, flowSchema))
+	require.Equal(t, "load-test", flowSchema.Spec.PriorityLevelConfiguration.Name)
+
+	kubeconfig, err := c.KubeconfigForToken(ctx, "load-token")
+	require.NoError(t, err)
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	labels := map[string]string{"flow_schema": "load-test", "priority_level": "load-test"}
+
+	// The API server picks up the FlowSchema asynchronously
+	require.Eventually(t, func() bool {
+		if _, err := clientset.CoreV1().ConfigMaps("default").List(ctx, metav1.ListOptions{}); err != nil {
+			return false
+		}
+
+		metrics, err := c.GetMetrics(ctx)
+		if err != nil {
+			return false
+		}
+
+		return metrics.Sum("apiserver_flowcontrol_dispatched_requests_total", labels) > 0
+	}, 30*time.Second, 500*time.Millisecond, "no request was dispatched by the load-test FlowSchema")
+}
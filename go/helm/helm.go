@@ -0,0 +1,233 @@
+// Package helm installs Helm charts into an envtest container, e.g. the CRDs and RBAC an operator chart ships.
+// It is a module of its own, so only its importers depend on Helm.
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/yaml"
+)
+
+// WorkloadKinds are the kinds InstallChart skips unless WithWorkloads is given, as envtest runs no kubelet
+// or controllers to run their pods
+var WorkloadKinds = []schema.GroupKind{
+	{Kind: "Pod"},
+	{Kind: "ReplicationController"},
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+}
+
+// chartConfig holds the configuration for InstallChart
+type chartConfig struct {
+	releaseName string
+	namespace   string
+	skipHooks   bool
+	workloads   bool
+	applyOpts   []envtest.ApplyOption
+}
+
+// ChartOption is a functional option for configuring InstallChart
+type ChartOption func(*chartConfig)
+
+// WithReleaseName sets the release name the chart is rendered with (default: the chart name)
+func WithReleaseName(name string) ChartOption {
+	return func(c *chartConfig) {
+		c.releaseName = name
+	}
+}
+
+// WithNamespace sets the release namespace, which namespaced objects are installed into
+// unless they specify one (default: "default")
+func WithNamespace(namespace string) ChartOption {
+	return func(c *chartConfig) {
+		c.namespace = namespace
+	}
+}
+
+// WithSkipHooks leaves out the pre-install and post-install hooks of the chart
+func WithSkipHooks() ChartOption {
+	return func(c *chartConfig) {
+		c.skipHooks = true
+	}
+}
+
+// WithWorkloads also installs the objects of WorkloadKinds
+func WithWorkloads() ChartOption {
+	return func(c *chartConfig) {
+		c.workloads = true
+	}
+}
+
+// WithApplyOptions sets the options the rendered manifests are applied with, e.g. envtest.WithFieldManager
+func WithApplyOptions(opts ...envtest.ApplyOption) ChartOption {
+	return func(c *chartConfig) {
+		c.applyOpts = append(c.applyOpts, opts...)
+	}
+}
+
+// InstallChart renders the chart at chartPath, a directory or packaged archive, with the given values
+// for the Kubernetes version and API versions of the container like `helm template`, and applies the output
+// with envtest.Apply, CRDs included. No release is recorded, so the chart cannot be upgraded or uninstalled with Helm.
+func InstallChart(
+	ctx context.Context,
+	c *envtest.EnvtestContainer,
+	chartPath string,
+	values map[string]any,
+	opts ...ChartOption,
+) error {
+	cfg := &chartConfig{namespace: metav1.NamespaceDefault}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	restConfig, err := c.RESTConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	apiVersions, err := action.GetVersionSet(discoveryClient)
+	if err != nil {
+		return fmt.Errorf("failed to get API versions: %w", err)
+	}
+
+	kubeVersion := &chartutil.KubeVersion{
+		Version: info.GitVersion,
+		Major:   info.Major,
+		Minor:   info.Minor,
+	}
+
+	manifest, err := render(ctx, chartPath, values, cfg, kubeVersion, apiVersions)
+	if err != nil {
+		return err
+	}
+
+	applyOpts := append(
+		[]envtest.ApplyOption{envtest.WithApplyNamespace(cfg.namespace)},
+		cfg.applyOpts...,
+	)
+	if err := c.Apply(ctx, manifest, applyOpts...); err != nil {
+		return fmt.Errorf("failed to install chart %s: %w", chartPath, err)
+	}
+
+	return nil
+}
+
+// render renders the chart client-side and returns the manifest to apply
+func render(
+	ctx context.Context,
+	chartPath string,
+	values map[string]any,
+	cfg *chartConfig,
+	kubeVersion *chartutil.KubeVersion,
+	apiVersions chartutil.VersionSet,
+) ([]byte, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", chartPath, err)
+	}
+
+	install := action.NewInstall(&action.Configuration{Log: func(string, ...any) {}})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.IncludeCRDs = true
+	install.ReleaseName = cfg.releaseName
+	install.Namespace = cfg.namespace
+	install.KubeVersion = kubeVersion
+	install.APIVersions = apiVersions
+
+	if install.ReleaseName == "" {
+		install.ReleaseName = chrt.Name()
+	}
+
+	rel, err := install.RunWithContext(ctx, chrt, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %s: %w", chartPath, err)
+	}
+
+	var manifest bytes.Buffer
+
+	// Hooks are applied in the order Helm would run them, sorted by weight
+	if !cfg.skipHooks {
+		writeHooks(&manifest, rel.Hooks, release.HookPreInstall)
+	}
+
+	manifest.WriteString(rel.Manifest)
+
+	if !cfg.skipHooks {
+		writeHooks(&manifest, rel.Hooks, release.HookPostInstall)
+	}
+
+	if cfg.workloads {
+		return manifest.Bytes(), nil
+	}
+
+	return withoutWorkloads(manifest.Bytes())
+}
+
+// writeHooks appends the manifests of the hooks firing on the given event
+func writeHooks(manifest *bytes.Buffer, hooks []*release.Hook, event release.HookEvent) {
+	for _, hook := range hooks {
+		if slices.Contains(hook.Events, event) {
+			fmt.Fprintf(manifest, "\n---\n%s\n", hook.Manifest)
+		}
+	}
+}
+
+// withoutWorkloads drops the objects of WorkloadKinds from a manifest, keeping the order of the others
+func withoutWorkloads(manifest []byte) ([]byte, error) {
+	// An empty scheme keeps every object unstructured
+	objs, err := envtest.LoadObjects(runtime.NewScheme(), manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	for _, obj := range objs {
+		if slices.Contains(WorkloadKinds, obj.GetObjectKind().GroupVersionKind().GroupKind()) {
+			continue
+		}
+
+		doc, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to encode %s %s: %w",
+				obj.GetObjectKind().GroupVersionKind().Kind,
+				obj.GetName(),
+				err,
+			)
+		}
+
+		out.WriteString("---\n")
+		out.Write(doc)
+	}
+
+	return out.Bytes(), nil
+}
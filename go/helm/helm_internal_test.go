@@ -0,0 +1,84 @@
+package helm
+
+import (
+	"testing"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// renderedObjects renders the fixture chart and returns its objects as "Kind name"
+func renderedObjects(t *testing.T, values map[string]any, opts ...ChartOption) []string {
+	t.Helper()
+
+	cfg := &chartConfig{namespace: "operators"}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	kubeVersion := &chartutil.KubeVersion{Version: "v1.35.0", Major: "1", Minor: "35"}
+
+	manifest, err := render(t.Context(), "testdata/gizmo", values, cfg, kubeVersion, chartutil.VersionSet{"v1"})
+	require.NoError(t, err)
+
+	objs, err := envtest.LoadObjects(runtime.NewScheme(), manifest)
+	require.NoError(t, err)
+
+	var names []string
+	for _, obj := range objs {
+		names = append(names, obj.GetObjectKind().GroupVersionKind().Kind+" "+obj.GetName())
+	}
+
+	return names
+}
+
+func TestRender(t *testing.T) {
+	objs := renderedObjects(t, nil)
+
+	// CRDs are included, workloads and hooks not firing on install are left out
+	require.Equal(t, []string{
+		"CustomResourceDefinition gizmos.charts.example.com",
+		"ServiceAccount gizmo-controller",
+		"ConfigMap gizmo-settings",
+		"ClusterRole gizmo-controller",
+		"ClusterRoleBinding gizmo-controller",
+		"Gizmo gizmo-default",
+	}, objs)
+
+	objs = renderedObjects(t, nil, WithReleaseName("ops"), WithSkipHooks(), WithWorkloads())
+	require.Contains(t, objs, "Deployment ops-controller")
+	require.NotContains(t, objs, "Gizmo ops-default")
+}
+
+func TestRenderValues(t *testing.T) {
+	cfg := &chartConfig{namespace: "operators", releaseName: "ops"}
+	kubeVersion := &chartutil.KubeVersion{Version: "v1.35.0", Major: "1", Minor: "35"}
+
+	manifest, err := render(t.Context(), "testdata/gizmo", map[string]any{"mode": "slow"}, cfg, kubeVersion, nil)
+	require.NoError(t, err)
+	require.Contains(t, string(manifest), "mode: slow")
+	require.Contains(t, string(manifest), "kubeVersion: v1.35.0")
+	require.Contains(t, string(manifest), "namespace: operators")
+
+	_, err = render(t.Context(), "testdata/missing", nil, cfg, kubeVersion, nil)
+	require.ErrorContains(t, err, "failed to load chart testdata/missing")
+}
+
+func TestChartOptions(t *testing.T) {
+	cfg := &chartConfig{}
+
+	WithReleaseName("ops")(cfg)
+	WithNamespace("operators")(cfg)
+	WithSkipHooks()(cfg)
+	WithWorkloads()(cfg)
+	WithApplyOptions(envtest.WithForceConflicts())(cfg)
+
+	require.Equal(t, "ops", cfg.releaseName)
+	require.Equal(t, "operators", cfg.namespace)
+	require.True(t, cfg.skipHooks)
+	require.True(t, cfg.workloads)
+	require.Len(t, cfg.applyOpts, 1)
+}
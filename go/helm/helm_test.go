@@ -0,0 +1,64 @@
+package helm_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/roma-glushko/testcontainers-envtest/go/helm"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func getEnvtestOptions() []envtest.Option {
+	var opts []envtest.Option
+	if image := os.Getenv("ENVTEST_IMAGE"); image != "" {
+		opts = append(opts, envtest.WithImage(image))
+	}
+	return opts
+}
+
+func TestEnvtestContainerInstallChart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithManifests("testdata/manifests"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	err = helm.InstallChart(ctx, c, "testdata/gizmo", map[string]any{"mode": "slow"},
+		helm.WithReleaseName("ops"), helm.WithNamespace("operators"))
+	require.NoError(t, err)
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "operators", Name: "ops-settings"}, cm))
+	require.Equal(t, "slow", cm.Data["mode"])
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "operators", Name: "ops-controller"}, &corev1.ServiceAccount{}))
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "ops-controller"}, &rbacv1.ClusterRoleBinding{}))
+
+	// The post-install hook creates a custom resource of the chart's CRD
+	gizmo := &unstructured.Unstructured{}
+	gizmo.SetAPIVersion("charts.example.com/v1")
+	gizmo.SetKind("Gizmo")
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "operators", Name: "ops-default"}, gizmo))
+
+	// There is no kubelet to run the controller
+	err = cl.Get(ctx, client.ObjectKey{Namespace: "operators", Name: "ops-controller"}, &appsv1.Deployment{})
+	require.True(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+}
@@ -0,0 +1,141 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// hostGatewayAlias is the special extra-host value Docker resolves to the host gateway IP
+	hostGatewayAlias = "host-gateway"
+
+	// hostGatewayMinAPIVersion is the first Docker Engine API version (20.10) supporting host-gateway
+	hostGatewayMinAPIVersion = "1.41"
+)
+
+// HostAlias returns the hostname the container reaches the test host by.
+// It resolves inside the container whenever a host-access-dependent option (e.g. WithHostAccess) is set,
+// so callback URLs for servers running on the host should be built with it.
+func (c *EnvtestContainer) HostAlias() string {
+	return testcontainers.HostInternal
+}
+
+// HostGatewayAddress returns the IP address HostAlias resolves to inside the container, i.e. the Docker host gateway
+// or, with forwarded ports (see WithHostAccess), the testcontainers SSH tunnel.
+// It fails unless host access is enabled.
+func (c *EnvtestContainer) HostGatewayAddress(ctx context.Context) (string, error) {
+	hosts, err := c.readContainerFile(ctx, "/etc/hosts")
+	if err != nil {
+		return "", err
+	}
+
+	address, ok := hostsFileAddress(hosts, c.HostAlias())
+	if !ok {
+		return "", errors.New("host access is not enabled, start the container WithHostAccess")
+	}
+
+	return address, nil
+}
+
+// hostsFileAddress looks up the address of a hostname in an /etc/hosts file, the first entry winning like in libc
+func hostsFileAddress(hosts []byte, name string) (string, bool) {
+	for line := range strings.Lines(string(hosts)) {
+		line, _, _ = strings.Cut(line, "#")
+
+		fields := strings.Fields(line)
+		if len(fields) > 1 && slices.Contains(fields[1:], name) {
+			return fields[0], true
+		}
+	}
+
+	return "", false
+}
+
+// forwardedHostPorts returns the host ports to forward to the container: the ones given to WithHostAccess
+// and the ones of the webhook servers, OIDC issuer and OTLP collector the API server calls back.
+// Unset webhook ports are ignored.
+func (c *config) forwardedHostPorts(webhookPorts ...int) []int {
+	ports := slices.Clone(c.hostAccessPorts)
+
+	if c.crdConversionWebhook != nil {
+		ports = append(ports, c.crdConversionWebhook.Port)
+	}
+
+	if c.oidc != nil {
+		ports = append(ports, c.oidc.Port)
+	}
+
+	if c.tracing != nil && c.tracing.hostPort() != 0 {
+		ports = append(ports, c.tracing.hostPort())
+	}
+
+	for _, port := range webhookPorts {
+		if port != 0 {
+			ports = append(ports, port)
+		}
+	}
+
+	slices.Sort(ports)
+
+	return slices.Compact(ports)
+}
+
+// hostURL builds a URL pointing to a server on the test host as seen from the container
+func hostURL(scheme string, port int, path string) string {
+	return fmt.Sprintf(
+		"%s://%s%s",
+		scheme,
+		net.JoinHostPort(testcontainers.HostInternal, strconv.Itoa(port)),
+		path,
+	)
+}
+
+// hostGateway returns the extra-host target for the host alias: host-gateway on engines that support it,
+// otherwise the gateway IP of the default bridge network, which is how containers reach the host on Linux
+func hostGateway(ctx context.Context) (string, error) {
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get docker server version: %w", err)
+	}
+
+	if versions.GreaterThanOrEqualTo(version.APIVersion, hostGatewayMinAPIVersion) {
+		return hostGatewayAlias, nil
+	}
+
+	bridge, err := cli.NetworkInspect(ctx, network.NetworkBridge, network.InspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect bridge network: %w", err)
+	}
+
+	for _, ipam := range bridge.IPAM.Config {
+		if ipam.Gateway != "" {
+			return ipam.Gateway, nil
+		}
+	}
+
+	return "", errors.New("bridge network has no gateway")
+}
+
+// withHostAlias adds the host alias to the container /etc/hosts pointing to the given gateway
+func withHostAlias(gateway string) func(*container.HostConfig) {
+	return func(hc *container.HostConfig) {
+		hc.ExtraHosts = append(hc.ExtraHosts, testcontainers.HostInternal+":"+gateway)
+	}
+}
@@ -0,0 +1,63 @@
+package envtest
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHostAlias(t *testing.T) {
+	hc := &container.HostConfig{ExtraHosts: []string{"registry.local:10.0.0.5"}}
+
+	withHostAlias("host-gateway")(hc)
+
+	require.Equal(t, []string{"registry.local:10.0.0.5", "host.testcontainers.internal:host-gateway"}, hc.ExtraHosts)
+}
+
+func TestHostAliasConsistency(t *testing.T) {
+	c := &EnvtestContainer{}
+
+	u, err := url.Parse(hostURL("https", 9443, "/validate"))
+	require.NoError(t, err)
+	require.Equal(t, c.HostAlias(), u.Hostname())
+	require.Equal(t, "9443", u.Port())
+	require.Equal(t, "/validate", u.Path)
+
+	// Webhook serving certificates must be valid for the name generated URLs use
+	require.Contains(t, certs.DefaultHosts, c.HostAlias())
+}
+
+func TestHostsFileAddress(t *testing.T) {
+	hosts := []byte(`127.0.0.1	localhost
+::1	localhost ip6-localhost ip6-loopback
+# 10.0.0.9 host.testcontainers.internal
+172.17.0.1	host.testcontainers.internal	host.docker.internal
+172.18.0.1	host.testcontainers.internal
+172.17.0.2	d0c5e1f0a2b3
+`)
+
+	address, ok := hostsFileAddress(hosts, "host.testcontainers.internal")
+	require.True(t, ok)
+	require.Equal(t, "172.17.0.1", address)
+
+	address, ok = hostsFileAddress(hosts, "host.docker.internal")
+	require.True(t, ok)
+	require.Equal(t, "172.17.0.1", address)
+
+	_, ok = hostsFileAddress(hosts, "registry.local")
+	require.False(t, ok)
+}
+
+func TestForwardedHostPorts(t *testing.T) {
+	cfg := &config{}
+	require.Empty(t, cfg.forwardedHostPorts(0))
+
+	WithHostAccess(8443, 9443)(cfg)
+	WithCRDConversionWebhook(CRDConversionWebhook{Port: 9443})(cfg)
+	WithOIDC(OIDCOptions{Port: 5556, ClientID: "envtest"})(cfg)
+
+	require.Equal(t, []int{5556, 8443, 9443, 30001}, cfg.forwardedHostPorts(30001, 0))
+}
@@ -0,0 +1,178 @@
+package envtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestEnvtestContainerHostAccess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// Listen on all interfaces, the container reaches the host via the bridge gateway
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("hello from host"))
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() { _ = srv.Serve(listener) }()
+
+	defer func() { _ = srv.Close() }()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithHostAccess())...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	inspect, err := c.Inspect(ctx)
+	require.NoError(t, err)
+	require.Condition(t, func() bool {
+		for _, host := range inspect.HostConfig.ExtraHosts {
+			if strings.HasPrefix(host, c.HostAlias()+":") {
+				return true
+			}
+		}
+
+		return false
+	}, "extra hosts %v do not include %s", inspect.HostConfig.ExtraHosts, c.HostAlias())
+
+	address, err := c.HostGatewayAddress(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, net.ParseIP(address), address)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	url := fmt.Sprintf("http://%s:%d/", c.HostAlias(), port)
+
+	code, reader, err := c.Exec(ctx, []string{"curl", "-sS", "--max-time", "5", url}, tcexec.Multiplexed())
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, 0, code, string(out))
+	require.Equal(t, "hello from host", string(out))
+}
+
+func TestEnvtestContainerHostAccessPorts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// Only the given port is forwarded, through the SSH tunnel instead of the gateway
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithHostAccess(port))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	address, err := c.HostGatewayAddress(ctx)
+	require.NoError(t, err)
+
+	// A plain HTTPS server receiving admission reviews from the API server
+	pki, err := certs.NewWebhookPKI(address)
+	require.NoError(t, err)
+
+	tlsConfig, err := pki.TLSConfig()
+	require.NoError(t, err)
+
+	var reviews atomic.Int32
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			review := &admissionv1.AdmissionReview{}
+			if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			reviews.Add(1)
+
+			review.Response = &admissionv1.AdmissionResponse{
+				UID:    review.Request.UID,
+				Result: &metav1.Status{Message: "denied by the host"},
+			}
+			review.Request = nil
+
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(review)
+		}),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() { _ = srv.ServeTLS(listener, "", "") }()
+
+	defer func() { _ = srv.Close() }()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	webhookURL := fmt.Sprintf("https://%s/validate", net.JoinHostPort(address, strconv.Itoa(port)))
+	webhook := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "host-access"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:                    "host-access.example.com",
+			ClientConfig:            admissionregistrationv1.WebhookClientConfig{URL: &webhookURL, CABundle: pki.CACert},
+			AdmissionReviewVersions: []string{"v1"},
+			SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+			ObjectSelector:          &metav1.LabelSelector{MatchLabels: map[string]string{"host-access": "true"}},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"configmaps"},
+				},
+			}},
+		}},
+	}
+	require.NoError(t, cl.Create(ctx, webhook))
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "reviewed",
+		Namespace: "default",
+		Labels:    map[string]string{"host-access": "true"},
+	}}
+
+	// Webhook configurations take effect asynchronously
+	require.Eventually(t, func() bool {
+		err := cl.Create(ctx, cm)
+
+		return err != nil && strings.Contains(err.Error(), "denied by the host")
+	}, 30*time.Second, 200*time.Millisecond, "the admission review did not reach the host")
+	require.Positive(t, reviews.Load())
+}
@@ -0,0 +1,132 @@
+package envtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TestUserPrefix prefixes the impersonated user names derived from test names
+	TestUserPrefix = "test:"
+
+	// TestGroup is the group every per-test identity belongs to. It is bound to cluster-admin.
+	TestGroup = "envtest:tests"
+
+	// maxTestUserNameLen keeps derived user names readable in audit logs and events
+	maxTestUserNameLen = 128
+)
+
+// ClientForTest returns a controller-runtime client that impersonates an identity unique to the test,
+// so its requests can be told apart in audit events and metrics when many tests share one cluster.
+// The identity is a member of TestGroup, which is granted cluster-admin on first use.
+func (c *EnvtestContainer) ClientForTest(t testing.TB) (client.Client, error) {
+	cfg, err := c.RESTConfigForTest(t)
+	if err != nil {
+		return nil, err
+	}
+
+	cl, err := client.New(cfg, client.Options{Scheme: clientgoscheme.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s: %w", cfg.Impersonate.UserName, err)
+	}
+
+	return cl, nil
+}
+
+// RESTConfigForTest returns a *rest.Config that impersonates the identity of ClientForTest,
+// e.g. for a manager whose reconcilers should be attributed to the test
+func (c *EnvtestContainer) RESTConfigForTest(t testing.TB) (*rest.Config, error) {
+	ctx := t.Context()
+
+	if err := c.ensureTestGroupBinding(ctx); err != nil {
+		return nil, err
+	}
+
+	cfg, err := c.RESTConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Impersonate.UserName = TestUserName(t)
+	cfg.Impersonate.Groups = []string{TestGroup, user.AllAuthenticated}
+
+	return cfg, nil
+}
+
+// TestUserName returns the user name ClientForTest impersonates for the test, e.g. "test:TestFoo/case_1".
+// Characters outside [A-Za-z0-9/_.-] are replaced with "_", and long names are truncated
+// with a hash of the full name appended, so the result is deterministic and unique per test.
+func TestUserName(t testing.TB) string {
+	return testUserName(t.Name())
+}
+
+// testUserName derives the per-test user name from a test name
+func testUserName(testName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '/', r == '_', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, testName)
+
+	if len(TestUserPrefix)+len(name) > maxTestUserNameLen {
+		sum := sha256.Sum256([]byte(testName))
+		suffix := "-" + hex.EncodeToString(sum[:])[:10]
+
+		name = name[:maxTestUserNameLen-len(TestUserPrefix)-len(suffix)] + suffix
+	}
+
+	return TestUserPrefix + name
+}
+
+// ensureTestGroupBinding grants cluster-admin to TestGroup
+func (c *EnvtestContainer) ensureTestGroupBinding(ctx context.Context) error {
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return err
+	}
+
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: TestGroup},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "cluster-admin",
+		},
+		Subjects: []rbacv1.Subject{
+			{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: TestGroup},
+		},
+	}
+
+	err = c.retry(ctx, "create test group binding", func(ctx context.Context) error {
+		_, err := clientset.RbacV1().
+			ClusterRoleBindings().
+			Create(ctx, binding, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bind %s to cluster-admin: %w", TestGroup, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestUserName(t *testing.T) {
+	tests := []struct {
+		name     string
+		testName string
+		want     string
+	}{
+		{name: "top-level test", testName: "TestFoo", want: "test:TestFoo"},
+		{name: "subtest", testName: "TestFoo/case_1", want: "test:TestFoo/case_1"},
+		{name: "odd characters", testName: "TestFoo/a:b#c=ü", want: "test:TestFoo/a_b_c__"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, testUserName(tt.testName))
+		})
+	}
+}
+
+func TestTestUserNameLong(t *testing.T) {
+	long := "TestFoo/" + strings.Repeat("x", 200)
+	other := "TestFoo/" + strings.Repeat("x", 199) + "y"
+
+	name := testUserName(long)
+	require.Len(t, name, maxTestUserNameLen)
+	require.True(t, strings.HasPrefix(name, "test:TestFoo/xxx"))
+	require.Equal(t, name, testUserName(long), "names must be deterministic")
+	require.NotEqual(t, name, testUserName(other), "names sharing a prefix must stay unique")
+}
+
+func TestTestUserNameFromT(t *testing.T) {
+	t.Run("case 1", func(t *testing.T) {
+		require.Equal(t, "test:TestTestUserNameFromT/case_1", TestUserName(t))
+	})
+}
@@ -0,0 +1,75 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const metadataAuditPolicy = `apiVersion: audit.k8s.io/v1
+kind: Policy
+omitStages: ["RequestReceived"]
+rules:
+- level: Metadata
+`
+
+func TestEnvtestContainerClientForTest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithAuditPolicy([]byte(metadataAuditPolicy)))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	users := make(map[string]string)
+
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			cl, err := c.ClientForTest(t)
+			require.NoError(t, err)
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+			require.NoError(t, cl.Create(ctx, cm))
+
+			users[name] = envtest.TestUserName(t)
+		})
+	}
+
+	require.Equal(t, "test:TestEnvtestContainerClientForTest/first", users["first"])
+	require.Equal(t, "test:TestEnvtestContainerClientForTest/second", users["second"])
+
+	events, err := c.GetAuditEvents(ctx)
+	require.NoError(t, err)
+
+	// Requests are authenticated as the admin and attributed to the impersonated per-test user
+	created := make(map[string][]string)
+
+	for _, event := range events {
+		if event.Verb != "create" || event.ObjectRef == nil || event.ObjectRef.Resource != "configmaps" {
+			continue
+		}
+
+		if event.ImpersonatedUser == nil {
+			continue
+		}
+
+		require.Contains(t, event.ImpersonatedUser.Groups, envtest.TestGroup)
+
+		created[event.ImpersonatedUser.Username] = append(created[event.ImpersonatedUser.Username], event.ObjectRef.Name)
+	}
+
+	require.Equal(t, map[string][]string{
+		users["first"]:  {"first"},
+		users["second"]: {"second"},
+	}, created)
+}
@@ -0,0 +1,383 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// CACertFileName is the CA certificate file written by WithCertFiles
+	CACertFileName = "ca.crt"
+
+	// ClientCertFileName is the client certificate file written by WithCertFiles
+	ClientCertFileName = "client.crt"
+
+	// ClientKeyFileName is the client key file written by WithCertFiles
+	ClientKeyFileName = "client.key"
+
+	// KubeconfigFileName is the file WriteKubeconfig writes into a given directory
+	KubeconfigFileName = "kubeconfig"
+)
+
+// kubeconfigConfig holds the output shape of a kubeconfig
+type kubeconfigConfig struct {
+	certDir        string
+	relativeDir    string
+	networkAddress bool
+}
+
+// KubeconfigOption is a functional option for configuring the kubeconfig returned by Kubeconfig
+type KubeconfigOption func(*kubeconfigConfig)
+
+// WithFlatten returns a self-contained kubeconfig with the certificates inlined as base64 data.
+// This is the default.
+func WithFlatten() KubeconfigOption {
+	return func(c *kubeconfigConfig) {
+		c.certDir = ""
+		c.relativeDir = ""
+	}
+}
+
+// WithCertFiles writes the CA certificate, client certificate and client key into dir
+// (as ca.crt, client.crt and client.key) and returns a kubeconfig referencing them by absolute path
+func WithCertFiles(dir string) KubeconfigOption {
+	return func(c *kubeconfigConfig) {
+		c.certDir = dir
+	}
+}
+
+// WithRelativePaths makes the file references of WithCertFiles relative to kubeconfigDir,
+// the directory the kubeconfig is going to be saved in, so both can be moved together
+func WithRelativePaths(kubeconfigDir string) KubeconfigOption {
+	return func(c *kubeconfigConfig) {
+		c.relativeDir = kubeconfigDir
+	}
+}
+
+// WithNetworkAddress points the kubeconfig at the first network alias of the container (see WithNetwork)
+// and the in-container API server port, for clients running in other containers on the same network
+func WithNetworkAddress() KubeconfigOption {
+	return func(c *kubeconfigConfig) {
+		c.networkAddress = true
+	}
+}
+
+// WriteKubeconfig writes the self-contained kubeconfig to dir/kubeconfig, or to a new temporary file if dir is empty,
+// readable by the owner only, and returns its path, e.g. to pass as KUBECONFIG to kubectl or helm.
+// An existing file is replaced atomically, so it can be called again while tools are using the file.
+// Temporary files are not removed; pass t.TempDir() as dir to have them cleaned up with the test.
+func (c *EnvtestContainer) WriteKubeconfig(ctx context.Context, dir string) (string, error) {
+	kubeconfig, err := c.Kubeconfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return writeKubeconfigFile(dir, kubeconfig)
+}
+
+// writeKubeconfigFile writes the kubeconfig into dir as described in WriteKubeconfig
+func writeKubeconfigFile(dir, kubeconfig string) (string, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	// CreateTemp creates the file with 0600 permissions
+	file, err := os.CreateTemp(dir, "kubeconfig-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create kubeconfig file: %w", err)
+	}
+
+	_, err = file.WriteString(kubeconfig)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+
+		return "", fmt.Errorf("failed to write %s: %w", file.Name(), err)
+	}
+
+	if dir == "" {
+		return file.Name(), nil
+	}
+
+	path := filepath.Join(dir, KubeconfigFileName)
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		_ = os.Remove(file.Name())
+
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// UnsupportedAuthError is returned by GetClientCertificate when the kubeconfig user
+// does not authenticate with a client certificate
+type UnsupportedAuthError struct {
+	// Method is how the user authenticates instead, e.g. "token"
+	Method string
+}
+
+func (e *UnsupportedAuthError) Error() string {
+	return "kubeconfig user authenticates with " + e.Method + ", not a client certificate"
+}
+
+// GetCACertificate returns the PEM-encoded CA certificate the API server serving certificate is verified with
+func (c *EnvtestContainer) GetCACertificate(ctx context.Context) ([]byte, error) {
+	cluster, _, err := c.currentKubeconfigEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cluster.CertificateAuthorityData) > 0 {
+		return cluster.CertificateAuthorityData, nil
+	}
+
+	if cluster.CertificateAuthority == "" {
+		return nil, errors.New("kubeconfig cluster has no CA certificate")
+	}
+
+	return c.readContainerFile(ctx, cluster.CertificateAuthority)
+}
+
+// GetClientCertificate returns the PEM-encoded client certificate and key of the admin user.
+// It returns an *UnsupportedAuthError if the kubeconfig user authenticates otherwise, e.g. with a token.
+func (c *EnvtestContainer) GetClientCertificate(
+	ctx context.Context,
+) (certPEM, keyPEM []byte, err error) {
+	_, user, err := c.currentKubeconfigEntries(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkClientCertAuth(user); err != nil {
+		return nil, nil, err
+	}
+
+	certPEM, err = c.kubeconfigFileData(ctx, user.ClientCertificateData, user.ClientCertificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err = c.kubeconfigFileData(ctx, user.ClientKeyData, user.ClientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return certPEM, keyPEM, nil
+}
+
+// currentKubeconfigEntries returns the cluster and user of the current context of the container kubeconfig
+func (c *EnvtestContainer) currentKubeconfigEntries(
+	ctx context.Context,
+) (*clientcmdapi.Cluster, *clientcmdapi.AuthInfo, error) {
+	raw, err := c.readContainerFile(ctx, KubeconfigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return kubeconfigEntries(raw)
+}
+
+// kubeconfigFileData returns inline kubeconfig data or reads the referenced container file
+func (c *EnvtestContainer) kubeconfigFileData(
+	ctx context.Context,
+	data []byte,
+	path string,
+) ([]byte, error) {
+	if len(data) > 0 {
+		return data, nil
+	}
+
+	return c.readContainerFile(ctx, path)
+}
+
+// kubeconfigEntries returns the cluster and user of the current context of a kubeconfig
+func kubeconfigEntries(kubeconfig []byte) (*clientcmdapi.Cluster, *clientcmdapi.AuthInfo, error) {
+	apiConfig, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	kubeContext, ok := apiConfig.Contexts[apiConfig.CurrentContext]
+	if !ok {
+		return nil, nil, fmt.Errorf("kubeconfig has no context %q", apiConfig.CurrentContext)
+	}
+
+	cluster, ok := apiConfig.Clusters[kubeContext.Cluster]
+	if !ok {
+		return nil, nil, fmt.Errorf("kubeconfig has no cluster %q", kubeContext.Cluster)
+	}
+
+	user, ok := apiConfig.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return nil, nil, fmt.Errorf("kubeconfig has no user %q", kubeContext.AuthInfo)
+	}
+
+	return cluster, user, nil
+}
+
+// checkClientCertAuth reports how the user authenticates if it is not with a client certificate
+func checkClientCertAuth(user *clientcmdapi.AuthInfo) error {
+	hasCert := len(user.ClientCertificateData) > 0 || user.ClientCertificate != ""
+	hasKey := len(user.ClientKeyData) > 0 || user.ClientKey != ""
+
+	switch {
+	case hasCert && hasKey:
+		return nil
+	case user.Token != "" || user.TokenFile != "":
+		return &UnsupportedAuthError{Method: "token"}
+	case user.Username != "":
+		return &UnsupportedAuthError{Method: "basic auth"}
+	case user.Exec != nil:
+		return &UnsupportedAuthError{Method: "exec plugin"}
+	case user.AuthProvider != nil:
+		return &UnsupportedAuthError{Method: "auth provider " + user.AuthProvider.Name}
+	default:
+		return &UnsupportedAuthError{Method: "no credentials"}
+	}
+}
+
+// renameKubeconfig renames the cluster, user and current context of a kubeconfig, updating every context
+// referring to them. The user is named after the cluster and the context defaults to the cluster name too.
+func renameKubeconfig(apiConfig *clientcmdapi.Config, clusterName, contextName string) error {
+	if contextName == "" {
+		contextName = clusterName
+	}
+
+	if contextName == "" {
+		return nil
+	}
+
+	current, ok := apiConfig.Contexts[apiConfig.CurrentContext]
+	if !ok {
+		return fmt.Errorf("kubeconfig has no context %q", apiConfig.CurrentContext)
+	}
+
+	if clusterName != "" {
+		oldCluster, oldUser := current.Cluster, current.AuthInfo
+
+		renameEntry(apiConfig.Clusters, oldCluster, clusterName)
+		renameEntry(apiConfig.AuthInfos, oldUser, clusterName)
+
+		for _, kubeContext := range apiConfig.Contexts {
+			if kubeContext.Cluster == oldCluster {
+				kubeContext.Cluster = clusterName
+			}
+
+			if kubeContext.AuthInfo == oldUser {
+				kubeContext.AuthInfo = clusterName
+			}
+		}
+	}
+
+	renameEntry(apiConfig.Contexts, apiConfig.CurrentContext, contextName)
+	apiConfig.CurrentContext = contextName
+
+	return nil
+}
+
+// renameEntry moves a kubeconfig entry to a new name
+func renameEntry[T any](entries map[string]T, oldName, newName string) {
+	entry, ok := entries[oldName]
+	if !ok || oldName == newName {
+		return
+	}
+
+	delete(entries, oldName)
+	entries[newName] = entry
+}
+
+// externalizeCerts writes the inline certificate data of a kubeconfig into files and replaces it with references
+func externalizeCerts(apiConfig *clientcmdapi.Config, cfg *kubeconfigConfig) error {
+	dir, err := filepath.Abs(cfg.certDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", cfg.certDir, err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	writeFile := func(name string, data []byte, perm os.FileMode) (string, error) {
+		path := filepath.Join(dir, name)
+
+		if err := os.WriteFile(path, data, perm); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		return cfg.referencePath(path)
+	}
+
+	for _, cluster := range apiConfig.Clusters {
+		if len(cluster.CertificateAuthorityData) == 0 {
+			continue
+		}
+
+		cluster.CertificateAuthority, err = writeFile(
+			CACertFileName,
+			cluster.CertificateAuthorityData,
+			0o644,
+		)
+		if err != nil {
+			return err
+		}
+
+		cluster.CertificateAuthorityData = nil
+	}
+
+	for _, user := range apiConfig.AuthInfos {
+		if len(user.ClientCertificateData) > 0 {
+			user.ClientCertificate, err = writeFile(
+				ClientCertFileName,
+				user.ClientCertificateData,
+				0o644,
+			)
+			if err != nil {
+				return err
+			}
+
+			user.ClientCertificateData = nil
+		}
+
+		if len(user.ClientKeyData) > 0 {
+			user.ClientKey, err = writeFile(ClientKeyFileName, user.ClientKeyData, 0o600)
+			if err != nil {
+				return err
+			}
+
+			user.ClientKeyData = nil
+		}
+	}
+
+	return nil
+}
+
+// referencePath returns how the kubeconfig refers to a written file
+func (c *kubeconfigConfig) referencePath(path string) (string, error) {
+	if c.relativeDir == "" {
+		return path, nil
+	}
+
+	base, err := filepath.Abs(c.relativeDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", c.relativeDir, err)
+	}
+
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to make %s relative to %s: %w", path, base, err)
+	}
+
+	return rel, nil
+}
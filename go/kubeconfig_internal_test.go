@@ -0,0 +1,249 @@
+package envtest
+
+import (
+	"encoding/base64"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func testKubeconfig() string {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	return `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: ` + encode("ca") + `
+    server: https://127.0.0.1:32768
+  name: envtest
+contexts:
+- context:
+    cluster: envtest
+    user: admin
+  name: envtest
+current-context: envtest
+users:
+- name: admin
+  user:
+    client-certificate-data: ` + encode("cert") + `
+    client-key-data: ` + encode("key") + `
+`
+}
+
+func loadTestKubeconfig(t *testing.T) *clientcmdapi.Config {
+	t.Helper()
+
+	cfg, err := clientcmd.Load([]byte(testKubeconfig()))
+	require.NoError(t, err)
+
+	return cfg
+}
+
+func TestExternalizeCerts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	cfg := loadTestKubeconfig(t)
+	require.NoError(t, externalizeCerts(cfg, &kubeconfigConfig{certDir: dir}))
+
+	cluster := cfg.Clusters["envtest"]
+	require.Empty(t, cluster.CertificateAuthorityData)
+	require.Equal(t, filepath.Join(dir, CACertFileName), cluster.CertificateAuthority)
+	require.Equal(t, "https://127.0.0.1:32768", cluster.Server)
+
+	user := cfg.AuthInfos["admin"]
+	require.Empty(t, user.ClientCertificateData)
+	require.Empty(t, user.ClientKeyData)
+	require.Equal(t, filepath.Join(dir, ClientCertFileName), user.ClientCertificate)
+	require.Equal(t, filepath.Join(dir, ClientKeyFileName), user.ClientKey)
+
+	for name, want := range map[string]string{CACertFileName: "ca", ClientCertFileName: "cert", ClientKeyFileName: "key"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, want, string(data))
+	}
+
+	info, err := os.Stat(filepath.Join(dir, ClientKeyFileName))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestExternalizeCertsRelativePaths(t *testing.T) {
+	root := t.TempDir()
+
+	cfg := loadTestKubeconfig(t)
+
+	err := externalizeCerts(cfg, &kubeconfigConfig{
+		certDir:     filepath.Join(root, "certs"),
+		relativeDir: root,
+	})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("certs", CACertFileName), cfg.Clusters["envtest"].CertificateAuthority)
+	require.Equal(t, filepath.Join("certs", ClientKeyFileName), cfg.AuthInfos["admin"].ClientKey)
+
+	// Relative references resolve against the location of the kubeconfig file
+	path := filepath.Join(root, "kubeconfig")
+	require.NoError(t, clientcmd.WriteToFile(*cfg, path))
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "certs", CACertFileName), restConfig.CAFile)
+}
+
+func TestKubeconfigOptions(t *testing.T) {
+	cfg := &kubeconfigConfig{}
+
+	WithCertFiles("certs")(cfg)
+	WithRelativePaths(".")(cfg)
+	require.Equal(t, &kubeconfigConfig{certDir: "certs", relativeDir: "."}, cfg)
+
+	WithFlatten()(cfg)
+	require.Equal(t, &kubeconfigConfig{}, cfg)
+}
+
+func TestWriteKubeconfigFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested")
+
+	path, err := writeKubeconfigFile(dir, "first")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, KubeconfigFileName), path)
+
+	path, err = writeKubeconfigFile(dir, "second")
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "second", string(content))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files were left behind")
+}
+
+func TestWriteKubeconfigFileTemp(t *testing.T) {
+	first, err := writeKubeconfigFile("", "kubeconfig")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = os.Remove(first) })
+
+	second, err := writeKubeconfigFile("", "kubeconfig")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = os.Remove(second) })
+
+	require.NotEqual(t, first, second)
+
+	info, err := os.Stat(first)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestKubeconfigEntries(t *testing.T) {
+	cluster, user, err := kubeconfigEntries([]byte(testKubeconfig()))
+	require.NoError(t, err)
+	require.Equal(t, []byte("ca"), cluster.CertificateAuthorityData)
+	require.Equal(t, []byte("cert"), user.ClientCertificateData)
+	require.NoError(t, checkClientCertAuth(user))
+
+	_, _, err = kubeconfigEntries([]byte(strings.Replace(testKubeconfig(), "current-context: envtest", "current-context: other", 1)))
+	require.ErrorContains(t, err, `no context "other"`)
+}
+
+func TestCheckClientCertAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   *clientcmdapi.AuthInfo
+		method string
+	}{
+		{name: "cert files", user: &clientcmdapi.AuthInfo{ClientCertificate: "/c.crt", ClientKey: "/c.key"}},
+		{name: "token", user: &clientcmdapi.AuthInfo{Token: "secret"}, method: "token"},
+		{name: "token file", user: &clientcmdapi.AuthInfo{TokenFile: "/token"}, method: "token"},
+		{name: "cert without key", user: &clientcmdapi.AuthInfo{ClientCertificate: "/c.crt"}, method: "no credentials"},
+		{name: "exec", user: &clientcmdapi.AuthInfo{Exec: &clientcmdapi.ExecConfig{}}, method: "exec plugin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClientCertAuth(tt.user)
+			if tt.method == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var authErr *UnsupportedAuthError
+
+			require.ErrorAs(t, err, &authErr)
+			require.Equal(t, tt.method, authErr.Method)
+		})
+	}
+}
+
+func TestRenameKubeconfig(t *testing.T) {
+	multiContext := func() *clientcmdapi.Config {
+		cfg := loadTestKubeconfig(t)
+		// A second context sharing the cluster and user, and an unrelated one
+		cfg.Contexts["envtest-system"] = &clientcmdapi.Context{Cluster: "envtest", AuthInfo: "admin", Namespace: "kube-system"}
+		cfg.Clusters["other"] = &clientcmdapi.Cluster{Server: "https://other:6443"}
+		cfg.AuthInfos["other"] = &clientcmdapi.AuthInfo{Token: "secret"}
+		cfg.Contexts["other"] = &clientcmdapi.Context{Cluster: "other", AuthInfo: "other"}
+
+		return cfg
+	}
+
+	t.Run("cluster and context", func(t *testing.T) {
+		cfg := multiContext()
+		require.NoError(t, renameKubeconfig(cfg, "debug-cluster", "debug"))
+
+		require.Equal(t, "debug", cfg.CurrentContext)
+		require.ElementsMatch(t, []string{"debug", "envtest-system", "other"}, slices.Collect(maps.Keys(cfg.Contexts)))
+		require.ElementsMatch(t, []string{"debug-cluster", "other"}, slices.Collect(maps.Keys(cfg.Clusters)))
+		require.ElementsMatch(t, []string{"debug-cluster", "other"}, slices.Collect(maps.Keys(cfg.AuthInfos)))
+
+		for _, name := range []string{"debug", "envtest-system"} {
+			require.Equal(t, "debug-cluster", cfg.Contexts[name].Cluster)
+			require.Equal(t, "debug-cluster", cfg.Contexts[name].AuthInfo)
+		}
+
+		require.Equal(t, "other", cfg.Contexts["other"].Cluster)
+		require.Equal(t, []byte("cert"), cfg.AuthInfos["debug-cluster"].ClientCertificateData)
+
+		// The renamed kubeconfig is still valid
+		_, err := clientcmd.NewDefaultClientConfig(*cfg, &clientcmd.ConfigOverrides{}).ClientConfig()
+		require.NoError(t, err)
+	})
+
+	t.Run("cluster only", func(t *testing.T) {
+		cfg := multiContext()
+		require.NoError(t, renameKubeconfig(cfg, "debug-cluster", ""))
+
+		require.Equal(t, "debug-cluster", cfg.CurrentContext)
+		require.Equal(t, "debug-cluster", cfg.Contexts["debug-cluster"].Cluster)
+	})
+
+	t.Run("context only", func(t *testing.T) {
+		cfg := multiContext()
+		require.NoError(t, renameKubeconfig(cfg, "", "debug"))
+
+		require.Equal(t, "debug", cfg.CurrentContext)
+		require.Equal(t, "envtest", cfg.Contexts["debug"].Cluster)
+		require.Equal(t, "admin", cfg.Contexts["debug"].AuthInfo)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		cfg := multiContext()
+		require.NoError(t, renameKubeconfig(cfg, "", ""))
+		require.Equal(t, multiContext(), cfg)
+	})
+}
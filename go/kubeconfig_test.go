@@ -0,0 +1,176 @@
+package envtest_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestEnvtestContainerKubeconfigShapes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	listNamespaces := func(t *testing.T, cfg *rest.Config) {
+		t.Helper()
+
+		clientset, err := kubernetes.NewForConfig(cfg)
+		require.NoError(t, err)
+
+		namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.NotEmpty(t, namespaces.Items)
+	}
+
+	t.Run("flattened", func(t *testing.T) {
+		kubeconfig, err := c.Kubeconfig(ctx, envtest.WithFlatten())
+		require.NoError(t, err)
+
+		apiConfig, err := clientcmd.Load([]byte(kubeconfig))
+		require.NoError(t, err)
+
+		for _, cluster := range apiConfig.Clusters {
+			require.NotEmpty(t, cluster.CertificateAuthorityData)
+			require.Empty(t, cluster.CertificateAuthority)
+		}
+
+		cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+		require.NoError(t, err)
+
+		listNamespaces(t, cfg)
+	})
+
+	t.Run("absolute cert files", func(t *testing.T) {
+		dir := t.TempDir()
+
+		kubeconfig, err := c.Kubeconfig(ctx, envtest.WithCertFiles(dir))
+		require.NoError(t, err)
+
+		apiConfig, err := clientcmd.Load([]byte(kubeconfig))
+		require.NoError(t, err)
+
+		for _, cluster := range apiConfig.Clusters {
+			require.Empty(t, cluster.CertificateAuthorityData)
+			require.Equal(t, filepath.Join(dir, envtest.CACertFileName), cluster.CertificateAuthority)
+		}
+
+		cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+		require.NoError(t, err)
+
+		listNamespaces(t, cfg)
+	})
+
+	t.Run("relative cert files", func(t *testing.T) {
+		root := t.TempDir()
+
+		kubeconfig, err := c.Kubeconfig(ctx,
+			envtest.WithCertFiles(filepath.Join(root, "pki")),
+			envtest.WithRelativePaths(root),
+		)
+		require.NoError(t, err)
+
+		path := filepath.Join(root, "kubeconfig")
+		require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0o600))
+
+		cfg, err := clientcmd.BuildConfigFromFlags("", path)
+		require.NoError(t, err)
+
+		listNamespaces(t, cfg)
+	})
+	t.Run("written file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		path, err := c.WriteKubeconfig(ctx, dir)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, envtest.KubeconfigFileName), path)
+
+		// Rewriting the file in place is fine
+		path, err = c.WriteKubeconfig(ctx, dir)
+		require.NoError(t, err)
+
+		cfg, err := clientcmd.BuildConfigFromFlags("", path)
+		require.NoError(t, err)
+
+		listNamespaces(t, cfg)
+	})
+	t.Run("raw certificates", func(t *testing.T) {
+		caPEM, err := c.GetCACertificate(ctx)
+		require.NoError(t, err)
+
+		certPEM, keyPEM, err := c.GetClientCertificate(ctx)
+		require.NoError(t, err)
+
+		url, err := c.APIServerURL(ctx)
+		require.NoError(t, err)
+
+		// The way a client that cannot consume a kubeconfig is configured
+		cfg := &rest.Config{
+			Host: url,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData:   caPEM,
+				CertData: certPEM,
+				KeyData:  keyPEM,
+			},
+		}
+
+		listNamespaces(t, cfg)
+	})
+	t.Run("parsed object", func(t *testing.T) {
+		apiConfig, err := c.GetKubeconfigObject(ctx)
+		require.NoError(t, err)
+
+		url, err := c.APIServerURL(ctx)
+		require.NoError(t, err)
+
+		// Rename the context, e.g. before merging it into another kubeconfig
+		kubeContext := apiConfig.Contexts[apiConfig.CurrentContext]
+		require.Equal(t, url, apiConfig.Clusters[kubeContext.Cluster].Server)
+
+		delete(apiConfig.Contexts, apiConfig.CurrentContext)
+		apiConfig.Contexts["renamed"] = kubeContext
+		apiConfig.CurrentContext = "renamed"
+
+		cfg, err := clientcmd.NewDefaultClientConfig(*apiConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+		require.NoError(t, err)
+
+		listNamespaces(t, cfg)
+	})
+	t.Run("server name override", func(t *testing.T) {
+		cfg, err := c.RESTConfig(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "localhost", cfg.ServerName)
+
+		// Reach the API server under a name outside of its certificate SANs,
+		// as with remote Docker daemons or Podman machines
+		addr := strings.TrimPrefix(cfg.Host, "https://")
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+
+		cfg.Host = "https://" + net.JoinHostPort("envtest.invalid", port)
+		dialer := &net.Dialer{}
+		cfg.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		listNamespaces(t, cfg)
+	})
+}
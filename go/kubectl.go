@@ -0,0 +1,106 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/testcontainers/testcontainers-go"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// kubectlScript runs the kubectl binary downloaded by setup-envtest together with the API server,
+	// whose directory depends on the Kubernetes version and platform of the image
+	kubectlScript = `exec /usr/local/bin/envtest/k8s/*/kubectl --kubeconfig ` +
+		KubeconfigPath + ` "$@"`
+
+	// execInspectInterval is how often a finished exec is inspected until it is no longer running
+	execInspectInterval = 100 * time.Millisecond
+)
+
+// Kubectl runs kubectl inside the container against the API server, with stdin streamed to it if not nil,
+// e.g. Kubectl(ctx, strings.NewReader(manifest), "apply", "-f", "-"). A non-zero exit code of kubectl
+// is returned as is, err is only set when kubectl could not be run.
+func (c *EnvtestContainer) Kubectl(
+	ctx context.Context,
+	stdin io.Reader,
+	args ...string,
+) (stdout, stderr string, exitCode int, err error) {
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	exec, err := cli.ContainerExecCreate(ctx, c.GetContainerID(), container.ExecOptions{
+		Cmd:          append([]string{"sh", "-c", kubectlScript, "kubectl"}, args...),
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to exec kubectl in container: %w", err)
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to exec kubectl in container: %w", err)
+	}
+
+	defer resp.Close()
+
+	if stdin != nil {
+		go func() {
+			_, _ = io.Copy(resp.Conn, stdin)
+			// Signals EOF, e.g. to `kubectl apply -f -`
+			_ = resp.CloseWrite()
+		}()
+	}
+
+	var outBuf, errBuf bytes.Buffer
+
+	if _, err := stdcopy.StdCopy(&outBuf, &errBuf, resp.Reader); err != nil {
+		return "", "", 0, fmt.Errorf("failed to read kubectl output: %w", err)
+	}
+
+	exitCode, err = execExitCode(ctx, cli, exec.ID)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to inspect kubectl exec: %w", err)
+	}
+
+	return outBuf.String(), errBuf.String(), exitCode, nil
+}
+
+// execExitCode waits for the exec to finish and returns its exit code. The exec may still be reported
+// as running, with exit code 0, for a moment after its output is closed.
+func execExitCode(
+	ctx context.Context,
+	cli *testcontainers.DockerClient,
+	execID string,
+) (int, error) {
+	var exitCode int
+
+	err := wait.PollUntilContextCancel(
+		ctx,
+		execInspectInterval,
+		true,
+		func(ctx context.Context) (bool, error) {
+			inspect, err := cli.ContainerExecInspect(ctx, execID)
+			if err != nil {
+				return false, err
+			}
+
+			exitCode = inspect.ExitCode
+
+			return !inspect.Running, nil
+		},
+	)
+
+	return exitCode, err
+}
@@ -0,0 +1,61 @@
+package envtest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerKubectl(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	stdout, stderr, exitCode, err := c.Kubectl(ctx, nil, "create", "namespace", "kubectl-test")
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode, stderr)
+	require.Contains(t, stdout, "namespace/kubectl-test created")
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "kubectl-test"}, &corev1.Namespace{}))
+
+	// Manifests can be streamed to stdin
+	manifest := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: piped
+  namespace: kubectl-test
+data:
+  mode: fast
+`
+
+	stdout, stderr, exitCode, err = c.Kubectl(ctx, strings.NewReader(manifest), "apply", "-f", "-")
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode, stderr)
+	require.Contains(t, stdout, "configmap/piped created")
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "kubectl-test", Name: "piped"}, cm))
+	require.Equal(t, "fast", cm.Data["mode"])
+
+	// Failures are reported through the exit code
+	_, stderr, exitCode, err = c.Kubectl(ctx, nil, "get", "namespace", "missing")
+	require.NoError(t, err)
+	require.Equal(t, 1, exitCode)
+	require.Contains(t, stderr, `namespaces "missing" not found`)
+}
@@ -0,0 +1,40 @@
+package envtest
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// renderKustomizations renders the kustomizations in the given directories, like `kustomize build`
+func renderKustomizations(dirs []string) ([]manifestDocument, error) {
+	var docs []manifestDocument
+
+	for _, dir := range dirs {
+		manifests, err := renderKustomization(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, manifests...)
+	}
+
+	return docs, nil
+}
+
+// renderKustomization renders a kustomization in-process and decodes its output
+func renderKustomization(dir string) ([]manifestDocument, error) {
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).
+		Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render kustomization %s: %w", dir, err)
+	}
+
+	manifest, err := resources.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render kustomization %s: %w", dir, err)
+	}
+
+	return decodeManifest("kustomization "+dir, manifest)
+}
@@ -0,0 +1,41 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRenderKustomizations(t *testing.T) {
+	docs, err := renderKustomizations([]string{"testdata/kustomize/overlay"})
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+
+	var (
+		settings string
+		pod      corev1.Pod
+	)
+
+	for _, doc := range docs {
+		require.Contains(t, doc.String(), "kustomization testdata/kustomize/overlay document")
+
+		switch doc.obj.GetKind() {
+		case "ConfigMap":
+			settings = doc.obj.GetName()
+			require.Equal(t, "kustomize-test", doc.obj.GetNamespace())
+		case "Pod":
+			require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(doc.obj.Object, &pod))
+		}
+	}
+
+	// The generated name has a content hash, and references to it are rewritten
+	require.True(t, strings.HasPrefix(settings, "dev-app-settings-"), settings)
+	require.Equal(t, "dev-app", pod.Name)
+	require.Equal(t, settings, pod.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name)
+
+	_, err = renderKustomizations([]string{"testdata/kustomize/missing"})
+	require.ErrorContains(t, err, "failed to render kustomization testdata/kustomize/missing")
+}
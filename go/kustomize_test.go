@@ -0,0 +1,45 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerWithKustomize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithKustomize("testdata/kustomize/overlay"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	pod := &corev1.Pod{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "kustomize-test", Name: "dev-app"}, pod))
+
+	// The pod refers to the generated ConfigMap by its hashed name
+	name := pod.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name
+	require.Regexp(t, `^dev-app-settings-[a-z0-9]+$`, name)
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "kustomize-test", Name: name}, cm))
+	require.Equal(t, "slow", cm.Data["mode"])
+}
+
+func TestEnvtestContainerWithKustomizeError(t *testing.T) {
+	_, err := envtest.Run(t.Context(), append(getEnvtestOptions(), envtest.WithKustomize("testdata/kustomize/missing"))...)
+	require.ErrorContains(t, err, "failed to render kustomization testdata/kustomize/missing")
+}
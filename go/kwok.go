@@ -0,0 +1,72 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KWOKNodeAnnotation marks the Nodes whose kubelet kwok simulates with WithKWOK, set to "fake"
+	KWOKNodeAnnotation = "kwok.x-k8s.io/node"
+
+	// kwokHealthz is the health check kwok serves inside the container
+	kwokHealthz = "http://127.0.0.1:10247/healthz"
+)
+
+// newSimulatedNode returns a Node managed by kwok, which fills in its status
+func newSimulatedNode(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{KWOKNodeAnnotation: "fake"},
+			Labels: map[string]string{
+				corev1.LabelHostname: name,
+				corev1.LabelOSStable: "linux",
+				"type":               "kwok",
+			},
+		},
+	}
+}
+
+// CreateSimulatedNodes creates n Nodes named kwok-node-0 to kwok-node-<n-1> for the kwok of WithKWOK to simulate,
+// which marks them Ready and keeps them so. They are labeled type=kwok, e.g. for node selectors.
+func (c *EnvtestContainer) CreateSimulatedNodes(
+	ctx context.Context,
+	n int,
+) ([]*corev1.Node, error) {
+	if !c.kwok {
+		return nil, errors.New("kwok is not running, start the container WithKWOK")
+	}
+
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*corev1.Node, 0, n)
+
+	for i := range n {
+		name := fmt.Sprintf("kwok-node-%d", i)
+
+		var node *corev1.Node
+
+		err := c.retry(ctx, "create simulated node", func(ctx context.Context) error {
+			node, err = clientset.CoreV1().
+				Nodes().
+				Create(ctx, newSimulatedNode(name), metav1.CreateOptions{})
+
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create node %s: %w", name, err)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
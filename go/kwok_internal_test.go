@@ -0,0 +1,24 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewSimulatedNode(t *testing.T) {
+	node := newSimulatedNode("kwok-node-0")
+
+	require.Equal(t, "kwok-node-0", node.Name)
+	require.Equal(t, map[string]string{KWOKNodeAnnotation: "fake"}, node.Annotations)
+	require.Equal(t, "kwok", node.Labels["type"])
+	require.Equal(t, "kwok-node-0", node.Labels[corev1.LabelHostname])
+}
+
+func TestCreateSimulatedNodesWithoutKWOK(t *testing.T) {
+	c := &EnvtestContainer{}
+
+	_, err := c.CreateSimulatedNodes(t.Context(), 1)
+	require.EqualError(t, err, "kwok is not running, start the container WithKWOK")
+}
@@ -0,0 +1,69 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerWithKWOK(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithKWOK())...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	nodes, err := c.CreateSimulatedNodes(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+
+	require.EventuallyWithT(t, func(collect *assert.CollectT) {
+		node, err := clientset.CoreV1().Nodes().Get(ctx, "kwok-node-1", metav1.GetOptions{})
+		require.NoError(collect, err)
+
+		ready := false
+
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				ready = condition.Status == corev1.ConditionTrue
+			}
+		}
+
+		assert.True(collect, ready, "node not ready yet")
+	}, time.Minute, 200*time.Millisecond)
+
+	// No scheduler runs, so the Pod is bound upfront
+	_, err = clientset.CoreV1().Pods("default").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "simulated"},
+		Spec: corev1.PodSpec{
+			NodeName:   nodes[0].Name,
+			Containers: []corev1.Container{{Name: "app", Image: "busybox"}},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.EventuallyWithT(t, func(collect *assert.CollectT) {
+		pod, err := clientset.CoreV1().Pods("default").Get(ctx, "simulated", metav1.GetOptions{})
+		require.NoError(collect, err)
+		assert.Equal(collect, corev1.PodRunning, pod.Status.Phase)
+	}, time.Minute, 200*time.Millisecond)
+}
@@ -0,0 +1,24 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// testLogConsumer writes container log lines to the test log
+type testLogConsumer struct {
+	t testing.TB
+}
+
+// TestLogConsumer returns a log consumer for WithLogConsumers writing the container logs to the test log,
+// so they are shown for failed tests or with go test -v. The container has to be terminated before the test ends.
+func TestLogConsumer(t testing.TB) testcontainers.LogConsumer {
+	return &testLogConsumer{t: t}
+}
+
+// Accept logs a container log line
+func (l *testLogConsumer) Accept(log testcontainers.Log) {
+	l.t.Log(strings.TrimRight(string(log.Content), "\n"))
+}
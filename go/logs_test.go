@@ -0,0 +1,62 @@
+package envtest_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// capturingLogConsumer keeps the container log lines
+type capturingLogConsumer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogConsumer) Accept(log testcontainers.Log) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, string(log.Content))
+}
+
+func (l *capturingLogConsumer) contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range l.lines {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestEnvtestContainerWithAPIServerVerbosity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	logs := &capturingLogConsumer{}
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithAPIServerVerbosity(4),
+		envtest.WithLogConsumers(logs, envtest.TestLogConsumer(t)),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	// Requests are traced from level 3 on, e.g. the ones of the readiness checks
+	require.Eventually(t, func() bool {
+		return logs.contains("Envtest is ready!") && logs.contains(`"HTTP" verb="GET" URI="/readyz"`)
+	}, 10*time.Second, 100*time.Millisecond)
+}
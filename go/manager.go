@@ -0,0 +1,261 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultCacheSyncTimeout is how long StartManager waits for the manager cache to sync
+	DefaultCacheSyncTimeout = 30 * time.Second
+
+	// DefaultManagerStopTimeout is how long stopping a RunningManager waits for the manager to exit
+	DefaultManagerStopTimeout = 30 * time.Second
+)
+
+// startConfig holds the configuration for StartManager
+type startConfig struct {
+	cacheSyncTimeout time.Duration
+	stopTimeout      time.Duration
+}
+
+// StartOption is a functional option for configuring StartManager
+type StartOption func(*startConfig)
+
+// WithCacheSyncTimeout sets how long StartManager waits for the manager cache to sync
+func WithCacheSyncTimeout(timeout time.Duration) StartOption {
+	return func(c *startConfig) {
+		c.cacheSyncTimeout = timeout
+	}
+}
+
+// WithStopTimeout sets how long stopping the manager waits for it to exit
+func WithStopTimeout(timeout time.Duration) StartOption {
+	return func(c *startConfig) {
+		c.stopTimeout = timeout
+	}
+}
+
+// RunningManager is a controller-runtime manager started by StartManager
+type RunningManager struct {
+	ctrl.Manager
+
+	cancel      context.CancelFunc
+	done        chan struct{}
+	err         error
+	stopTimeout time.Duration
+	stopOnce    sync.Once
+	stopErr     error
+}
+
+// StartManager starts the manager in the background and waits for its cache to sync,
+// failing the test if the manager does not start or the cache does not sync.
+// A cleanup stops the manager and waits for it to exit, so register the container termination
+// with t.Cleanup before calling StartManager to have the manager stopped first.
+func StartManager(t testing.TB, mgr ctrl.Manager, opts ...StartOption) *RunningManager {
+	t.Helper()
+
+	cfg := &startConfig{
+		cacheSyncTimeout: DefaultCacheSyncTimeout,
+		stopTimeout:      DefaultManagerStopTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// The manager outlives t.Context(), which is canceled before cleanups run
+	ctx, cancel := context.WithCancel(context.WithoutCancel(t.Context()))
+
+	rm := &RunningManager{
+		Manager:     mgr,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		stopTimeout: cfg.stopTimeout,
+	}
+
+	go func() {
+		defer close(rm.done)
+
+		rm.err = mgr.Start(ctx)
+	}()
+
+	t.Cleanup(func() {
+		if err := rm.Stop(); err != nil {
+			t.Errorf("manager: %v", err)
+		}
+	})
+
+	synced := make(chan error, 1)
+
+	go func() {
+		synced <- WaitForCacheSync(ctx, mgr, cfg.cacheSyncTimeout)
+	}()
+
+	select {
+	case <-rm.done:
+		t.Fatalf("manager failed to start: %v", rm.err)
+	case err := <-synced:
+		if err != nil {
+			t.Fatalf("manager: %v", err)
+		}
+	}
+
+	return rm
+}
+
+// Stop cancels the manager and waits for it to exit. It returns the error the manager exited with,
+// or an error if it did not exit within the stop timeout. Calling Stop again returns the same result.
+func (m *RunningManager) Stop() error {
+	m.stopOnce.Do(func() {
+		m.cancel()
+
+		select {
+		case <-m.done:
+			if m.err != nil {
+				m.stopErr = fmt.Errorf("manager exited with error: %w", m.err)
+			}
+		case <-time.After(m.stopTimeout):
+			m.stopErr = fmt.Errorf("manager did not stop within %s", m.stopTimeout)
+		}
+	})
+
+	return m.stopErr
+}
+
+// Done returns a channel that is closed once the manager has exited
+func (m *RunningManager) Done() <-chan struct{} {
+	return m.done
+}
+
+// WaitForCacheSync waits for the manager cache to sync within the timeout.
+// If it does not, every type the manager cache has an informer for is probed with a direct List
+// and the returned error names the ones that cannot be listed and why (forbidden, missing CRD, etc.).
+func WaitForCacheSync(ctx context.Context, mgr ctrl.Manager, timeout time.Duration) error {
+	syncCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if mgr.GetCache().WaitForCacheSync(syncCtx) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("cache did not sync within %s", timeout)
+
+	kinds, err := informerKinds(mgr.GetCache(), mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("%s (diagnostics failed: %w)", msg, err)
+	}
+
+	// Probe with a fresh context, the sync one has expired
+	failures, err := probeKinds(ctx, mgr.GetConfig(), mgr.GetScheme(), kinds)
+	if err != nil {
+		return fmt.Errorf("%s (diagnostics failed: %w)", msg, err)
+	}
+
+	if len(failures) == 0 {
+		return errors.New(msg + ": all watched types can be listed, check the informer logs")
+	}
+
+	return fmt.Errorf("%s: %s", msg, strings.Join(failures, "; "))
+}
+
+// informerKinds returns the listable kinds of the scheme the cache has an informer for.
+// The cache has no API to enumerate its informers, so its Peek method, which looks one up
+// without starting it, is called for every kind.
+func informerKinds(c cache.Cache, scheme *runtime.Scheme) ([]schema.GroupVersionKind, error) {
+	peek := reflect.ValueOf(c).MethodByName("Peek")
+	if !peek.IsValid() || peek.Type().NumIn() != 2 || peek.Type().NumOut() != 3 ||
+		peek.Type().Out(2).Kind() != reflect.Bool {
+		return nil, fmt.Errorf("cannot look up the informers of cache %T", c)
+	}
+
+	var kinds []schema.GroupVersionKind
+
+	for _, gvk := range listableKinds(scheme) {
+		obj, err := scheme.New(gvk)
+		if err != nil {
+			continue
+		}
+
+		out := peek.Call([]reflect.Value{reflect.ValueOf(gvk), reflect.ValueOf(obj)})
+		if out[2].Bool() {
+			kinds = append(kinds, gvk)
+		}
+	}
+
+	return kinds, nil
+}
+
+// probeKinds lists each of the kinds with an uncached client and describes the ones that fail
+func probeKinds(
+	ctx context.Context,
+	cfg *rest.Config,
+	scheme *runtime.Scheme,
+	kinds []schema.GroupVersionKind,
+) ([]string, error) {
+	// A fresh client discovers a fresh REST mapping, so CRDs missing since the manager started are noticed
+	cl, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	var failures []string
+
+	for _, gvk := range kinds {
+		list, err := scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err != nil {
+			continue
+		}
+
+		objList, ok := list.(client.ObjectList)
+		if !ok {
+			continue
+		}
+
+		err = cl.List(ctx, objList, client.Limit(1))
+		if err == nil {
+			continue
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %v", gvk, err))
+	}
+
+	return failures, nil
+}
+
+// listableKinds returns the versioned kinds of the scheme that have a matching list kind, sorted
+func listableKinds(scheme *runtime.Scheme) []schema.GroupVersionKind {
+	var kinds []schema.GroupVersionKind
+
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+
+		if !scheme.Recognizes(gvk.GroupVersion().WithKind(gvk.Kind + "List")) {
+			continue
+		}
+
+		kinds = append(kinds, gvk)
+	}
+
+	slices.SortFunc(kinds, func(a, b schema.GroupVersionKind) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	return kinds
+}
@@ -0,0 +1,86 @@
+package envtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+func TestListableKinds(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	kinds := listableKinds(scheme)
+
+	require.Contains(t, kinds, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	require.Contains(t, kinds, corev1.SchemeGroupVersion.WithKind("Namespace"))
+	require.NotContains(t, kinds, corev1.SchemeGroupVersion.WithKind("ConfigMapList"))
+	// Option types registered into every group version have no list kind
+	require.NotContains(t, kinds, corev1.SchemeGroupVersion.WithKind("ListOptions"))
+	require.NotContains(t, kinds, schema.GroupVersionKind{Version: metav1.SchemeGroupVersion.Version, Kind: "Status"})
+	require.IsIncreasing(t, kindStrings(kinds))
+}
+
+func TestInformerKinds(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	configMapGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(configMapGVK, meta.RESTScopeNamespace)
+
+	opts := cache.Options{Scheme: scheme, Mapper: mapper}
+
+	c, err := cache.New(&rest.Config{Host: "http://127.0.0.1:1"}, opts)
+	require.NoError(t, err)
+
+	// The informer is registered but not started, as by a controller watching ConfigMaps
+	_, err = c.GetInformer(t.Context(), &corev1.ConfigMap{}, cache.BlockUntilSynced(false))
+	require.NoError(t, err)
+
+	kinds, err := informerKinds(c, scheme)
+	require.NoError(t, err)
+	require.Equal(t, []schema.GroupVersionKind{configMapGVK}, kinds)
+
+	// A wrapped cache hides the informer map
+	_, err = informerKinds(struct{ cache.Cache }{c}, scheme)
+	require.ErrorContains(t, err, "cannot look up the informers of cache")
+}
+
+func kindStrings(kinds []schema.GroupVersionKind) []string {
+	out := make([]string, 0, len(kinds))
+
+	for _, gvk := range kinds {
+		out = append(out, gvk.String())
+	}
+
+	return out
+}
+
+func TestRunningManagerStop(t *testing.T) {
+	t.Run("exited", func(t *testing.T) {
+		rm := &RunningManager{cancel: func() {}, done: make(chan struct{}), stopTimeout: time.Second}
+		rm.err = errors.New("leader election lost")
+		close(rm.done)
+
+		require.EqualError(t, rm.Stop(), "manager exited with error: leader election lost")
+		require.EqualError(t, rm.Stop(), "manager exited with error: leader election lost")
+	})
+
+	t.Run("stuck", func(t *testing.T) {
+		canceled := false
+		rm := &RunningManager{cancel: func() { canceled = true }, done: make(chan struct{}), stopTimeout: 10 * time.Millisecond}
+
+		require.EqualError(t, rm.Stop(), "manager did not stop within 10ms")
+		require.True(t, canceled)
+	})
+}
@@ -0,0 +1,99 @@
+package envtest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// gadgetGVK is a custom resource kind whose CRD is never installed
+var gadgetGVK = schema.GroupVersionKind{Group: "missing.example.com", Version: "v1", Kind: "Gadget"}
+
+type gadget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+func (g *gadget) DeepCopyObject() runtime.Object {
+	out := *g
+	g.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	return &out
+}
+
+type gadgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []gadget `json:"items"`
+}
+
+func (l *gadgetList) DeepCopyObject() runtime.Object {
+	out := *l
+	out.Items = make([]gadget, len(l.Items))
+
+	for i := range l.Items {
+		out.Items[i] = *l.Items[i].DeepCopyObject().(*gadget)
+	}
+
+	return &out
+}
+
+func TestEnvtestContainerWaitForCacheSyncMissingCRD(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(gadgetGVK, &gadget{})
+	scheme.AddKnownTypeWithName(gadgetGVK.GroupVersion().WithKind("GadgetList"), &gadgetList{})
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		// A static mapping lets the informer be created as if the CRD had been installed and then removed
+		MapperProvider: func(*rest.Config, *http.Client) (meta.RESTMapper, error) {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(gadgetGVK, meta.RESTScopeNamespace)
+
+			return mapper, nil
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = mgr.GetCache().GetInformer(ctx, &gadget{})
+	require.NoError(t, err)
+
+	mgrCtx, mgrCancel := context.WithCancel(ctx)
+	defer mgrCancel()
+
+	go func() { _ = mgr.Start(mgrCtx) }()
+
+	err = envtest.WaitForCacheSync(ctx, mgr, 3*time.Second)
+	require.ErrorContains(t, err, "cache did not sync within 3s")
+	require.ErrorContains(t, err, gadgetGVK.String())
+	require.ErrorContains(t, err, "no matches for kind \"Gadget\"")
+}
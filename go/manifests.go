@@ -0,0 +1,86 @@
+package envtest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+)
+
+// manifestFS is a directory of manifests in a file system given to WithManifestsFS
+type manifestFS struct {
+	fsys fs.FS
+	dir  string
+}
+
+// readManifests decodes the manifests in the given files and directories, which are read the same way
+// as for WithCRDs, followed by the ones in the given file system directories
+func readManifests(paths []string, dirs []manifestFS) ([]manifestDocument, error) {
+	var docs []manifestDocument
+
+	for _, p := range paths {
+		files, err := manifestFiles(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifests: %w", err)
+			}
+
+			manifests, err := decodeManifest(file, content)
+			if err != nil {
+				return nil, err
+			}
+
+			docs = append(docs, manifests...)
+		}
+	}
+
+	for _, d := range dirs {
+		manifests, err := readManifestsFS(d.fsys, d.dir)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, manifests...)
+	}
+
+	return docs, nil
+}
+
+// readManifestsFS decodes the .yaml, .yml and .json manifests in a file system directory, in name order
+func readManifestsFS(fsys fs.FS, dir string) ([]manifestDocument, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests: %w", err)
+	}
+
+	var docs []manifestDocument
+
+	for _, entry := range entries {
+		if entry.IsDir() ||
+			!slices.Contains([]string{".yaml", ".yml", ".json"}, path.Ext(entry.Name())) {
+			continue
+		}
+
+		file := path.Join(dir, entry.Name())
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests: %w", err)
+		}
+
+		manifests, err := decodeManifest(file, content)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, manifests...)
+	}
+
+	return docs, nil
+}
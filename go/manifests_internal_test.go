@@ -0,0 +1,40 @@
+package envtest
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadManifests(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fixtures/rbac.yaml":      {Data: []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: app\n")},
+		"fixtures/README.md":      {Data: []byte("# Fixtures\n")},
+		"fixtures/nested/ns.yaml": {Data: []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: nested\n")},
+	}
+
+	docs, err := readManifests([]string{"testdata/manifests"}, []manifestFS{{fsys: fsys, dir: "fixtures"}})
+	require.NoError(t, err)
+
+	var sources []string
+	for _, doc := range docs {
+		sources = append(sources, doc.String())
+	}
+
+	// Directories are not read recursively and non-manifest files are skipped
+	require.Equal(t, []string{
+		"testdata/manifests/app.yaml document 0",
+		"testdata/manifests/app.yaml document 1",
+		"testdata/manifests/namespace.yaml document 0",
+		"fixtures/rbac.yaml document 0",
+	}, sources)
+
+	_, err = readManifests(nil, []manifestFS{{fsys: fsys, dir: "missing"}})
+	require.ErrorContains(t, err, "failed to read manifests")
+
+	fsys["fixtures/broken.yaml"] = &fstest.MapFile{Data: []byte("kind: ConfigMap\n")}
+
+	_, err = readManifests(nil, []manifestFS{{fsys: fsys, dir: "fixtures"}})
+	require.EqualError(t, err, "fixtures/broken.yaml document 0 has no apiVersion, kind or name")
+}
@@ -0,0 +1,63 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerWithManifests(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	fsys := fstest.MapFS{
+		"fixtures/settings.yaml": {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n")},
+	}
+
+	// The namespace and the CRD of the fixtures come in files read after theirs
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithManifests("testdata/manifests", "testdata/crds/widgets.yaml"),
+		envtest.WithManifestsFS(fsys, "fixtures"),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "manifests", Name: "app-config"}, cm))
+	require.Equal(t, "2", cm.Data["replicas"])
+
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("crds.example.com/v1")
+	widget.SetKind("Widget")
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "manifests", Name: "blue"}, widget))
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "settings"}, &corev1.ConfigMap{}))
+}
+
+func TestEnvtestContainerWithManifestsFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// The Widget CRD is not installed
+	fsys := fstest.MapFS{
+		"fixtures/widget.yaml": {Data: []byte("apiVersion: crds.example.com/v1\nkind: Widget\nmetadata:\n  name: blue\n")},
+	}
+
+	_, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithManifestsFS(fsys, "fixtures"))...)
+	require.ErrorContains(t, err, "fixtures/widget.yaml document 0 is a Widget of crds.example.com/v1 unknown to the API server")
+}
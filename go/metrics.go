@@ -0,0 +1,192 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// Sample is a single Prometheus sample: a metric name, its labels and the value
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// MetricsSnapshot is a point-in-time scrape of a Prometheus text endpoint.
+// Samples are keyed by the name they are exposed under, so histogram and summary
+// families show up as their _bucket, _sum and _count series.
+type MetricsSnapshot map[string][]Sample
+
+// Has reports whether the snapshot contains at least one sample with the given name
+func (s MetricsSnapshot) Has(name string) bool {
+	return len(s[name]) > 0
+}
+
+// Samples returns the samples with the given name whose labels include all the given label pairs
+func (s MetricsSnapshot) Samples(name string, matchLabels map[string]string) []Sample {
+	var samples []Sample
+
+	for _, sample := range s[name] {
+		if labelsMatch(sample.Labels, matchLabels) {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples
+}
+
+// Sum adds up the values of the samples with the given name whose labels include all the given label pairs
+func (s MetricsSnapshot) Sum(name string, matchLabels map[string]string) float64 {
+	var sum float64
+
+	for _, sample := range s.Samples(name, matchLabels) {
+		sum += sample.Value
+	}
+
+	return sum
+}
+
+// Names returns the sorted list of sample names in the snapshot
+func (s MetricsSnapshot) Names() []string {
+	return slices.Sorted(maps.Keys(s))
+}
+
+// GetMetrics scrapes the API server /metrics endpoint using the admin credentials
+func (c *EnvtestContainer) GetMetrics(ctx context.Context) (MetricsSnapshot, error) {
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := clientset.CoreV1().RESTClient().Get().AbsPath("/metrics").DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape API server metrics: %w", err)
+	}
+
+	return parseMetrics(bytes.NewReader(raw))
+}
+
+// parseMetrics parses Prometheus text exposition format into a MetricsSnapshot
+func parseMetrics(r io.Reader) (MetricsSnapshot, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+
+	snapshot := make(MetricsSnapshot)
+
+	for name, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel()))
+
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			for _, sample := range flattenMetric(name, family.GetType(), metric, labels) {
+				snapshot[sample.Name] = append(snapshot[sample.Name], sample)
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// flattenMetric expands a single metric into the samples it is exposed as
+func flattenMetric(
+	name string,
+	kind dto.MetricType,
+	metric *dto.Metric,
+	labels map[string]string,
+) []Sample {
+	//nolint:exhaustive // gauge histograms are not exposed by the API server or etcd
+	switch kind {
+	case dto.MetricType_COUNTER:
+		return []Sample{{Name: name, Labels: labels, Value: metric.GetCounter().GetValue()}}
+	case dto.MetricType_GAUGE:
+		return []Sample{{Name: name, Labels: labels, Value: metric.GetGauge().GetValue()}}
+	case dto.MetricType_HISTOGRAM:
+		histogram := metric.GetHistogram()
+		samples := make([]Sample, 0, len(histogram.GetBucket())+2)
+
+		for _, bucket := range histogram.GetBucket() {
+			samples = append(samples, Sample{
+				Name:   name + "_bucket",
+				Labels: withLabel(labels, "le", formatFloat(bucket.GetUpperBound())),
+				Value:  float64(bucket.GetCumulativeCount()),
+			})
+		}
+
+		return append(
+			samples,
+			Sample{Name: name + "_sum", Labels: labels, Value: histogram.GetSampleSum()},
+			Sample{
+				Name:   name + "_count",
+				Labels: labels,
+				Value:  float64(histogram.GetSampleCount()),
+			},
+		)
+	case dto.MetricType_SUMMARY:
+		summary := metric.GetSummary()
+		samples := make([]Sample, 0, len(summary.GetQuantile())+2)
+
+		for _, quantile := range summary.GetQuantile() {
+			samples = append(samples, Sample{
+				Name:   name,
+				Labels: withLabel(labels, "quantile", formatFloat(quantile.GetQuantile())),
+				Value:  quantile.GetValue(),
+			})
+		}
+
+		return append(samples,
+			Sample{Name: name + "_sum", Labels: labels, Value: summary.GetSampleSum()},
+			Sample{Name: name + "_count", Labels: labels, Value: float64(summary.GetSampleCount())},
+		)
+	default:
+		return []Sample{{Name: name, Labels: labels, Value: metric.GetUntyped().GetValue()}}
+	}
+}
+
+// labelsMatch reports whether labels contain all the given label pairs
+func labelsMatch(labels, match map[string]string) bool {
+	for k, v := range match {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withLabel returns a copy of labels with one extra label pair added
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := maps.Clone(labels)
+	if result == nil {
+		result = make(map[string]string, 1)
+	}
+
+	result[name] = value
+
+	return result
+}
+
+// formatFloat renders a bucket bound or quantile the same way Prometheus does
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,67 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sampleMetrics = `# HELP apiserver_request_total [STABLE] Counter of apiserver requests.
+# TYPE apiserver_request_total counter
+apiserver_request_total{code="200",group="",resource="namespaces",verb="LIST",version="v1"} 3
+apiserver_request_total{code="201",group="",resource="namespaces",verb="POST",version="v1"} 2
+apiserver_request_total{code="200",group="apps",resource="deployments",verb="GET",version="v1"} 1
+# HELP etcd_request_duration_seconds [ALPHA] Etcd request latency in seconds.
+# TYPE etcd_request_duration_seconds histogram
+etcd_request_duration_seconds_bucket{operation="get",le="0.005"} 4
+etcd_request_duration_seconds_bucket{operation="get",le="0.1"} 9
+etcd_request_duration_seconds_bucket{operation="get",le="+Inf"} 10
+etcd_request_duration_seconds_sum{operation="get"} 0.25
+etcd_request_duration_seconds_count{operation="get"} 10
+# HELP process_start_time_seconds Start time of the process.
+# TYPE process_start_time_seconds gauge
+process_start_time_seconds 1.7e+09
+`
+
+func TestParseMetrics(t *testing.T) {
+	snapshot, err := parseMetrics(strings.NewReader(sampleMetrics))
+	require.NoError(t, err)
+
+	t.Run("counter", func(t *testing.T) {
+		require.True(t, snapshot.Has("apiserver_request_total"))
+		require.Len(t, snapshot.Samples("apiserver_request_total", nil), 3)
+		require.InDelta(t, 5.0, snapshot.Sum("apiserver_request_total", map[string]string{"resource": "namespaces"}), 0)
+		require.InDelta(t, 2.0, snapshot.Sum("apiserver_request_total", map[string]string{"verb": "POST"}), 0)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		require.False(t, snapshot.Has("etcd_request_duration_seconds"))
+		require.InDelta(t, 10.0, snapshot.Sum("etcd_request_duration_seconds_count", nil), 0)
+		require.InDelta(t, 0.25, snapshot.Sum("etcd_request_duration_seconds_sum", nil), 0)
+
+		buckets := snapshot.Samples("etcd_request_duration_seconds_bucket", map[string]string{"le": "+Inf"})
+		require.Len(t, buckets, 1)
+		require.InDelta(t, 10.0, buckets[0].Value, 0)
+		require.Equal(t, "get", buckets[0].Labels["operation"])
+	})
+
+	t.Run("gauge", func(t *testing.T) {
+		require.InDelta(t, 1.7e+09, snapshot.Sum("process_start_time_seconds", nil), 0)
+	})
+
+	t.Run("names", func(t *testing.T) {
+		require.Equal(t, []string{
+			"apiserver_request_total",
+			"etcd_request_duration_seconds_bucket",
+			"etcd_request_duration_seconds_count",
+			"etcd_request_duration_seconds_sum",
+			"process_start_time_seconds",
+		}, snapshot.Names())
+	})
+}
+
+func TestParseMetricsInvalid(t *testing.T) {
+	_, err := parseMetrics(strings.NewReader("not a metric line {\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,262 @@
+package envtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// etcdPrefix is the key prefix the API server stores resources under
+const etcdPrefix = "/registry"
+
+// StoredObject identifies an object persisted in etcd together with the version it is encoded in
+type StoredObject struct {
+	Namespace  string
+	Name       string
+	APIVersion string
+}
+
+// StorageVersionHashes returns the storage version hash advertised in discovery for every
+// served version of the given resource, keyed by version.
+// The hash changes when the storage version of the resource changes.
+func (c *EnvtestContainer) StorageVersionHashes(
+	ctx context.Context,
+	gr schema.GroupResource,
+) (map[string]string, error) {
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := clientset.Discovery().ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover API groups: %w", err)
+	}
+
+	hashes := make(map[string]string)
+
+	for _, group := range groups.Groups {
+		if group.Name != gr.Group {
+			continue
+		}
+
+		for _, version := range group.Versions {
+			resources, err := clientset.Discovery().
+				ServerResourcesForGroupVersion(version.GroupVersion)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"failed to discover resources of %s: %w",
+					version.GroupVersion,
+					err,
+				)
+			}
+
+			for _, resource := range resources.APIResources {
+				if resource.Name == gr.Resource {
+					hashes[version.Version] = resource.StorageVersionHash
+				}
+			}
+		}
+	}
+
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("resource %s is not served by the API server", gr)
+	}
+
+	return hashes, nil
+}
+
+// ObjectsNeedingMigration reads the raw objects of a custom resource from etcd and returns
+// the ones that are not encoded in the current storage version of its CRD
+func (c *EnvtestContainer) ObjectsNeedingMigration(
+	ctx context.Context,
+	gvr schema.GroupVersionResource,
+) ([]StoredObject, error) {
+	crd, err := c.getCRD(ctx, gvr.GroupResource())
+	if err != nil {
+		return nil, err
+	}
+
+	storageVersion := schema.GroupVersion{
+		Group:   gvr.Group,
+		Version: crdStorageVersion(crd),
+	}.String()
+
+	kvs, err := c.etcdRange(ctx, resourceEtcdPrefix(gvr.GroupResource()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from etcd: %w", gvr.GroupResource(), err)
+	}
+
+	var stale []StoredObject
+
+	for _, kv := range kvs {
+		obj, err := decodeStoredObject(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", kv.Key, err)
+		}
+
+		if obj.APIVersion != storageVersion {
+			stale = append(stale, obj)
+		}
+	}
+
+	return stale, nil
+}
+
+// MigrateStorage rewrites every object of a custom resource with a no-op update, so the API server
+// re-encodes it in the current storage version, and then prunes the CRD status.storedVersions down
+// to the storage version. It returns the number of objects that were rewritten.
+func (c *EnvtestContainer) MigrateStorage(
+	ctx context.Context,
+	gvr schema.GroupVersionResource,
+) (int, error) {
+	dyn, err := c.dynamicClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	list, err := dyn.Resource(gvr).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %w", gvr.GroupResource(), err)
+	}
+
+	migrated := 0
+
+	for i := range list.Items {
+		err := c.retry(ctx, "rewrite object", func(ctx context.Context) error {
+			return rewriteObject(ctx, dyn.Resource(gvr), &list.Items[i])
+		})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return migrated, fmt.Errorf("failed to migrate %s %s/%s: %w",
+				gvr.GroupResource(), list.Items[i].GetNamespace(), list.Items[i].GetName(), err)
+		}
+
+		migrated++
+	}
+
+	if err := c.pruneStoredVersions(ctx, gvr.GroupResource()); err != nil {
+		return migrated, err
+	}
+
+	return migrated, nil
+}
+
+// rewriteObject performs a no-op update, refetching the object when the update conflicts
+func rewriteObject(
+	ctx context.Context,
+	resource dynamic.NamespaceableResourceInterface,
+	obj *unstructured.Unstructured,
+) error {
+	client := resource.Namespace(obj.GetNamespace())
+	current := obj
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if current == nil {
+			latest, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			current = latest
+		}
+
+		_, err := client.Update(ctx, current, metav1.UpdateOptions{})
+		current = nil
+
+		return err
+	})
+}
+
+// pruneStoredVersions drops every version but the storage one from the CRD status.storedVersions
+func (c *EnvtestContainer) pruneStoredVersions(ctx context.Context, gr schema.GroupResource) error {
+	client, err := c.apiExtensionsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.retry(ctx, "prune stored versions", func(ctx context.Context) error {
+		crd, err := client.ApiextensionsV1().
+			CustomResourceDefinitions().
+			Get(ctx, gr.String(), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get CRD %s: %w", gr, err)
+		}
+
+		crd.Status.StoredVersions = []string{crdStorageVersion(crd)}
+
+		_, err = client.ApiextensionsV1().
+			CustomResourceDefinitions().
+			UpdateStatus(ctx, crd, metav1.UpdateOptions{})
+
+		return err
+	})
+}
+
+// getCRD fetches the CRD that serves the given resource
+func (c *EnvtestContainer) getCRD(
+	ctx context.Context,
+	gr schema.GroupResource,
+) (*apiextensionsv1.CustomResourceDefinition, error) {
+	client, err := c.apiExtensionsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	crd, err := client.ApiextensionsV1().
+		CustomResourceDefinitions().
+		Get(ctx, gr.String(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CRD %s: %w", gr, err)
+	}
+
+	return crd, nil
+}
+
+// crdStorageVersion returns the version the CRD is currently persisted in
+func crdStorageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			return version.Name
+		}
+	}
+
+	return ""
+}
+
+// resourceEtcdPrefix returns the etcd key prefix of a custom resource
+func resourceEtcdPrefix(gr schema.GroupResource) string {
+	return etcdPrefix + "/" + gr.Group + "/" + gr.Resource + "/"
+}
+
+// decodeStoredObject extracts the identity of a JSON-encoded custom resource stored in etcd
+func decodeStoredObject(raw []byte) (StoredObject, error) {
+	var obj struct {
+		APIVersion string `json:"apiVersion"`
+		Metadata   struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return StoredObject{}, err
+	}
+
+	return StoredObject{
+		Namespace:  obj.Metadata.Namespace,
+		Name:       obj.Metadata.Name,
+		APIVersion: obj.APIVersion,
+	}, nil
+}
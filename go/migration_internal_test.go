@@ -0,0 +1,40 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceEtcdPrefix(t *testing.T) {
+	gr := schema.GroupResource{Group: "example.com", Resource: "widgets"}
+
+	require.Equal(t, "/registry/example.com/widgets/", resourceEtcdPrefix(gr))
+}
+
+func TestDecodeStoredObject(t *testing.T) {
+	obj, err := decodeStoredObject([]byte(`{"apiVersion":"example.com/v1beta1","kind":"Widget",` +
+		`"metadata":{"name":"foo","namespace":"default","uid":"1234"},"spec":{}}`))
+	require.NoError(t, err)
+	require.Equal(t, StoredObject{Namespace: "default", Name: "foo", APIVersion: "example.com/v1beta1"}, obj)
+
+	// Built-in resources are stored as protobuf and cannot be decoded
+	_, err = decodeStoredObject([]byte("k8s\x00\n\x0f\n\x02v1\x12\tConfigMap"))
+	require.Error(t, err)
+}
+
+func TestCRDStorageVersion(t *testing.T) {
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1beta1", Served: true},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+	}
+
+	require.Equal(t, "v1", crdStorageVersion(crd))
+	require.Empty(t, crdStorageVersion(&apiextensionsv1.CustomResourceDefinition{}))
+}
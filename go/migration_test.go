@@ -0,0 +1,133 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/ptr"
+)
+
+// widgetCRD returns a namespaced CRD served at v1beta1 and v1 with v1beta1 as the storage version
+func widgetCRD() *apiextensionsv1.CustomResourceDefinition {
+	schemaProps := &apiextensionsv1.CustomResourceValidation{
+		OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"spec": {Type: "object", XPreserveUnknownFields: ptr.To(true)},
+			},
+		},
+	}
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.migration.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "migration.example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:   "widgets",
+				Singular: "widget",
+				Kind:     "Widget",
+				ListKind: "WidgetList",
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1beta1", Served: true, Storage: true, Schema: schemaProps},
+				{Name: "v1", Served: true, Storage: false, Schema: schemaProps},
+			},
+		},
+	}
+}
+
+func TestEnvtestContainerStorageMigration(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	crdClient, err := apiextensionsclientset.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	dyn, err := dynamic.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	crds := crdClient.ApiextensionsV1().CustomResourceDefinitions()
+
+	_, err = crds.Create(ctx, widgetCRD(), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	gr := schema.GroupResource{Group: "migration.example.com", Resource: "widgets"}
+	oldGVR := gr.WithVersion("v1beta1")
+	newGVR := gr.WithVersion("v1")
+
+	// Store an object while v1beta1 is the storage version
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("migration.example.com/v1beta1")
+	widget.SetKind("Widget")
+	widget.SetName("gadget")
+	widget.SetNamespace("default")
+	require.NoError(t, unstructured.SetNestedField(widget.Object, "blue", "spec", "color"))
+
+	require.Eventually(t, func() bool {
+		_, err := dyn.Resource(oldGVR).Namespace("default").Create(ctx, widget, metav1.CreateOptions{})
+
+		return err == nil
+	}, 30*time.Second, 100*time.Millisecond, "CRD was not served in time")
+
+	oldHashes, err := c.StorageVersionHashes(ctx, gr)
+	require.NoError(t, err)
+	require.Len(t, oldHashes, 2)
+
+	// Flip the storage version to v1
+	crd, err := crds.Get(ctx, gr.String(), metav1.GetOptions{})
+	require.NoError(t, err)
+
+	crd.Spec.Versions[0].Storage = false
+	crd.Spec.Versions[1].Storage = true
+
+	crd, err = crds.Update(ctx, crd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"v1beta1", "v1"}, crd.Status.StoredVersions)
+
+	// Discovery advertises a new hash once the API server serves the new storage version
+	require.Eventually(t, func() bool {
+		hashes, err := c.StorageVersionHashes(ctx, gr)
+
+		return err == nil && hashes["v1"] != oldHashes["v1"]
+	}, 30*time.Second, 100*time.Millisecond, "storage version hash did not change")
+
+	stale, err := c.ObjectsNeedingMigration(ctx, newGVR)
+	require.NoError(t, err)
+	require.Equal(t, []envtest.StoredObject{
+		{Namespace: "default", Name: "gadget", APIVersion: "migration.example.com/v1beta1"},
+	}, stale)
+
+	migrated, err := c.MigrateStorage(ctx, newGVR)
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	stale, err = c.ObjectsNeedingMigration(ctx, newGVR)
+	require.NoError(t, err)
+	require.Empty(t, stale)
+
+	crd, err = crds.Get(ctx, gr.String(), metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1"}, crd.Status.StoredVersions)
+}
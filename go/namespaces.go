@@ -0,0 +1,471 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespaceRemovalTimeout bounds how long ForceDeleteNamespace waits for the finalized namespace to be gone
+const namespaceRemovalTimeout = 30 * time.Second
+
+// clearFinalizersPatch removes the finalizers of an object, so its deletion completes without a controller
+var clearFinalizersPatch = []byte(`{"metadata":{"finalizers":null}}`)
+
+// retryFunc runs a mutating operation, retrying transient errors, see EnvtestContainer.retry
+type retryFunc func(ctx context.Context, op string, fn func(ctx context.Context) error) error
+
+// ForceDeleteNamespace deletes the namespace along with everything in it, without waiting for the namespace
+// controller, which only runs WithControllerManager: it deletes the objects of every namespaced resource,
+// clearing their finalizers, then finalizes the namespace and waits for it to be gone, so its name can be reused
+// right away. Nothing finalizers guard is cleaned up. It returns nil if the namespace does not exist.
+func (c *EnvtestContainer) ForceDeleteNamespace(ctx context.Context, name string) error {
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = deleteNamespace(ctx, cl, c.retry, name)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return err
+	}
+
+	kinds, err := discoveredNamespacedKinds(clientset.Discovery())
+	if err != nil {
+		return err
+	}
+
+	return forceDeleteNamespace(ctx, cl, c.retry, kinds, name)
+}
+
+// deleteNamespace deletes the namespace, which is the first step of emptying it:
+// a terminating namespace keeps new objects from being created in it
+func deleteNamespace(ctx context.Context, cl client.Client, retry retryFunc, name string) error {
+	err := retry(ctx, "delete namespace", func(ctx context.Context) error {
+		return cl.Delete(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete namespace %s: %w", name, err)
+	}
+
+	return err
+}
+
+// forceDeleteNamespace empties and finalizes a deleted namespace, deleting the objects of the given kinds in it,
+// and waits for it to be gone
+func forceDeleteNamespace(
+	ctx context.Context,
+	cl client.Client,
+	retry retryFunc,
+	kinds []schema.GroupVersionKind,
+	name string,
+) error {
+	for _, gvk := range kinds {
+		target := purgeTarget{gvk: gvk, namespace: name}
+
+		if err := target.deleteObjects(ctx, cl, retry); err != nil {
+			return err
+		}
+
+		if err := target.clearFinalizers(ctx, cl, retry); err != nil {
+			return err
+		}
+	}
+
+	if err := finalizeNamespace(ctx, cl, retry, name); err != nil {
+		return err
+	}
+
+	if err := waitForNamespaceRemoval(ctx, cl, name, namespaceRemovalTimeout); err != nil {
+		return fmt.Errorf("namespace %s was not removed: %w", name, err)
+	}
+
+	return nil
+}
+
+// discoveredNamespacedKinds returns the namespaced kinds, in their preferred version, whose objects can be listed
+// and deleted. Groups that fail discovery, e.g. of an unavailable aggregated API, are skipped.
+func discoveredNamespacedKinds(
+	client discovery.DiscoveryInterface,
+) ([]schema.GroupVersionKind, error) {
+	lists, err := client.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover namespaced resources: %w", err)
+	}
+
+	var kinds []schema.GroupVersionKind
+
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, resource := range list.APIResources {
+			if !slices.Contains(resource.Verbs, "list") ||
+				!slices.Contains(resource.Verbs, "deletecollection") {
+				continue
+			}
+
+			kinds = append(kinds, gv.WithKind(resource.Kind))
+		}
+	}
+
+	return kinds, nil
+}
+
+// clientNamespacedKinds returns the served namespaced kinds, in their preferred version, of the client scheme
+// and of the CRDs, for callers with a controller-runtime client only, which cannot discover resources
+func clientNamespacedKinds(
+	ctx context.Context,
+	cl client.Client,
+) ([]schema.GroupVersionKind, error) {
+	groupKinds := map[schema.GroupKind]bool{}
+
+	for gvk := range cl.Scheme().AllKnownTypes() {
+		kind, ok := strings.CutSuffix(gvk.Kind, "List")
+		if ok && cl.Scheme().Recognizes(gvk.GroupVersion().WithKind(kind)) {
+			groupKinds[schema.GroupKind{Group: gvk.Group, Kind: kind}] = true
+		}
+	}
+
+	crds := &unstructured.UnstructuredList{}
+	crds.SetGroupVersionKind(crdKind.GroupVersion().WithKind(crdKind.Kind + "List"))
+
+	if err := cl.List(ctx, crds); err != nil {
+		return nil, fmt.Errorf("failed to list CRDs: %w", err)
+	}
+
+	for _, crd := range crds.Items {
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+		groupKinds[schema.GroupKind{Group: group, Kind: kind}] = true
+	}
+
+	var kinds []schema.GroupVersionKind
+
+	for gk := range groupKinds {
+		// Kinds the API server does not serve, e.g. internal or removed versions, have no mapping
+		mapping, err := cl.RESTMapper().RESTMapping(gk)
+		if err != nil || mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			continue
+		}
+
+		kinds = append(kinds, mapping.GroupVersionKind)
+	}
+
+	return kinds, nil
+}
+
+// crdKind is the kind of CustomResourceDefinitions
+var crdKind = schema.GroupVersionKind{
+	Group:   "apiextensions.k8s.io",
+	Version: "v1",
+	Kind:    "CustomResourceDefinition",
+}
+
+// purgeTarget is a kind, in a namespace unless cluster-scoped, whose objects are deleted by force
+type purgeTarget struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	// keep reports objects to leave in place
+	keep func(obj *unstructured.Unstructured) bool
+}
+
+// remaining lists the objects of the target not deleted yet
+func (t purgeTarget) remaining(
+	ctx context.Context,
+	cl client.Client,
+) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(t.gvk.GroupVersion().WithKind(t.gvk.Kind + "List"))
+
+	err := cl.List(ctx, list, client.InNamespace(t.namespace))
+
+	switch {
+	// The namespace or CRD may be gone already, and some kinds, e.g. Bindings, cannot be listed
+	case apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to list %s: %w", t, err)
+	}
+
+	var remaining []unstructured.Unstructured
+
+	for _, obj := range list.Items {
+		if t.keep == nil || !t.keep(&obj) {
+			remaining = append(remaining, obj)
+		}
+	}
+
+	return remaining, nil
+}
+
+// deleteObjects deletes the objects of the target, in bulk unless some are kept
+func (t purgeTarget) deleteObjects(ctx context.Context, cl client.Client, retry retryFunc) error {
+	if t.keep == nil {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(t.gvk)
+
+		err := retry(ctx, "delete "+t.String(), func(ctx context.Context) error {
+			return cl.DeleteAllOf(ctx, obj, client.InNamespace(t.namespace))
+		})
+
+		// Some kinds, e.g. Bindings, cannot be deleted in bulk
+		if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsMethodNotSupported(err) {
+			return fmt.Errorf("failed to delete %s: %w", t, err)
+		}
+
+		return nil
+	}
+
+	objects, err := t.remaining(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+
+		err := retry(ctx, "delete "+t.String(), func(ctx context.Context) error {
+			return cl.Delete(ctx, obj)
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s %s: %w", t.gvk.Kind, objectKey(obj), err)
+		}
+	}
+
+	return nil
+}
+
+// clearFinalizers clears the finalizers of the objects of the target still held by them
+func (t purgeTarget) clearFinalizers(ctx context.Context, cl client.Client, retry retryFunc) error {
+	objects, err := t.remaining(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		if len(obj.GetFinalizers()) == 0 {
+			continue
+		}
+
+		err := retry(ctx, "clear finalizers", func(ctx context.Context) error {
+			return cl.Patch(ctx, obj, client.RawPatch(types.MergePatchType, clearFinalizersPatch))
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf(
+				"failed to clear finalizers of %s %s: %w",
+				t.gvk.Kind,
+				objectKey(obj),
+				err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// String returns the kind of the target and its namespace, if any
+func (t purgeTarget) String() string {
+	if t.namespace == "" {
+		return t.gvk.Kind
+	}
+
+	return t.gvk.Kind + " in namespace " + t.namespace
+}
+
+// objectKey returns "namespace/name" of a namespaced object and "name" of a cluster-scoped one
+func objectKey(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// finalizeNamespace clears the kubernetes finalizer of the namespace via the finalize subresource,
+// which the namespace controller would remove once the namespace is empty
+func finalizeNamespace(ctx context.Context, cl client.Client, retry retryFunc, name string) error {
+	err := retry(ctx, "finalize namespace", func(ctx context.Context) error {
+		ns := &corev1.Namespace{}
+
+		if err := cl.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+			return err
+		}
+
+		if len(ns.Spec.Finalizers) == 0 {
+			return nil
+		}
+
+		ns.Spec.Finalizers = []corev1.FinalizerName{}
+
+		return cl.SubResource("finalize").Update(ctx, ns)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to finalize namespace %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// waitForNamespaceRemoval polls until the namespace is gone
+func waitForNamespaceRemoval(
+	ctx context.Context,
+	cl client.Client,
+	name string,
+	timeout time.Duration,
+) error {
+	return wait.PollUntilContextTimeout(
+		ctx,
+		100*time.Millisecond,
+		timeout,
+		true,
+		func(ctx context.Context) (bool, error) {
+			err := cl.Get(ctx, client.ObjectKey{Name: name}, &corev1.Namespace{})
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+
+			return false, err
+		},
+	)
+}
+
+const (
+	// namespaceControllerGrace is how long a test namespace cleanup waits for the namespace controller
+	// to pick up a deleted namespace before removing it by force
+	namespaceControllerGrace = 2 * time.Second
+
+	// testNamespaceCleanupTimeout bounds how long deleting the namespace of NewTestNamespace may take
+	testNamespaceCleanupTimeout = time.Minute
+)
+
+// namespaceConfig holds the configuration for NewTestNamespace
+type namespaceConfig struct {
+	labels map[string]string
+}
+
+// NamespaceOption is a functional option for configuring NewTestNamespace
+type NamespaceOption func(*namespaceConfig)
+
+// WithNamespaceLabels adds labels to the test namespace, e.g. for namespace selectors of webhooks or policies.
+// Calling it multiple times merges the labels.
+func WithNamespaceLabels(labels map[string]string) NamespaceOption {
+	return func(c *namespaceConfig) {
+		maps.Copy(c.labels, labels)
+	}
+}
+
+// testNamespaceName derives a unique namespace name from the test name, e.g. "testfoo-case-1-x7k2p"
+// for TestFoo/case 1, see LoadFixtures for the sanitization
+func testNamespaceName(testName string) string {
+	name := fixtureTestName(testName)
+	if name == "" {
+		name = "test"
+	}
+
+	return name + "-" + utilrand.String(5)
+}
+
+// NewTestNamespace creates a namespace for the test, named after the test plus a random suffix, so tests sharing
+// one container do not see each other's objects. The namespace and its contents are deleted when the test finishes:
+// the namespace controller of WithControllerManager does it if it runs, otherwise the objects of every namespaced
+// resource known to the client scheme or defined by a CRD are deleted, clearing their finalizers,
+// and the namespace is finalized, like ForceDeleteNamespace.
+func NewTestNamespace(
+	ctx context.Context,
+	t testing.TB,
+	c client.Client,
+	opts ...NamespaceOption,
+) (*corev1.Namespace, error) {
+	t.Helper()
+
+	cfg := &namespaceConfig{labels: map[string]string{}}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: testNamespaceName(t.Name()), Labels: cfg.labels},
+	}
+
+	if err := c.Create(ctx, ns); err != nil {
+		return nil, fmt.Errorf("failed to create test namespace %s: %w", ns.Name, err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), testNamespaceCleanupTimeout)
+		defer cancel()
+
+		if err := deleteTestNamespace(ctx, c, ns.Name); err != nil {
+			t.Errorf("failed to delete test namespace %s: %v", ns.Name, err)
+		}
+	})
+
+	return ns, nil
+}
+
+// deleteTestNamespace deletes the namespace, leaving it to the namespace controller if it picks it up in time,
+// and removing it by force otherwise
+func deleteTestNamespace(ctx context.Context, cl client.Client, name string) error {
+	if err := deleteNamespace(ctx, cl, retryDefault, name); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	// The namespace controller reports its progress in the namespace conditions
+	handled := false
+	ns := &corev1.Namespace{}
+
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, namespaceControllerGrace, true,
+		func(ctx context.Context) (bool, error) {
+			if err := cl.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+				return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+			}
+
+			handled = len(ns.Status.Conditions) > 0
+
+			return handled, nil
+		})
+	if err != nil && !wait.Interrupted(err) {
+		return err
+	}
+
+	if handled && waitForNamespaceRemoval(ctx, cl, name, namespaceRemovalTimeout) == nil {
+		return nil
+	}
+
+	kinds, err := clientNamespacedKinds(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	return forceDeleteNamespace(ctx, cl, retryDefault, kinds, name)
+}
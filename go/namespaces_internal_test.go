@@ -0,0 +1,88 @@
+package envtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTestNamespaceName(t *testing.T) {
+	tests := map[string]string{
+		"TestFoo":                    `^testfoo-[a-z0-9]{5}$`,
+		"TestFoo/sub case 1":         `^testfoo-sub-case-1-[a-z0-9]{5}$`,
+		"TestFoo/nested/sub_case":    `^testfoo-nested-sub-case-[a-z0-9]{5}$`,
+		"TestFoo/ trailing space / ": `^testfoo--trailing-space-[a-z0-9]{5}$`,
+		"TestFoo/a_very_long_subtest_name_that_goes_on_and_on": `^testfoo-a-very-long-subtest-nam-[0-9a-f]{8}-[a-z0-9]{5}$`,
+		"/": `^test-[a-z0-9]{5}$`,
+	}
+
+	for testName, pattern := range tests {
+		name := testNamespaceName(testName)
+
+		require.Regexp(t, pattern, name, testName)
+		require.Empty(t, validation.IsDNS1123Label(name), testName)
+	}
+
+	require.NotEqual(t, testNamespaceName("TestFoo"), testNamespaceName("TestFoo"))
+}
+
+func TestTestNamespaceNameOfSubtest(t *testing.T) {
+	t.Run("a b/c", func(t *testing.T) {
+		require.Regexp(t, `^testtestnamespacenameofsubtest-a-b-c-[a-z0-9]{5}$`, testNamespaceName(t.Name()))
+	})
+}
+
+func TestPurgeTarget(t *testing.T) {
+	configMap := func(name string, finalizers ...string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", Finalizers: finalizers}}
+	}
+
+	cl := fake.NewClientBuilder().WithObjects(
+		configMap("app"),
+		configMap("guarded", "example.com/cleanup"),
+		configMap("kube-root-ca.crt"),
+	).Build()
+
+	var ops []string
+
+	retry := func(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+		ops = append(ops, op)
+
+		return fn(ctx)
+	}
+
+	target := purgeTarget{
+		gvk:       corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+		namespace: "apps",
+		keep: func(obj *unstructured.Unstructured) bool {
+			return obj.GetName() == "kube-root-ca.crt"
+		},
+	}
+
+	require.NoError(t, target.deleteObjects(t.Context(), cl, retry))
+
+	remaining, err := target.remaining(t.Context(), cl)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1, "the finalizer holds the deleted object")
+	require.Equal(t, "guarded", remaining[0].GetName())
+
+	require.NoError(t, target.clearFinalizers(t.Context(), cl, retry))
+
+	err = cl.Get(t.Context(), client.ObjectKey{Namespace: "apps", Name: "guarded"}, &corev1.ConfigMap{})
+	require.True(t, apierrors.IsNotFound(err), "object should be gone, got %v", err)
+
+	require.NoError(t, cl.Get(t.Context(), client.ObjectKey{Namespace: "apps", Name: "kube-root-ca.crt"}, &corev1.ConfigMap{}))
+	require.Equal(t, []string{
+		"delete ConfigMap in namespace apps",
+		"delete ConfigMap in namespace apps",
+		"clear finalizers",
+	}, ops)
+}
@@ -0,0 +1,101 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerForceDeleteNamespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	for range 2 {
+		_, err = clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "reused"},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		// Nothing removes the finalizer of the ConfigMap either
+		_, err = clientset.CoreV1().ConfigMaps("reused").Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "guarded", Finalizers: []string{"example.com/cleanup"}},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		start := time.Now()
+
+		require.NoError(t, c.ForceDeleteNamespace(ctx, "reused"))
+		require.Less(t, time.Since(start), 10*time.Second)
+
+		_, err = clientset.CoreV1().Namespaces().Get(ctx, "reused", metav1.GetOptions{})
+		require.True(t, apierrors.IsNotFound(err), "namespace should be gone, got %v", err)
+	}
+
+	require.NoError(t, c.ForceDeleteNamespace(ctx, "reused"))
+	require.NoError(t, c.ForceDeleteNamespace(ctx, "never-created"))
+}
+
+func TestNewTestNamespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	cl, err := client.New(cfg, client.Options{})
+	require.NoError(t, err)
+
+	var name string
+
+	t.Run("sub case/1", func(t *testing.T) {
+		ns, err := envtest.NewTestNamespace(ctx, t, cl, envtest.WithNamespaceLabels(map[string]string{"team": "a"}))
+		require.NoError(t, err)
+		require.Regexp(t, `^testnewtestnamespace-sub-case-1-[a-z0-9]{5}$`, ns.Name)
+		require.Equal(t, "a", ns.Labels["team"])
+
+		name = ns.Name
+
+		// The finalizer of the ConfigMap holds the namespace until it is cleared
+		require.NoError(t, cl.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "guarded",
+				Namespace:  ns.Name,
+				Finalizers: []string{"example.com/cleanup"},
+			},
+		}))
+	})
+
+	err = cl.Get(ctx, client.ObjectKey{Name: name}, &corev1.Namespace{})
+	require.True(t, apierrors.IsNotFound(err), "namespace should be gone, got %v", err)
+}
@@ -0,0 +1,102 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// DisconnectNetwork disconnects the container from the network given to WithNetwork.
+// Connections to the mapped port fail right away instead of hanging as with Pause:
+// established connections and watches break and new ones are refused until ReconnectNetwork.
+// Containers attached to the default bridge network only cannot be disconnected, as that would
+// not sever the port mapping cleanly, so WithNetwork is required.
+func (c *EnvtestContainer) DisconnectNetwork(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.network == "" {
+		return errors.New("container is attached to the default bridge network only, " +
+			"start it WithNetwork to disconnect it")
+	}
+
+	if c.disconnected {
+		return fmt.Errorf("container is already disconnected from network %s", c.network)
+	}
+
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	if err := cli.NetworkDisconnect(ctx, c.network, c.GetContainerID(), false); err != nil {
+		return fmt.Errorf("failed to disconnect container from network %s: %w", c.network, err)
+	}
+
+	c.disconnected = true
+
+	return nil
+}
+
+// ReconnectNetwork reconnects the container to its network with the original aliases and
+// waits until the API server is reachable again. Docker may map the API server to a new host port,
+// so the cached kubeconfig is dropped: clients built from RESTConfig or Kubeconfig before have to be rebuilt.
+func (c *EnvtestContainer) ReconnectNetwork(ctx context.Context) error {
+	if err := c.reconnectNetwork(ctx); err != nil {
+		return err
+	}
+
+	c.invalidateKubeconfig()
+
+	return c.WaitForReady(ctx, DefaultReadyTimeout)
+}
+
+// reconnectNetwork attaches the container back to its network
+func (c *EnvtestContainer) reconnectNetwork(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.disconnected {
+		return errors.New("container is not disconnected")
+	}
+
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	settings := &network.EndpointSettings{Aliases: c.networkAliases}
+
+	if err := cli.NetworkConnect(ctx, c.network, c.GetContainerID(), settings); err != nil {
+		return fmt.Errorf("failed to reconnect container to network %s: %w", c.network, err)
+	}
+
+	c.disconnected = false
+
+	return nil
+}
+
+// freeHostPort asks the kernel for a currently unused TCP port
+func freeHostPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free host port: %w", err)
+	}
+
+	defer func() { _ = listener.Close() }()
+
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return 0, fmt.Errorf("unexpected listener address %v", listener.Addr())
+	}
+
+	return addr.Port, nil
+}
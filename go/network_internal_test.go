@@ -0,0 +1,19 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkGuards(t *testing.T) {
+	c := &EnvtestContainer{}
+
+	require.ErrorContains(t, c.DisconnectNetwork(t.Context()), "default bridge network")
+	require.ErrorContains(t, c.ReconnectNetwork(t.Context()), "not disconnected")
+
+	c.network = "envtest-net"
+	c.disconnected = true
+
+	require.ErrorContains(t, c.DisconnectNetwork(t.Context()), "already disconnected")
+}
@@ -0,0 +1,162 @@
+package envtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"github.com/testcontainers/testcontainers-go/network"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerNetworkDisconnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	nw, err := network.New(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, nw.Remove(context.WithoutCancel(ctx)))
+	}()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithNetwork(nw.Name, "apiserver"))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	cl, err := client.New(cfg, client.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, cl.List(ctx, &corev1.NamespaceList{}))
+
+	host, err := c.Host(ctx)
+	require.NoError(t, err)
+
+	port, err := c.MappedPort(ctx, envtest.DefaultAPIServerPort+"/tcp")
+	require.NoError(t, err)
+
+	require.NoError(t, c.DisconnectNetwork(ctx))
+	require.Error(t, c.DisconnectNetwork(ctx), "disconnecting twice must be refused")
+
+	// Requests fail fast instead of hanging until the client timeout
+	reqCtx, reqCancel := context.WithTimeout(ctx, 10*time.Second)
+	start := time.Now()
+
+	err = cl.List(reqCtx, &corev1.NamespaceList{})
+
+	reqCancel()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// Nothing accepts connections on the mapped port while disconnected
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port.Port()), time.Second)
+	if err == nil {
+		// docker-proxy may still accept and immediately drop the connection
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		_ = conn.Close()
+	}
+
+	require.Error(t, err)
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "connection to the mapped port was kept open: %v", err)
+	}
+
+	require.NoError(t, c.ReconnectNetwork(ctx))
+
+	// The mapped port may have changed, so the client is rebuilt
+	cfg, err = c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	reconnectedPort, err := c.MappedPort(ctx, envtest.DefaultAPIServerPort+"/tcp")
+	require.NoError(t, err)
+	require.Contains(t, cfg.Host, ":"+reconnectedPort.Port())
+
+	cl, err = client.New(cfg, client.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, cl.List(ctx, &corev1.NamespaceList{}))
+}
+
+func TestEnvtestContainerCertSANs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	nw, err := network.New(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, nw.Remove(context.WithoutCancel(ctx)))
+	}()
+
+	opts := append(getEnvtestOptions(),
+		envtest.WithNetwork(nw.Name, "apiserver"),
+		envtest.WithCertSANs("envtest.ci.internal"),
+	)
+
+	c, err := envtest.Run(ctx, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	// Clients on the network verify the certificate against the CA for the alias and the extra SAN
+	curl := func(url string, args ...string) {
+		cmd := append([]string{"curl", "-sS", "--max-time", "5", "--cacert", "/tmp/ca.crt"}, args...)
+
+		code, reader, err := c.Exec(ctx, append(cmd, url), tcexec.Multiplexed())
+		require.NoError(t, err)
+
+		out, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, 0, code, string(out))
+		require.Equal(t, "ok", string(out))
+	}
+
+	curl("https://apiserver:6443/livez")
+	curl("https://envtest.ci.internal:6443/livez", "--connect-to", "envtest.ci.internal:6443:apiserver:6443")
+
+	kubeconfig, err := c.Kubeconfig(ctx, envtest.WithNetworkAddress())
+	require.NoError(t, err)
+	require.NotContains(t, kubeconfig, "insecure-skip-tls-verify")
+
+	// The kubeconfig CA verifies the extra SAN from the host too
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	addr := strings.TrimPrefix(cfg.Host, "https://")
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	cfg.Host = "https://" + net.JoinHostPort("envtest.ci.internal", port)
+	cfg.ServerName = ""
+	dialer := &net.Dialer{}
+	cfg.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	cl, err := client.New(cfg, client.Options{})
+	require.NoError(t, err)
+	require.NoError(t, cl.List(ctx, &corev1.NamespaceList{}))
+}
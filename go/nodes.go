@@ -0,0 +1,259 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// nodeHeartbeatInterval is how often RegisterNode renews the lease and Ready condition of a node,
+	// like the kubelet does, well within the grace period of the node lifecycle controller
+	nodeHeartbeatInterval = 10 * time.Second
+
+	// nodeLeaseDurationSeconds is the lease duration kubelets report
+	nodeLeaseDurationSeconds = 40
+)
+
+// nodeConfig holds the configuration for RegisterNode
+type nodeConfig struct {
+	labels      map[string]string
+	taints      []corev1.Taint
+	allocatable corev1.ResourceList
+}
+
+// NodeOption is a functional option for configuring RegisterNode
+type NodeOption func(*nodeConfig)
+
+// WithNodeLabels adds labels to the node, e.g. topology.kubernetes.io/zone for topology spread tests.
+// Calling it multiple times merges the labels.
+func WithNodeLabels(labels map[string]string) NodeOption {
+	return func(c *nodeConfig) {
+		maps.Copy(c.labels, labels)
+	}
+}
+
+// WithNodeTaints adds taints to the node
+func WithNodeTaints(taints ...corev1.Taint) NodeOption {
+	return func(c *nodeConfig) {
+		c.taints = append(c.taints, taints...)
+	}
+}
+
+// WithNodeAllocatable sets the allocatable resources and capacity of the node, merged into the default
+// of 4 CPUs, 16Gi of memory and 110 pods
+func WithNodeAllocatable(resources corev1.ResourceList) NodeOption {
+	return func(c *nodeConfig) {
+		maps.Copy(c.allocatable, resources)
+	}
+}
+
+// defaultNodeAllocatable returns the resources of a node registered without WithNodeAllocatable
+func defaultNodeAllocatable() corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}
+}
+
+// newFakeNode returns the node RegisterNode creates, without its status
+func newFakeNode(name string, cfg *nodeConfig) *corev1.Node {
+	labels := map[string]string{
+		corev1.LabelHostname: name,
+		corev1.LabelOSStable: "linux",
+	}
+	maps.Copy(labels, cfg.labels)
+
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{Taints: cfg.taints},
+	}
+}
+
+// fakeNodeStatus returns a Ready status with the given heartbeat time
+func fakeNodeStatus(cfg *nodeConfig, now metav1.Time) corev1.NodeStatus {
+	return corev1.NodeStatus{
+		Capacity:    cfg.allocatable.DeepCopy(),
+		Allocatable: cfg.allocatable.DeepCopy(),
+		Phase:       corev1.NodeRunning,
+		Conditions: []corev1.NodeCondition{{
+			Type:               corev1.NodeReady,
+			Status:             corev1.ConditionTrue,
+			Reason:             "KubeletReady",
+			Message:            "registered by envtest",
+			LastHeartbeatTime:  now,
+			LastTransitionTime: now,
+		}},
+	}
+}
+
+// RegisterNode creates a Ready Node, as no kubelet runs in the container to register one, e.g. for Pods to be
+// scheduled with WithScheduler. The node has 4 CPUs, 16Gi of memory and 110 pods allocatable unless
+// WithNodeAllocatable is given. Its lease and Ready condition are renewed in the background, so the node lifecycle
+// controller of WithControllerManager keeps it Ready, until the returned stop func is called or the container is
+// terminated. The stop func leaves the node in place, and is safe to call multiple times.
+func (c *EnvtestContainer) RegisterNode(
+	ctx context.Context,
+	name string,
+	opts ...NodeOption,
+) (*corev1.Node, func(), error) {
+	cfg := &nodeConfig{labels: map[string]string{}, allocatable: defaultNodeAllocatable()}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var node *corev1.Node
+
+	err = c.retry(ctx, "register node", func(ctx context.Context) error {
+		node, err = clientset.CoreV1().
+			Nodes().
+			Create(ctx, newFakeNode(name, cfg), metav1.CreateOptions{})
+
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create node %s: %w", name, err)
+	}
+
+	if node, err = heartbeatNode(ctx, clientset, node, cfg); err != nil {
+		return nil, nil, err
+	}
+
+	// The heartbeat outlives the ctx of the registration, as the node is used after it returns
+	heartbeatCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(nodeHeartbeatInterval)
+		defer ticker.Stop()
+
+		current := node
+
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// A missed heartbeat is retried on the next tick, like the kubelet does
+			if updated, err := heartbeatNode(heartbeatCtx, clientset, current, cfg); err == nil {
+				current = updated
+			}
+		}
+	}()
+
+	stop := sync.OnceFunc(func() {
+		cancel()
+		<-done
+	})
+
+	c.onTerminate(stop)
+
+	return node, stop, nil
+}
+
+// heartbeatNode renews the lease of the node and marks it Ready as of now
+func heartbeatNode(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	node *corev1.Node,
+	cfg *nodeConfig,
+) (*corev1.Node, error) {
+	now := metav1.NowMicro()
+
+	if err := renewNodeLease(ctx, clientset, node, now); err != nil {
+		return nil, err
+	}
+
+	current, err := clientset.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", node.Name, err)
+	}
+
+	status := fakeNodeStatus(cfg, metav1.NewTime(now.Time))
+
+	// The Ready condition keeps the time it became Ready
+	for _, condition := range current.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			status.Conditions[0].LastTransitionTime = condition.LastTransitionTime
+		}
+	}
+
+	current.Status = status
+
+	updated, err := clientset.CoreV1().Nodes().UpdateStatus(ctx, current, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update status of node %s: %w", node.Name, err)
+	}
+
+	return updated, nil
+}
+
+// renewNodeLease creates or renews the lease of the node in kube-node-lease, owned by the node like the kubelet's
+func renewNodeLease(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	node *corev1.Node,
+	now metav1.MicroTime,
+) error {
+	leases := clientset.CoordinationV1().Leases(corev1.NamespaceNodeLease)
+
+	lease, err := leases.Get(ctx, node.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      node.Name,
+				Namespace: corev1.NamespaceNodeLease,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "Node",
+					Name:       node.Name,
+					UID:        node.UID,
+				}},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(node.Name),
+				LeaseDurationSeconds: ptr.To[int32](nodeLeaseDurationSeconds),
+				RenewTime:            &now,
+			},
+		}
+
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create lease of node %s: %w", node.Name, err)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get lease of node %s: %w", node.Name, err)
+	}
+
+	lease.Spec.RenewTime = &now
+
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to renew lease of node %s: %w", node.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewFakeNode(t *testing.T) {
+	cfg := &nodeConfig{labels: map[string]string{}, allocatable: defaultNodeAllocatable()}
+
+	taint := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}
+
+	WithNodeLabels(map[string]string{"topology.kubernetes.io/zone": "a"})(cfg)
+	WithNodeLabels(map[string]string{corev1.LabelHostname: "custom"})(cfg)
+	WithNodeTaints(taint)(cfg)
+	WithNodeAllocatable(corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")})(cfg)
+
+	node := newFakeNode("worker", cfg)
+
+	require.Equal(t, "worker", node.Name)
+	require.Equal(t, map[string]string{
+		corev1.LabelHostname:          "custom",
+		corev1.LabelOSStable:          "linux",
+		"topology.kubernetes.io/zone": "a",
+	}, node.Labels)
+	require.Equal(t, []corev1.Taint{taint}, node.Spec.Taints)
+
+	now := metav1.Now()
+	status := fakeNodeStatus(cfg, now)
+
+	require.True(t, status.Allocatable.Cpu().Equal(resource.MustParse("500m")))
+	require.True(t, status.Allocatable.Memory().Equal(resource.MustParse("16Gi")))
+	require.Equal(t, status.Allocatable, status.Capacity)
+	require.Len(t, status.Conditions, 1)
+	require.Equal(t, corev1.NodeReady, status.Conditions[0].Type)
+	require.Equal(t, corev1.ConditionTrue, status.Conditions[0].Status)
+	require.Equal(t, now, status.Conditions[0].LastHeartbeatTime)
+}
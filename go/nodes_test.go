@@ -0,0 +1,78 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerRegisterNode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithScheduler())...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// The tainted node is not eligible, so the Pod lands on the zone-b node
+	_, stopA, err := c.RegisterNode(ctx, "zone-a",
+		envtest.WithNodeLabels(map[string]string{"topology.kubernetes.io/zone": "a"}),
+		envtest.WithNodeTaints(corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}),
+	)
+	require.NoError(t, err)
+
+	defer stopA()
+
+	node, stopB, err := c.RegisterNode(ctx, "zone-b",
+		envtest.WithNodeLabels(map[string]string{"topology.kubernetes.io/zone": "b"}),
+	)
+	require.NoError(t, err)
+
+	defer stopB()
+
+	require.Equal(t, "b", node.Labels["topology.kubernetes.io/zone"])
+	require.Equal(t, corev1.ConditionTrue, node.Status.Conditions[0].Status)
+
+	lease, err := clientset.CoordinationV1().Leases(corev1.NamespaceNodeLease).Get(ctx, "zone-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, lease.Spec.RenewTime)
+
+	_, err = clientset.CoreV1().Pods("default").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "busybox"}},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.EventuallyWithT(t, func(collect *assert.CollectT) {
+		pod, err := clientset.CoreV1().Pods("default").Get(ctx, "app", metav1.GetOptions{})
+		require.NoError(collect, err)
+		assert.Equal(collect, "zone-b", pod.Spec.NodeName)
+	}, time.Minute, 200*time.Millisecond)
+
+	// Stopping the heartbeat leaves the node in place
+	stopB()
+	stopB()
+
+	_, err = clientset.CoreV1().Nodes().Get(ctx, "zone-b", metav1.GetOptions{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,128 @@
+package envtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LoadObjects decodes the objects of a multi-document YAML or JSON manifest in document order, skipping
+// empty documents and expanding lists such as v1/List. Kinds known to the scheme, or to the built-in types
+// if scheme is nil, are decoded into their Go types, others into *unstructured.Unstructured.
+func LoadObjects(scheme *runtime.Scheme, manifests []byte) ([]client.Object, error) {
+	if scheme == nil {
+		scheme = clientgoscheme.Scheme
+	}
+
+	var objs []client.Object
+
+	err := decodeDocuments(
+		"manifest",
+		bytes.NewReader(manifests),
+		func(i int, doc *unstructured.Unstructured) error {
+			if doc.GetKind() == "" || doc.GetAPIVersion() == "" {
+				return fmt.Errorf("manifest document %d has no apiVersion or kind", i)
+			}
+
+			if !doc.IsList() {
+				obj, err := typedObject(scheme, doc)
+				if err != nil {
+					return fmt.Errorf("failed to decode manifest document %d: %w", i, err)
+				}
+
+				objs = append(objs, obj)
+
+				return nil
+			}
+
+			list, err := doc.ToList()
+			if err != nil {
+				return fmt.Errorf("failed to decode manifest document %d: %w", i, err)
+			}
+
+			for j := range list.Items {
+				obj, err := typedObject(scheme, &list.Items[j])
+				if err != nil {
+					return fmt.Errorf(
+						"failed to decode manifest document %d item %d: %w",
+						i,
+						j,
+						err,
+					)
+				}
+
+				objs = append(objs, obj)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return objs, nil
+}
+
+// decodeDocuments decodes a multi-document YAML or JSON manifest read from source, calling fn with every
+// non-empty document and its index, and stops at the first error
+func decodeDocuments(
+	source string,
+	r io.Reader,
+	fn func(index int, doc *unstructured.Unstructured) error,
+) error {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	for i := 0; ; i++ {
+		doc := &unstructured.Unstructured{}
+
+		err := decoder.Decode(&doc.Object)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to decode %s document %d: %w", source, i, err)
+		}
+
+		// Empty documents, e.g. a trailing separator
+		if len(doc.Object) == 0 {
+			continue
+		}
+
+		if err := fn(i, doc); err != nil {
+			return err
+		}
+	}
+}
+
+// typedObject converts an object into its Go type if the scheme knows its kind
+func typedObject(scheme *runtime.Scheme, u *unstructured.Unstructured) (client.Object, error) {
+	gvk := u.GroupVersionKind()
+	if !scheme.Recognizes(gvk) {
+		return u, nil
+	}
+
+	typed, err := scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := typed.(client.Object)
+	if !ok {
+		return u, nil
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
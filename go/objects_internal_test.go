@@ -0,0 +1,101 @@
+package envtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestLoadObjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		err      string
+	}{
+		{
+			name:     "empty",
+			manifest: "",
+		},
+		{
+			name: "comments-only documents",
+			manifest: `# Leading comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+# Only a comment
+---
+apiVersion: examples.com/v1
+kind: Widget
+metadata:
+  name: blue
+---
+`,
+			want: []string{"*v1.ConfigMap settings", "*unstructured.Unstructured blue"},
+		},
+		{
+			name: "list kind",
+			manifest: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: apps
+- apiVersion: examples.com/v1
+  kind: Widget
+  metadata:
+    name: green
+---
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "credentials"}}
+`,
+			want: []string{"*v1.Namespace apps", "*unstructured.Unstructured green", "*v1.Secret credentials"},
+		},
+		{
+			name:     "missing kind",
+			manifest: "apiVersion: v1\nmetadata:\n  name: settings\n",
+			err:      "manifest document 0 has no apiVersion or kind",
+		},
+		{
+			name:     "invalid yaml",
+			manifest: "apiVersion: v1\nkind: ConfigMap\n---\nkind: [ConfigMap\n",
+			err:      "failed to decode manifest document 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := LoadObjects(nil, []byte(tt.manifest))
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			var got []string
+			for _, obj := range objs {
+				got = append(got, fmt.Sprintf("%T %s", obj, obj.GetName()))
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadObjectsTyped(t *testing.T) {
+	objs, err := LoadObjects(nil, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  mode: fast\n"))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+
+	cm, ok := objs[0].(*corev1.ConfigMap)
+	require.True(t, ok)
+	require.Equal(t, "fast", cm.Data["mode"])
+	require.Equal(t, "ConfigMap", cm.Kind)
+}
@@ -0,0 +1,71 @@
+package envtest
+
+import (
+	"errors"
+	"strings"
+)
+
+// OIDCCAPath is the path to the CA bundle of the OIDC issuer of WithOIDC inside the container
+const OIDCCAPath = "/etc/envtest/oidc-ca.crt"
+
+// OIDCOptions configures the API server to authenticate ID tokens of an OpenID Connect issuer running on the test host,
+// e.g. a fake issuer serving discovery and keys for the tokens a test signs
+type OIDCOptions struct {
+	// Port is the port the issuer listens on
+	Port int
+	// Path is the path of the issuer URL, if any
+	Path string
+	// ClientID is the audience tokens have to be issued for
+	ClientID string
+	// UsernameClaim is the claim used as the username (default: "sub")
+	UsernameClaim string
+	// UsernamePrefix is prepended to usernames (default: the issuer URL and "#" for claims other than "email", "-" for none)
+	UsernamePrefix string
+	// GroupsClaim is the claim used as the groups, none if empty
+	GroupsClaim string
+	// GroupsPrefix is prepended to groups
+	GroupsPrefix string
+	// CABundle is the PEM-encoded CA of the issuer serving certificate, e.g. certs.WebhookPKI.CACert
+	CABundle []byte
+}
+
+// IssuerURL returns the URL the API server reaches the issuer at, which has to be the issuer
+// of its discovery document and the iss claim of tokens
+func (o OIDCOptions) IssuerURL() string {
+	return hostURL("https", o.Port, strings.TrimSuffix(o.Path, "/"))
+}
+
+// check rejects options kube-apiserver would fail to start with
+func (o OIDCOptions) check() error {
+	if o.Port <= 0 {
+		return errors.New("OIDC issuer port must be set")
+	}
+
+	if o.ClientID == "" {
+		return errors.New("OIDC client ID must be set")
+	}
+
+	return nil
+}
+
+// apiServerArgs renders the kube-apiserver OIDC flags
+func (o OIDCOptions) apiServerArgs() []string {
+	args := []string{"--oidc-issuer-url=" + o.IssuerURL(), "--oidc-client-id=" + o.ClientID}
+
+	for _, flag := range []struct{ name, value string }{
+		{"oidc-username-claim", o.UsernameClaim},
+		{"oidc-username-prefix", o.UsernamePrefix},
+		{"oidc-groups-claim", o.GroupsClaim},
+		{"oidc-groups-prefix", o.GroupsPrefix},
+	} {
+		if flag.value != "" {
+			args = append(args, "--"+flag.name+"="+flag.value)
+		}
+	}
+
+	if len(o.CABundle) > 0 {
+		args = append(args, "--oidc-ca-file="+OIDCCAPath)
+	}
+
+	return args
+}
@@ -0,0 +1,47 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithOIDC(t *testing.T) {
+	cfg := &config{}
+
+	WithOIDC(OIDCOptions{
+		Port:           5556,
+		Path:           "/dex/",
+		ClientID:       "envtest",
+		UsernameClaim:  "email",
+		GroupsClaim:    "groups",
+		GroupsPrefix:   "oidc:",
+		CABundle:       []byte("ca"),
+		UsernamePrefix: "-",
+	})(cfg)
+
+	require.True(t, cfg.hostAccessEnabled())
+	require.Equal(t, "https://host.testcontainers.internal:5556/dex", cfg.oidc.IssuerURL())
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--oidc-issuer-url=https://host.testcontainers.internal:5556/dex",
+		"--oidc-client-id=envtest",
+		"--oidc-username-claim=email",
+		"--oidc-username-prefix=-",
+		"--oidc-groups-claim=groups",
+		"--oidc-groups-prefix=oidc:",
+		"--oidc-ca-file=" + OIDCCAPath,
+	}, cfg.apiServerArgs())
+
+	cfg = &config{}
+	WithOIDC(OIDCOptions{ClientID: "envtest"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "OIDC issuer port must be set")
+
+	cfg = &config{}
+	WithOIDC(OIDCOptions{Port: 5556})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "OIDC client ID must be set")
+
+	cfg = &config{}
+	WithAPIServerArg("oidc-issuer-url", "https://issuer.example.com")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "kube-apiserver flag --oidc-issuer-url is set by WithOIDC")
+}
@@ -0,0 +1,154 @@
+package envtest_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// fakeOIDCIssuer serves the discovery document and signing key of an OpenID Connect issuer
+type fakeOIDCIssuer struct {
+	url string
+	key *rsa.PrivateKey
+}
+
+func (i *fakeOIDCIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body any
+
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		body = map[string]any{
+			"issuer":                                i.url,
+			"jwks_uri":                              i.url + "/keys",
+			"response_types_supported":              []string{"id_token"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		}
+	case "/keys":
+		body = map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}}}
+	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// token signs an RS256 ID token with the given claims
+func (i *fakeOIDCIssuer) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestEnvtestContainerWithOIDC(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// The issuer listens on the test host, reached by the container through the host alias
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	require.NoError(t, err)
+
+	pki, err := certs.NewWebhookPKI(testcontainers.HostInternal)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, pki.WriteDir(dir))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	opts := envtest.OIDCOptions{
+		Port:           listener.Addr().(*net.TCPAddr).Port,
+		ClientID:       "envtest",
+		UsernamePrefix: "oidc:",
+		GroupsClaim:    "groups",
+		GroupsPrefix:   "oidc:",
+		CABundle:       pki.CACert,
+	}
+
+	issuer := &fakeOIDCIssuer{url: opts.IssuerURL(), key: key}
+	srv := &http.Server{Handler: issuer, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		_ = srv.ServeTLS(listener, filepath.Join(dir, certs.CertFileName), filepath.Join(dir, certs.KeyFileName))
+	}()
+
+	defer func() { _ = srv.Close() }()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithOIDC(opts))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	token := issuer.token(t, map[string]any{
+		"iss":    issuer.url,
+		"aud":    opts.ClientID,
+		"sub":    "alice",
+		"groups": []string{"admins", "developers"},
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+
+	kubeconfig, err := c.KubeconfigForToken(ctx, token)
+	require.NoError(t, err)
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// The API server fetches the issuer keys in the background after starting
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		require.Equal(t, "oidc:alice", review.Status.UserInfo.Username)
+		require.ElementsMatch(t, []string{"oidc:admins", "oidc:developers", "system:authenticated"}, review.Status.UserInfo.Groups)
+	}, 30*time.Second, 500*time.Millisecond)
+}
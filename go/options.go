@@ -1,14 +1,187 @@
 package envtest
 
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
 // config holds the configuration for the envtest container
 type config struct {
-	image             string
-	kubernetesVersion string
+	image               string
+	kubernetesVersion   string
+	runtimeConfig       map[string]bool
+	hostAccess          bool
+	hostAccessPorts     []int
+	auditPolicy         []byte
+	auditWebhook        bool
+	encryptionConfig    []byte
+	tokenUsers          []TokenUser
+	oidc                *OIDCOptions
+	authnWebhook        []byte
+	authzWebhook        []byte
+	authnConfig         []byte
+	tracing             *tracingOptions
+	saIssuer            string
+	saSigningKey        []byte
+	apiAudiences        []string
+	noRetries           bool
+	etcdExposed         bool
+	etcdDataVolume      string
+	etcdInMemory        bool
+	etcdQuotaBytes      int64
+	etcdFlags           []apiServerFlag
+	controllerManager   bool
+	controllers         []string
+	scheduler           bool
+	kwok                bool
+	reuseName           string
+	startupTimeout      time.Duration
+	customWait          wait.Strategy
+	legacyWait          bool
+	shutdownDelay       time.Duration
+	network             string
+	networkAliases      []string
+	clusterName         string
+	contextName         string
+	certSANs            []string
+	crdPaths            []string
+	crdURLs             []string
+	webhookPaths        []string
+	webhookTarget       string
+	webhookPort         int
+	manifestPaths       []string
+	manifestDirs        []manifestFS
+	kustomizeDirs       []string
+	apiServerFlags      []apiServerFlag
+	featureGates        map[string]bool
+	enablePlugins       []string
+	disablePlugins      []string
+	authzModes          []string
+	verbosity           *int
+	maxInflight         *int
+	maxMutating         *int
+	requestTimeout      *time.Duration
+	priorityAndFairness *bool
+	profiling           *bool
+	anonymousAuth       *bool
+	watchCache          *bool
+	watchCacheSize      *int
+	serviceCIDR         string
+	nodePortRange       string
+	logConsumers        []testcontainers.LogConsumer
+
+	crdConversionWebhook *CRDConversionWebhook
 }
 
 // Option is a functional option for configuring the envtest container
 type Option func(*config)
 
+// apiServerFlag is an extra kube-apiserver or etcd flag, named without the leading dashes
+type apiServerFlag struct {
+	name  string
+	value string
+}
+
+// switchFlag is a kube-apiserver flag listing things to enable or disable, such as --feature-gates,
+// whose entries given by an option and by WithAPIServerFlags are merged
+type switchFlag struct {
+	name   string
+	kind   string
+	format string
+	// bareEnabled accepts entries without a value as enabled
+	bareEnabled bool
+}
+
+var (
+	runtimeConfigFlag = switchFlag{
+		name:        "runtime-config",
+		kind:        "runtime config API",
+		format:      "API[=true|false]",
+		bareEnabled: true,
+	}
+	featureGatesFlag = switchFlag{
+		name:   "feature-gates",
+		kind:   "feature gate",
+		format: "Name=true|false",
+	}
+)
+
+// managedAPIServerFlags are set by the container entrypoint or by other options, and cannot be overridden
+var managedAPIServerFlags = map[string]string{
+	"etcd-servers":                             "the container",
+	"bind-address":                             "the container",
+	"secure-port":                              "the container",
+	"tls-cert-file":                            "the container",
+	"tls-private-key-file":                     "the container",
+	"client-ca-file":                           "the container",
+	"service-account-key-file":                 "the container",
+	"service-account-signing-key-file":         "the container",
+	"service-account-issuer":                   "WithServiceAccountIssuer",
+	"api-audiences":                            "WithAPIAudiences",
+	"tracing-config-file":                      "WithTracing",
+	"audit-policy-file":                        "WithAuditPolicy",
+	"audit-log-path":                           "WithAuditPolicy",
+	"audit-webhook-config-file":                "WithAuditWebhook",
+	"audit-webhook-mode":                       "WithAuditWebhook",
+	"encryption-provider-config":               "WithEncryptionConfig",
+	"token-auth-file":                          "WithTokenAuth",
+	"oidc-issuer-url":                          "WithOIDC",
+	"oidc-client-id":                           "WithOIDC",
+	"oidc-username-claim":                      "WithOIDC",
+	"oidc-username-prefix":                     "WithOIDC",
+	"oidc-groups-claim":                        "WithOIDC",
+	"oidc-groups-prefix":                       "WithOIDC",
+	"oidc-ca-file":                             "WithOIDC",
+	"authentication-token-webhook-config-file": "WithAuthenticationWebhook",
+	"authorization-webhook-config-file":        "WithAuthorizationWebhook",
+	"authentication-config":                    "WithAuthenticationConfig",
+	"shutdown-delay-duration":                  "WithShutdownDelay",
+	"enable-admission-plugins":                 "WithEnableAdmissionPlugins",
+	"disable-admission-plugins":                "WithDisableAdmissionPlugins",
+	"authorization-mode":                       "WithAuthorizationModes",
+	"service-cluster-ip-range":                 "WithServiceClusterIPRange",
+	"service-node-port-range":                  "WithServiceNodePortRange",
+}
+
+// managedEtcdFlags are set by the container entrypoint, which needs them to serve kube-apiserver and the helpers
+var managedEtcdFlags = map[string]string{
+	"data-dir":                    "the container",
+	"listen-client-urls":          "the container",
+	"advertise-client-urls":       "the container",
+	"listen-peer-urls":            "the container",
+	"initial-advertise-peer-urls": "the container",
+	"initial-cluster":             "the container",
+}
+
+const (
+	// verbosityFlag is the log verbosity flag of kube-apiserver
+	verbosityFlag = "v"
+
+	// maxVerbosity is the highest log verbosity WithAPIServerVerbosity accepts
+	maxVerbosity = 10
+)
+
+// authorizationModes are the values of --authorization-mode. ABAC and Webhook need their configuration
+// passed with WithAPIServerArg, e.g. --authorization-webhook-config-file.
+var authorizationModes = []string{"AlwaysAllow", "AlwaysDeny", "ABAC", "Webhook", "RBAC", "Node"}
+
+// defaultDisabledAdmissionPlugins are disabled by the container entrypoint, as envtest runs no controllers
+// creating the default ServiceAccount of namespaces
+var defaultDisabledAdmissionPlugins = []string{"ServiceAccount"}
+
 // WithImage sets a custom Docker image for the envtest container.
 // By default, it uses ghcr.io/roma-glushko/testcontainers-envtest:latest
 func WithImage(image string) Option {
@@ -25,3 +198,1131 @@ func WithKubernetesVersion(version string) Option {
 		c.kubernetesVersion = version
 	}
 }
+
+// WithStartupTimeout sets how long Run waits for the container to become ready, e.g. longer on slow CI runners
+// (default: 1m per readiness check). Pulling the image is not included.
+func WithStartupTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.startupTimeout = d
+	}
+}
+
+// WithWaitStrategy replaces the readiness checks of Run, e.g. for custom images serving the API server elsewhere.
+// It is still bounded by WithStartupTimeout if given.
+func WithWaitStrategy(strategy wait.Strategy) Option {
+	return func(c *config) {
+		c.customWait = strategy
+	}
+}
+
+// WithLegacyWait also waits for the entrypoint to log "Envtest is ready!", as Run did before probing /readyz,
+// e.g. for images running extra setup after the API server is ready
+func WithLegacyWait() Option {
+	return func(c *config) {
+		c.legacyWait = true
+	}
+}
+
+// WithReuse names the container and reuses it if it is already running, so the test packages of one go test run
+// share a single container instead of starting one each. The container is created by the first Run and kept
+// until the go test run ends, as Ryuk reaps it with the containers of that session: Terminate removes it for all
+// the packages, so tests sharing it should leave it running, and use Reset to wipe their state instead.
+// Only the first Run configures the container; the others get it as is, except for the CRDs, manifests and
+// webhooks they install, and fail if it runs another Kubernetes version than the one they request.
+// It cannot be combined with WithAuditWebhook or the ports of WithHostAccess.
+func WithReuse(name string) Option {
+	return func(c *config) {
+		c.reuseName = name
+	}
+}
+
+// WithRuntimeConfig enables or disables API groups and versions on the API server
+// via --runtime-config, e.g. {"networking.k8s.io/v1beta1": true} or {"batch/v1": false}.
+// Calling it multiple times merges the entries, and so do --runtime-config given to WithAPIServerFlags.
+// Alpha APIs usually need their feature gate too (see WithFeatureGates), which only enables the feature,
+// while runtime config only serves the group version.
+func WithRuntimeConfig(apis map[string]bool) Option {
+	return func(c *config) {
+		if c.runtimeConfig == nil {
+			c.runtimeConfig = make(map[string]bool, len(apis))
+		}
+
+		maps.Copy(c.runtimeConfig, apis)
+	}
+}
+
+// WithHostAccess makes servers running on the test host reachable from the container under HostAlias.
+// Options that make the API server call back to the host enable it automatically.
+//
+// Without ports, HostAlias resolves to the Docker host gateway, which reaches any port of the host but requires
+// the test to run on the Docker host. With ports, only those are forwarded to the host through testcontainers'
+// SSH tunnel, which also works with remote Docker daemons. The ports of WithWebhooks and WithCRDConversionWebhook
+// are forwarded as well.
+func WithHostAccess(ports ...int) Option {
+	return func(c *config) {
+		c.hostAccess = true
+		c.hostAccessPorts = append(c.hostAccessPorts, ports...)
+	}
+}
+
+// WithAuditPolicy enables audit logging on the API server with the given audit.k8s.io policy.
+// The recorded events can be read with GetAuditEvents.
+func WithAuditPolicy(policyYAML []byte) Option {
+	return func(c *config) {
+		c.auditPolicy = policyYAML
+	}
+}
+
+// WithAuditWebhook streams the audit events of the given audit.k8s.io policy to a webhook server
+// the module runs on the test host, delivering them on AuditEvents. The API server sends them in batches,
+// so auditing never blocks requests. It enables WithHostAccess and replaces the policy of WithAuditPolicy,
+// as the API server applies one policy to every audit backend; GetAuditEvents keeps working.
+func WithAuditWebhook(policyYAML []byte) Option {
+	return func(c *config) {
+		c.auditPolicy = policyYAML
+		c.auditWebhook = true
+		c.hostAccess = true
+	}
+}
+
+// WithEncryptionConfig encrypts resources at rest with the given apiserver.config.k8s.io EncryptionConfiguration,
+// mounted at EncryptionConfigPath. The stored values can be inspected with EtcdGetKey.
+func WithEncryptionConfig(configYAML []byte) Option {
+	return func(c *config) {
+		c.encryptionConfig = configYAML
+	}
+}
+
+// WithTokenAuth authenticates requests bearing the tokens of the given users, written to a --token-auth-file
+// at TokenAuthFilePath before the container starts. KubeconfigForToken returns a kubeconfig for a token.
+func WithTokenAuth(tokens []TokenUser) Option {
+	return func(c *config) {
+		c.tokenUsers = append(c.tokenUsers, tokens...)
+	}
+}
+
+// WithOIDC authenticates ID tokens of an OpenID Connect issuer on the test host. It enables WithHostAccess.
+func WithOIDC(opts OIDCOptions) Option {
+	return func(c *config) {
+		c.oidc = &opts
+		c.hostAccess = true
+	}
+}
+
+// WithAuthenticationConfig configures JWT authenticators, e.g. several issuers with CEL claim mappings,
+// with an apiserver.config.k8s.io AuthenticationConfiguration (v1beta1 from Kubernetes 1.30, v1 from 1.34).
+// Issuers on the test host need WithHostAccess.
+func WithAuthenticationConfig(configYAML []byte) Option {
+	return func(c *config) {
+		c.authnConfig = configYAML
+	}
+}
+
+// WithAuthenticationWebhook authenticates bearer tokens with the authentication.k8s.io/v1 TokenReview webhook
+// of the given kubeconfig, e.g. one of HostWebhookKubeconfig. It enables WithHostAccess.
+func WithAuthenticationWebhook(kubeconfigYAML []byte) Option {
+	return func(c *config) {
+		c.authnWebhook = kubeconfigYAML
+		c.hostAccess = true
+	}
+}
+
+// WithAuthorizationWebhook authorizes requests with the authorization.k8s.io/v1 SubjectAccessReview webhook
+// of the given kubeconfig, e.g. one of HostWebhookKubeconfig. Unless set by WithAuthorizationModes,
+// which has to include Webhook, the authorization modes are RBAC and Webhook. It enables WithHostAccess.
+func WithAuthorizationWebhook(kubeconfigYAML []byte) Option {
+	return func(c *config) {
+		c.authzWebhook = kubeconfigYAML
+		c.hostAccess = true
+	}
+}
+
+// WithTracing exports the spans of the API server to the OTLP gRPC collector at otlpEndpoint (host:port),
+// sampling samplingRatePerMillion of the requests that are not part of a sampled trace already.
+// The endpoint defaults to DefaultOTLPPort on HostAlias, so a collector in the test can receive the spans;
+// an endpoint on HostAlias enables WithHostAccess. It requires Kubernetes 1.27 or later.
+func WithTracing(otlpEndpoint string, samplingRatePerMillion int) Option {
+	return func(c *config) {
+		c.tracing = newTracingOptions(otlpEndpoint, samplingRatePerMillion)
+
+		if c.tracing.hostPort() != 0 {
+			c.hostAccess = true
+		}
+	}
+}
+
+// WithAnonymousAuth enables or disables anonymous requests via --anonymous-auth (default: enabled).
+// Disabled, requests without credentials, including to /healthz and /version, fail with 401 like in hardened
+// clusters, while the clients of the module authenticate with the admin certificate.
+func WithAnonymousAuth(enabled bool) Option {
+	return func(c *config) {
+		c.anonymousAuth = &enabled
+	}
+}
+
+// WithServiceAccountIssuer sets the iss claim of ServiceAccount tokens via --service-account-issuer
+// (default: DefaultServiceAccountIssuer). It is also the default audience of the tokens, see WithAPIAudiences.
+func WithServiceAccountIssuer(issuerURL string) Option {
+	return func(c *config) {
+		c.saIssuer = issuerURL
+	}
+}
+
+// WithServiceAccountSigningKey signs ServiceAccount tokens with the given PEM-encoded RSA or ECDSA private key,
+// mounted at ServiceAccountSigningKeyPath, instead of the key of the API server certificate,
+// so tests can verify the tokens with the matching public key
+func WithServiceAccountSigningKey(pemKey []byte) Option {
+	return func(c *config) {
+		c.saSigningKey = pemKey
+	}
+}
+
+// WithAPIAudiences sets the audiences the API server accepts tokens for and gives tokens requested without any
+// via --api-audiences (default: the service account issuer)
+func WithAPIAudiences(audiences ...string) Option {
+	return func(c *config) {
+		c.apiAudiences = append(c.apiAudiences, audiences...)
+	}
+}
+
+// WithEtcdExposed publishes the etcd client port on a random host port, for inspecting the storage
+// with GetEtcdClient. etcd serves plain HTTP without authentication, so only expose it to trusted networks.
+func WithEtcdExposed() Option {
+	return func(c *config) {
+		c.etcdExposed = true
+	}
+}
+
+// WithEtcdDataVolume mounts the named Docker volume at the etcd data dir, so the cluster state survives Restart
+// and outlives the container, e.g. to inspect it or to start another container on the same data.
+// The volume is created on first use and left in place on Terminate, pass testcontainers.RemoveVolumes to drop it.
+func WithEtcdDataVolume(volumeName string) Option {
+	return func(c *config) {
+		c.etcdDataVolume = volumeName
+	}
+}
+
+// WithEtcdInMemory mounts a tmpfs at the etcd data dir and turns off fsync in etcd (--unsafe-no-fsync,
+// with etcd 3.5 and later), which speeds up writes on slow disks. The cluster state lives in memory only:
+// it is lost when the container stops, so Restart starts from an empty cluster, and Snapshot copies use memory too.
+// It cannot be combined with WithEtcdDataVolume.
+func WithEtcdInMemory() Option {
+	return func(c *config) {
+		c.etcdInMemory = true
+	}
+}
+
+// WithEtcdQuotaBytes sets the size of the etcd backend (--quota-backend-bytes), 2GiB by default.
+// Once the database grows past it, etcd raises a NOSPACE alarm and rejects writes with
+// "mvcc: database space exceeded" until CompactEtcd and DefragEtcd free space.
+func WithEtcdQuotaBytes(n int64) Option {
+	return func(c *config) {
+		c.etcdQuotaBytes = n
+	}
+}
+
+// WithEtcdFlags passes extra flags to the embedded etcd, e.g. {"heartbeat-interval": "50", "max-request-bytes": "262144"},
+// named with or without the leading dashes. Flags are appended in name order, after the ones of the container.
+// Run fails if a flag is given twice with different values, or is set by the container or another option,
+// such as the data dir and listen URLs.
+func WithEtcdFlags(flags map[string]string) Option {
+	return func(c *config) {
+		for _, name := range slices.Sorted(maps.Keys(flags)) {
+			c.etcdFlags = append(
+				c.etcdFlags,
+				apiServerFlag{name: strings.TrimLeft(name, "-"), value: flags[name]},
+			)
+		}
+	}
+}
+
+// WithNoRetries disables retrying transient API errors in the mutating helpers,
+// for tests that exercise the error paths themselves
+func WithNoRetries() Option {
+	return func(c *config) {
+		c.noRetries = true
+	}
+}
+
+// WithShutdownDelay sets --shutdown-delay-duration: on graceful shutdown the API server keeps serving
+// for this long with /readyz failing, so clients and load balancers can move away (see ShutdownAPIServer)
+func WithShutdownDelay(delay time.Duration) Option {
+	return func(c *config) {
+		c.shutdownDelay = delay
+	}
+}
+
+// WithAPIServerFlags passes extra flags to kube-apiserver, e.g. {"max-requests-inflight": "800"},
+// named with or without the leading dashes. Flags are appended in name order, after the ones of the container.
+func WithAPIServerFlags(flags map[string]string) Option {
+	return func(c *config) {
+		for _, name := range slices.Sorted(maps.Keys(flags)) {
+			WithAPIServerArg(name, flags[name])(c)
+		}
+	}
+}
+
+// WithAPIServerArg passes an extra flag to kube-apiserver like WithAPIServerFlags.
+// Run fails if a flag is given twice with different values, or is set by the container or another option.
+func WithAPIServerArg(name, value string) Option {
+	return func(c *config) {
+		c.apiServerFlags = append(
+			c.apiServerFlags,
+			apiServerFlag{name: strings.TrimLeft(name, "-"), value: value},
+		)
+	}
+}
+
+// WithFeatureGates sets feature gates of the API server via --feature-gates, e.g. {"WatchList": true}.
+// Calling it multiple times merges the gates, and so do --feature-gates given to WithAPIServerFlags,
+// as long as they do not set a gate to a different value.
+func WithFeatureGates(gates map[string]bool) Option {
+	return func(c *config) {
+		if c.featureGates == nil {
+			c.featureGates = make(map[string]bool, len(gates))
+		}
+
+		maps.Copy(c.featureGates, gates)
+	}
+}
+
+// WithEnableAdmissionPlugins enables admission plugins via --enable-admission-plugins, e.g. "PodSecurity".
+// Enabling ServiceAccount, which the container disables by default, requires every namespace to have
+// a default ServiceAccount for pods to be created.
+func WithEnableAdmissionPlugins(names ...string) Option {
+	return func(c *config) {
+		c.enablePlugins = append(c.enablePlugins, names...)
+	}
+}
+
+// WithDisableAdmissionPlugins disables admission plugins via --disable-admission-plugins,
+// e.g. "NamespaceLifecycle", besides ServiceAccount disabled by the container
+func WithDisableAdmissionPlugins(names ...string) Option {
+	return func(c *config) {
+		c.disablePlugins = append(c.disablePlugins, names...)
+	}
+}
+
+// WithAuthorizationModes sets the authorization modes of the API server via --authorization-mode,
+// checked in the given order (default: RBAC). They do not restrict the admin user of RESTConfig.
+func WithAuthorizationModes(modes ...string) Option {
+	return func(c *config) {
+		c.authzModes = append(c.authzModes, modes...)
+	}
+}
+
+// WithServiceClusterIPRange sets the CIDR Service cluster IPs are allocated from (default: 10.0.0.0/24),
+// or an IPv4 and an IPv6 CIDR separated by a comma for dual-stack Services
+func WithServiceClusterIPRange(cidr string) Option {
+	return func(c *config) {
+		c.serviceCIDR = cidr
+	}
+}
+
+// WithServiceNodePortRange sets the port range NodePort Services are allocated from, e.g. "30000-32767"
+func WithServiceNodePortRange(r string) Option {
+	return func(c *config) {
+		c.nodePortRange = r
+	}
+}
+
+// WithControllerManager runs kube-controller-manager in the container next to the API server, so namespaces
+// finish terminating, owner references are garbage collected and namespaces get their default ServiceAccount.
+// Run waits for it to be healthy. Pods still start without a ServiceAccount token unless the ServiceAccount
+// admission plugin is enabled with WithEnableAdmissionPlugins, and nothing schedules or runs them.
+func WithControllerManager() Option {
+	return func(c *config) {
+		c.controllerManager = true
+	}
+}
+
+// WithControllers runs kube-controller-manager like WithControllerManager with only the given controllers
+// via --controllers, e.g. "namespace", "garbagecollector" and "serviceaccount", or "*" and "-name" to enable all
+// but some. Calling it multiple times appends the names.
+func WithControllers(names ...string) Option {
+	return func(c *config) {
+		c.controllerManager = true
+		c.controllers = append(c.controllers, names...)
+	}
+}
+
+// WithScheduler runs kube-scheduler in the container next to the API server, so Pods get bound to Nodes and their
+// spec.nodeName set. Run waits for it to be healthy. There is no kubelet, so Nodes have to be created by the test
+// with a Ready condition and allocatable resources, and bound Pods never start running.
+func WithScheduler() Option {
+	return func(c *config) {
+		c.scheduler = true
+	}
+}
+
+// WithKWOK runs kwok in the container next to the API server, simulating the kubelet of the Nodes annotated
+// with kwok.x-k8s.io/node=fake (see CreateSimulatedNodes): they report Ready, and Pods bound to them go Running
+// without running any container. Run waits for it to be healthy. Pods are only bound with WithScheduler,
+// or when created with spec.nodeName set.
+func WithKWOK() Option {
+	return func(c *config) {
+		c.kwok = true
+	}
+}
+
+// WithMaxRequestsInflight limits the non-mutating requests the API server serves at a time via --max-requests-inflight.
+// With API Priority and Fairness, enabled by default, the limits of WithMaxRequestsInflight and
+// WithMaxMutatingRequestsInflight are the total shared by the priority levels, requests beyond are queued,
+// and system:masters requests, like the ones of RESTConfig, are exempt. To have excess requests rejected with 429
+// right away, disable it with WithAPIPriorityAndFairness(false).
+func WithMaxRequestsInflight(n int) Option {
+	return func(c *config) {
+		c.maxInflight = &n
+	}
+}
+
+// WithMaxMutatingRequestsInflight limits the mutating requests the API server serves at a time
+// via --max-mutating-requests-inflight, see WithMaxRequestsInflight
+func WithMaxMutatingRequestsInflight(n int) Option {
+	return func(c *config) {
+		c.maxMutating = &n
+	}
+}
+
+// WithAPIPriorityAndFairness enables or disables API Priority and Fairness via --enable-priority-and-fairness
+// (default: enabled). FlowSchemas and PriorityLevelConfigurations of WithManifests are applied before Run returns,
+// so they are in place before the test traffic, though the API server takes them into account asynchronously.
+func WithAPIPriorityAndFairness(enabled bool) Option {
+	return func(c *config) {
+		c.priorityAndFairness = &enabled
+	}
+}
+
+// WithProfiling enables or disables the pprof endpoints of the API server via --profiling (default: enabled),
+// see GetAPIServerProfile
+func WithProfiling(enabled bool) Option {
+	return func(c *config) {
+		c.profiling = &enabled
+	}
+}
+
+// WithWatchCache enables or disables the watch cache of the API server via --watch-cache (default: enabled).
+// Disabled, lists and watches are served from etcd, like on a cold cache.
+func WithWatchCache(enabled bool) Option {
+	return func(c *config) {
+		c.watchCache = &enabled
+	}
+}
+
+// WithDefaultWatchCacheSize sets the watch cache capacity of resources via --default-watch-cache-size,
+// 0 disabling the cache of the resources without an explicit size. Small sizes make watches resuming
+// from an older resource version fail with 410 Gone sooner, so clients have to re-list.
+func WithDefaultWatchCacheSize(n int) Option {
+	return func(c *config) {
+		c.watchCacheSize = &n
+	}
+}
+
+// WithRequestTimeout sets how long the API server handles a request before timing it out via --request-timeout
+// (default: 1m). Watches and other long-running requests are not affected.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.requestTimeout = &d
+	}
+}
+
+// WithAPIServerVerbosity sets the log verbosity of the API server via --v, from 0 (default) to 10.
+// Combine it with WithLogConsumers to see the logs, e.g. request traces from level 3 on.
+func WithAPIServerVerbosity(level int) Option {
+	return func(c *config) {
+		c.verbosity = &level
+	}
+}
+
+// WithLogConsumers streams the container logs, including the ones of the API server, to the consumers,
+// e.g. TestLogConsumer(t)
+func WithLogConsumers(consumers ...testcontainers.LogConsumer) Option {
+	return func(c *config) {
+		c.logConsumers = append(c.logConsumers, consumers...)
+	}
+}
+
+// WithNetwork attaches the container to the given user-defined Docker network (instead of the default bridge)
+// under the given aliases, so other containers on it can reach the API server.
+// The network has to exist, e.g. created with testcontainers network.New. It enables DisconnectNetwork.
+func WithNetwork(name string, aliases ...string) Option {
+	return func(c *config) {
+		c.network = name
+		c.networkAliases = aliases
+	}
+}
+
+// WithClusterName names the cluster and user entries of the kubeconfig returned by Kubeconfig and
+// GetKubeconfigObject, e.g. to merge it into ~/.kube/config without collisions.
+// The context is named the same unless WithContextName is given.
+func WithClusterName(name string) Option {
+	return func(c *config) {
+		c.clusterName = name
+	}
+}
+
+// WithContextName names the context of the kubeconfig returned by Kubeconfig and GetKubeconfigObject
+func WithContextName(name string) Option {
+	return func(c *config) {
+		c.contextName = name
+	}
+}
+
+// WithCertSANs adds hostnames or IPs to the API server serving certificate, generated at container startup,
+// e.g. the name the API server is reached under from a CI job container. Network aliases are added automatically.
+func WithCertSANs(sans ...string) Option {
+	return func(c *config) {
+		c.certSANs = append(c.certSANs, sans...)
+	}
+}
+
+// WithCRDs installs the CustomResourceDefinitions in the given files and directories once the API server is ready,
+// like CRDDirectoryPaths of controller-runtime envtest. Run returns once all of them are established.
+// Multi-document files are supported; directories are read non-recursively.
+func WithCRDs(paths ...string) Option {
+	return func(c *config) {
+		c.crdPaths = append(c.crdPaths, paths...)
+	}
+}
+
+// WithCRDsFromURL downloads the CustomResourceDefinition manifests at the given URLs, e.g. the CRDs
+// of a cert-manager release, and installs them like WithCRDs. Downloads are cached under os.UserCacheDir
+// and revalidated by ETag, so runs without network access succeed once the cache is warm.
+func WithCRDsFromURL(urls ...string) Option {
+	return func(c *config) {
+		c.crdURLs = append(c.crdURLs, urls...)
+	}
+}
+
+// WithCRDConversionWebhook points the webhook conversion of the CRDs installed by WithCRDs and WithCRDsFromURL
+// to a conversion webhook server on the test host. It enables WithHostAccess.
+func WithCRDConversionWebhook(webhook CRDConversionWebhook) Option {
+	return func(c *config) {
+		c.crdConversionWebhook = &webhook
+		c.hostAccess = true
+	}
+}
+
+// WithWebhooks installs the ValidatingWebhookConfigurations and MutatingWebhookConfigurations in the given files
+// and directories once the API server is ready, like WebhookInstallOptions of controller-runtime envtest.
+// The webhooks are pointed at a webhook server on the test host, whose address and serving certificate
+// are returned by WebhookOptions. It enables WithHostAccess unless WithWebhookTarget is given.
+func WithWebhooks(paths ...string) Option {
+	return func(c *config) {
+		c.webhookPaths = append(c.webhookPaths, paths...)
+	}
+}
+
+// WithWebhookTarget points the webhooks of WithWebhooks at a webhook server running in a sibling container
+// under the given network alias and port, instead of the test host. It requires WithNetwork.
+// The serving certificate in WebhookOptions().LocalServingCertDir is issued for the alias, for mounting into that container.
+func WithWebhookTarget(alias string, port int) Option {
+	return func(c *config) {
+		c.webhookTarget = alias
+		c.webhookPort = port
+	}
+}
+
+// WithManifests server-side applies the manifests in the given files and directories once the API server is ready,
+// after the CRDs of WithCRDs. Directories are read like for WithCRDs. Objects are applied like by Apply,
+// Namespaces and CRDs first, and Run fails with the file and document of the object that could not be applied.
+func WithManifests(paths ...string) Option {
+	return func(c *config) {
+		c.manifestPaths = append(c.manifestPaths, paths...)
+	}
+}
+
+// WithManifestsFS applies the .yaml, .yml and .json manifests in a directory of fsys like WithManifests,
+// e.g. fixtures embedded with go:embed
+func WithManifestsFS(fsys fs.FS, dir string) Option {
+	return func(c *config) {
+		c.manifestDirs = append(c.manifestDirs, manifestFS{fsys: fsys, dir: dir})
+	}
+}
+
+// WithKustomize renders the kustomization in dir in-process, like `kustomize build`, and applies the output
+// like WithManifests, so CRDs and RBAC kept as kustomize overlays under config/ can be installed as is
+func WithKustomize(dir string) Option {
+	return func(c *config) {
+		c.kustomizeDirs = append(c.kustomizeDirs, dir)
+	}
+}
+
+// hostAccessEnabled reports whether the container has to reach the test host
+func (c *config) hostAccessEnabled() bool {
+	return c.hostAccess || (len(c.webhookPaths) > 0 && c.webhookTarget == "")
+}
+
+// extraCertSANs returns the SANs added to the API server certificate besides localhost
+func (c *config) extraCertSANs() []string {
+	var sans []string
+
+	for _, san := range slices.Concat(c.networkAliases, c.certSANs) {
+		if !slices.Contains(sans, san) {
+			sans = append(sans, san)
+		}
+	}
+
+	return sans
+}
+
+// checkAPIServerFlags rejects extra kube-apiserver flags that conflict with each other or with managed ones
+func (c *config) checkAPIServerFlags() error {
+	if err := c.checkAdmissionPlugins(); err != nil {
+		return err
+	}
+
+	if err := c.checkControllers(); err != nil {
+		return err
+	}
+
+	if err := c.checkServiceRanges(); err != nil {
+		return err
+	}
+
+	if c.oidc != nil {
+		if err := c.oidc.check(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.checkAuthentication(); err != nil {
+		return err
+	}
+
+	if err := c.checkServiceAccounts(); err != nil {
+		return err
+	}
+
+	if c.tracing != nil {
+		if err := c.tracing.check(); err != nil {
+			return err
+		}
+
+		if err := c.requireKubernetesVersion("WithTracing", tracingMinVersion); err != nil {
+			return err
+		}
+	}
+
+	if err := c.checkEtcd(); err != nil {
+		return err
+	}
+
+	if err := c.checkReuse(); err != nil {
+		return err
+	}
+
+	if err := c.checkTypedFlags(); err != nil {
+		return err
+	}
+
+	for _, mode := range c.authzModes {
+		if !slices.Contains(authorizationModes, mode) {
+			return fmt.Errorf(
+				"unknown authorization mode %q, expected one of %s",
+				mode,
+				strings.Join(authorizationModes, ", "),
+			)
+		}
+	}
+
+	if c.authzWebhook != nil && !slices.Contains(c.authorizationModes(), "Webhook") {
+		return errors.New("WithAuthorizationWebhook requires the Webhook authorization mode")
+	}
+
+	if _, err := c.mergedSwitches(runtimeConfigFlag, c.runtimeConfig); err != nil {
+		return err
+	}
+
+	if _, err := c.mergedSwitches(featureGatesFlag, c.featureGates); err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(c.apiServerFlags))
+
+	for _, flag := range c.apiServerFlags {
+		// Runtime config and feature gates are merged instead
+		if flag.name == runtimeConfigFlag.name || flag.name == featureGatesFlag.name {
+			continue
+		}
+
+		if owner, ok := managedAPIServerFlags[flag.name]; ok {
+			return fmt.Errorf("kube-apiserver flag --%s is set by %s", flag.name, owner)
+		}
+
+		if value, ok := values[flag.name]; ok && value != flag.value {
+			return fmt.Errorf(
+				"conflicting values for kube-apiserver flag --%s: %q and %q",
+				flag.name,
+				value,
+				flag.value,
+			)
+		}
+
+		values[flag.name] = flag.value
+	}
+
+	return nil
+}
+
+// checkEtcd validates the etcd options, rejecting extra etcd flags set by the container or another option
+func (c *config) checkEtcd() error {
+	if c.etcdInMemory && c.etcdDataVolume != "" {
+		return errors.New(
+			"WithEtcdInMemory and WithEtcdDataVolume both mount the etcd data dir, use one of them",
+		)
+	}
+
+	if c.etcdQuotaBytes < 0 {
+		return fmt.Errorf("etcd quota must not be negative, got %d", c.etcdQuotaBytes)
+	}
+
+	values := make(map[string]string, len(c.etcdFlags))
+
+	for _, flag := range c.etcdFlags {
+		if owner := c.etcdFlagOwner(flag.name); owner != "" {
+			return fmt.Errorf("etcd flag --%s is set by %s", flag.name, owner)
+		}
+
+		// The entrypoint reads the flags one per line
+		if strings.ContainsAny(flag.name+flag.value, "\n\r") {
+			return fmt.Errorf("etcd flag --%s must not contain line breaks", flag.name)
+		}
+
+		if value, ok := values[flag.name]; ok && value != flag.value {
+			return fmt.Errorf(
+				"conflicting values for etcd flag --%s: %q and %q",
+				flag.name,
+				value,
+				flag.value,
+			)
+		}
+
+		values[flag.name] = flag.value
+	}
+
+	return nil
+}
+
+// etcdFlagOwner returns what sets the etcd flag, the container or an option in use, or an empty string
+func (c *config) etcdFlagOwner(name string) string {
+	if owner, ok := managedEtcdFlags[name]; ok {
+		return owner
+	}
+
+	switch {
+	case name == "unsafe-no-fsync" && c.etcdInMemory:
+		return "WithEtcdInMemory"
+	case name == "quota-backend-bytes" && c.etcdQuotaBytes > 0:
+		return "WithEtcdQuotaBytes"
+	default:
+		return ""
+	}
+}
+
+// etcdArgs returns the extra etcd flags of WithEtcdFlags, given once each
+func (c *config) etcdArgs() []string {
+	var args []string
+
+	for _, flag := range c.etcdFlags {
+		arg := "--" + flag.name + "=" + flag.value
+		if !slices.Contains(args, arg) {
+			args = append(args, arg)
+		}
+	}
+
+	return args
+}
+
+// checkAdmissionPlugins rejects malformed admission plugin names, and plugins both enabled and disabled
+func (c *config) checkAdmissionPlugins() error {
+	for _, name := range slices.Concat(c.enablePlugins, c.disablePlugins) {
+		if name == "" ||
+			strings.ContainsFunc(
+				name,
+				func(r rune) bool { return r == ',' || unicode.IsSpace(r) },
+			) {
+			return fmt.Errorf("invalid admission plugin name %q", name)
+		}
+	}
+
+	for _, name := range c.enablePlugins {
+		if slices.Contains(c.disablePlugins, name) {
+			return fmt.Errorf("admission plugin %s is both enabled and disabled", name)
+		}
+	}
+
+	return nil
+}
+
+// requireKubernetesVersion rejects an option on Kubernetes versions before minVersion
+func (c *config) requireKubernetesVersion(option, minVersion string) error {
+	current, err := utilversion.ParseGeneric(c.kubernetesVersion)
+	if err != nil {
+		return fmt.Errorf("invalid Kubernetes version %q: %w", c.kubernetesVersion, err)
+	}
+
+	if current.LessThan(utilversion.MustParseGeneric(minVersion)) {
+		return fmt.Errorf(
+			"%s requires Kubernetes %s or later, got %s",
+			option,
+			minVersion,
+			c.kubernetesVersion,
+		)
+	}
+
+	return nil
+}
+
+// typedFlags returns the flags set by typed options, which can also be given to WithAPIServerFlags with the same value
+func (c *config) typedFlags() []apiServerFlag {
+	var flags []apiServerFlag
+
+	if c.verbosity != nil {
+		flags = append(flags, apiServerFlag{name: verbosityFlag, value: strconv.Itoa(*c.verbosity)})
+	}
+
+	if c.maxInflight != nil {
+		flags = append(
+			flags,
+			apiServerFlag{name: "max-requests-inflight", value: strconv.Itoa(*c.maxInflight)},
+		)
+	}
+
+	if c.maxMutating != nil {
+		flags = append(
+			flags,
+			apiServerFlag{
+				name:  "max-mutating-requests-inflight",
+				value: strconv.Itoa(*c.maxMutating),
+			},
+		)
+	}
+
+	if c.requestTimeout != nil {
+		flags = append(
+			flags,
+			apiServerFlag{name: "request-timeout", value: c.requestTimeout.String()},
+		)
+	}
+
+	if c.priorityAndFairness != nil {
+		flags = append(
+			flags,
+			apiServerFlag{
+				name:  "enable-priority-and-fairness",
+				value: strconv.FormatBool(*c.priorityAndFairness),
+			},
+		)
+	}
+
+	if c.profiling != nil {
+		flags = append(
+			flags,
+			apiServerFlag{name: "profiling", value: strconv.FormatBool(*c.profiling)},
+		)
+	}
+
+	if c.anonymousAuth != nil {
+		flags = append(
+			flags,
+			apiServerFlag{name: "anonymous-auth", value: strconv.FormatBool(*c.anonymousAuth)},
+		)
+	}
+
+	if c.watchCache != nil {
+		flags = append(
+			flags,
+			apiServerFlag{name: "watch-cache", value: strconv.FormatBool(*c.watchCache)},
+		)
+	}
+
+	if c.watchCacheSize != nil {
+		flags = append(
+			flags,
+			apiServerFlag{name: "default-watch-cache-size", value: strconv.Itoa(*c.watchCacheSize)},
+		)
+	}
+
+	return flags
+}
+
+// checkTypedFlags validates the values of typed options, and rejects extra flags with other values
+func (c *config) checkTypedFlags() error {
+	if c.verbosity != nil && (*c.verbosity < 0 || *c.verbosity > maxVerbosity) {
+		return fmt.Errorf(
+			"kube-apiserver verbosity %d is out of range 0-%d",
+			*c.verbosity,
+			maxVerbosity,
+		)
+	}
+
+	if c.maxInflight != nil && *c.maxInflight <= 0 {
+		return fmt.Errorf("max requests inflight must be positive, got %d", *c.maxInflight)
+	}
+
+	if c.maxMutating != nil && *c.maxMutating <= 0 {
+		return fmt.Errorf("max mutating requests inflight must be positive, got %d", *c.maxMutating)
+	}
+
+	if c.requestTimeout != nil && *c.requestTimeout <= 0 {
+		return fmt.Errorf("request timeout must be positive, got %s", c.requestTimeout)
+	}
+
+	if c.watchCacheSize != nil && *c.watchCacheSize < 0 {
+		return fmt.Errorf(
+			"default watch cache size must not be negative, got %d",
+			*c.watchCacheSize,
+		)
+	}
+
+	if c.watchCacheSize != nil && c.watchCache != nil && !*c.watchCache {
+		return errors.New("WithDefaultWatchCacheSize has no effect with WithWatchCache(false)")
+	}
+
+	for _, typed := range c.typedFlags() {
+		for _, flag := range c.apiServerFlags {
+			if flag.name == typed.name && flag.value != typed.value {
+				return fmt.Errorf(
+					"conflicting values for kube-apiserver flag --%s: %q and %q",
+					typed.name,
+					typed.value,
+					flag.value,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkServiceRanges validates the Service ranges before the container starts, as kube-apiserver
+// would fail to start with them. Dual-stack CIDRs have to be of different IP families.
+func (c *config) checkServiceRanges() error {
+	if c.serviceCIDR != "" {
+		cidrs := strings.Split(c.serviceCIDR, ",")
+		if len(cidrs) > 2 {
+			return fmt.Errorf(
+				"invalid service cluster IP range %q: expected at most two CIDRs",
+				c.serviceCIDR,
+			)
+		}
+
+		var families []bool
+
+		for _, cidr := range cidrs {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+			if err != nil {
+				return fmt.Errorf("invalid service cluster IP range %q: %w", c.serviceCIDR, err)
+			}
+
+			if slices.Contains(families, prefix.Addr().Is4()) {
+				return fmt.Errorf(
+					"invalid service cluster IP range %q: dual-stack CIDRs must be IPv4 and IPv6",
+					c.serviceCIDR,
+				)
+			}
+
+			families = append(families, prefix.Addr().Is4())
+		}
+	}
+
+	if c.nodePortRange != "" {
+		if _, err := utilnet.ParsePortRange(c.nodePortRange); err != nil {
+			return fmt.Errorf("invalid service node port range: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// containerDisabledAdmissionPlugins returns the plugins the container disables by default that are not enabled,
+// and whether that differs from the container default
+func (c *config) containerDisabledAdmissionPlugins() ([]string, bool) {
+	plugins := slices.DeleteFunc(
+		slices.Clone(defaultDisabledAdmissionPlugins),
+		func(name string) bool {
+			return slices.Contains(c.enablePlugins, name)
+		},
+	)
+
+	return plugins, len(plugins) != len(defaultDisabledAdmissionPlugins)
+}
+
+// mergedSwitches merges the switches of an option with the ones given to WithAPIServerFlags for the same flag
+func (c *config) mergedSwitches(flag switchFlag, base map[string]bool) (map[string]bool, error) {
+	switches := make(map[string]bool, len(base))
+
+	for name, enabled := range base {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%s names must not be empty", flag.kind)
+		}
+
+		switches[name] = enabled
+	}
+
+	for _, extra := range c.apiServerFlags {
+		if extra.name != flag.name {
+			continue
+		}
+
+		for entry := range strings.SplitSeq(extra.value, ",") {
+			name, value, hasValue := strings.Cut(strings.TrimSpace(entry), "=")
+
+			enabled := true
+
+			var err error
+			if hasValue || !flag.bareEnabled {
+				enabled, err = strconv.ParseBool(value)
+			}
+
+			if name == "" || err != nil {
+				return nil, fmt.Errorf(
+					"invalid --%s entry %q, expected %s",
+					flag.name,
+					entry,
+					flag.format,
+				)
+			}
+
+			if previous, ok := switches[name]; ok && previous != enabled {
+				return nil, fmt.Errorf(
+					"conflicting values for %s %s: %t and %t",
+					flag.kind,
+					name,
+					previous,
+					enabled,
+				)
+			}
+
+			switches[name] = enabled
+		}
+	}
+
+	return switches, nil
+}
+
+// switchesArg renders merged switches as a flag with the entries in name order, or nothing if there are none
+func switchesArg(flag switchFlag, switches map[string]bool) []string {
+	if len(switches) == 0 {
+		return nil
+	}
+
+	entries := make([]string, 0, len(switches))
+
+	for _, name := range slices.Sorted(maps.Keys(switches)) {
+		entries = append(entries, name+"="+strconv.FormatBool(switches[name]))
+	}
+
+	return []string{"--" + flag.name + "=" + strings.Join(entries, ",")}
+}
+
+// apiServerArgs renders the extra kube-apiserver flags passed to the container entrypoint.
+// Invalid flags are rejected by checkAPIServerFlags beforehand.
+func (c *config) apiServerArgs() []string {
+	runtimeConfig, _ := c.mergedSwitches(runtimeConfigFlag, c.runtimeConfig)
+	args := switchesArg(runtimeConfigFlag, runtimeConfig)
+
+	if c.auditPolicy != nil {
+		args = append(
+			args,
+			"--audit-policy-file="+AuditPolicyPath,
+			"--audit-log-path="+AuditLogPath,
+		)
+	}
+
+	if c.auditWebhook {
+		args = append(args,
+			"--audit-webhook-config-file="+AuditWebhookConfigPath,
+			"--audit-webhook-mode=batch",
+			"--audit-webhook-version=audit.k8s.io/v1",
+			"--audit-webhook-batch-max-wait="+auditWebhookBatchMaxWait.String(),
+		)
+	}
+
+	if c.encryptionConfig != nil {
+		args = append(args, "--encryption-provider-config="+EncryptionConfigPath)
+	}
+
+	if len(c.tokenUsers) > 0 {
+		args = append(args, "--token-auth-file="+TokenAuthFilePath)
+	}
+
+	if c.oidc != nil {
+		args = append(args, c.oidc.apiServerArgs()...)
+	}
+
+	if c.authnConfig != nil {
+		args = append(args, "--authentication-config="+AuthenticationConfigPath)
+	}
+
+	if c.authnWebhook != nil {
+		args = append(args,
+			"--authentication-token-webhook-config-file="+AuthenticationWebhookConfigPath,
+			"--authentication-token-webhook-version=v1",
+		)
+	}
+
+	if c.authzWebhook != nil {
+		args = append(args,
+			"--authorization-webhook-config-file="+AuthorizationWebhookConfigPath,
+			"--authorization-webhook-version=v1",
+		)
+	}
+
+	if c.tracing != nil {
+		args = append(args, "--tracing-config-file="+TracingConfigPath)
+	}
+
+	if len(c.apiAudiences) > 0 {
+		args = append(args, "--api-audiences="+strings.Join(c.apiAudiences, ","))
+	}
+
+	if c.shutdownDelay > 0 {
+		args = append(args, "--shutdown-delay-duration="+c.shutdownDelay.String())
+	}
+
+	featureGates, _ := c.mergedSwitches(featureGatesFlag, c.featureGates)
+	args = append(args, switchesArg(featureGatesFlag, featureGates)...)
+
+	if len(c.enablePlugins) > 0 {
+		args = append(
+			args,
+			"--enable-admission-plugins="+strings.Join(
+				slices.Compact(slices.Clone(c.enablePlugins)),
+				",",
+			),
+		)
+	}
+
+	// Added to the plugins disabled by the container
+	if len(c.disablePlugins) > 0 {
+		args = append(
+			args,
+			"--disable-admission-plugins="+strings.Join(
+				slices.Compact(slices.Clone(c.disablePlugins)),
+				",",
+			),
+		)
+	}
+
+	// Overrides the range set by the container
+	if c.serviceCIDR != "" {
+		args = append(args, "--service-cluster-ip-range="+c.serviceCIDR)
+	}
+
+	if c.nodePortRange != "" {
+		args = append(args, "--service-node-port-range="+c.nodePortRange)
+	}
+
+	seen := map[string]bool{runtimeConfigFlag.name: true, featureGatesFlag.name: true}
+
+	for _, flag := range slices.Concat(c.typedFlags(), c.apiServerFlags) {
+		if !seen[flag.name] {
+			seen[flag.name] = true
+			args = append(args, "--"+flag.name+"="+flag.value)
+		}
+	}
+
+	return args
+}
@@ -1,8 +1,12 @@
 package envtest
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestWithImage(t *testing.T) {
@@ -38,3 +42,576 @@ func TestDefaultConfig(t *testing.T) {
 	require.Equal(t, DefaultImage, cfg.image)
 	require.Equal(t, DefaultKubernetesVersion, cfg.kubernetesVersion)
 }
+
+func TestWithRuntimeConfig(t *testing.T) {
+	cfg := &config{}
+
+	WithRuntimeConfig(map[string]bool{"networking.k8s.io/v1beta1": true})(cfg)
+	WithRuntimeConfig(map[string]bool{"batch/v1": false})(cfg)
+
+	require.Equal(t, map[string]bool{"networking.k8s.io/v1beta1": true, "batch/v1": false}, cfg.runtimeConfig)
+	require.Equal(t, []string{"--runtime-config=batch/v1=false,networking.k8s.io/v1beta1=true"}, cfg.apiServerArgs())
+}
+
+func TestAPIServerArgsDefault(t *testing.T) {
+	cfg := &config{}
+
+	require.Empty(t, cfg.apiServerArgs())
+}
+
+func TestWithHostAccess(t *testing.T) {
+	cfg := &config{}
+	require.False(t, cfg.hostAccess)
+
+	WithHostAccess()(cfg)
+
+	require.True(t, cfg.hostAccess)
+	require.Empty(t, cfg.hostAccessPorts)
+
+	WithHostAccess(8443)(cfg)
+	WithHostAccess(9443, 9444)(cfg)
+
+	require.Equal(t, []int{8443, 9443, 9444}, cfg.hostAccessPorts)
+}
+
+func TestWithAuditPolicy(t *testing.T) {
+	cfg := &config{}
+
+	WithAuditPolicy([]byte("apiVersion: audit.k8s.io/v1\nkind: Policy\n"))(cfg)
+
+	require.Equal(t, []string{
+		"--audit-policy-file=" + AuditPolicyPath,
+		"--audit-log-path=" + AuditLogPath,
+	}, cfg.apiServerArgs())
+}
+
+func TestWithNoRetries(t *testing.T) {
+	cfg := &config{}
+
+	WithNoRetries()(cfg)
+
+	require.True(t, cfg.noRetries)
+}
+
+func TestWithShutdownDelay(t *testing.T) {
+	cfg := &config{}
+
+	WithShutdownDelay(10 * time.Second)(cfg)
+
+	require.Equal(t, []string{"--shutdown-delay-duration=10s"}, cfg.apiServerArgs())
+}
+
+func TestWithNetwork(t *testing.T) {
+	cfg := &config{}
+
+	WithNetwork("envtest-net", "apiserver", "kube")(cfg)
+
+	require.Equal(t, "envtest-net", cfg.network)
+	require.Equal(t, []string{"apiserver", "kube"}, cfg.networkAliases)
+}
+
+func TestWithClusterAndContextName(t *testing.T) {
+	cfg := &config{}
+
+	WithClusterName("envtest-debug")(cfg)
+	WithContextName("debug")(cfg)
+
+	require.Equal(t, "envtest-debug", cfg.clusterName)
+	require.Equal(t, "debug", cfg.contextName)
+}
+
+func TestWithCertSANs(t *testing.T) {
+	cfg := &config{}
+
+	require.Empty(t, cfg.extraCertSANs())
+
+	WithNetwork("ci", "apiserver", "envtest")(cfg)
+	WithCertSANs("envtest", "10.0.0.5")(cfg)
+	WithCertSANs("envtest.ci.internal")(cfg)
+
+	require.Equal(t, []string{"apiserver", "envtest", "10.0.0.5", "envtest.ci.internal"}, cfg.extraCertSANs())
+}
+
+func TestWithCRDs(t *testing.T) {
+	cfg := &config{}
+
+	WithCRDs("config/crd/bases")(cfg)
+	WithCRDs("testdata/crds/widgets.yaml", "testdata/extra")(cfg)
+
+	require.Equal(t, []string{"config/crd/bases", "testdata/crds/widgets.yaml", "testdata/extra"}, cfg.crdPaths)
+}
+
+func TestWithCRDsFromURL(t *testing.T) {
+	cfg := &config{}
+
+	WithCRDsFromURL("https://example.com/a.yaml")(cfg)
+	WithCRDsFromURL("https://example.com/b.yaml", "https://example.com/c.yaml")(cfg)
+
+	require.Equal(t, []string{"https://example.com/a.yaml", "https://example.com/b.yaml", "https://example.com/c.yaml"}, cfg.crdURLs)
+}
+
+func TestWithCRDConversionWebhook(t *testing.T) {
+	cfg := &config{}
+
+	WithCRDConversionWebhook(CRDConversionWebhook{Port: 9443, CABundle: []byte("ca")})(cfg)
+
+	require.Equal(t, &CRDConversionWebhook{Port: 9443, CABundle: []byte("ca")}, cfg.crdConversionWebhook)
+	require.True(t, cfg.hostAccess)
+}
+
+func TestWithWebhooks(t *testing.T) {
+	cfg := &config{}
+
+	WithWebhooks("config/webhook")(cfg)
+	WithWebhooks("testdata/webhooks/manifests.yaml")(cfg)
+
+	require.Equal(t, []string{"config/webhook", "testdata/webhooks/manifests.yaml"}, cfg.webhookPaths)
+	require.True(t, cfg.hostAccessEnabled())
+
+	// Webhooks served by a sibling container do not need the host
+	WithWebhookTarget("webhook", 8443)(cfg)
+
+	require.Equal(t, "webhook", cfg.webhookTarget)
+	require.Equal(t, 8443, cfg.webhookPort)
+	require.False(t, cfg.hostAccessEnabled())
+
+	WithHostAccess()(cfg)
+	require.True(t, cfg.hostAccessEnabled())
+}
+
+func TestWithManifests(t *testing.T) {
+	cfg := &config{}
+	fsys := fstest.MapFS{}
+
+	WithManifests("testdata/manifests")(cfg)
+	WithManifests("config/samples/app.yaml")(cfg)
+	WithManifestsFS(fsys, "fixtures")(cfg)
+
+	require.Equal(t, []string{"testdata/manifests", "config/samples/app.yaml"}, cfg.manifestPaths)
+	require.Len(t, cfg.manifestDirs, 1)
+	require.Equal(t, "fixtures", cfg.manifestDirs[0].dir)
+}
+
+func TestWithKustomize(t *testing.T) {
+	cfg := &config{}
+
+	WithKustomize("config/default")(cfg)
+	WithKustomize("config/rbac")(cfg)
+
+	require.Equal(t, []string{"config/default", "config/rbac"}, cfg.kustomizeDirs)
+}
+
+func TestWithAPIServerFlags(t *testing.T) {
+	cfg := &config{}
+
+	WithShutdownDelay(10 * time.Second)(cfg)
+	WithAPIServerFlags(map[string]string{"max-requests-inflight": "800", "--event-ttl": "5m"})(cfg)
+	WithAPIServerArg("--v", "2")(cfg)
+	WithAPIServerArg("max-requests-inflight", "800")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--shutdown-delay-duration=10s",
+		"--event-ttl=5m",
+		"--max-requests-inflight=800",
+		"--v=2",
+	}, cfg.apiServerArgs())
+
+	WithAPIServerArg("max-requests-inflight", "400")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		`conflicting values for kube-apiserver flag --max-requests-inflight: "800" and "400"`)
+
+	cfg = &config{}
+	WithAPIServerArg("--audit-log-path", "/tmp/audit.log")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "kube-apiserver flag --audit-log-path is set by WithAuditPolicy")
+
+	cfg = &config{}
+	WithAPIServerFlags(map[string]string{"secure-port": "7443"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "kube-apiserver flag --secure-port is set by the container")
+}
+
+func TestWithFeatureGates(t *testing.T) {
+	cfg := &config{}
+
+	WithFeatureGates(map[string]bool{"WatchList": true})(cfg)
+	WithFeatureGates(map[string]bool{"MutatingAdmissionPolicy": true, "AnonymousAuthConfigurableEndpoints": false})(cfg)
+	WithAPIServerFlags(map[string]string{"feature-gates": "WatchList=true, StorageVersionAPI=true"})(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--feature-gates=AnonymousAuthConfigurableEndpoints=false,MutatingAdmissionPolicy=true,StorageVersionAPI=true,WatchList=true",
+	}, cfg.apiServerArgs())
+
+	WithAPIServerArg("--feature-gates", "WatchList=false")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "conflicting values for feature gate WatchList: true and false")
+
+	cfg = &config{}
+	WithFeatureGates(map[string]bool{"": true})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "feature gate names must not be empty")
+
+	cfg = &config{}
+	WithAPIServerArg("feature-gates", "WatchList")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), `invalid --feature-gates entry "WatchList", expected Name=true|false`)
+}
+
+func TestWithRuntimeConfigMergesFlags(t *testing.T) {
+	cfg := &config{}
+
+	WithRuntimeConfig(map[string]bool{"batch/v1": false})(cfg)
+	WithAPIServerArg("runtime-config", "resource.k8s.io/v1alpha3,networking.k8s.io/v1beta1=true")(cfg)
+	WithAPIServerArg("runtime-config", "batch/v1=false")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--runtime-config=batch/v1=false,networking.k8s.io/v1beta1=true,resource.k8s.io/v1alpha3=true",
+	}, cfg.apiServerArgs())
+
+	WithAPIServerArg("runtime-config", "batch/v1")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "conflicting values for runtime config API batch/v1: false and true")
+
+	cfg = &config{}
+	WithRuntimeConfig(map[string]bool{" ": true})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "runtime config API names must not be empty")
+
+	cfg = &config{}
+	WithAPIServerArg("runtime-config", "batch/v1=maybe")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), `invalid --runtime-config entry "batch/v1=maybe", expected API[=true|false]`)
+}
+
+func TestWithAdmissionPlugins(t *testing.T) {
+	cfg := &config{}
+
+	plugins, changed := cfg.containerDisabledAdmissionPlugins()
+	require.False(t, changed)
+	require.Equal(t, []string{"ServiceAccount"}, plugins)
+
+	WithEnableAdmissionPlugins("PodSecurity", "ServiceAccount")(cfg)
+	WithDisableAdmissionPlugins("NamespaceLifecycle")(cfg)
+	WithDisableAdmissionPlugins("DefaultStorageClass")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--enable-admission-plugins=PodSecurity,ServiceAccount",
+		"--disable-admission-plugins=NamespaceLifecycle,DefaultStorageClass",
+	}, cfg.apiServerArgs())
+
+	// Enabling ServiceAccount overrides the container default
+	plugins, changed = cfg.containerDisabledAdmissionPlugins()
+	require.True(t, changed)
+	require.Empty(t, plugins)
+
+	WithDisableAdmissionPlugins("PodSecurity")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "admission plugin PodSecurity is both enabled and disabled")
+
+	for _, name := range []string{"", "Pod Security", "PodSecurity,ServiceAccount"} {
+		cfg = &config{}
+		WithEnableAdmissionPlugins(name)(cfg)
+		require.EqualError(t, cfg.checkAPIServerFlags(), fmt.Sprintf("invalid admission plugin name %q", name))
+	}
+
+	cfg = &config{}
+	WithAPIServerArg("enable-admission-plugins", "PodSecurity")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		"kube-apiserver flag --enable-admission-plugins is set by WithEnableAdmissionPlugins")
+}
+
+func TestWithAuthorizationModes(t *testing.T) {
+	cfg := &config{}
+
+	WithAuthorizationModes("Node", "RBAC")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"Node", "RBAC"}, cfg.authzModes)
+	require.Empty(t, cfg.apiServerArgs())
+
+	WithAuthorizationModes("rbac")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		`unknown authorization mode "rbac", expected one of AlwaysAllow, AlwaysDeny, ABAC, Webhook, RBAC, Node`)
+
+	cfg = &config{}
+	WithAPIServerArg("authorization-mode", "AlwaysAllow")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "kube-apiserver flag --authorization-mode is set by WithAuthorizationModes")
+}
+
+func TestWithAPIServerVerbosity(t *testing.T) {
+	cfg := &config{}
+
+	WithAPIServerVerbosity(5)(cfg)
+	WithAPIServerArg("v", "5")(cfg)
+	WithAPIServerArg("event-ttl", "5m")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--v=5", "--event-ttl=5m"}, cfg.apiServerArgs())
+
+	WithAPIServerArg("v", "2")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), `conflicting values for kube-apiserver flag --v: "5" and "2"`)
+
+	for _, level := range []int{-1, 11} {
+		cfg = &config{}
+		WithAPIServerVerbosity(level)(cfg)
+		require.EqualError(t, cfg.checkAPIServerFlags(), fmt.Sprintf("kube-apiserver verbosity %d is out of range 0-10", level))
+	}
+}
+
+func TestWithEncryptionConfig(t *testing.T) {
+	cfg := &config{}
+
+	WithEncryptionConfig([]byte("apiVersion: apiserver.config.k8s.io/v1\nkind: EncryptionConfiguration\n"))(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--encryption-provider-config=" + EncryptionConfigPath}, cfg.apiServerArgs())
+
+	WithAPIServerArg("encryption-provider-config", "/tmp/other.yaml")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		"kube-apiserver flag --encryption-provider-config is set by WithEncryptionConfig")
+}
+
+func TestWithServiceRanges(t *testing.T) {
+	cfg := &config{}
+
+	WithServiceClusterIPRange("10.96.0.0/16,fd00:10:96::/108")(cfg)
+	WithServiceNodePortRange("30000-30100")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--service-cluster-ip-range=10.96.0.0/16,fd00:10:96::/108",
+		"--service-node-port-range=30000-30100",
+	}, cfg.apiServerArgs())
+
+	for _, cidr := range []string{"10.96.0/16", "10.96.0.0", "10.96.0.0/16,10.97.0.0/16", "10.96.0.0/16,fd00::/108,fd01::/108"} {
+		cfg = &config{}
+		WithServiceClusterIPRange(cidr)(cfg)
+		require.ErrorContains(t, cfg.checkAPIServerFlags(), fmt.Sprintf("invalid service cluster IP range %q", cidr))
+	}
+
+	cfg = &config{}
+	WithServiceNodePortRange("30000:30100")(cfg)
+	require.ErrorContains(t, cfg.checkAPIServerFlags(), "invalid service node port range")
+
+	cfg = &config{}
+	WithAPIServerArg("service-cluster-ip-range", "10.96.0.0/16")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		"kube-apiserver flag --service-cluster-ip-range is set by WithServiceClusterIPRange")
+}
+
+func TestWithTokenAuth(t *testing.T) {
+	cfg := &config{}
+
+	WithTokenAuth([]TokenUser{{Token: "cli-token", Username: "cli"}})(cfg)
+	WithTokenAuth([]TokenUser{{Token: "bot-token", Username: "bot"}})(cfg)
+
+	require.Len(t, cfg.tokenUsers, 2)
+	require.Equal(t, []string{"--token-auth-file=" + TokenAuthFilePath}, cfg.apiServerArgs())
+}
+
+func TestWithRequestLimits(t *testing.T) {
+	cfg := &config{}
+
+	WithMaxRequestsInflight(1)(cfg)
+	WithMaxMutatingRequestsInflight(2)(cfg)
+	WithRequestTimeout(90 * time.Second)(cfg)
+	WithAPIPriorityAndFairness(false)(cfg)
+	WithAPIServerArg("request-timeout", "1m30s")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{
+		"--max-requests-inflight=1",
+		"--max-mutating-requests-inflight=2",
+		"--request-timeout=1m30s",
+		"--enable-priority-and-fairness=false",
+	}, cfg.apiServerArgs())
+
+	WithAPIServerArg("max-requests-inflight", "400")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), `conflicting values for kube-apiserver flag --max-requests-inflight: "1" and "400"`)
+
+	for _, tt := range []struct {
+		opt Option
+		err string
+	}{
+		{WithMaxRequestsInflight(0), "max requests inflight must be positive, got 0"},
+		{WithMaxMutatingRequestsInflight(-1), "max mutating requests inflight must be positive, got -1"},
+		{WithRequestTimeout(-time.Second), "request timeout must be positive, got -1s"},
+	} {
+		cfg = &config{}
+		tt.opt(cfg)
+		require.EqualError(t, cfg.checkAPIServerFlags(), tt.err)
+	}
+}
+
+func TestWithAnonymousAuth(t *testing.T) {
+	cfg := &config{}
+
+	WithAnonymousAuth(false)(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--anonymous-auth=false"}, cfg.apiServerArgs())
+}
+
+func TestWithWatchCache(t *testing.T) {
+	cfg := &config{}
+
+	WithWatchCache(true)(cfg)
+	WithDefaultWatchCacheSize(10)(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--watch-cache=true", "--default-watch-cache-size=10"}, cfg.apiServerArgs())
+
+	WithWatchCache(false)(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "WithDefaultWatchCacheSize has no effect with WithWatchCache(false)")
+
+	cfg = &config{}
+	WithDefaultWatchCacheSize(-1)(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "default watch cache size must not be negative, got -1")
+}
+
+func TestWithEtcdInMemory(t *testing.T) {
+	cfg := &config{}
+
+	WithEtcdInMemory()(cfg)
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Empty(t, cfg.apiServerArgs())
+
+	WithEtcdDataVolume("envtest-etcd")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		"WithEtcdInMemory and WithEtcdDataVolume both mount the etcd data dir, use one of them")
+}
+
+func TestWithEtcdQuotaBytes(t *testing.T) {
+	cfg := &config{}
+
+	WithEtcdQuotaBytes(64 << 20)(cfg)
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, int64(64<<20), cfg.etcdQuotaBytes)
+
+	WithEtcdQuotaBytes(-1)(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "etcd quota must not be negative, got -1")
+}
+
+func TestWithEtcdFlags(t *testing.T) {
+	cfg := &config{}
+
+	WithEtcdFlags(map[string]string{"max-request-bytes": "262144", "heartbeat-interval": "50"})(cfg)
+	WithEtcdFlags(map[string]string{"--max-request-bytes": "262144"})(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--heartbeat-interval=50", "--max-request-bytes=262144"}, cfg.etcdArgs())
+	require.Empty(t, cfg.apiServerArgs())
+
+	WithEtcdFlags(map[string]string{"max-request-bytes": "1048576"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		`conflicting values for etcd flag --max-request-bytes: "262144" and "1048576"`)
+
+	cfg = &config{}
+	WithEtcdFlags(map[string]string{"data-dir": "/var/lib/etcd"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "etcd flag --data-dir is set by the container")
+
+	cfg = &config{}
+	WithEtcdQuotaBytes(64 << 20)(cfg)
+	WithEtcdFlags(map[string]string{"quota-backend-bytes": "1024"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "etcd flag --quota-backend-bytes is set by WithEtcdQuotaBytes")
+
+	cfg = &config{}
+	WithEtcdFlags(map[string]string{"name": "default\n--data-dir=/"})(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), "etcd flag --name must not contain line breaks")
+}
+
+func TestWithControllerManager(t *testing.T) {
+	cfg := &config{}
+
+	WithControllerManager()(cfg)
+	require.True(t, cfg.controllerManager)
+	require.Empty(t, cfg.controllers)
+	require.NoError(t, cfg.checkAPIServerFlags())
+
+	cfg = &config{}
+	WithControllers("namespace", "garbagecollector")(cfg)
+	WithControllers("serviceaccount")(cfg)
+
+	require.True(t, cfg.controllerManager)
+	require.Equal(t, []string{"namespace", "garbagecollector", "serviceaccount"}, cfg.controllers)
+	require.NoError(t, cfg.checkAPIServerFlags())
+
+	WithControllers("namespace,garbagecollector")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), `invalid controller name "namespace,garbagecollector"`)
+}
+
+func TestWithScheduler(t *testing.T) {
+	cfg := &config{}
+
+	WithScheduler()(cfg)
+
+	require.True(t, cfg.scheduler)
+	require.NoError(t, cfg.checkAPIServerFlags())
+}
+
+func TestWithKWOK(t *testing.T) {
+	cfg := &config{}
+
+	WithKWOK()(cfg)
+
+	require.True(t, cfg.kwok)
+	require.NoError(t, cfg.checkAPIServerFlags())
+}
+
+func TestWithReuse(t *testing.T) {
+	cfg := &config{}
+
+	WithReuse("envtest-shared")(cfg)
+	require.Equal(t, "envtest-shared", cfg.reuseName)
+	require.NoError(t, cfg.checkAPIServerFlags())
+
+	WithWebhooks("testdata/webhooks")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(),
+		"WithReuse requires WithWebhookTarget for WithWebhooks, the host port is picked by the creating process")
+
+	WithWebhookTarget("webhook", 9443)(cfg)
+	require.NoError(t, cfg.checkAPIServerFlags())
+
+	WithHostAccess(8080)(cfg)
+	require.ErrorContains(t, cfg.checkAPIServerFlags(), "cannot be combined with ports of WithHostAccess")
+
+	require.EqualError(t, (&config{reuseName: "envtest shared"}).checkReuse(),
+		`invalid container name "envtest shared" of WithReuse`)
+}
+
+func TestWithStartupTimeout(t *testing.T) {
+	timeouts := func(cfg *config) []time.Duration {
+		var got []time.Duration
+
+		for _, strategy := range cfg.waitStrategy().(*wait.MultiStrategy).Strategies {
+			got = append(got, *strategy.(wait.StrategyTimeout).Timeout())
+		}
+
+		return got
+	}
+
+	cfg := &config{}
+	WithControllerManager()(cfg)
+
+	require.Equal(t, []time.Duration{time.Minute, time.Minute}, timeouts(cfg))
+
+	WithStartupTimeout(5 * time.Minute)(cfg)
+	require.Equal(t, []time.Duration{5 * time.Minute, 5 * time.Minute}, timeouts(cfg))
+}
+
+func TestWithLegacyWait(t *testing.T) {
+	cfg := &config{}
+	require.Len(t, cfg.waitStrategy().(*wait.MultiStrategy).Strategies, 1)
+
+	WithLegacyWait()(cfg)
+
+	strategies := cfg.waitStrategy().(*wait.MultiStrategy).Strategies
+	require.Len(t, strategies, 2)
+	require.Equal(t, "Envtest is ready!", strategies[1].(*wait.LogStrategy).Log)
+}
+
+func TestWithWaitStrategy(t *testing.T) {
+	custom := wait.ForLog("custom image is ready")
+
+	cfg := &config{}
+	WithWaitStrategy(custom)(cfg)
+	WithKWOK()(cfg)
+
+	// The custom strategy replaces the checks of the components too
+	require.Equal(t, []wait.Strategy{custom}, cfg.waitStrategy().(*wait.MultiStrategy).Strategies)
+}
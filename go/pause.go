@@ -0,0 +1,106 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// unpauseTimeout bounds the unpause done after PausedFor and before terminating a paused container
+const unpauseTimeout = 10 * time.Second
+
+// Pause freezes every process of the container with the Docker pause API, so the API server
+// stops responding without refusing connections: requests hang until the client times out.
+// Watches time out while the container is paused and informers have to relist or resync after Unpause.
+// Pausing a paused container is an error. Terminate unpauses the container first.
+func (c *EnvtestContainer) Pause(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		return errors.New("container is already paused")
+	}
+
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	if err := cli.ContainerPause(ctx, c.GetContainerID()); err != nil {
+		return fmt.Errorf("failed to pause container: %w", err)
+	}
+
+	c.paused = true
+
+	return nil
+}
+
+// Unpause resumes a container frozen by Pause. Clients created before Pause keep working, requests hanging
+// meanwhile complete once the API server is back, see WaitForReady. Unpausing a running container is an error.
+func (c *EnvtestContainer) Unpause(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		return errors.New("container is not paused")
+	}
+
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	if err := cli.ContainerUnpause(ctx, c.GetContainerID()); err != nil {
+		return fmt.Errorf("failed to unpause container: %w", err)
+	}
+
+	c.paused = false
+
+	return nil
+}
+
+// PausedFor pauses the container for the given duration, or until ctx is done, and unpauses it again.
+// The unpause is deferred, so it also happens when the caller panics while the container is paused.
+func (c *EnvtestContainer) PausedFor(ctx context.Context, d time.Duration) (err error) {
+	if pauseErr := c.Pause(ctx); pauseErr != nil {
+		return pauseErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), unpauseTimeout)
+		defer cancel()
+
+		err = errors.Join(err, c.Unpause(ctx))
+	}()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// unpauseIfPaused unpauses the container if it is still paused, so it can be stopped gracefully
+func (c *EnvtestContainer) unpauseIfPaused(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, unpauseTimeout)
+	defer cancel()
+
+	c.mu.Lock()
+	paused := c.paused
+	c.mu.Unlock()
+
+	if paused {
+		_ = c.Unpause(ctx)
+	}
+}
@@ -0,0 +1,18 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseGuards(t *testing.T) {
+	c := &EnvtestContainer{}
+
+	require.ErrorContains(t, c.Unpause(t.Context()), "not paused")
+
+	c.paused = true
+
+	require.ErrorContains(t, c.Pause(t.Context()), "already paused")
+	require.ErrorContains(t, c.PausedFor(t.Context(), 0), "already paused")
+}
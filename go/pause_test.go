@@ -0,0 +1,91 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerPause(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Pause(ctx))
+	require.Error(t, c.Pause(ctx), "pausing twice must be refused")
+
+	// The request hangs instead of failing fast with connection refused
+	reqCtx, reqCancel := context.WithTimeout(ctx, 2*time.Second)
+	start := time.Now()
+
+	_, err = clientset.CoreV1().Namespaces().List(reqCtx, metav1.ListOptions{})
+
+	reqCancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+
+	require.NoError(t, c.Unpause(ctx))
+	require.Error(t, c.Unpause(ctx))
+	require.NoError(t, c.WaitForReady(ctx, 10*time.Second))
+
+	// The client created before the outage is used as is
+	_, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+}
+
+func TestEnvtestContainerPausedFor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	start := time.Now()
+	require.NoError(t, c.PausedFor(ctx, time.Second))
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// A cancelled context cuts the pause short and still unpauses
+	pauseCtx, pauseCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer pauseCancel()
+
+	err = c.PausedFor(pauseCtx, time.Minute)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, c.Pause(ctx), "container was left paused")
+	require.NoError(t, c.Unpause(ctx))
+}
+
+func TestEnvtestContainerTerminatePaused(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Pause(ctx))
+	require.NoError(t, testcontainers.TerminateContainer(c))
+}
@@ -0,0 +1,79 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrProfilingDisabled is returned by GetAPIServerProfile when the API server runs WithProfiling(false)
+var ErrProfilingDisabled = errors.New("kube-apiserver profiling is disabled, see WithProfiling")
+
+// pprofProfiles are the profiles served under /debug/pprof/. profile is the CPU profile.
+var pprofProfiles = []string{
+	"allocs",
+	"block",
+	"goroutine",
+	"heap",
+	"mutex",
+	"profile",
+	"threadcreate",
+	"trace",
+}
+
+// checkProfile rejects profiles the API server does not serve, as it answers them with 404 like when profiling is disabled
+func checkProfile(profile string, seconds int) error {
+	if !slices.Contains(pprofProfiles, profile) {
+		return fmt.Errorf(
+			"unknown pprof profile %q, expected one of %s",
+			profile,
+			strings.Join(pprofProfiles, ", "),
+		)
+	}
+
+	if seconds < 0 {
+		return fmt.Errorf("profile duration must not be negative, got %ds", seconds)
+	}
+
+	return nil
+}
+
+// GetAPIServerProfile fetches a pprof profile of the API server from /debug/pprof/ using the admin credentials,
+// e.g. "heap", or "profile" for a CPU profile. With seconds > 0 the profile covers that many seconds,
+// the delta since the request for heap-like profiles; CPU profiles and traces default to 30s otherwise.
+// The result can be written to a file for `go tool pprof`. It fails with ErrProfilingDisabled on WithProfiling(false).
+func (c *EnvtestContainer) GetAPIServerProfile(
+	ctx context.Context,
+	profile string,
+	seconds int,
+) ([]byte, error) {
+	if err := checkProfile(profile, seconds); err != nil {
+		return nil, err
+	}
+
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := clientset.CoreV1().RESTClient().Get().AbsPath("/debug/pprof", profile)
+	if seconds > 0 {
+		req = req.Param("seconds", strconv.Itoa(seconds))
+	}
+
+	data, err := req.DoRaw(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil, ErrProfilingDisabled
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s profile of the API server: %w", profile, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,28 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProfiling(t *testing.T) {
+	cfg := &config{}
+
+	WithProfiling(false)(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--profiling=false"}, cfg.apiServerArgs())
+
+	WithAPIServerArg("profiling", "true")(cfg)
+	require.EqualError(t, cfg.checkAPIServerFlags(), `conflicting values for kube-apiserver flag --profiling: "false" and "true"`)
+}
+
+func TestCheckProfile(t *testing.T) {
+	require.NoError(t, checkProfile("heap", 0))
+	require.NoError(t, checkProfile("profile", 5))
+
+	require.EqualError(t, checkProfile("cpu", 0),
+		"unknown pprof profile \"cpu\", expected one of allocs, block, goroutine, heap, mutex, profile, threadcreate, trace")
+	require.EqualError(t, checkProfile("heap", -1), "profile duration must not be negative, got -1s")
+}
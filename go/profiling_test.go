@@ -0,0 +1,50 @@
+package envtest_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestEnvtestContainerGetAPIServerProfile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	data, err := c.GetAPIServerProfile(ctx, "heap", 0)
+	require.NoError(t, err)
+
+	heap, err := profile.Parse(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.NotEmpty(t, heap.Sample)
+	require.NoError(t, heap.CheckValid())
+}
+
+func TestEnvtestContainerWithProfilingDisabled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithProfiling(false))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	_, err = c.GetAPIServerProfile(ctx, "heap", 0)
+	require.ErrorIs(t, err, envtest.ErrProfilingDisabled)
+}
@@ -0,0 +1,356 @@
+package envtest
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// UpdateGoldenEnv is the environment variable that makes AssertMatchesGolden rewrite golden files
+	UpdateGoldenEnv = "ENVTEST_UPDATE_GOLDEN"
+
+	// maskedValue replaces the values of masked body fields
+	maskedValue = "<masked>"
+
+	// recordingQuietPeriod is how long no new interactions may arrive before a recording is considered settled
+	recordingQuietPeriod = time.Second
+
+	// recordingSettleTimeout bounds how long to wait for a recording to settle
+	recordingSettleTimeout = 30 * time.Second
+)
+
+// RecordingAuditPolicy is an audit policy that captures the request bodies of mutations,
+// as required by StartRecording. Pass it to WithAuditPolicy.
+var RecordingAuditPolicy = []byte(`apiVersion: audit.k8s.io/v1
+kind: Policy
+omitStages: ["RequestReceived"]
+rules:
+- level: Request
+  verbs: ["create", "update", "patch", "delete", "deletecollection"]
+- level: Metadata
+`)
+
+// mutatingVerbs are the audit verbs a recording captures
+var mutatingVerbs = []string{"create", "update", "patch", "delete", "deletecollection"}
+
+// defaultIgnoredFields are dropped from recorded bodies as they differ between runs
+var defaultIgnoredFields = []string{
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"metadata.creationTimestamp",
+	"metadata.generation",
+	"metadata.managedFields",
+	"metadata.selfLink",
+}
+
+// Interaction is a single API mutation performed during a recording
+type Interaction struct {
+	Method      string         `json:"method"`
+	Resource    string         `json:"resource"`
+	Subresource string         `json:"subresource,omitempty"`
+	Namespace   string         `json:"namespace,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Body        map[string]any `json:"body,omitempty"`
+}
+
+// Recording captures the API mutations performed with the per-test identity of a test
+// (see ClientForTest and RESTConfigForTest) from the moment it was started.
+// It requires audit logging with request bodies, e.g. WithAuditPolicy(RecordingAuditPolicy).
+type Recording struct {
+	container *EnvtestContainer
+	user      string
+	offset    int
+}
+
+// StartRecording starts recording the mutations performed by the per-test identity of the test
+func (c *EnvtestContainer) StartRecording(ctx context.Context, t testing.TB) (*Recording, error) {
+	events, err := c.GetAuditEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	return &Recording{container: c, user: TestUserName(t), offset: len(events)}, nil
+}
+
+// Interactions returns the mutations recorded so far, once no new ones have arrived for a quiet period
+func (r *Recording) Interactions(ctx context.Context) ([]Interaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, recordingSettleTimeout)
+	defer cancel()
+
+	var (
+		interactions []Interaction
+		settledAt    time.Time
+	)
+
+	for {
+		events, err := r.container.GetAuditEvents(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := recordedInteractions(events[min(r.offset, len(events)):], r.user)
+		if err != nil {
+			return nil, err
+		}
+
+		if settledAt.IsZero() || len(current) != len(interactions) {
+			interactions = current
+			settledAt = time.Now()
+		} else if time.Since(settledAt) >= recordingQuietPeriod {
+			return interactions, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("recording did not settle: %w", ctx.Err())
+		case <-time.After(recordingQuietPeriod / 5):
+		}
+	}
+}
+
+// goldenConfig holds the normalization rules for comparing recordings
+type goldenConfig struct {
+	ignoreOrder bool
+	maskFields  []string
+}
+
+// GoldenOption is a functional option for configuring AssertMatchesGolden
+type GoldenOption func(*goldenConfig)
+
+// IgnoreOrder compares the recorded interactions regardless of their order
+func IgnoreOrder() GoldenOption {
+	return func(c *goldenConfig) {
+		c.ignoreOrder = true
+	}
+}
+
+// MaskFields replaces the values of the given dot-separated body fields (e.g. "metadata.annotations")
+// with a placeholder, for values that legitimately differ between runs
+func MaskFields(paths ...string) GoldenOption {
+	return func(c *goldenConfig) {
+		c.maskFields = append(c.maskFields, paths...)
+	}
+}
+
+// AssertMatchesGolden asserts that the recorded interactions match the golden file.
+// resourceVersion, uid, timestamps and managed fields are ignored. Set ENVTEST_UPDATE_GOLDEN=1
+// to rewrite the golden file with the current recording instead.
+func (r *Recording) AssertMatchesGolden(t testing.TB, path string, opts ...GoldenOption) {
+	t.Helper()
+
+	cfg := &goldenConfig{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	interactions, err := r.Interactions(t.Context())
+	if err != nil {
+		t.Fatalf("failed to get recorded interactions: %v", err)
+	}
+
+	actual, err := renderGolden(normalizeInteractions(interactions, cfg))
+	if err != nil {
+		t.Fatalf("failed to render recording: %v", err)
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden dir: %v", err)
+		}
+
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+
+		return
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+
+	var expected []Interaction
+	if err := yaml.Unmarshal(golden, &expected); err != nil {
+		t.Fatalf("failed to parse golden file %s: %v", path, err)
+	}
+
+	// Normalize the golden file too, so it may be hand-edited or carry fields masked since
+	want, err := renderGolden(normalizeInteractions(expected, cfg))
+	if err != nil {
+		t.Fatalf("failed to render golden file: %v", err)
+	}
+
+	if !bytes.Equal(want, actual) {
+		t.Errorf(
+			"recorded interactions do not match %s (set %s=1 to update it)\n"+
+				"--- expected\n%s\n--- actual\n%s",
+			path,
+			UpdateGoldenEnv,
+			want,
+			actual,
+		)
+	}
+}
+
+// recordedInteractions converts the mutation events of the user into interactions
+func recordedInteractions(events []auditv1.Event, user string) ([]Interaction, error) {
+	var interactions []Interaction
+
+	for _, event := range events {
+		if event.Stage != auditv1.StageResponseComplete || event.ObjectRef == nil ||
+			!slices.Contains(mutatingVerbs, event.Verb) || eventUser(event) != user {
+			continue
+		}
+
+		gvr := schema.GroupVersionResource{
+			Group:    event.ObjectRef.APIGroup,
+			Version:  event.ObjectRef.APIVersion,
+			Resource: event.ObjectRef.Resource,
+		}
+
+		interaction := Interaction{
+			Method:      event.Verb,
+			Resource:    strings.TrimPrefix(gvr.GroupVersion().String()+"/"+gvr.Resource, "/"),
+			Subresource: event.ObjectRef.Subresource,
+			Namespace:   event.ObjectRef.Namespace,
+			Name:        event.ObjectRef.Name,
+		}
+
+		if event.RequestObject != nil && len(event.RequestObject.Raw) > 0 {
+			if err := json.Unmarshal(event.RequestObject.Raw, &interaction.Body); err != nil {
+				return nil, fmt.Errorf(
+					"failed to decode request body of %s %s: %w",
+					event.Verb,
+					event.RequestURI,
+					err,
+				)
+			}
+		}
+
+		interactions = append(interactions, interaction)
+	}
+
+	return interactions, nil
+}
+
+// eventUser returns the user a request was made as, preferring the impersonated one
+func eventUser(event auditv1.Event) string {
+	if event.ImpersonatedUser != nil {
+		return event.ImpersonatedUser.Username
+	}
+
+	return event.User.Username
+}
+
+// normalizeInteractions applies the normalization rules to copies of the interactions
+func normalizeInteractions(interactions []Interaction, cfg *goldenConfig) []Interaction {
+	normalized := make([]Interaction, 0, len(interactions))
+
+	for _, interaction := range interactions {
+		if interaction.Body != nil {
+			body := deepCopyJSON(interaction.Body)
+
+			for _, path := range defaultIgnoredFields {
+				removeField(body, strings.Split(path, "."))
+			}
+
+			for _, path := range cfg.maskFields {
+				maskField(body, strings.Split(path, "."))
+			}
+
+			if len(body) == 0 {
+				body = nil
+			}
+
+			interaction.Body = body
+		}
+
+		normalized = append(normalized, interaction)
+	}
+
+	if cfg.ignoreOrder {
+		slices.SortStableFunc(normalized, compareInteractions)
+	}
+
+	return normalized
+}
+
+// compareInteractions orders interactions by their identity, then by body
+func compareInteractions(a, b Interaction) int {
+	return cmp.Or(
+		strings.Compare(a.Resource, b.Resource),
+		strings.Compare(a.Namespace, b.Namespace),
+		strings.Compare(a.Name, b.Name),
+		strings.Compare(a.Subresource, b.Subresource),
+		strings.Compare(a.Method, b.Method),
+		bytes.Compare(mustJSON(a.Body), mustJSON(b.Body)),
+	)
+}
+
+// renderGolden renders interactions in the golden file format
+func renderGolden(interactions []Interaction) ([]byte, error) {
+	if len(interactions) == 0 {
+		return []byte("[]\n"), nil
+	}
+
+	return yaml.Marshal(interactions)
+}
+
+// removeField deletes the field at the path, if present
+func removeField(obj map[string]any, path []string) {
+	if len(path) == 1 {
+		delete(obj, path[0])
+
+		return
+	}
+
+	if child, ok := obj[path[0]].(map[string]any); ok {
+		removeField(child, path[1:])
+	}
+}
+
+// maskField replaces the value of the field at the path, if present
+func maskField(obj map[string]any, path []string) {
+	if len(path) == 1 {
+		if _, ok := obj[path[0]]; ok {
+			obj[path[0]] = maskedValue
+		}
+
+		return
+	}
+
+	if child, ok := obj[path[0]].(map[string]any); ok {
+		maskField(child, path[1:])
+	}
+}
+
+// deepCopyJSON copies a decoded JSON object
+func deepCopyJSON(obj map[string]any) map[string]any {
+	var out map[string]any
+
+	_ = json.Unmarshal(mustJSON(obj), &out)
+
+	return out
+}
+
+// mustJSON encodes a value decoded from JSON, which always succeeds
+func mustJSON(v any) []byte {
+	data, _ := json.Marshal(v)
+
+	return data
+}
@@ -0,0 +1,66 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const recordedAuditLog = `{"level":"Request","auditID":"1","stage":"ResponseComplete","verb":"update","user":{"username":"admin"},"impersonatedUser":{"username":"test:TestFoo"},"objectRef":{"resource":"configmaps","namespace":"default","name":"app","apiVersion":"v1"},"requestObject":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"app","namespace":"default","resourceVersion":"42","uid":"abc","creationTimestamp":"2025-01-01T00:00:00Z","annotations":{"hash":"123"}},"data":{"key":"value"}}}
+{"level":"Metadata","auditID":"2","stage":"ResponseComplete","verb":"get","user":{"username":"admin"},"impersonatedUser":{"username":"test:TestFoo"},"objectRef":{"resource":"configmaps","namespace":"default","name":"app","apiVersion":"v1"}}
+{"level":"Request","auditID":"3","stage":"ResponseComplete","verb":"create","user":{"username":"admin"},"objectRef":{"resource":"configmaps","namespace":"default","name":"other","apiVersion":"v1"}}
+{"level":"Request","auditID":"4","stage":"ResponseComplete","verb":"delete","user":{"username":"test:TestFoo"},"objectRef":{"resource":"deployments","namespace":"default","name":"web","apiGroup":"apps","apiVersion":"v1"}}
+{"level":"Request","auditID":"5","stage":"ResponseStarted","verb":"create","user":{"username":"test:TestFoo"},"objectRef":{"resource":"configmaps","namespace":"default","name":"app","apiVersion":"v1"}}
+`
+
+func TestRecordedInteractions(t *testing.T) {
+	events, err := parseAuditEvents(strings.NewReader(recordedAuditLog))
+	require.NoError(t, err)
+
+	interactions, err := recordedInteractions(events, "test:TestFoo")
+	require.NoError(t, err)
+	require.Len(t, interactions, 2)
+
+	require.Equal(t, "update", interactions[0].Method)
+	require.Equal(t, "v1/configmaps", interactions[0].Resource)
+	require.Equal(t, "default", interactions[0].Namespace)
+	require.Equal(t, "app", interactions[0].Name)
+	require.Equal(t, map[string]any{"key": "value"}, interactions[0].Body["data"])
+
+	require.Equal(t, Interaction{Method: "delete", Resource: "apps/v1/deployments", Namespace: "default", Name: "web"}, interactions[1])
+}
+
+func TestNormalizeInteractions(t *testing.T) {
+	events, err := parseAuditEvents(strings.NewReader(recordedAuditLog))
+	require.NoError(t, err)
+
+	interactions, err := recordedInteractions(events, "test:TestFoo")
+	require.NoError(t, err)
+
+	normalized := normalizeInteractions(interactions, &goldenConfig{
+		ignoreOrder: true,
+		maskFields:  []string{"metadata.annotations", "spec.missing"},
+	})
+
+	require.Equal(t, "apps/v1/deployments", normalized[0].Resource)
+	require.Equal(t, map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "app", "namespace": "default", "annotations": maskedValue},
+		"data":       map[string]any{"key": "value"},
+	}, normalized[1].Body)
+
+	// The recorded interactions are left untouched
+	require.Equal(t, "42", interactions[0].Body["metadata"].(map[string]any)["resourceVersion"])
+}
+
+func TestRenderGolden(t *testing.T) {
+	golden, err := renderGolden(nil)
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", string(golden))
+
+	golden, err = renderGolden([]Interaction{{Method: "delete", Resource: "v1/configmaps", Namespace: "default", Name: "app"}})
+	require.NoError(t, err)
+	require.Equal(t, "- method: delete\n  name: app\n  namespace: default\n  resource: v1/configmaps\n", string(golden))
+}
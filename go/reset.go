@@ -0,0 +1,310 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultResetGracePeriod is how long Reset waits for finalizers to run before clearing them
+const defaultResetGracePeriod = 5 * time.Second
+
+// systemNamespaces are the namespaces the API server creates, which Reset leaves alone
+var systemNamespaces = []string{
+	metav1.NamespaceSystem,
+	metav1.NamespacePublic,
+	corev1.NamespaceNodeLease,
+}
+
+// resetConfig holds the configuration for Reset
+type resetConfig struct {
+	clusterScoped []schema.GroupVersionKind
+	includeCRDs   bool
+	gracePeriod   time.Duration
+}
+
+// ResetOption is a functional option for configuring Reset
+type ResetOption func(*resetConfig)
+
+// ResetClusterScoped also deletes the cluster-scoped objects of the given kinds, e.g. ClusterRoles or
+// PriorityClasses, except the ones the API server bootstraps
+func ResetClusterScoped(gvks ...schema.GroupVersionKind) ResetOption {
+	return func(c *resetConfig) {
+		c.clusterScoped = append(c.clusterScoped, gvks...)
+	}
+}
+
+// ResetIncludeCRDs also deletes the CRDs along with their cluster-scoped custom resources
+func ResetIncludeCRDs() ResetOption {
+	return func(c *resetConfig) {
+		c.includeCRDs = true
+	}
+}
+
+// ResetGracePeriod sets how long Reset waits for finalizers to be handled, e.g. by the controllers under test,
+// before clearing them (default: 5s)
+func ResetGracePeriod(d time.Duration) ResetOption {
+	return func(c *resetConfig) {
+		c.gracePeriod = d
+	}
+}
+
+// Reset wipes the state tests left in the container, so one container can be shared by tests instead of
+// started per test: it deletes every namespace but default and the kube-* ones, and every namespaced object,
+// custom resources included, in them and in default, except the ones maintained there, e.g. the kubernetes Service.
+// CRDs and other cluster-scoped objects are kept unless ResetIncludeCRDs or ResetClusterScoped is given.
+// Objects still held by finalizers after the grace period of ResetGracePeriod have them cleared,
+// and namespaces are finalized, like ForceDeleteNamespace, so nothing finalizers guard is cleaned up then.
+// Reset emits EventReset once the cluster is back to its initial state.
+func (c *EnvtestContainer) Reset(ctx context.Context, opts ...ResetOption) error {
+	cfg := &resetConfig{gracePeriod: defaultResetGracePeriod}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	namespaceList := &corev1.NamespaceList{}
+
+	if err := cl.List(ctx, namespaceList); err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	var namespaces []string
+
+	for _, ns := range namespaceList.Items {
+		if slices.Contains(systemNamespaces, ns.Name) {
+			continue
+		}
+
+		if ns.Name != metav1.NamespaceDefault {
+			err := deleteNamespace(ctx, cl, c.retry, ns.Name)
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+
+		namespaces = append(namespaces, ns.Name)
+	}
+
+	targets, err := c.resetTargets(ctx, cl, namespaces, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if err := target.deleteObjects(ctx, cl, c.retry); err != nil {
+			return err
+		}
+	}
+
+	// Waiting for the grace period is cut short once everything is gone
+	if err := waitForReset(ctx, cl, targets, cfg.gracePeriod); err == nil {
+		c.events.emit(EventReset, "")
+
+		return nil
+	}
+
+	for _, target := range targets {
+		if err := target.clearFinalizers(ctx, cl, c.retry); err != nil {
+			return err
+		}
+	}
+
+	for _, ns := range namespaces {
+		if ns == metav1.NamespaceDefault {
+			continue
+		}
+
+		if err := finalizeNamespace(ctx, cl, c.retry, ns); err != nil {
+			return err
+		}
+	}
+
+	if err := waitForReset(ctx, cl, targets, namespaceRemovalTimeout); err != nil {
+		return fmt.Errorf("cluster was not reset: %w", err)
+	}
+
+	c.events.emit(EventReset, "")
+
+	return nil
+}
+
+// resetTargets returns the kinds Reset deletes the objects of, CRDs last, so their custom resources go first
+func (c *EnvtestContainer) resetTargets(
+	ctx context.Context,
+	cl client.Client,
+	namespaces []string,
+	cfg *resetConfig,
+) ([]purgeTarget, error) {
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds, err := discoveredNamespacedKinds(clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []purgeTarget
+
+	for _, ns := range namespaces {
+		for _, gvk := range kinds {
+			target := purgeTarget{gvk: gvk, namespace: ns}
+			if ns == metav1.NamespaceDefault {
+				target.keep = keepDefaultNamespaceObject(gvk)
+			}
+
+			targets = append(targets, target)
+		}
+	}
+
+	for _, gvk := range cfg.clusterScoped {
+		mapping, err := cl.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map %s: %w", gvk, err)
+		}
+
+		if mapping.Scope.Name() != meta.RESTScopeNameRoot {
+			return nil, fmt.Errorf("%s is not cluster-scoped", gvk)
+		}
+
+		targets = append(
+			targets,
+			purgeTarget{gvk: mapping.GroupVersionKind, keep: isBootstrapObject},
+		)
+	}
+
+	if cfg.includeCRDs {
+		crds := &unstructured.UnstructuredList{}
+		crds.SetGroupVersionKind(crdKind.GroupVersion().WithKind(crdKind.Kind + "List"))
+
+		if err := cl.List(ctx, crds); err != nil {
+			return nil, fmt.Errorf("failed to list CRDs: %w", err)
+		}
+
+		for _, crd := range crds.Items {
+			if gvk, ok := clusterScopedCRDKind(&crd); ok {
+				targets = append(targets, purgeTarget{gvk: gvk})
+			}
+		}
+
+		targets = append(targets, purgeTarget{gvk: crdKind})
+	}
+
+	return targets, nil
+}
+
+// clusterScopedCRDKind returns the kind, in its storage version, of a cluster-scoped CRD
+func clusterScopedCRDKind(crd *unstructured.Unstructured) (schema.GroupVersionKind, bool) {
+	scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+	if scope != "Cluster" {
+		return schema.GroupVersionKind{}, false
+	}
+
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+
+	for _, v := range versions {
+		version, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if storage, _ := version["storage"].(bool); storage {
+			name, _ := version["name"].(string)
+
+			return schema.GroupVersionKind{Group: group, Version: name, Kind: kind}, true
+		}
+	}
+
+	return schema.GroupVersionKind{}, false
+}
+
+// defaultNamespaceObjects are the objects of the default namespace the API server, or the controllers
+// of WithControllerManager, maintain, by kind
+var defaultNamespaceObjects = map[schema.GroupKind]string{
+	{Kind: "Service"}:   "kubernetes",
+	{Kind: "Endpoints"}: "kubernetes",
+	{Group: "discovery.k8s.io", Kind: "EndpointSlice"}: "kubernetes",
+	{Kind: "ServiceAccount"}:                           "default",
+	{Kind: "ConfigMap"}:                                "kube-root-ca.crt",
+}
+
+// keepDefaultNamespaceObject returns a keep func of a purgeTarget in the default namespace for the objects
+// of the kind in defaultNamespaceObjects
+func keepDefaultNamespaceObject(gvk schema.GroupVersionKind) func(*unstructured.Unstructured) bool {
+	name, ok := defaultNamespaceObjects[gvk.GroupKind()]
+	if !ok {
+		return nil
+	}
+
+	return func(obj *unstructured.Unstructured) bool {
+		return obj.GetName() == name
+	}
+}
+
+// isBootstrapObject returns whether the API server created the object on startup, e.g. the system ClusterRoles
+// or PriorityClasses
+func isBootstrapObject(obj *unstructured.Unstructured) bool {
+	if _, ok := obj.GetLabels()["kubernetes.io/bootstrapping"]; ok {
+		return true
+	}
+
+	return strings.HasPrefix(obj.GetName(), "system:") ||
+		strings.HasPrefix(obj.GetName(), "system-")
+}
+
+// waitForReset polls until the objects of the targets and the deleted namespaces are gone
+func waitForReset(
+	ctx context.Context,
+	cl client.Client,
+	targets []purgeTarget,
+	timeout time.Duration,
+) error {
+	return wait.PollUntilContextTimeout(
+		ctx,
+		250*time.Millisecond,
+		timeout,
+		true,
+		func(ctx context.Context) (bool, error) {
+			namespaces := &corev1.NamespaceList{}
+
+			if err := cl.List(ctx, namespaces); err != nil {
+				return false, fmt.Errorf("failed to list namespaces: %w", err)
+			}
+
+			for _, ns := range namespaces.Items {
+				if ns.Name != metav1.NamespaceDefault &&
+					!slices.Contains(systemNamespaces, ns.Name) {
+					return false, nil
+				}
+			}
+
+			for _, target := range targets {
+				objects, err := target.remaining(ctx, cl)
+				if err != nil || len(objects) > 0 {
+					return false, err
+				}
+			}
+
+			return true, nil
+		},
+	)
+}
@@ -0,0 +1,81 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClusterScopedCRDKind(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"group": "reset.example.com",
+			"scope": "Cluster",
+			"names": map[string]any{"plural": "regions", "kind": "Region"},
+			"versions": []any{
+				map[string]any{"name": "v1beta1", "storage": false},
+				map[string]any{"name": "v1", "storage": true},
+			},
+		},
+	}}
+
+	gvk, ok := clusterScopedCRDKind(crd)
+	require.True(t, ok)
+	require.Equal(t, schema.GroupVersionKind{Group: "reset.example.com", Version: "v1", Kind: "Region"}, gvk)
+
+	require.NoError(t, unstructured.SetNestedField(crd.Object, "Namespaced", "spec", "scope"))
+
+	_, ok = clusterScopedCRDKind(crd)
+	require.False(t, ok)
+}
+
+func TestKeepDefaultNamespaceObject(t *testing.T) {
+	named := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetName(name)
+
+		return obj
+	}
+
+	keep := keepDefaultNamespaceObject(schema.GroupVersionKind{Version: "v1", Kind: "Service"})
+	require.True(t, keep(named("kubernetes")))
+	require.False(t, keep(named("app")))
+
+	keep = keepDefaultNamespaceObject(schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"})
+	require.True(t, keep(named("default")))
+	require.False(t, keep(named("kubernetes")))
+
+	require.Nil(t, keepDefaultNamespaceObject(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}))
+}
+
+func TestIsBootstrapObject(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+
+	obj.SetName("system:controller:namespace-controller")
+	require.True(t, isBootstrapObject(obj))
+
+	obj.SetName("system-node-critical")
+	require.True(t, isBootstrapObject(obj))
+
+	obj.SetName("cluster-admin")
+	require.False(t, isBootstrapObject(obj))
+
+	obj.SetLabels(map[string]string{"kubernetes.io/bootstrapping": "rbac-defaults"})
+	require.True(t, isBootstrapObject(obj))
+}
+
+func TestResetOptions(t *testing.T) {
+	cfg := &resetConfig{gracePeriod: defaultResetGracePeriod}
+
+	gvk := schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+
+	for _, opt := range []ResetOption{ResetIncludeCRDs(), ResetClusterScoped(gvk), ResetGracePeriod(0)} {
+		opt(cfg)
+	}
+
+	require.True(t, cfg.includeCRDs)
+	require.Equal(t, []schema.GroupVersionKind{gvk}, cfg.clusterScoped)
+	require.Zero(t, cfg.gracePeriod)
+}
@@ -0,0 +1,214 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// resetManifest leaves objects behind in new and system-owned namespaces, some held by finalizers
+const resetManifest = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.reset.example.com
+spec:
+  group: reset.example.com
+  names:
+    kind: Gadget
+    listKind: GadgetList
+    plural: gadgets
+    singular: gadget
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        x-kubernetes-preserve-unknown-fields: true
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: regions.reset.example.com
+spec:
+  group: reset.example.com
+  names:
+    kind: Region
+    listKind: RegionList
+    plural: regions
+    singular: region
+  scope: Cluster
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        x-kubernetes-preserve-unknown-fields: true
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-b
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team-a
+  finalizers:
+  - example.com/cleanup
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+  namespace: team-b
+---
+apiVersion: reset.example.com/v1
+kind: Gadget
+metadata:
+  name: held
+  namespace: team-b
+  finalizers:
+  - example.com/cleanup
+---
+apiVersion: reset.example.com/v1
+kind: Region
+metadata:
+  name: west
+  finalizers:
+  - example.com/cleanup
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: leftover
+  namespace: default
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: gadget-reader
+rules:
+- apiGroups: ["reset.example.com"]
+  resources: ["gadgets"]
+  verbs: ["get"]
+`
+
+func TestEnvtestContainerReset(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	// Namespaces and cluster-scoped objects present before the tests stay in place
+	var before corev1.NamespaceList
+
+	require.NoError(t, cl.List(ctx, &before))
+
+	for range 2 {
+		require.NoError(t, c.Apply(ctx, []byte(resetManifest)))
+
+		err = c.Reset(ctx,
+			envtest.ResetIncludeCRDs(),
+			envtest.ResetClusterScoped(rbacv1.SchemeGroupVersion.WithKind("ClusterRole")),
+			envtest.ResetGracePeriod(time.Second),
+		)
+		require.NoError(t, err)
+
+		var after corev1.NamespaceList
+
+		require.NoError(t, cl.List(ctx, &after))
+		require.Len(t, after.Items, len(before.Items))
+
+		err = cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "leftover"}, &corev1.ConfigMap{})
+		require.True(t, apierrors.IsNotFound(err), "ConfigMap should be gone, got %v", err)
+
+		require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "kubernetes"}, &corev1.Service{}))
+
+		err = cl.Get(ctx, client.ObjectKey{Name: "gadget-reader"}, &rbacv1.ClusterRole{})
+		require.True(t, apierrors.IsNotFound(err), "ClusterRole should be gone, got %v", err)
+
+		require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "cluster-admin"}, &rbacv1.ClusterRole{}))
+
+		crds := &unstructured.UnstructuredList{}
+		crds.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "apiextensions.k8s.io",
+			Version: "v1",
+			Kind:    "CustomResourceDefinitionList",
+		})
+
+		require.NoError(t, cl.List(ctx, crds))
+		require.Empty(t, crds.Items)
+	}
+
+	// The cluster is still usable, also under the names used before
+	require.NoError(t, cl.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}))
+
+	event, err := c.WaitForEvent(ctx, envtest.EventReset)
+	require.NoError(t, err)
+	require.False(t, event.Time.IsZero())
+}
+
+func TestEnvtestContainerResetKeepsCRDs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, c.Apply(ctx, []byte(resetManifest)))
+	require.NoError(t, c.Reset(ctx, envtest.ResetGracePeriod(time.Second)))
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	// Custom resources are gone but their CRDs and cluster-scoped objects stay
+	gadgets := &unstructured.UnstructuredList{}
+	gadgets.SetAPIVersion("reset.example.com/v1")
+	gadgets.SetKind("GadgetList")
+
+	require.NoError(t, cl.List(ctx, gadgets))
+	require.Empty(t, gadgets.Items)
+
+	region := &unstructured.Unstructured{}
+	region.SetAPIVersion("reset.example.com/v1")
+	region.SetKind("Region")
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "west"}, region))
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "gadget-reader"}, &rbacv1.ClusterRole{}))
+}
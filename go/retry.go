@@ -0,0 +1,193 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// TransientError describes a class of errors a retry is expected to absorb
+type TransientError struct {
+	// Name identifies the error class in the recorded attempts
+	Name string
+	// Match reports whether the error belongs to the class
+	Match func(err error) bool
+}
+
+// TransientErrors is the classification table the mutating helpers consult before retrying.
+// Append to it to treat more errors as transient. Helpers re-run the whole operation on retry,
+// so conflicts are absorbed by refetching the object.
+var TransientErrors = []TransientError{
+	{Name: "etcd leader changed", Match: messageContains("etcdserver: leader changed")},
+	{Name: "etcd request timed out", Match: messageContains("etcdserver: request timed out")},
+	{Name: "apiserver shutting down", Match: messageContains("apiserver is shutting down")},
+	{Name: "connection refused", Match: isErrno(syscall.ECONNREFUSED, "connection refused")},
+	{Name: "connection reset", Match: isErrno(syscall.ECONNRESET, "connection reset by peer")},
+	{Name: "unexpected EOF", Match: func(err error) bool {
+		return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}},
+	{Name: "server timeout", Match: func(err error) bool {
+		return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err)
+	}},
+	{Name: "too many requests", Match: apierrors.IsTooManyRequests},
+	{Name: "service unavailable", Match: apierrors.IsServiceUnavailable},
+	{Name: "conflict", Match: apierrors.IsConflict},
+}
+
+// retryBackoff is the bounded exponential backoff between attempts of a transient failure
+type retryBackoff struct {
+	attempts int
+	initial  time.Duration
+	factor   float64
+	max      time.Duration
+}
+
+// defaultRetryBackoff rides out an apiserver restart, which takes a few seconds
+var defaultRetryBackoff = retryBackoff{
+	attempts: 10,
+	initial:  100 * time.Millisecond,
+	factor:   2,
+	max:      2 * time.Second,
+}
+
+// delay returns how long to wait after the given (zero-based) failed attempt
+func (b retryBackoff) delay(attempt int) time.Duration {
+	d := b.initial
+
+	for range attempt {
+		d = time.Duration(float64(d) * b.factor)
+		if d >= b.max {
+			return b.max
+		}
+	}
+
+	return d
+}
+
+// RetryError is returned by helpers whose operation kept failing.
+// It unwraps to the last error, so apierrors checks keep working.
+type RetryError struct {
+	// Op describes the operation that was retried
+	Op string
+	// Attempts are the errors of every attempt, in order
+	Attempts []error
+}
+
+// Error lists every attempt
+func (e *RetryError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s failed after %d attempts", e.Op, len(e.Attempts))
+
+	for i, err := range e.Attempts {
+		fmt.Fprintf(&sb, "\n  attempt %d: %v", i+1, err)
+
+		if class := transientClass(err); class != "" {
+			fmt.Fprintf(&sb, " (%s)", class)
+		}
+	}
+
+	return sb.String()
+}
+
+// Unwrap returns the error of the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Attempts[len(e.Attempts)-1]
+}
+
+// retriable reports whether the error is transient according to TransientErrors
+func retriable(err error) bool {
+	return transientClass(err) != ""
+}
+
+// transientClass returns the name of the transient class the error belongs to, or "" if it is not transient
+func transientClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, te := range TransientErrors {
+		if te.Match(err) {
+			return te.Name
+		}
+	}
+
+	return ""
+}
+
+// retry runs a mutating operation, retrying it with backoff while it fails with a transient error.
+// Errors are returned as is when the first attempt fails permanently or retries are disabled.
+func (c *EnvtestContainer) retry(
+	ctx context.Context,
+	op string,
+	fn func(ctx context.Context) error,
+) error {
+	backoff := defaultRetryBackoff
+	if c.noRetries {
+		backoff.attempts = 1
+	}
+
+	return retryWithBackoff(ctx, backoff, op, fn)
+}
+
+// retryDefault is retry for helpers without a container, e.g. the cleanup of NewTestNamespace
+func retryDefault(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	return retryWithBackoff(ctx, defaultRetryBackoff, op, fn)
+}
+
+// retryWithBackoff implements retry for a given backoff
+func retryWithBackoff(
+	ctx context.Context,
+	backoff retryBackoff,
+	op string,
+	fn func(ctx context.Context) error,
+) error {
+	var attempts []error
+
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		attempts = append(attempts, err)
+
+		if len(attempts) == 1 && (!retriable(err) || backoff.attempts <= 1) {
+			return err
+		}
+
+		if !retriable(err) || len(attempts) >= backoff.attempts {
+			return &RetryError{Op: op, Attempts: attempts}
+		}
+
+		timer := time.NewTimer(backoff.delay(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("%w: %w", ctx.Err(), &RetryError{Op: op, Attempts: attempts})
+		case <-timer.C:
+		}
+	}
+}
+
+// messageContains matches errors whose message contains the substring
+func messageContains(substr string) func(error) bool {
+	return func(err error) bool {
+		return strings.Contains(err.Error(), substr)
+	}
+}
+
+// isErrno matches the syscall error, or its message once it has been flattened into a string
+func isErrno(errno syscall.Errno, msg string) func(error) bool {
+	return func(err error) bool {
+		return errors.Is(err, errno) || strings.Contains(err.Error(), msg)
+	}
+}
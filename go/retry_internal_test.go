@@ -0,0 +1,164 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetriable(t *testing.T) {
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantClass string
+	}{
+		{name: "nil", err: nil},
+		{name: "leader changed", err: apierrors.NewInternalError(errors.New("etcdserver: leader changed")), wantClass: "etcd leader changed"},
+		{name: "connection refused", err: fmt.Errorf("post: %w", dialErr), wantClass: "connection refused"},
+		{name: "flattened connection refused", err: errors.New("dial tcp 127.0.0.1:6443: connect: connection refused"), wantClass: "connection refused"},
+		{name: "unexpected EOF", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), wantClass: "unexpected EOF"},
+		{name: "conflict", err: apierrors.NewConflict(configMaps, "app", errors.New("object was modified")), wantClass: "conflict"},
+		{name: "too many requests", err: apierrors.NewTooManyRequests("slow down", 1), wantClass: "too many requests"},
+		{name: "service unavailable", err: apierrors.NewServiceUnavailable("starting"), wantClass: "service unavailable"},
+		{name: "not found", err: apierrors.NewNotFound(configMaps, "app")},
+		{name: "forbidden", err: apierrors.NewForbidden(configMaps, "app", errors.New("denied"))},
+		{name: "invalid", err: apierrors.NewBadRequest("bad")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantClass, transientClass(tt.err))
+			require.Equal(t, tt.wantClass != "", retriable(tt.err))
+		})
+	}
+}
+
+func TestRetriableExtended(t *testing.T) {
+	custom := errors.New("quota status not ready")
+
+	require.False(t, retriable(custom))
+
+	original := TransientErrors
+	t.Cleanup(func() { TransientErrors = original })
+
+	TransientErrors = append(TransientErrors, TransientError{
+		Name:  "quota",
+		Match: func(err error) bool { return errors.Is(err, custom) },
+	})
+
+	require.True(t, retriable(fmt.Errorf("update: %w", custom)))
+}
+
+func TestRetryBackoffDelay(t *testing.T) {
+	backoff := retryBackoff{attempts: 10, initial: 100 * time.Millisecond, factor: 2, max: time.Second}
+
+	require.Equal(t, 100*time.Millisecond, backoff.delay(0))
+	require.Equal(t, 200*time.Millisecond, backoff.delay(1))
+	require.Equal(t, 800*time.Millisecond, backoff.delay(3))
+	require.Equal(t, time.Second, backoff.delay(4))
+	require.Equal(t, time.Second, backoff.delay(100))
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	backoff := retryBackoff{attempts: 3, initial: time.Millisecond, factor: 2, max: 5 * time.Millisecond}
+	transient := apierrors.NewServiceUnavailable("starting")
+	permanent := apierrors.NewBadRequest("bad")
+
+	t.Run("absorbs transient errors", func(t *testing.T) {
+		calls := 0
+
+		err := retryWithBackoff(t.Context(), backoff, "create", func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return transient
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("returns permanent errors as is", func(t *testing.T) {
+		calls := 0
+
+		err := retryWithBackoff(t.Context(), backoff, "create", func(context.Context) error {
+			calls++
+
+			return permanent
+		})
+		require.Same(t, permanent, err)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("records every attempt", func(t *testing.T) {
+		err := retryWithBackoff(t.Context(), backoff, "create", func(context.Context) error {
+			return transient
+		})
+
+		var retryErr *RetryError
+
+		require.ErrorAs(t, err, &retryErr)
+		require.Len(t, retryErr.Attempts, 3)
+		require.True(t, apierrors.IsServiceUnavailable(err))
+		require.Contains(t, err.Error(), "create failed after 3 attempts")
+		require.Contains(t, err.Error(), "attempt 3: starting (service unavailable)")
+	})
+
+	t.Run("stops on a permanent error after retries", func(t *testing.T) {
+		errs := []error{transient, permanent}
+
+		err := retryWithBackoff(t.Context(), backoff, "create", func(context.Context) error {
+			err := errs[0]
+			errs = errs[1:]
+
+			return err
+		})
+
+		var retryErr *RetryError
+
+		require.ErrorAs(t, err, &retryErr)
+		require.Equal(t, []error{transient, permanent}, retryErr.Attempts)
+		require.True(t, apierrors.IsBadRequest(err))
+	})
+
+	t.Run("honors context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+
+		err := retryWithBackoff(ctx, retryBackoff{attempts: 5, initial: time.Hour, factor: 1, max: time.Hour}, "create",
+			func(context.Context) error {
+				cancel()
+
+				return transient
+			},
+		)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Contains(t, err.Error(), "create failed after 1 attempts")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		calls := 0
+		c := &EnvtestContainer{noRetries: true}
+
+		err := c.retry(t.Context(), "create", func(context.Context) error {
+			calls++
+
+			return transient
+		})
+		require.Same(t, transient, err)
+		require.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,38 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnvtestContainerRetriesDuringAPIServerRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	// Kill the API server, the entrypoint brings it back up within a few seconds
+	code, _, err := c.Exec(ctx, []string{"sh", "-c", "kill -9 $(cat /tmp/envtest/apiserver.pid)"})
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+
+	// Creating the test group binding hits the restart window and has to be retried
+	cl, err := c.ClientForTest(t)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "after-restart", Namespace: "default"}}
+	require.NoError(t, cl.Create(ctx, cm))
+}
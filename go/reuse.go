@@ -0,0 +1,79 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// containerNamePattern matches the container names Docker accepts
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// checkReuse rejects options WithReuse cannot honour, as they tie the container to the process that created it
+func (c *config) checkReuse() error {
+	if c.reuseName == "" {
+		return nil
+	}
+
+	if !containerNamePattern.MatchString(c.reuseName) {
+		return fmt.Errorf("invalid container name %q of WithReuse", c.reuseName)
+	}
+
+	switch {
+	case c.auditWebhook:
+		return errors.New("WithReuse cannot be combined with WithAuditWebhook, " +
+			"its server runs in the creating process")
+	case len(c.hostAccessPorts) > 0:
+		return errors.New("WithReuse cannot be combined with ports of WithHostAccess, " +
+			"they are forwarded to the creating process")
+	case len(c.webhookPaths) > 0 && c.webhookTarget == "":
+		return errors.New("WithReuse requires WithWebhookTarget for WithWebhooks, " +
+			"the host port is picked by the creating process")
+	}
+
+	return nil
+}
+
+// matchesKubernetesVersion returns whether the server version is the requested one, compared on the components
+// it specifies, so "1.35" matches any 1.35 patch release
+func matchesKubernetesVersion(requested, server string) (bool, error) {
+	want, err := utilversion.ParseGeneric(requested)
+	if err != nil {
+		return false, fmt.Errorf("invalid Kubernetes version %q: %w", requested, err)
+	}
+
+	got, err := utilversion.ParseGeneric(server)
+	if err != nil {
+		return false, fmt.Errorf("invalid server version %q: %w", server, err)
+	}
+
+	components, gotComponents := want.Components(), got.Components()
+
+	return slices.Equal(components, gotComponents[:min(len(components), len(gotComponents))]), nil
+}
+
+// checkReusedVersion fails if the container runs another Kubernetes version than requested,
+// e.g. when it was created by a package asking for another one under the same WithReuse name
+func (c *EnvtestContainer) checkReusedVersion(ctx context.Context) error {
+	info, err := c.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	ok, err := matchesKubernetesVersion(c.kubernetesVersion, info.GitVersion)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("reused container %s runs Kubernetes %s, not the requested %s: "+
+			"remove it or pick another name for WithReuse",
+			c.reuseName, info.GitVersion, c.kubernetesVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesKubernetesVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		server    string
+		want      bool
+	}{
+		{"1.35.0", "v1.35.0", true},
+		{"1.35", "v1.35.2", true},
+		{"1.35.0", "v1.35.2", false},
+		{"1.34.1", "v1.35.0", false},
+		{"1.35.0", "v1.35.0+k3s1", true},
+	}
+
+	for _, tt := range tests {
+		got, err := matchesKubernetesVersion(tt.requested, tt.server)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, "%s against %s", tt.requested, tt.server)
+	}
+
+	_, err := matchesKubernetesVersion("latest", "v1.35.0")
+	require.ErrorContains(t, err, `invalid Kubernetes version "latest"`)
+}
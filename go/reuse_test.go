@@ -0,0 +1,58 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerWithReuse(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	name := "envtest-reuse-" + utilrand.String(5)
+
+	first, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithReuse(name))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(first)
+		require.NoError(t, err)
+	}()
+
+	cl, err := first.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, cl.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: metav1.NamespaceDefault},
+	}))
+
+	// Another package running Run with the same name gets the same container
+	second, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithReuse(name))...)
+	require.NoError(t, err)
+	require.Equal(t, first.GetContainerID(), second.GetContainerID())
+
+	firstKubeconfig, err := first.Kubeconfig(ctx)
+	require.NoError(t, err)
+
+	secondKubeconfig, err := second.Kubeconfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, firstKubeconfig, secondKubeconfig)
+
+	cl, err = second.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, cl.Get(ctx, client.ObjectKey{Name: "shared", Namespace: metav1.NamespaceDefault}, &corev1.ConfigMap{}))
+
+	// A package asking for another version cannot share it
+	_, err = envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithKubernetesVersion("1.31.0"), envtest.WithReuse(name))...)
+	require.ErrorContains(t, err, "not the requested 1.31.0")
+}
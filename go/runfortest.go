@@ -0,0 +1,85 @@
+package envtest
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// runForTestTimeout bounds the startup of RunForTest for tests without a deadline
+	runForTestTimeout = 5 * time.Minute
+
+	// runForTestCleanupTimeout bounds dumping the logs and terminating the container of RunForTest
+	runForTestCleanupTimeout = time.Minute
+)
+
+// startupContext returns the context RunForTest starts the container with: the one of the test,
+// bounded by its deadline or, without one, by runForTestTimeout
+func startupContext(t testing.TB) (context.Context, context.CancelFunc) {
+	// testing.TB has no Deadline, which *testing.T has
+	if tt, ok := t.(interface{ Deadline() (time.Time, bool) }); ok {
+		if deadline, ok := tt.Deadline(); ok {
+			return context.WithDeadline(t.Context(), deadline)
+		}
+	}
+
+	return context.WithTimeout(t.Context(), runForTestTimeout)
+}
+
+// RunForTest starts an envtest container for the test like Run, failing the test if it does not start,
+// and terminates it when the test and its subtests finish. The container logs are written to the test output
+// if the test failed. A container of WithReuse is left running for the other packages sharing it.
+func RunForTest(t testing.TB, opts ...Option) *EnvtestContainer {
+	t.Helper()
+
+	ctx, cancel := startupContext(t)
+	defer cancel()
+
+	c, err := Run(ctx, opts...)
+	if err != nil {
+		t.Fatalf("failed to start envtest container: %v", err)
+	}
+
+	t.Cleanup(func() {
+		// The context of the test is canceled by now
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runForTestCleanupTimeout)
+		defer cancel()
+
+		if t.Failed() {
+			c.logContainerLogs(ctx, t)
+		}
+
+		if c.reuseName != "" {
+			return
+		}
+
+		err := testcontainers.TerminateContainer(c, testcontainers.StopContext(ctx))
+		if err != nil {
+			t.Errorf("failed to terminate envtest container: %v", err)
+		}
+	})
+
+	return c
+}
+
+// logContainerLogs writes the logs of the container to the test output
+func (c *EnvtestContainer) logContainerLogs(ctx context.Context, t testing.TB) {
+	logs, err := c.Logs(ctx)
+	if err != nil {
+		t.Logf("failed to read envtest container logs: %v", err)
+
+		return
+	}
+	defer logs.Close()
+
+	content, err := io.ReadAll(logs)
+	if err != nil {
+		t.Logf("failed to read envtest container logs: %v", err)
+	}
+
+	t.Logf("envtest container logs:\n%s", content)
+}
@@ -0,0 +1,64 @@
+package envtest
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fatalTB records the failure of a test instead of reporting it
+type fatalTB struct {
+	testing.TB
+
+	fatal string
+}
+
+func (t *fatalTB) Fatalf(format string, args ...any) {
+	t.fatal = fmt.Sprintf(format, args...)
+
+	runtime.Goexit()
+}
+
+func TestRunForTestFailsTest(t *testing.T) {
+	tb := &fatalTB{TB: t}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		RunForTest(tb, WithEtcdInMemory(), WithEtcdDataVolume("envtest-etcd"))
+		t.Error("RunForTest returned despite invalid options")
+	}()
+
+	<-done
+
+	require.Equal(t, "failed to start envtest container: "+
+		"WithEtcdInMemory and WithEtcdDataVolume both mount the etcd data dir, use one of them", tb.fatal)
+}
+
+// noDeadlineTB is a test without the Deadline of *testing.T
+type noDeadlineTB struct {
+	testing.TB
+}
+
+func TestStartupContext(t *testing.T) {
+	ctx, cancel := startupContext(noDeadlineTB{TB: t})
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(runForTestTimeout), deadline, time.Second)
+
+	ctx, cancel = startupContext(t)
+	defer cancel()
+
+	deadline, ok = ctx.Deadline()
+	require.True(t, ok)
+
+	if testDeadline, ok := t.Deadline(); ok {
+		require.Equal(t, testDeadline, deadline)
+	}
+}
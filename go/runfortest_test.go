@@ -0,0 +1,55 @@
+package envtest_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// failedTB is a failed test collecting its logs and cleanups
+type failedTB struct {
+	testing.TB
+
+	logs     []string
+	cleanups []func()
+}
+
+func (t *failedTB) Failed() bool { return true }
+
+func (t *failedTB) Logf(format string, args ...any) {
+	t.logs = append(t.logs, format)
+	t.TB.Logf(format, args...)
+}
+
+func (t *failedTB) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+
+func TestRunForTest(t *testing.T) {
+	c := envtest.RunForTest(t, getEnvtestOptions()...)
+
+	cl, err := c.GetClient(t.Context(), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, cl.Get(t.Context(), client.ObjectKey{Name: metav1.NamespaceDefault}, &corev1.Namespace{}))
+}
+
+func TestRunForTestDumpsLogs(t *testing.T) {
+	tb := &failedTB{TB: t}
+
+	c := envtest.RunForTest(tb, getEnvtestOptions()...)
+	require.Len(t, tb.cleanups, 1)
+
+	tb.cleanups[0]()
+
+	require.True(t, slices.ContainsFunc(tb.logs, func(log string) bool {
+		return strings.HasPrefix(log, "envtest container logs:")
+	}))
+
+	_, err := c.State(t.Context())
+	require.Error(t, err, "the container should be terminated")
+}
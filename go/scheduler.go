@@ -0,0 +1,4 @@
+package envtest
+
+// schedulerHealthz is the health check kube-scheduler serves inside the container
+const schedulerHealthz = "https://127.0.0.1:10259/healthz"
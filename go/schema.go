@@ -0,0 +1,149 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// schemaAssertConfig holds the configuration for the schema assertions
+type schemaAssertConfig struct {
+	persist bool
+}
+
+// SchemaAssertOption is a functional option for configuring AssertRejectedBySchema and AssertAcceptedBySchema
+type SchemaAssertOption func(*schemaAssertConfig)
+
+// Persist sends the request for real instead of as a server-side dry run, so an accepted object is stored
+func Persist() SchemaAssertOption {
+	return func(c *schemaAssertConfig) {
+		c.persist = true
+	}
+}
+
+// AssertRejectedBySchema asserts that creating the object (or updating it, when it has a resourceVersion)
+// is rejected as Invalid or BadRequest with a cause for fieldPath (e.g. "spec.replicas") whose message
+// contains msgSubstring. Both OpenAPI schema violations and x-kubernetes-validations (CEL) rule errors
+// are reported as causes. An empty fieldPath matches any field. The request is a dry run unless Persist is set.
+func AssertRejectedBySchema(
+	t testing.TB,
+	ctx context.Context,
+	c client.Client,
+	obj client.Object,
+	fieldPath, msgSubstring string,
+	opts ...SchemaAssertOption,
+) {
+	t.Helper()
+
+	err := submit(ctx, c, obj, opts)
+
+	if msg := checkSchemaRejection(err, fieldPath, msgSubstring); msg != "" {
+		t.Errorf("%s", msg)
+	}
+}
+
+// AssertAcceptedBySchema asserts that creating the object (or updating it, when it has a resourceVersion)
+// passes validation. The request is a dry run unless Persist is set.
+func AssertAcceptedBySchema(
+	t testing.TB,
+	ctx context.Context,
+	c client.Client,
+	obj client.Object,
+	opts ...SchemaAssertOption,
+) {
+	t.Helper()
+
+	if err := submit(ctx, c, obj, opts); err != nil {
+		t.Errorf("expected object to be accepted, got: %v%s", err, formatCauses(statusCauses(err)))
+	}
+}
+
+// submit creates or updates a copy of the object
+func submit(
+	ctx context.Context,
+	c client.Client,
+	obj client.Object,
+	opts []SchemaAssertOption,
+) error {
+	cfg := &schemaAssertConfig{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	probe, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	var dryRun []string
+	if !cfg.persist {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	if probe.GetResourceVersion() == "" {
+		return c.Create(ctx, probe, &client.CreateOptions{DryRun: dryRun})
+	}
+
+	return c.Update(ctx, probe, &client.UpdateOptions{DryRun: dryRun})
+}
+
+// checkSchemaRejection describes why err is not a validation error for the field, or returns "" if it is
+func checkSchemaRejection(err error, fieldPath, msgSubstring string) string {
+	if err == nil {
+		return "expected object to be rejected by schema, but it was accepted"
+	}
+
+	if !apierrors.IsInvalid(err) && !apierrors.IsBadRequest(err) {
+		return fmt.Sprintf(
+			"expected Invalid or BadRequest error, got reason %q: %v",
+			apierrors.ReasonForError(err),
+			err,
+		)
+	}
+
+	causes := statusCauses(err)
+
+	for _, cause := range causes {
+		if (fieldPath == "" || cause.Field == fieldPath) &&
+			strings.Contains(cause.Message, msgSubstring) {
+			return ""
+		}
+	}
+
+	// BadRequest errors (e.g. undecodable objects) may come without causes
+	if len(causes) == 0 && strings.Contains(err.Error(), fieldPath) &&
+		strings.Contains(err.Error(), msgSubstring) {
+		return ""
+	}
+
+	return fmt.Sprintf("expected a cause for field %q with message containing %q, got: %v%s",
+		fieldPath, msgSubstring, err, formatCauses(causes))
+}
+
+// statusCauses returns the causes of an API status error
+func statusCauses(err error) []metav1.StatusCause {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+
+	return status.Status().Details.Causes
+}
+
+// formatCauses lists causes for failure messages
+func formatCauses(causes []metav1.StatusCause) string {
+	var sb strings.Builder
+
+	for _, cause := range causes {
+		fmt.Fprintf(&sb, "\n  %s: %s", cause.Field, cause.Message)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,94 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestCheckSchemaRejection(t *testing.T) {
+	gk := schema.GroupKind{Group: "schema.example.com", Kind: "Widget"}
+
+	invalid := apierrors.NewInvalid(gk, "widget", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "replicas"), 20, "should be less than or equal to 10"),
+		field.Invalid(field.NewPath("spec"), "object", "minReplicas must not exceed maxReplicas"),
+	})
+
+	tests := []struct {
+		name      string
+		err       error
+		fieldPath string
+		msg       string
+		wantMsg   string
+	}{
+		{
+			name:      "schema violation",
+			err:       invalid,
+			fieldPath: "spec.replicas",
+			msg:       "less than or equal to 10",
+		},
+		{
+			name:      "CEL rule",
+			err:       invalid,
+			fieldPath: "spec",
+			msg:       "must not exceed",
+		},
+		{
+			name: "any field",
+			err:  invalid,
+			msg:  "must not exceed",
+		},
+		{
+			name:      "bad request without causes",
+			err:       apierrors.NewBadRequest(`strict decoding error: unknown field "spec.colour"`),
+			fieldPath: "spec.colour",
+			msg:       "unknown field",
+		},
+		{
+			name:    "accepted",
+			wantMsg: "but it was accepted",
+		},
+		{
+			name:    "other error",
+			err:     apierrors.NewForbidden(schema.GroupResource{Resource: "widgets"}, "widget", nil),
+			wantMsg: `got reason "Forbidden"`,
+		},
+		{
+			name:      "message on another field",
+			err:       invalid,
+			fieldPath: "spec.replicas",
+			msg:       "must not exceed",
+			wantMsg:   "\n  spec.replicas: Invalid value: 20: should be less than or equal to 10",
+		},
+		{
+			name:      "missing field",
+			err:       invalid,
+			fieldPath: "spec.size",
+			msg:       "",
+			wantMsg:   `expected a cause for field "spec.size"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := checkSchemaRejection(tt.err, tt.fieldPath, tt.msg)
+
+			if tt.wantMsg == "" {
+				require.Empty(t, msg)
+			} else {
+				require.Contains(t, msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestPersist(t *testing.T) {
+	cfg := &schemaAssertConfig{}
+
+	Persist()(cfg)
+
+	require.True(t, cfg.persist)
+}
@@ -0,0 +1,161 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// scalerCRD returns a CRD with a structural schema and a CEL rule across its fields
+func scalerCRD() *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "scalers.schema.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "schema.example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:   "scalers",
+				Singular: "scaler",
+				Kind:     "Scaler",
+				ListKind: "ScalerList",
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:    "v1",
+				Served:  true,
+				Storage: true,
+				Schema: &apiextensionsv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"spec": {
+								Type:     "object",
+								Required: []string{"size"},
+								Properties: map[string]apiextensionsv1.JSONSchemaProps{
+									"size": {Type: "string", Enum: []apiextensionsv1.JSON{
+										{Raw: []byte(`"small"`)}, {Raw: []byte(`"large"`)},
+									}},
+									"minReplicas": {Type: "integer", Minimum: ptr.To(1.0)},
+									"maxReplicas": {Type: "integer", Maximum: ptr.To(10.0)},
+								},
+								XValidations: apiextensionsv1.ValidationRules{{
+									Rule:    "!has(self.minReplicas) || !has(self.maxReplicas) || self.minReplicas <= self.maxReplicas",
+									Message: "minReplicas must not exceed maxReplicas",
+								}},
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func scaler(name string, spec map[string]any) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	obj.SetAPIVersion("schema.example.com/v1")
+	obj.SetKind("Scaler")
+	obj.SetName(name)
+	obj.SetNamespace("default")
+
+	return obj
+}
+
+func TestEnvtestContainerSchemaAssertions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	crdClient, err := apiextensionsclientset.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = crdClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, scalerCRD(), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cl, err := client.New(cfg, client.Options{})
+	require.NoError(t, err)
+
+	valid := map[string]any{"size": "small", "minReplicas": int64(1), "maxReplicas": int64(3)}
+
+	require.Eventually(t, func() bool {
+		return cl.Create(ctx, scaler("probe", valid), client.DryRunAll) == nil
+	}, 30*time.Second, 100*time.Millisecond, "CRD was not served in time")
+
+	envtest.AssertAcceptedBySchema(t, ctx, cl, scaler("valid", valid))
+
+	tests := []struct {
+		name      string
+		spec      map[string]any
+		fieldPath string
+		msg       string
+	}{
+		{
+			name:      "missing required field",
+			spec:      map[string]any{},
+			fieldPath: "spec.size",
+			msg:       "Required value",
+		},
+		{
+			name:      "enum violation",
+			spec:      map[string]any{"size": "huge"},
+			fieldPath: "spec.size",
+			msg:       `supported values: "small", "large"`,
+		},
+		{
+			name:      "below minimum",
+			spec:      map[string]any{"size": "small", "minReplicas": int64(0)},
+			fieldPath: "spec.minReplicas",
+			msg:       "greater than or equal to 1",
+		},
+		{
+			name:      "above maximum",
+			spec:      map[string]any{"size": "small", "maxReplicas": int64(11)},
+			fieldPath: "spec.maxReplicas",
+			msg:       "less than or equal to 10",
+		},
+		{
+			name:      "CEL rule",
+			spec:      map[string]any{"size": "large", "minReplicas": int64(5), "maxReplicas": int64(2)},
+			fieldPath: "spec",
+			msg:       "minReplicas must not exceed maxReplicas",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envtest.AssertRejectedBySchema(t, ctx, cl, scaler("invalid", tt.spec), tt.fieldPath, tt.msg)
+		})
+	}
+
+	// Dry runs leave nothing behind, Persist stores the object
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("schema.example.com/v1")
+	list.SetKind("ScalerList")
+
+	require.NoError(t, cl.List(ctx, list))
+	require.Empty(t, list.Items)
+
+	envtest.AssertAcceptedBySchema(t, ctx, cl, scaler("stored", valid), envtest.Persist())
+
+	require.NoError(t, cl.List(ctx, list))
+	require.Len(t, list.Items, 1)
+}
@@ -0,0 +1,109 @@
+package envtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/keyutil"
+)
+
+const (
+	// DefaultServiceAccountIssuer is the issuer of ServiceAccount tokens unless WithServiceAccountIssuer is given
+	DefaultServiceAccountIssuer = "https://kubernetes.default.svc"
+
+	// ServiceAccountSigningKeyPath is the path to the signing key of WithServiceAccountSigningKey inside the container
+	ServiceAccountSigningKeyPath = "/etc/envtest/service-account.key"
+)
+
+// checkServiceAccounts validates the ServiceAccount token options before the container starts
+func (c *config) checkServiceAccounts() error {
+	if c.saIssuer != "" {
+		u, err := url.Parse(c.saIssuer)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf(
+				"invalid service account issuer %q, expected an absolute URL",
+				c.saIssuer,
+			)
+		}
+	}
+
+	if c.saSigningKey != nil {
+		if err := checkServiceAccountSigningKey(c.saSigningKey); err != nil {
+			return err
+		}
+	}
+
+	for _, audience := range c.apiAudiences {
+		if audience == "" {
+			return errors.New("API audiences cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+// checkServiceAccountSigningKey checks that the PEM key is an RSA or ECDSA private key kube-apiserver can sign with
+func checkServiceAccountSigningKey(pemKey []byte) error {
+	key, err := keyutil.ParsePrivateKeyPEM(pemKey)
+	if err != nil {
+		return fmt.Errorf("invalid service account signing key: %w", err)
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		return nil
+	default:
+		return fmt.Errorf("service account signing key is a %T, expected an RSA or ECDSA key", key)
+	}
+}
+
+// GetServiceAccountToken mints a token of the ServiceAccount via the TokenRequest API, like a projected
+// ServiceAccount token volume. The token is valid for the audiences, the API audiences (by default the issuer)
+// if none are given, and expires after ttl, the API server default of 1h if zero. kube-apiserver rejects
+// ttls below 10m.
+func (c *EnvtestContainer) GetServiceAccountToken(
+	ctx context.Context,
+	namespace, name string,
+	audiences []string,
+	ttl time.Duration,
+) (string, error) {
+	clientset, err := c.clientset(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{Audiences: audiences},
+	}
+	if ttl > 0 {
+		seconds := int64(ttl / time.Second)
+		req.Spec.ExpirationSeconds = &seconds
+	}
+
+	var token *authenticationv1.TokenRequest
+
+	err = c.retry(ctx, "request service account token", func(ctx context.Context) error {
+		token, err = clientset.CoreV1().
+			ServiceAccounts(namespace).
+			CreateToken(ctx, name, req, metav1.CreateOptions{})
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf(
+			"failed to request a token of service account %s/%s: %w",
+			namespace,
+			name,
+			err,
+		)
+	}
+
+	return token.Status.Token, nil
+}
@@ -0,0 +1,78 @@
+package envtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestWithServiceAccountOptions(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	require.NoError(t, err)
+
+	cfg := &config{}
+
+	WithServiceAccountIssuer("https://issuer.example.com")(cfg)
+	WithServiceAccountSigningKey(keyPEM)(cfg)
+	WithAPIAudiences("vault", "https://issuer.example.com")(cfg)
+
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--api-audiences=vault,https://issuer.example.com"}, cfg.apiServerArgs())
+
+	WithAPIServerArg("service-account-issuer", "https://other.example.com")(cfg)
+	require.ErrorContains(t, cfg.checkAPIServerFlags(), "WithServiceAccountIssuer")
+}
+
+func TestCheckServiceAccounts(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	for _, tt := range []struct {
+		name string
+		opt  Option
+		err  string
+	}{
+		{
+			name: "relative issuer",
+			opt:  WithServiceAccountIssuer("kubernetes.default.svc"),
+			err:  `invalid service account issuer "kubernetes.default.svc", expected an absolute URL`,
+		},
+		{
+			name: "not a key",
+			opt:  WithServiceAccountSigningKey([]byte("not a key")),
+			err:  "invalid service account signing key: data does not contain a valid RSA or ECDSA private key",
+		},
+		{
+			name: "ed25519 key",
+			opt:  WithServiceAccountSigningKey(edPEM),
+			err:  "service account signing key is a ed25519.PrivateKey, expected an RSA or ECDSA key",
+		},
+		{
+			name: "empty audience",
+			opt:  WithAPIAudiences("vault", ""),
+			err:  "API audiences cannot be empty",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{}
+			tt.opt(cfg)
+
+			require.EqualError(t, cfg.checkAPIServerFlags(), tt.err)
+		})
+	}
+}
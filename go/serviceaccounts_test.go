@@ -0,0 +1,91 @@
+package envtest_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestEnvtestContainerServiceAccountTokens(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	require.NoError(t, err)
+
+	const issuer = "https://issuer.envtest.example.com"
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithServiceAccountIssuer(issuer),
+		envtest.WithServiceAccountSigningKey(keyPEM),
+		envtest.WithAPIAudiences(issuer, "vault"),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default"}}
+	_, err = clientset.CoreV1().ServiceAccounts("default").Create(ctx, sa, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	token, err := c.GetServiceAccountToken(ctx, "default", "workload", []string{"vault"}, 15*time.Minute)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	// The token is signed with the configured key
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Audience []string `json:"aud"`
+		Subject  string   `json:"sub"`
+		IssuedAt int64    `json:"iat"`
+		Expiry   int64    `json:"exp"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+
+	require.Equal(t, issuer, claims.Issuer)
+	require.Equal(t, []string{"vault"}, claims.Audience)
+	require.Equal(t, "system:serviceaccount:default:workload", claims.Subject)
+	require.Equal(t, int64((15 * time.Minute).Seconds()), claims.Expiry-claims.IssuedAt)
+
+	// Tokens cannot be requested for ServiceAccounts that do not exist
+	_, err = c.GetServiceAccountToken(ctx, "default", "missing", nil, 0)
+	require.ErrorContains(t, err, "default/missing")
+}
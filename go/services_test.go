@@ -0,0 +1,53 @@
+package envtest_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnvtestContainerWithServiceRanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithServiceClusterIPRange("10.96.0.0/16"),
+		envtest.WithServiceNodePortRange("31000-31100"),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{{Port: 80}},
+		},
+	}
+	require.NoError(t, cl.Create(ctx, svc))
+
+	clusterIP, err := netip.ParseAddr(svc.Spec.ClusterIP)
+	require.NoError(t, err)
+	require.True(t, netip.MustParsePrefix("10.96.0.0/16").Contains(clusterIP), "%s is outside 10.96.0.0/16", clusterIP)
+
+	require.GreaterOrEqual(t, svc.Spec.Ports[0].NodePort, int32(31000))
+	require.LessOrEqual(t, svc.Spec.Ports[0].NodePort, int32(31100))
+
+	// Invalid ranges fail before a container is started
+	_, err = envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithServiceClusterIPRange("10.96.0.0/33"))...)
+	require.ErrorContains(t, err, "invalid service cluster IP range")
+}
@@ -0,0 +1,274 @@
+package envtest
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// errNotAcquired is returned by Release for containers not acquired or released already
+var errNotAcquired = errors.New("envtest container was not acquired or is released already")
+
+// sharedEntry is a container shared by the acquirers of the same options
+type sharedEntry struct {
+	refs      int
+	container *EnvtestContainer
+	err       error
+	// started is closed once the container started, or failed to
+	started chan struct{}
+}
+
+// sharedContainers counts the references to the containers of Acquire
+type sharedContainers struct {
+	mu        sync.Mutex
+	entries   map[string]*sharedEntry
+	run       func(ctx context.Context, opts ...Option) (*EnvtestContainer, error)
+	terminate func(c *EnvtestContainer) error
+}
+
+// shared holds the containers of Acquire and TestMainWrapper
+var shared = newSharedContainers(
+	Run,
+	func(c *EnvtestContainer) error { return testcontainers.TerminateContainer(c) },
+)
+
+func newSharedContainers(
+	run func(ctx context.Context, opts ...Option) (*EnvtestContainer, error),
+	terminate func(c *EnvtestContainer) error,
+) *sharedContainers {
+	return &sharedContainers{entries: map[string]*sharedEntry{}, run: run, terminate: terminate}
+}
+
+// Acquire returns the container shared by the callers passing the same options, starting it like Run for the first
+// one, e.g. for the tests of a package to share one container, set up by TestMainWrapper. Options are compared
+// by value, so different Kubernetes versions or flags get different containers, except functions, log consumers
+// and file systems, compared by identity. Every Acquire has to be paired with a Release, the last one terminates
+// the container. It is safe for parallel tests, which share the cluster state too, see NewTestNamespace.
+func Acquire(ctx context.Context, opts ...Option) (*EnvtestContainer, error) {
+	return shared.acquire(ctx, opts...)
+}
+
+// Release drops the reference of an Acquire to the container, terminating it if it was the last one
+func (c *EnvtestContainer) Release() error {
+	return shared.release(c)
+}
+
+// TestMainWrapper holds a container of Acquire for the opts around m.Run, so it is started once for the package
+// and the tests calling Acquire with the same opts share it, and returns the exit code, e.g.
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(envtest.TestMainWrapper(m, envtest.WithCRDs("config/crd")))
+//	}
+func TestMainWrapper(m *testing.M, opts ...Option) int {
+	ctx, cancel := context.WithTimeout(context.Background(), runForTestTimeout)
+	defer cancel()
+
+	c, err := Acquire(ctx, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start envtest container: %v\n", err)
+
+		return 1
+	}
+
+	code := m.Run()
+
+	if err := c.Release(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to terminate envtest container: %v\n", err)
+
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func (s *sharedContainers) acquire(ctx context.Context, opts ...Option) (*EnvtestContainer, error) {
+	key := optionsFingerprint(opts)
+
+	s.mu.Lock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &sharedEntry{started: make(chan struct{})}
+		s.entries[key] = entry
+	}
+
+	entry.refs++
+	s.mu.Unlock()
+
+	if !ok {
+		c, err := s.run(ctx, opts...)
+		if err == nil {
+			c.sharedKey = key
+		}
+
+		s.mu.Lock()
+		entry.container, entry.err = c, err
+
+		// A failed start is retried by the next Acquire
+		if err != nil {
+			delete(s.entries, key)
+		}
+
+		s.mu.Unlock()
+		close(entry.started)
+	}
+
+	select {
+	case <-entry.started:
+	case <-ctx.Done():
+		// The container keeps starting for the other acquirers
+		s.drop(key, entry)
+
+		return nil, fmt.Errorf("failed to acquire envtest container: %w", ctx.Err())
+	}
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	return entry.container, nil
+}
+
+// drop releases a reference to an entry still starting, after its container started
+func (s *sharedContainers) drop(key string, entry *sharedEntry) {
+	go func() {
+		<-entry.started
+
+		if entry.err == nil {
+			_ = s.releaseEntry(key, entry)
+		}
+	}()
+}
+
+func (s *sharedContainers) release(c *EnvtestContainer) error {
+	s.mu.Lock()
+	entry, ok := s.entries[c.sharedKey]
+	s.mu.Unlock()
+
+	if c.sharedKey == "" || !ok || entry.container != c {
+		return errNotAcquired
+	}
+
+	return s.releaseEntry(c.sharedKey, entry)
+}
+
+// releaseEntry drops a reference to the entry, terminating its container if it was the last one
+func (s *sharedContainers) releaseEntry(key string, entry *sharedEntry) error {
+	s.mu.Lock()
+
+	if s.entries[key] != entry || entry.refs == 0 {
+		s.mu.Unlock()
+
+		return errNotAcquired
+	}
+
+	entry.refs--
+
+	last := entry.refs == 0
+	if last {
+		delete(s.entries, key)
+	}
+
+	s.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+
+	return s.terminate(entry.container)
+}
+
+// optionsFingerprint identifies the configuration the options produce
+func optionsFingerprint(opts []Option) string {
+	cfg := &config{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	h := sha256.New()
+	writeFingerprint(h, reflect.ValueOf(cfg).Elem())
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeFingerprint writes the value, following pointers and sorting map keys. Values behind interfaces,
+// e.g. log consumers, and functions are written by type and identity, as they are not owned by the config.
+func writeFingerprint(w io.Writer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			_, _ = io.WriteString(w, "nil")
+
+			return
+		}
+
+		writeFingerprint(w, v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			_, _ = io.WriteString(w, "nil")
+
+			return
+		}
+
+		elem := v.Elem()
+		if elem.Kind() == reflect.Pointer || elem.Kind() == reflect.Func ||
+			elem.Kind() == reflect.Map {
+			_, _ = fmt.Fprintf(w, "%s@%x", elem.Type(), elem.Pointer())
+		} else {
+			_, _ = fmt.Fprintf(w, "%s(%v)", elem.Type(), elem)
+		}
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		_, _ = fmt.Fprintf(w, "%s@%x", v.Type(), v.Pointer())
+	case reflect.Struct:
+		_, _ = io.WriteString(w, "{")
+
+		for i := range v.NumField() {
+			_, _ = fmt.Fprintf(w, "%s:", v.Type().Field(i).Name)
+			writeFingerprint(w, v.Field(i))
+			_, _ = io.WriteString(w, ",")
+		}
+
+		_, _ = io.WriteString(w, "}")
+	case reflect.Slice, reflect.Array:
+		_, _ = io.WriteString(w, "[")
+
+		for i := range v.Len() {
+			writeFingerprint(w, v.Index(i))
+			_, _ = io.WriteString(w, ",")
+		}
+
+		_, _ = io.WriteString(w, "]")
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return cmp.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		})
+
+		_, _ = io.WriteString(w, "map[")
+
+		for _, key := range keys {
+			writeFingerprint(w, key)
+			_, _ = io.WriteString(w, ":")
+			writeFingerprint(w, v.MapIndex(key))
+			_, _ = io.WriteString(w, ",")
+		}
+
+		_, _ = io.WriteString(w, "]")
+	default:
+		_, _ = fmt.Fprintf(w, "%v", v)
+	}
+}
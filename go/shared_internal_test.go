@@ -0,0 +1,184 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSharedContainers returns a registry starting and terminating fake containers, counting both
+func fakeSharedContainers(runErr error) (*sharedContainers, *atomic.Int32, *atomic.Int32) {
+	var runs, terminations atomic.Int32
+
+	s := newSharedContainers(
+		func(_ context.Context, opts ...Option) (*EnvtestContainer, error) {
+			runs.Add(1)
+			// Give the other acquirers time to pile up while the container starts
+			time.Sleep(50 * time.Millisecond)
+
+			if runErr != nil {
+				return nil, runErr
+			}
+
+			cfg := &config{}
+			for _, opt := range opts {
+				opt(cfg)
+			}
+
+			return &EnvtestContainer{kubernetesVersion: cfg.kubernetesVersion}, nil
+		},
+		func(*EnvtestContainer) error {
+			terminations.Add(1)
+
+			return nil
+		},
+	)
+
+	return s, &runs, &terminations
+}
+
+func TestSharedContainersConcurrentAcquire(t *testing.T) {
+	s, runs, terminations := fakeSharedContainers(nil)
+
+	const acquirers = 16
+
+	containers := make([]*EnvtestContainer, acquirers)
+
+	var wg sync.WaitGroup
+
+	for i := range acquirers {
+		wg.Go(func() {
+			c, err := s.acquire(t.Context(), WithKubernetesVersion("1.34.0"))
+			if err != nil {
+				t.Error(err)
+			}
+
+			containers[i] = c
+		})
+	}
+
+	wg.Wait()
+
+	require.Equal(t, int32(1), runs.Load())
+
+	for _, c := range containers {
+		require.Same(t, containers[0], c)
+	}
+
+	for _, c := range containers[1:] {
+		wg.Go(func() {
+			if err := s.release(c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	wg.Wait()
+	require.Zero(t, terminations.Load())
+
+	require.NoError(t, s.release(containers[0]))
+	require.Equal(t, int32(1), terminations.Load())
+	require.ErrorIs(t, s.release(containers[0]), errNotAcquired)
+
+	// The next acquirer starts a new container
+	c, err := s.acquire(t.Context(), WithKubernetesVersion("1.34.0"))
+	require.NoError(t, err)
+	require.NotSame(t, containers[0], c)
+	require.Equal(t, int32(2), runs.Load())
+}
+
+func TestSharedContainersPerOptions(t *testing.T) {
+	s, runs, _ := fakeSharedContainers(nil)
+
+	older, err := s.acquire(t.Context(), WithKubernetesVersion("1.34.0"))
+	require.NoError(t, err)
+
+	newer, err := s.acquire(t.Context(), WithKubernetesVersion("1.35.0"))
+	require.NoError(t, err)
+
+	require.NotSame(t, older, newer)
+	require.Equal(t, "1.34.0", older.KubernetesVersion())
+	require.Equal(t, "1.35.0", newer.KubernetesVersion())
+	require.Equal(t, int32(2), runs.Load())
+
+	require.ErrorIs(t, s.release(&EnvtestContainer{}), errNotAcquired)
+}
+
+func TestSharedContainersFailedStart(t *testing.T) {
+	s, runs, _ := fakeSharedContainers(errors.New("no docker"))
+
+	var wg sync.WaitGroup
+
+	for range 4 {
+		wg.Go(func() {
+			_, err := s.acquire(t.Context())
+			if err == nil || err.Error() != "no docker" {
+				t.Errorf("expected the start error, got %v", err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	// Acquirers arriving after the failure start again
+	_, err := s.acquire(t.Context())
+	require.EqualError(t, err, "no docker")
+	require.Equal(t, int32(2), runs.Load())
+}
+
+func TestSharedContainersAcquireCanceled(t *testing.T) {
+	s, _, terminations := fakeSharedContainers(nil)
+
+	ready := make(chan *EnvtestContainer)
+
+	go func() {
+		c, err := s.acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+
+		ready <- c
+	}()
+
+	// Waits for the container started by the acquirer above, and gives up
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := s.acquire(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	c := <-ready
+
+	// The reference of the canceled acquirer is dropped once the container started
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return s.entries[c.sharedKey].refs == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, s.release(c))
+	require.Equal(t, int32(1), terminations.Load())
+}
+
+func TestOptionsFingerprint(t *testing.T) {
+	base := []Option{WithKubernetesVersion("1.35.0"), WithAPIServerFlags(map[string]string{"v": "2"})}
+
+	require.Equal(t, optionsFingerprint(base), optionsFingerprint(base))
+	require.Equal(t,
+		optionsFingerprint([]Option{WithFeatureGates(map[string]bool{"A": true, "B": false})}),
+		optionsFingerprint([]Option{WithFeatureGates(map[string]bool{"B": false, "A": true})}))
+
+	require.NotEqual(t, optionsFingerprint(base), optionsFingerprint([]Option{WithKubernetesVersion("1.34.0")}))
+	require.NotEqual(t, optionsFingerprint(base), optionsFingerprint(append(base, WithAPIServerVerbosity(4))))
+	require.NotEqual(t,
+		optionsFingerprint([]Option{WithLogConsumers(TestLogConsumer(t))}),
+		optionsFingerprint([]Option{WithLogConsumers(TestLogConsumer(t))}))
+}
@@ -0,0 +1,54 @@
+package envtest_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	const acquirers = 4
+
+	containers := make([]*envtest.EnvtestContainer, acquirers)
+
+	var wg sync.WaitGroup
+
+	for i := range acquirers {
+		wg.Go(func() {
+			c, err := envtest.Acquire(ctx, getEnvtestOptions()...)
+			if err != nil {
+				t.Error(err)
+			}
+
+			containers[i] = c
+		})
+	}
+
+	wg.Wait()
+
+	for _, c := range containers {
+		require.NotNil(t, c)
+		require.Equal(t, containers[0].GetContainerID(), c.GetContainerID())
+	}
+
+	for _, c := range containers[1:] {
+		require.NoError(t, c.Release())
+	}
+
+	// Released by all but one acquirer, the container is still running
+	state, err := containers[0].State(ctx)
+	require.NoError(t, err)
+	require.True(t, state.Running)
+
+	require.NoError(t, containers[0].Release())
+
+	_, err = containers[0].State(ctx)
+	require.Error(t, err, "the container should be terminated")
+}
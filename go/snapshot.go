@@ -0,0 +1,141 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+)
+
+const (
+	// etcdDataDir is the data dir of etcd inside the container
+	etcdDataDir = "/tmp/envtest/etcd"
+
+	// etcdPIDPath is where the entrypoint records the PID of the running etcd
+	etcdPIDPath = "/tmp/envtest/etcd.pid"
+
+	// etcdHoldPath keeps the entrypoint from restarting an exited etcd while it exists
+	etcdHoldPath = "/tmp/envtest/etcd.hold"
+
+	// snapshotsDir holds the copies of the etcd data dir taken by Snapshot
+	snapshotsDir = "/tmp/envtest/snapshots"
+)
+
+// snapshotIDPattern matches the IDs Snapshot returns, so Restore never touches paths outside snapshotsDir
+var snapshotIDPattern = regexp.MustCompile(`^snapshot-\d+$`)
+
+// SnapshotID identifies a snapshot of the cluster state taken by Snapshot
+type SnapshotID string
+
+// stoppedStorageScript stops kube-apiserver and etcd, runs the command, then lets the entrypoint restart etcd
+// and, once it is healthy, kube-apiserver. The hold files are removed whatever happens, so a failed command
+// does not leave the cluster down.
+const stoppedStorageScript = `set -e
+trap 'rm -f %[1]s %[2]s' EXIT
+
+wait_exit() {
+    for _ in $(seq 300); do
+        kill -0 "$1" 2>/dev/null || return 0
+        sleep 0.1
+    done
+    echo "process $1 did not exit" >&2
+    return 1
+}
+
+touch %[1]s %[2]s
+
+APISERVER_PID="$(cat %[3]s)"
+kill -KILL "${APISERVER_PID}" 2>/dev/null || true
+wait_exit "${APISERVER_PID}"
+
+ETCD_PID="$(cat %[4]s)"
+kill -TERM "${ETCD_PID}" 2>/dev/null || true
+wait_exit "${ETCD_PID}"
+
+%[5]s
+
+rm -f %[2]s
+
+for _ in $(seq 300); do
+    if [ "$(cat %[4]s)" != "${ETCD_PID}" ] && curl -s %[6]s/health | grep -q true; then
+        exit 0
+    fi
+    sleep 0.1
+done
+
+echo "etcd did not become healthy" >&2
+exit 1
+`
+
+// Snapshot saves the cluster state by copying the etcd data dir inside the container, so Restore can bring it back
+// after a test, e.g. to seed a large fixture set once. etcd and the API server are stopped during the copy
+// and restarted afterwards, so clients see a brief outage.
+func (c *EnvtestContainer) Snapshot(ctx context.Context) (SnapshotID, error) {
+	c.mu.Lock()
+	c.snapshots++
+	id := SnapshotID(fmt.Sprintf("snapshot-%d", c.snapshots))
+	c.mu.Unlock()
+
+	dir := path.Join(snapshotsDir, string(id))
+	command := fmt.Sprintf("mkdir -p %s && cp -a %s %s", snapshotsDir, etcdDataDir, dir)
+
+	if err := c.withStorageStopped(ctx, command); err != nil {
+		return "", fmt.Errorf("failed to take snapshot %s: %w", id, err)
+	}
+
+	return id, nil
+}
+
+// Restore replaces the cluster state with the one saved by Snapshot, restarting etcd and the API server
+// against the restored data dir. Resource versions go back to the ones of the snapshot, so informers,
+// caches and managers started before have to be restarted; watches resuming from a newer resource version fail.
+// The snapshot is kept, so it can be restored again.
+func (c *EnvtestContainer) Restore(ctx context.Context, id SnapshotID) error {
+	if !snapshotIDPattern.MatchString(string(id)) {
+		return fmt.Errorf("invalid snapshot ID %q", id)
+	}
+
+	dir := path.Join(snapshotsDir, string(id))
+
+	if _, err := c.execOutput(ctx, "test", "-d", dir); err != nil {
+		return fmt.Errorf("snapshot %s not found: %w", id, err)
+	}
+
+	// The data dir is emptied rather than removed, as it may be the mount point of WithEtcdDataVolume
+	command := fmt.Sprintf(
+		"find %s -mindepth 1 -delete && cp -a %s/. %s",
+		etcdDataDir,
+		dir,
+		etcdDataDir,
+	)
+
+	if err := c.withStorageStopped(ctx, command); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// withStorageStopped runs the shell command with kube-apiserver and etcd stopped, and waits for both to be back
+func (c *EnvtestContainer) withStorageStopped(ctx context.Context, command string) error {
+	oldPID, err := c.apiServerPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf(
+		stoppedStorageScript,
+		apiServerHoldPath,
+		etcdHoldPath,
+		apiServerPIDPath,
+		etcdPIDPath,
+		command,
+		etcdEndpoint,
+	)
+
+	if _, err := c.execOutput(ctx, "bash", "-c", script); err != nil {
+		return err
+	}
+
+	return c.waitForAPIServerRestart(ctx, oldPID)
+}
@@ -0,0 +1,22 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreRejectsInvalidSnapshotID(t *testing.T) {
+	c := &EnvtestContainer{}
+
+	for _, id := range []SnapshotID{"", "snapshot-", "../etcd", "snapshot-1; rm -rf /", "snapshot-1/.."} {
+		err := c.Restore(t.Context(), id)
+		require.ErrorContains(t, err, "invalid snapshot ID", id)
+	}
+}
+
+func TestSnapshotIDPattern(t *testing.T) {
+	require.True(t, snapshotIDPattern.MatchString("snapshot-1"))
+	require.True(t, snapshotIDPattern.MatchString("snapshot-42"))
+	require.False(t, snapshotIDPattern.MatchString("snapshot-1\n"))
+}
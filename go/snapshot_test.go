@@ -0,0 +1,73 @@
+package envtest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerSnapshotRestore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	id, err := c.Snapshot(ctx)
+	require.NoError(t, err)
+
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "snapshot-test"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	for i := range 5 {
+		_, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("snapshot-%d", i)},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, c.Restore(ctx, id))
+
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "snapshot-test", metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err), "namespace should be gone after restore, got %v", err)
+
+	for i := range 5 {
+		_, err := clientset.CoreV1().ConfigMaps("default").Get(ctx, fmt.Sprintf("snapshot-%d", i), metav1.GetOptions{})
+		require.True(t, apierrors.IsNotFound(err), "configmap should be gone after restore, got %v", err)
+	}
+
+	// The snapshot is kept, so it can be restored again
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "after-restore"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Restore(ctx, id))
+
+	_, err = clientset.CoreV1().ConfigMaps("default").Get(ctx, "after-restore", metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err), "configmap should be gone after restore, got %v", err)
+
+	require.Error(t, c.Restore(ctx, envtest.SnapshotID("snapshot-999")))
+}
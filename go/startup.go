@@ -0,0 +1,86 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/roma-glushko/testcontainers-envtest/go/waitk8s"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// readyLog is the line the entrypoint logs once every component is up
+const readyLog = "Envtest is ready!"
+
+// waitStrategy returns the strategy Run waits for the container to be ready with: /readyz of the API server,
+// the health of the components enabled next to it and, WithLegacyWait, the ready log line,
+// or the one of WithWaitStrategy, bounded by WithStartupTimeout
+func (c *config) waitStrategy() wait.Strategy {
+	if c.customWait != nil {
+		strategy := wait.ForAll(c.customWait)
+		if c.startupTimeout > 0 {
+			strategy = strategy.WithDeadline(c.startupTimeout)
+		}
+
+		return strategy
+	}
+
+	apiServerTimeout := waitk8s.DefaultStartupTimeout
+	logTimeout := time.Minute
+	componentTimeout := componentStartupTimeout
+
+	if c.startupTimeout > 0 {
+		apiServerTimeout = c.startupTimeout
+		logTimeout = c.startupTimeout
+		componentTimeout = c.startupTimeout
+	}
+
+	// The API server is probed with the CA of the kubeconfig the entrypoint writes once it answers
+	strategies := []wait.Strategy{
+		waitk8s.ForAPIServer(DefaultAPIServerPort+"/tcp",
+			waitk8s.WithKubeconfigFromContainer(KubeconfigPath),
+			waitk8s.WithStartupTimeout(apiServerTimeout),
+			waitk8s.WithVerboseOnFailure(),
+		),
+	}
+
+	if c.legacyWait {
+		strategies = append(strategies, wait.ForLog(readyLog).WithStartupTimeout(logTimeout))
+	}
+
+	if c.controllerManager {
+		strategies = append(
+			strategies,
+			forComponentHealthz(controllerManagerHealthz, componentTimeout),
+		)
+	}
+
+	if c.scheduler {
+		strategies = append(strategies, forComponentHealthz(schedulerHealthz, componentTimeout))
+	}
+
+	if c.kwok {
+		strategies = append(strategies, forComponentHealthz(kwokHealthz, componentTimeout))
+	}
+
+	strategy := wait.ForAll(strategies...)
+	if c.startupTimeout > 0 {
+		strategy = strategy.WithDeadline(c.startupTimeout)
+	}
+
+	return strategy
+}
+
+// startupTimeoutError points at WithStartupTimeout when the container did not become ready in time
+func startupTimeoutError(err error, timeout time.Duration) error {
+	if timeout <= 0 || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return fmt.Errorf(
+		"%w: not ready within the startup timeout of %s, see WithStartupTimeout",
+		err,
+		timeout,
+	)
+}
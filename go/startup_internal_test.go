@@ -0,0 +1,26 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartupTimeoutError(t *testing.T) {
+	timedOut := fmt.Errorf("wait until ready: %w", context.DeadlineExceeded)
+
+	err := startupTimeoutError(timedOut, 2*time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualError(t, err,
+		"wait until ready: context deadline exceeded: not ready within the startup timeout of 2s, see WithStartupTimeout")
+
+	// Without WithStartupTimeout, or for other failures, the error is kept as is
+	require.Same(t, timedOut, startupTimeoutError(timedOut, 0))
+
+	other := errors.New("image not found")
+	require.Same(t, other, startupTimeoutError(other, 2*time.Second))
+}
@@ -0,0 +1,246 @@
+package envtest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// resourceObjectsMetric is the per-resource object count exposed since Kubernetes 1.34
+	resourceObjectsMetric = "apiserver_resource_objects"
+
+	// storageObjectsMetric is the deprecated predecessor of resourceObjectsMetric
+	storageObjectsMetric = "apiserver_storage_objects"
+)
+
+// StorageStats is a snapshot of what is stored in etcd: object counts per resource
+// (as reported by the API server, refreshed roughly once a minute) and the etcd database status
+type StorageStats struct {
+	// Objects maps a resource in resource.group form (e.g. "configmaps", "deployments.apps") to its object count
+	Objects map[string]int64
+	// DBSize is the physically allocated size of the etcd database in bytes
+	DBSize int64
+	// DBSizeInUse is the logically used size of the etcd database in bytes
+	DBSizeInUse int64
+	// Revision is the current etcd revision
+	Revision int64
+}
+
+// ResourceCount is the number of stored objects of a single resource
+type ResourceCount struct {
+	Resource string
+	Count    int64
+}
+
+// Resources returns the object counts sorted by count (largest first), then by resource name
+func (s StorageStats) Resources() []ResourceCount {
+	counts := make([]ResourceCount, 0, len(s.Objects))
+
+	for resource, count := range s.Objects {
+		counts = append(counts, ResourceCount{Resource: resource, Count: count})
+	}
+
+	slices.SortFunc(counts, func(a, b ResourceCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+
+		return strings.Compare(a.Resource, b.Resource)
+	})
+
+	return counts
+}
+
+// WriteTable prints the stats as an aligned text table
+func (s StorageStats) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "RESOURCE\tOBJECTS")
+
+	for _, rc := range s.Resources() {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\n", rc.Resource, rc.Count)
+	}
+
+	_, _ = fmt.Fprintf(tw, "\netcd db size\t%d bytes (%d in use)\n", s.DBSize, s.DBSizeInUse)
+	_, _ = fmt.Fprintf(tw, "etcd revision\t%d\n", s.Revision)
+
+	return tw.Flush()
+}
+
+// String renders the stats as a table
+func (s StorageStats) String() string {
+	var sb strings.Builder
+
+	_ = s.WriteTable(&sb)
+
+	return sb.String()
+}
+
+// ResourceDelta is the change in the object count of a single resource
+type ResourceDelta struct {
+	Resource string
+	Before   int64
+	After    int64
+}
+
+// Delta returns the object count growth (negative when objects were removed)
+func (d ResourceDelta) Delta() int64 {
+	return d.After - d.Before
+}
+
+// StorageStatsDiff is the difference between two StorageStats snapshots
+type StorageStatsDiff struct {
+	// Resources holds the resources whose object count changed, largest growth first
+	Resources []ResourceDelta
+	// DBSizeDelta is the change of the etcd database size in bytes
+	DBSizeDelta int64
+	// RevisionDelta is the number of etcd revisions between the snapshots
+	RevisionDelta int64
+}
+
+// Delta returns the object count change of the given resource
+func (d StorageStatsDiff) Delta(resource string) int64 {
+	for _, rd := range d.Resources {
+		if rd.Resource == resource {
+			return rd.Delta()
+		}
+	}
+
+	return 0
+}
+
+// Growth returns only the resources whose object count increased
+func (d StorageStatsDiff) Growth() []ResourceDelta {
+	var growth []ResourceDelta
+
+	for _, rd := range d.Resources {
+		if rd.Delta() > 0 {
+			growth = append(growth, rd)
+		}
+	}
+
+	return growth
+}
+
+// WriteTable prints the diff as an aligned text table
+func (d StorageStatsDiff) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "RESOURCE\tBEFORE\tAFTER\tDELTA")
+
+	for _, rd := range d.Resources {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\n", rd.Resource, rd.Before, rd.After, rd.Delta())
+	}
+
+	_, _ = fmt.Fprintf(tw, "\netcd db size\t%+d bytes\n", d.DBSizeDelta)
+	_, _ = fmt.Fprintf(tw, "etcd revisions\t%+d\n", d.RevisionDelta)
+
+	return tw.Flush()
+}
+
+// String renders the diff as a table
+func (d StorageStatsDiff) String() string {
+	var sb strings.Builder
+
+	_ = d.WriteTable(&sb)
+
+	return sb.String()
+}
+
+// DiffStorageStats compares two snapshots, typically taken before and after a test suite
+func DiffStorageStats(before, after StorageStats) StorageStatsDiff {
+	diff := StorageStatsDiff{
+		DBSizeDelta:   after.DBSize - before.DBSize,
+		RevisionDelta: after.Revision - before.Revision,
+	}
+
+	resources := make(map[string]struct{}, len(after.Objects))
+
+	for resource := range maps.Keys(before.Objects) {
+		resources[resource] = struct{}{}
+	}
+
+	for resource := range maps.Keys(after.Objects) {
+		resources[resource] = struct{}{}
+	}
+
+	for resource := range resources {
+		rd := ResourceDelta{
+			Resource: resource,
+			Before:   before.Objects[resource],
+			After:    after.Objects[resource],
+		}
+		if rd.Delta() != 0 {
+			diff.Resources = append(diff.Resources, rd)
+		}
+	}
+
+	slices.SortFunc(diff.Resources, func(a, b ResourceDelta) int {
+		if a.Delta() != b.Delta() {
+			return cmp.Compare(b.Delta(), a.Delta())
+		}
+
+		return strings.Compare(a.Resource, b.Resource)
+	})
+
+	return diff
+}
+
+// GetStorageStats returns per-resource object counts and the etcd database status
+func (c *EnvtestContainer) GetStorageStats(ctx context.Context) (StorageStats, error) {
+	snapshot, err := c.GetMetrics(ctx)
+	if err != nil {
+		return StorageStats{}, fmt.Errorf("failed to get storage stats: %w", err)
+	}
+
+	status, err := c.etcdStatus(ctx)
+	if err != nil {
+		return StorageStats{}, fmt.Errorf("failed to get storage stats: %w", err)
+	}
+
+	return StorageStats{
+		Objects:     storageObjects(snapshot),
+		DBSize:      status.DBSize,
+		DBSizeInUse: status.DBSizeInUse,
+		Revision:    status.Revision,
+	}, nil
+}
+
+// storageObjects extracts per-resource object counts, preferring the newer metric when it is exposed.
+// Resources the API server has not counted yet are reported as -1 and are skipped.
+func storageObjects(snapshot MetricsSnapshot) map[string]int64 {
+	objects := make(map[string]int64)
+
+	if snapshot.Has(resourceObjectsMetric) {
+		for _, sample := range snapshot[resourceObjectsMetric] {
+			if sample.Value < 0 {
+				continue
+			}
+
+			resource := sample.Labels["resource"]
+			if group := sample.Labels["group"]; group != "" {
+				resource += "." + group
+			}
+
+			objects[resource] = int64(sample.Value)
+		}
+
+		return objects
+	}
+
+	for _, sample := range snapshot[storageObjectsMetric] {
+		if sample.Value < 0 {
+			continue
+		}
+
+		objects[sample.Labels["resource"]] = int64(sample.Value)
+	}
+
+	return objects
+}
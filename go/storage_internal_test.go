@@ -0,0 +1,112 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageObjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics string
+		want    map[string]int64
+	}{
+		{
+			name: "resource objects metric",
+			metrics: `# TYPE apiserver_resource_objects gauge
+apiserver_resource_objects{group="",resource="configmaps"} 3
+apiserver_resource_objects{group="apps",resource="deployments"} 1
+apiserver_resource_objects{group="",resource="secrets"} -1
+# TYPE apiserver_storage_objects gauge
+apiserver_storage_objects{resource="configmaps"} 42
+`,
+			want: map[string]int64{"configmaps": 3, "deployments.apps": 1},
+		},
+		{
+			name: "legacy storage objects metric",
+			metrics: `# TYPE apiserver_storage_objects gauge
+apiserver_storage_objects{resource="configmaps"} 2
+apiserver_storage_objects{resource="deployments.apps"} 4
+`,
+			want: map[string]int64{"configmaps": 2, "deployments.apps": 4},
+		},
+		{
+			name:    "no metrics",
+			metrics: "",
+			want:    map[string]int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := parseMetrics(strings.NewReader(tt.metrics))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, storageObjects(snapshot))
+		})
+	}
+}
+
+func TestStorageStatsResources(t *testing.T) {
+	stats := StorageStats{Objects: map[string]int64{"secrets": 2, "configmaps": 5, "namespaces": 2}}
+
+	require.Equal(t, []ResourceCount{
+		{Resource: "configmaps", Count: 5},
+		{Resource: "namespaces", Count: 2},
+		{Resource: "secrets", Count: 2},
+	}, stats.Resources())
+}
+
+func TestDiffStorageStats(t *testing.T) {
+	before := StorageStats{
+		Objects:  map[string]int64{"configmaps": 1, "secrets": 4, "namespaces": 4},
+		DBSize:   1000,
+		Revision: 10,
+	}
+	after := StorageStats{
+		Objects:  map[string]int64{"configmaps": 6, "secrets": 3, "namespaces": 4, "leases.coordination.k8s.io": 2},
+		DBSize:   1500,
+		Revision: 25,
+	}
+
+	diff := DiffStorageStats(before, after)
+
+	require.Equal(t, []ResourceDelta{
+		{Resource: "configmaps", Before: 1, After: 6},
+		{Resource: "leases.coordination.k8s.io", Before: 0, After: 2},
+		{Resource: "secrets", Before: 4, After: 3},
+	}, diff.Resources)
+	require.Equal(t, int64(5), diff.Delta("configmaps"))
+	require.Equal(t, int64(-1), diff.Delta("secrets"))
+	require.Equal(t, int64(0), diff.Delta("namespaces"))
+	require.Len(t, diff.Growth(), 2)
+	require.Equal(t, int64(500), diff.DBSizeDelta)
+	require.Equal(t, int64(15), diff.RevisionDelta)
+}
+
+func TestStorageStatsDiffTable(t *testing.T) {
+	diff := DiffStorageStats(
+		StorageStats{Objects: map[string]int64{"configmaps": 1}},
+		StorageStats{Objects: map[string]int64{"configmaps": 3}, DBSize: 4096, Revision: 2},
+	)
+
+	require.Equal(t, `RESOURCE    BEFORE  AFTER  DELTA
+configmaps  1       3      +2
+
+etcd db size    +4096 bytes
+etcd revisions  +2
+`, diff.String())
+}
+
+func TestStorageStatsTable(t *testing.T) {
+	stats := StorageStats{Objects: map[string]int64{"configmaps": 3, "namespaces": 4}, DBSize: 8192, DBSizeInUse: 4096, Revision: 7}
+
+	require.Equal(t, `RESOURCE    OBJECTS
+namespaces  4
+configmaps  3
+
+etcd db size   8192 bytes (4096 in use)
+etcd revision  7
+`, stats.String())
+}
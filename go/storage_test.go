@@ -0,0 +1,78 @@
+package envtest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestEnvtestContainerStorageStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// Object counts are refreshed periodically, so wait until configmaps are counted at all
+	var before envtest.StorageStats
+
+	require.Eventually(t, func() bool {
+		before, err = c.GetStorageStats(ctx)
+		if err != nil {
+			return false
+		}
+
+		_, ok := before.Objects["configmaps"]
+
+		return ok
+	}, 2*time.Minute, time.Second)
+
+	require.Positive(t, before.DBSize)
+	require.Positive(t, before.Revision)
+
+	const created = 5
+
+	for i := range created {
+		_, err := clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("storage-stats-%d", i)},
+			Data:       map[string]string{"key": "value"},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	var diff envtest.StorageStatsDiff
+
+	require.Eventually(t, func() bool {
+		after, err := c.GetStorageStats(ctx)
+		if err != nil {
+			return false
+		}
+
+		diff = envtest.DiffStorageStats(before, after)
+
+		return diff.Delta("configmaps") == created
+	}, 2*time.Minute, time.Second)
+
+	require.GreaterOrEqual(t, diff.RevisionDelta, int64(created))
+
+	t.Logf("Storage growth:\n%s", diff)
+}
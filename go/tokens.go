@@ -0,0 +1,90 @@
+package envtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// TokenAuthFilePath is the path to the static token file of WithTokenAuth inside the container
+const TokenAuthFilePath = "/etc/envtest/token-auth.csv"
+
+// TokenUser is a user authenticated by a static bearer token, see WithTokenAuth
+type TokenUser struct {
+	// Token is the bearer token of the user
+	Token string
+	// Username is the name requests with the token are attributed to
+	Username string
+	// UID is the unique ID of the user, the username if empty
+	UID string
+	// Groups are the groups of the user besides system:authenticated
+	Groups []string
+}
+
+// tokenAuthFile renders the users as the CSV of --token-auth-file: token,user,uid,"group1,group2"
+func tokenAuthFile(users []TokenUser) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	seen := make(map[string]bool, len(users))
+
+	for i, user := range users {
+		switch {
+		case strings.TrimSpace(user.Token) == "" || strings.TrimSpace(user.Username) == "":
+			return nil, fmt.Errorf("token user %d has no token or username", i)
+		case strings.ContainsAny(user.Token, ",\" \t\r\n"):
+			return nil, fmt.Errorf(
+				"token of user %s contains commas, quotes or whitespace",
+				user.Username,
+			)
+		case seen[user.Token]:
+			return nil, fmt.Errorf("token of user %s is given twice", user.Username)
+		}
+
+		seen[user.Token] = true
+
+		uid := user.UID
+		if uid == "" {
+			uid = user.Username
+		}
+
+		record := []string{user.Token, user.Username, uid}
+		if len(user.Groups) > 0 {
+			record = append(record, strings.Join(user.Groups, ","))
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write token auth file: %w", err)
+		}
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// KubeconfigForToken returns a kubeconfig YAML authenticating with the given bearer token instead of
+// the admin certificate, e.g. a token of WithTokenAuth or of a ServiceAccount
+func (c *EnvtestContainer) KubeconfigForToken(ctx context.Context, token string) (string, error) {
+	apiConfig, err := c.GetKubeconfigObject(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for name := range apiConfig.AuthInfos {
+		apiConfig.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: token}
+	}
+
+	kubeconfig, err := clientcmd.Write(*apiConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+
+	return string(kubeconfig), nil
+}
@@ -0,0 +1,42 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAuthFile(t *testing.T) {
+	csv, err := tokenAuthFile([]TokenUser{
+		{Token: "cli-token", Username: "cli", UID: "1001", Groups: []string{"developers", "system:masters"}},
+		{Token: "bot-token", Username: "bot"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "cli-token,cli,1001,\"developers,system:masters\"\nbot-token,bot,bot\n", string(csv))
+
+	tests := []struct {
+		name  string
+		users []TokenUser
+		err   string
+	}{
+		{name: "no token", users: []TokenUser{{Username: "cli"}}, err: "token user 0 has no token or username"},
+		{name: "no username", users: []TokenUser{{Token: "cli-token"}}, err: "token user 0 has no token or username"},
+		{
+			name:  "comma",
+			users: []TokenUser{{Token: "cli,token", Username: "cli"}},
+			err:   "token of user cli contains commas, quotes or whitespace",
+		},
+		{
+			name:  "duplicate",
+			users: []TokenUser{{Token: "cli-token", Username: "cli"}, {Token: "cli-token", Username: "bot"}},
+			err:   "token of user bot is given twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokenAuthFile(tt.users)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
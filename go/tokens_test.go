@@ -0,0 +1,68 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestEnvtestContainerWithTokenAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithTokenAuth([]envtest.TokenUser{
+		{Token: "cli-token", Username: "cli-user", UID: "1001", Groups: []string{"developers", "testers"}},
+		{Token: "bot-token", Username: "bot"},
+	}))...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	kubeconfig, err := c.KubeconfigForToken(ctx, "cli-token")
+	require.NoError(t, err)
+	require.NotContains(t, kubeconfig, "client-certificate-data")
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	user := review.Status.UserInfo
+	require.Equal(t, "cli-user", user.Username)
+	require.Equal(t, "1001", user.UID)
+	require.ElementsMatch(t, []string{"developers", "testers", "system:authenticated"}, user.Groups)
+
+	// Token users are subject to RBAC like any other
+	_, err = clientset.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}}, metav1.CreateOptions{})
+	require.True(t, apierrors.IsForbidden(err), "expected Forbidden, got %v", err)
+
+	// Unknown tokens are rejected
+	kubeconfig, err = c.KubeconfigForToken(ctx, "unknown-token")
+	require.NoError(t, err)
+
+	cfg, err = clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	require.NoError(t, err)
+
+	clientset, err = kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	_, err = clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	require.True(t, apierrors.IsUnauthorized(err), "expected Unauthorized, got %v", err)
+}
@@ -0,0 +1,94 @@
+package envtest
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/testcontainers/testcontainers-go"
+	apiserverv1beta1 "k8s.io/apiserver/pkg/apis/apiserver/v1beta1"
+	tracingapi "k8s.io/component-base/tracing/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// TracingConfigPath is the path to the tracing configuration of WithTracing inside the container
+	TracingConfigPath = "/etc/envtest/tracing-config.yaml"
+
+	// DefaultOTLPPort is the OTLP gRPC port of the collector WithTracing reports to by default
+	DefaultOTLPPort = 4317
+
+	// maxSamplingRatePerMillion samples every span
+	maxSamplingRatePerMillion = 1_000_000
+
+	// tracingMinVersion is the first Kubernetes version serving the v1beta1 TracingConfiguration
+	tracingMinVersion = "1.27.0"
+)
+
+// tracingOptions configures the OTLP exporter of the API server, see WithTracing
+type tracingOptions struct {
+	endpoint     string
+	samplingRate int
+}
+
+// newTracingOptions returns the tracing options, the endpoint defaulting to DefaultOTLPPort on the test host
+func newTracingOptions(endpoint string, samplingRatePerMillion int) *tracingOptions {
+	if endpoint == "" {
+		endpoint = net.JoinHostPort(testcontainers.HostInternal, strconv.Itoa(DefaultOTLPPort))
+	}
+
+	return &tracingOptions{endpoint: endpoint, samplingRate: samplingRatePerMillion}
+}
+
+// check validates the endpoint and sampling rate before the container starts
+func (t *tracingOptions) check() error {
+	if _, port, err := net.SplitHostPort(t.endpoint); err != nil || port == "" {
+		return fmt.Errorf("invalid OTLP endpoint %q, expected host:port", t.endpoint)
+	}
+
+	if t.samplingRate < 0 || t.samplingRate > maxSamplingRatePerMillion {
+		return fmt.Errorf(
+			"tracing sampling rate %d is out of range 0-%d per million",
+			t.samplingRate,
+			maxSamplingRatePerMillion,
+		)
+	}
+
+	return nil
+}
+
+// hostPort returns the port of an endpoint on the test host, which has to be forwarded, or 0
+func (t *tracingOptions) hostPort() int {
+	host, port, err := net.SplitHostPort(t.endpoint)
+	if err != nil || host != testcontainers.HostInternal {
+		return 0
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// configFile renders the TracingConfiguration of --tracing-config-file
+func (t *tracingOptions) configFile() ([]byte, error) {
+	rate := int32(t.samplingRate)
+
+	config := &apiserverv1beta1.TracingConfiguration{
+		TracingConfiguration: tracingapi.TracingConfiguration{
+			Endpoint:               &t.endpoint,
+			SamplingRatePerMillion: &rate,
+		},
+	}
+	config.APIVersion = apiserverv1beta1.ConfigSchemeGroupVersion.String()
+	config.Kind = "TracingConfiguration"
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tracing configuration: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package envtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiserverv1beta1 "k8s.io/apiserver/pkg/apis/apiserver/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWithTracing(t *testing.T) {
+	cfg := &config{kubernetesVersion: DefaultKubernetesVersion}
+
+	WithTracing("", 1_000_000)(cfg)
+
+	require.True(t, cfg.hostAccessEnabled())
+	require.Equal(t, []int{DefaultOTLPPort}, cfg.forwardedHostPorts())
+	require.NoError(t, cfg.checkAPIServerFlags())
+	require.Equal(t, []string{"--tracing-config-file=" + TracingConfigPath}, cfg.apiServerArgs())
+
+	data, err := cfg.tracing.configFile()
+	require.NoError(t, err)
+
+	var tracingConfig apiserverv1beta1.TracingConfiguration
+	require.NoError(t, yaml.UnmarshalStrict(data, &tracingConfig))
+	require.Equal(t, "apiserver.config.k8s.io/v1beta1", tracingConfig.APIVersion)
+	require.Equal(t, "TracingConfiguration", tracingConfig.Kind)
+	require.Equal(t, "host.testcontainers.internal:4317", *tracingConfig.Endpoint)
+	require.Equal(t, int32(1_000_000), *tracingConfig.SamplingRatePerMillion)
+
+	// Collectors elsewhere do not need host access
+	cfg = &config{kubernetesVersion: DefaultKubernetesVersion}
+	WithTracing("otel-collector:4317", 100)(cfg)
+
+	require.False(t, cfg.hostAccessEnabled())
+	require.Empty(t, cfg.forwardedHostPorts())
+}
+
+func TestCheckTracing(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		version string
+		opt     Option
+		err     string
+	}{
+		{
+			name:    "endpoint without port",
+			version: DefaultKubernetesVersion,
+			opt:     WithTracing("otel-collector", 100),
+			err:     `invalid OTLP endpoint "otel-collector", expected host:port`,
+		},
+		{
+			name:    "sampling rate out of range",
+			version: DefaultKubernetesVersion,
+			opt:     WithTracing("", 1_000_001),
+			err:     "tracing sampling rate 1000001 is out of range 0-1000000 per million",
+		},
+		{
+			name:    "old Kubernetes",
+			version: "1.26.0",
+			opt:     WithTracing("", 100),
+			err:     "WithTracing requires Kubernetes 1.27.0 or later, got 1.26.0",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{kubernetesVersion: tt.version}
+			tt.opt(cfg)
+
+			require.EqualError(t, cfg.checkAPIServerFlags(), tt.err)
+		})
+	}
+}
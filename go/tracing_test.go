@@ -0,0 +1,97 @@
+package envtest_test
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// otlpReceiver is a minimal OTLP gRPC trace collector recording the names of the spans it receives
+type otlpReceiver struct {
+	collectortracev1.UnimplementedTraceServiceServer
+
+	mu    sync.Mutex
+	spans []string
+}
+
+func (r *otlpReceiver) Export(
+	_ context.Context,
+	req *collectortracev1.ExportTraceServiceRequest,
+) (*collectortracev1.ExportTraceServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, resourceSpans := range req.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			for _, span := range scopeSpans.GetSpans() {
+				r.spans = append(r.spans, span.GetName())
+			}
+		}
+	}
+
+	return &collectortracev1.ExportTraceServiceResponse{}, nil
+}
+
+func (r *otlpReceiver) spanNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.spans...)
+}
+
+func TestEnvtestContainerWithTracing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	// The container reaches the receiver by the host gateway, so it listens on all interfaces
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	require.NoError(t, err)
+
+	receiver := &otlpReceiver{}
+
+	server := grpc.NewServer()
+	collectortracev1.RegisterTraceServiceServer(server, receiver)
+
+	go func() { _ = server.Serve(listener) }()
+
+	defer server.Stop()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithTracing(net.JoinHostPort(testcontainers.HostInternal, strconv.Itoa(port)), 1_000_000),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+
+	// Spans are exported in batches
+	require.Eventually(t, func() bool {
+		_, err := clientset.CoreV1().Namespaces().Get(ctx, "default", metav1.GetOptions{})
+		require.NoError(t, err)
+
+		return len(receiver.spanNames()) > 0
+	}, 30*time.Second, time.Second, "no spans received from the API server")
+
+	require.Contains(t, receiver.spanNames(), "KubernetesAPI")
+}
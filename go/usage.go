@@ -0,0 +1,333 @@
+package envtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// memoryPeakPath is the cgroup v2 peak memory file, read when the stats API does not report the peak
+	memoryPeakPath = "/sys/fs/cgroup/memory.peak"
+
+	// mebibyte converts bytes to MiB
+	mebibyte = 1 << 20
+)
+
+// UsageStats is a resource usage sample of the container
+type UsageStats struct {
+	// Timestamp is when the sample was taken
+	Timestamp time.Time
+	// CPUTotal is the CPU time consumed since the container started
+	CPUTotal time.Duration
+	// MemoryUsage is the current memory usage in bytes, excluding inactive page cache
+	MemoryUsage uint64
+	// MemoryPeak is the peak memory usage in bytes, or 0 when the engine and cgroup version do not report it
+	MemoryPeak uint64
+	// BlockRead is the number of bytes read from block devices
+	BlockRead uint64
+	// BlockWrite is the number of bytes written to block devices
+	BlockWrite uint64
+}
+
+// ResourceUsage returns the current resource usage of the container as reported by the container engine
+func (c *EnvtestContainer) ResourceUsage(ctx context.Context) (UsageStats, error) {
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return UsageStats{}, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	defer func() { _ = cli.Close() }()
+
+	return c.resourceUsage(ctx, cli)
+}
+
+// resourceUsage samples the container stats with the given client
+func (c *EnvtestContainer) resourceUsage(
+	ctx context.Context,
+	cli *testcontainers.DockerClient,
+) (UsageStats, error) {
+	resp, err := cli.ContainerStatsOneShot(ctx, c.GetContainerID())
+	if err != nil {
+		return UsageStats{}, fmt.Errorf("failed to get container stats: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	usage, err := parseUsageStats(resp.Body)
+	if err != nil {
+		return UsageStats{}, err
+	}
+
+	if usage.MemoryPeak == 0 {
+		// cgroup v2 engines do not report the peak, but the container cgroup may
+		if out, err := c.execOutput(ctx, "cat", memoryPeakPath); err == nil {
+			usage.MemoryPeak, _ = strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+		}
+	}
+
+	return usage, nil
+}
+
+// parseUsageStats decodes a stats API response. Docker and Podman differ in which fields they fill
+// and in the casing of block IO operations, so missing fields are left zero.
+func parseUsageStats(r io.Reader) (UsageStats, error) {
+	var stats container.StatsResponse
+	if err := json.NewDecoder(r).Decode(&stats); err != nil {
+		return UsageStats{}, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	usage := UsageStats{
+		Timestamp:   stats.Read,
+		CPUTotal:    time.Duration(stats.CPUStats.CPUUsage.TotalUsage),
+		MemoryUsage: stats.MemoryStats.Usage,
+		MemoryPeak:  stats.MemoryStats.MaxUsage,
+	}
+
+	if usage.Timestamp.IsZero() {
+		usage.Timestamp = time.Now()
+	}
+
+	// Page cache that can be reclaimed is not counted, the same way docker stats does
+	for _, key := range []string{"inactive_file", "total_inactive_file"} {
+		if inactive, ok := stats.MemoryStats.Stats[key]; ok && inactive < usage.MemoryUsage {
+			usage.MemoryUsage -= inactive
+
+			break
+		}
+	}
+
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			usage.BlockRead += entry.Value
+		case "write":
+			usage.BlockWrite += entry.Value
+		}
+	}
+
+	return usage, nil
+}
+
+// UsageSampler periodically samples the container resource usage until it is stopped
+// or the container is terminated
+type UsageSampler struct {
+	mu      sync.Mutex
+	samples []UsageStats
+	err     error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartUsageSampler starts sampling the container resource usage at the given interval.
+// Sampling stops when ctx is canceled, Stop is called or the container is terminated.
+func (c *EnvtestContainer) StartUsageSampler(
+	ctx context.Context,
+	interval time.Duration,
+) (*UsageSampler, error) {
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &UsageSampler{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		defer func() { _ = cli.Close() }()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			usage, err := c.resourceUsage(ctx, cli)
+			if err != nil {
+				// Errors caused by stopping are expected
+				if ctx.Err() == nil {
+					s.setErr(err)
+				}
+
+				return
+			}
+
+			s.add(usage)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	c.onTerminate(s.Stop)
+
+	return s, nil
+}
+
+// Stop stops sampling and waits for the sampler to exit. It is safe to call multiple times.
+func (s *UsageSampler) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// Done returns a channel that is closed once the sampler has stopped
+func (s *UsageSampler) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that stopped sampling early, if any
+func (s *UsageSampler) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Samples returns the samples taken so far
+func (s *UsageSampler) Samples() []UsageStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]UsageStats(nil), s.samples...)
+}
+
+// Summary summarizes the samples taken so far
+func (s *UsageSampler) Summary() UsageSummary {
+	return summarizeUsage(s.Samples())
+}
+
+// add records a sample
+func (s *UsageSampler) add(usage UsageStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samples = append(s.samples, usage)
+}
+
+// setErr records the error that stopped sampling
+func (s *UsageSampler) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// UsageRange is the min/avg/max of a sampled value
+type UsageRange struct {
+	Min float64
+	Avg float64
+	Max float64
+}
+
+// UsageSummary summarizes the samples of a UsageSampler
+type UsageSummary struct {
+	// Samples is the number of samples
+	Samples int
+	// Duration is the time between the first and the last sample
+	Duration time.Duration
+	// CPUCores is the CPU usage in cores between consecutive samples
+	CPUCores UsageRange
+	// MemoryBytes is the sampled memory usage
+	MemoryBytes UsageRange
+	// MemoryPeak is the highest reported peak memory usage in bytes
+	MemoryPeak uint64
+	// BlockRead and BlockWrite are the block IO bytes between the first and the last sample
+	BlockRead  uint64
+	BlockWrite uint64
+}
+
+// String renders the summary as a table, e.g. for t.Log or CI artifacts
+func (s UsageSummary) String() string {
+	var sb strings.Builder
+
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(tw, "samples\t%d over %s\n", s.Samples, s.Duration.Round(time.Millisecond))
+	_, _ = fmt.Fprintln(tw, "\tMIN\tAVG\tMAX")
+	_, _ = fmt.Fprintf(
+		tw,
+		"cpu (cores)\t%.3f\t%.3f\t%.3f\n",
+		s.CPUCores.Min,
+		s.CPUCores.Avg,
+		s.CPUCores.Max,
+	)
+	_, _ = fmt.Fprintf(tw, "memory (MiB)\t%.1f\t%.1f\t%.1f\n",
+		s.MemoryBytes.Min/mebibyte, s.MemoryBytes.Avg/mebibyte, s.MemoryBytes.Max/mebibyte)
+	_, _ = fmt.Fprintf(tw, "memory peak (MiB)\t%.1f\n", float64(s.MemoryPeak)/mebibyte)
+	_, _ = fmt.Fprintf(tw, "block io\t%d bytes read, %d bytes written\n", s.BlockRead, s.BlockWrite)
+
+	_ = tw.Flush()
+
+	return sb.String()
+}
+
+// summarizeUsage computes the summary of usage samples
+func summarizeUsage(samples []UsageStats) UsageSummary {
+	summary := UsageSummary{Samples: len(samples)}
+	if len(samples) == 0 {
+		return summary
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+
+	summary.Duration = last.Timestamp.Sub(first.Timestamp)
+	summary.BlockRead = last.BlockRead - min(first.BlockRead, last.BlockRead)
+	summary.BlockWrite = last.BlockWrite - min(first.BlockWrite, last.BlockWrite)
+
+	memory := make([]float64, 0, len(samples))
+	cpu := make([]float64, 0, len(samples)-1)
+
+	for i, sample := range samples {
+		memory = append(memory, float64(sample.MemoryUsage))
+		summary.MemoryPeak = max(summary.MemoryPeak, sample.MemoryPeak, sample.MemoryUsage)
+
+		if i == 0 {
+			continue
+		}
+
+		prev := samples[i-1]
+		if elapsed := sample.Timestamp.Sub(prev.Timestamp); elapsed > 0 &&
+			sample.CPUTotal >= prev.CPUTotal {
+			cpu = append(cpu, float64(sample.CPUTotal-prev.CPUTotal)/float64(elapsed))
+		}
+	}
+
+	summary.MemoryBytes = usageRange(memory)
+	summary.CPUCores = usageRange(cpu)
+
+	return summary
+}
+
+// usageRange computes the min/avg/max of the values
+func usageRange(values []float64) UsageRange {
+	if len(values) == 0 {
+		return UsageRange{}
+	}
+
+	r := UsageRange{Min: values[0], Max: values[0]}
+
+	var sum float64
+
+	for _, v := range values {
+		r.Min = min(r.Min, v)
+		r.Max = max(r.Max, v)
+		sum += v
+	}
+
+	r.Avg = sum / float64(len(values))
+
+	return r
+}
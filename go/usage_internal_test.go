@@ -0,0 +1,83 @@
+package envtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUsageStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats string
+		want  UsageStats
+	}{
+		{
+			name: "docker cgroup v2",
+			stats: `{"read":"2025-01-01T00:00:01Z","cpu_stats":{"cpu_usage":{"total_usage":1500000000}},
+"memory_stats":{"usage":104857600,"stats":{"inactive_file":4857600}},
+"blkio_stats":{"io_service_bytes_recursive":[{"major":8,"minor":0,"op":"read","value":4096},{"major":8,"minor":0,"op":"write","value":8192}]}}`,
+			want: UsageStats{
+				Timestamp:   time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC),
+				CPUTotal:    1500 * time.Millisecond,
+				MemoryUsage: 100000000,
+				BlockRead:   4096,
+				BlockWrite:  8192,
+			},
+		},
+		{
+			name: "docker cgroup v1",
+			stats: `{"read":"2025-01-01T00:00:01Z","cpu_stats":{"cpu_usage":{"total_usage":10}},
+"memory_stats":{"usage":2000,"max_usage":3000,"stats":{"total_inactive_file":500}},
+"blkio_stats":{"io_service_bytes_recursive":[{"op":"Read","value":1},{"op":"Write","value":2},{"op":"Total","value":3}]}}`,
+			want: UsageStats{
+				Timestamp:   time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC),
+				CPUTotal:    10,
+				MemoryUsage: 1500,
+				MemoryPeak:  3000,
+				BlockRead:   1,
+				BlockWrite:  2,
+			},
+		},
+		{
+			name: "podman without block io",
+			stats: `{"read":"2025-01-01T00:00:01Z","cpu_stats":{"cpu_usage":{"total_usage":10}},
+"memory_stats":{"usage":2000},"blkio_stats":{"io_service_bytes_recursive":null}}`,
+			want: UsageStats{
+				Timestamp:   time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC),
+				CPUTotal:    10,
+				MemoryUsage: 2000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUsageStats(strings.NewReader(tt.stats))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	summary := summarizeUsage([]UsageStats{
+		{Timestamp: start, CPUTotal: time.Second, MemoryUsage: 100 * mebibyte, BlockWrite: 100},
+		{Timestamp: start.Add(time.Second), CPUTotal: 1500 * time.Millisecond, MemoryUsage: 300 * mebibyte, BlockWrite: 300},
+		{Timestamp: start.Add(2 * time.Second), CPUTotal: 3500 * time.Millisecond, MemoryUsage: 200 * mebibyte, MemoryPeak: 400 * mebibyte, BlockWrite: 700},
+	})
+
+	require.Equal(t, 3, summary.Samples)
+	require.Equal(t, 2*time.Second, summary.Duration)
+	require.Equal(t, UsageRange{Min: 0.5, Avg: 1.25, Max: 2}, summary.CPUCores)
+	require.Equal(t, UsageRange{Min: 100 * mebibyte, Avg: 200 * mebibyte, Max: 300 * mebibyte}, summary.MemoryBytes)
+	require.Equal(t, uint64(400*mebibyte), summary.MemoryPeak)
+	require.Equal(t, uint64(600), summary.BlockWrite)
+	require.Contains(t, summary.String(), "memory (MiB)       100.0  200.0  300.0")
+
+	require.Equal(t, UsageSummary{}, summarizeUsage(nil))
+}
@@ -0,0 +1,64 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestEnvtestContainerResourceUsage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	terminated := false
+
+	defer func() {
+		if !terminated {
+			require.NoError(t, testcontainers.TerminateContainer(c))
+		}
+	}()
+
+	usage, err := c.ResourceUsage(ctx)
+	require.NoError(t, err)
+	require.Positive(t, usage.CPUTotal)
+	require.Positive(t, usage.MemoryUsage)
+
+	sampler, err := c.StartUsageSampler(ctx, 200*time.Millisecond)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(sampler.Samples()) >= 3
+	}, 30*time.Second, 100*time.Millisecond, "sampler did not collect samples")
+
+	summary := sampler.Summary()
+	require.GreaterOrEqual(t, summary.Samples, 3)
+	require.Positive(t, summary.MemoryBytes.Min)
+	require.Positive(t, summary.Duration)
+
+	t.Log("\n" + summary.String())
+
+	// Terminating the container stops the sampler
+	require.NoError(t, testcontainers.TerminateContainer(c))
+
+	terminated = true
+
+	select {
+	case <-sampler.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("sampler did not stop on terminate")
+	}
+
+	require.NoError(t, sampler.Err())
+
+	samples := len(sampler.Samples())
+
+	sampler.Stop()
+	require.Len(t, sampler.Samples(), samples)
+}
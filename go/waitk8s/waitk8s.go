@@ -0,0 +1,392 @@
+// Package waitk8s provides testcontainers wait strategies for containers that embed a Kubernetes API server
+package waitk8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// DefaultPath is the readiness endpoint probed by default
+	DefaultPath = "/readyz"
+
+	// DefaultStartupTimeout bounds how long the API server may take to become ready
+	DefaultStartupTimeout = 60 * time.Second
+
+	// DefaultPollInterval is the delay between two probes
+	DefaultPollInterval = 100 * time.Millisecond
+
+	// defaultServiceAccountPath is the default ServiceAccount of the default namespace
+	defaultServiceAccountPath = "/api/v1/namespaces/default/serviceaccounts/default"
+
+	// verboseTimeout bounds the verbose probe made after the API server failed to become ready
+	verboseTimeout = 5 * time.Second
+
+	// maxBodyLen caps how much of a response body is quoted in errors
+	maxBodyLen = 4096
+)
+
+var (
+	_ wait.Strategy        = (*apiServerStrategy)(nil)
+	_ wait.StrategyTimeout = (*apiServerStrategy)(nil)
+)
+
+// ProbeOption customizes the API server readiness probe
+type ProbeOption func(*apiServerStrategy)
+
+// apiServerStrategy waits until an API server answers its readiness endpoint over TLS
+type apiServerStrategy struct {
+	port           nat.Port
+	path           string
+	statusCode     int
+	timeout        time.Duration
+	pollInterval   time.Duration
+	caCert         []byte
+	caPath         string
+	kubeconfigPath string
+	insecure       bool
+	verbose        bool
+	serviceAccount bool
+}
+
+// ForAPIServer returns a strategy waiting until the API server listening on the given container port
+// answers GET /readyz with 200 over TLS. The serving certificate is verified against the CA given
+// by WithCACert, WithCAFromContainer or WithKubeconfigFromContainer, or not at all with WithInsecureSkipVerify.
+func ForAPIServer(port nat.Port, opts ...ProbeOption) wait.Strategy {
+	s := &apiServerStrategy{
+		port:         port,
+		path:         DefaultPath,
+		statusCode:   http.StatusOK,
+		timeout:      DefaultStartupTimeout,
+		pollInterval: DefaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithPath sets the endpoint to probe instead of /readyz
+func WithPath(path string) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.path = path
+	}
+}
+
+// WithStatusCode sets the status code the probed endpoint must answer with instead of 200
+func WithStatusCode(code int) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.statusCode = code
+	}
+}
+
+// WithStartupTimeout sets how long to wait for the API server to become ready
+func WithStartupTimeout(timeout time.Duration) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.timeout = timeout
+	}
+}
+
+// WithPollInterval sets the delay between two probes
+func WithPollInterval(interval time.Duration) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.pollInterval = interval
+	}
+}
+
+// WithCACert verifies the serving certificate against the given PEM-encoded CA
+func WithCACert(caPEM []byte) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.caCert = caPEM
+	}
+}
+
+// WithCAFromContainer verifies the serving certificate against the PEM-encoded CA at the given container path.
+// The file is read on every probe, so it may be generated by the container while it starts.
+func WithCAFromContainer(path string) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.caPath = path
+	}
+}
+
+// WithKubeconfigFromContainer probes with the CA and credentials of the kubeconfig at the given container path.
+// The server URL of the kubeconfig is replaced by the mapped port. Probes fail until the file exists.
+func WithKubeconfigFromContainer(path string) ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.kubeconfigPath = path
+	}
+}
+
+// WithInsecureSkipVerify skips verification of the serving certificate
+func WithInsecureSkipVerify() ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.insecure = true
+	}
+}
+
+// WithVerboseOnFailure queries the probed endpoint with ?verbose once the API server failed to become ready
+// and includes the per-check output in the returned error
+func WithVerboseOnFailure() ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.verbose = true
+	}
+}
+
+// WithDefaultServiceAccount additionally waits for the default ServiceAccount of the default namespace,
+// which images running the ServiceAccount controller create shortly after the API server is ready.
+// It needs credentials allowed to read ServiceAccounts, e.g. from WithKubeconfigFromContainer.
+func WithDefaultServiceAccount() ProbeOption {
+	return func(s *apiServerStrategy) {
+		s.serviceAccount = true
+	}
+}
+
+// Timeout returns the startup timeout of the strategy
+func (s *apiServerStrategy) Timeout() *time.Duration {
+	return &s.timeout
+}
+
+// String describes the strategy
+func (s *apiServerStrategy) String() string {
+	return fmt.Sprintf("API server on port %s to answer %s with %d", s.port, s.path, s.statusCode)
+}
+
+// WaitUntilReady probes the API server until it is ready, the container exits or the timeout expires
+func (s *apiServerStrategy) WaitUntilReady(ctx context.Context, target wait.StrategyTarget) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	for {
+		err := s.probe(ctx, target)
+		if err == nil {
+			return nil
+		}
+
+		if exitErr := exited(ctx, target); exitErr != nil {
+			return exitErr
+		}
+
+		select {
+		case <-ctx.Done():
+			return s.failure(ctx, target, err)
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// probe makes a single readiness check
+func (s *apiServerStrategy) probe(ctx context.Context, target wait.StrategyTarget) error {
+	cfg, err := s.restConfig(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	client, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	if err := get(ctx, client, cfg.Host+s.path, s.statusCode); err != nil {
+		return err
+	}
+
+	if s.serviceAccount {
+		if err := get(ctx, client, cfg.Host+defaultServiceAccountPath, http.StatusOK); err != nil {
+			return fmt.Errorf("default ServiceAccount is not available: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// failure builds the timeout error, enriched with the verbose check output when requested
+func (s *apiServerStrategy) failure(
+	ctx context.Context,
+	target wait.StrategyTarget,
+	err error,
+) error {
+	err = fmt.Errorf("API server on port %s is not ready after %s: %w", s.port, s.timeout, err)
+
+	if !s.verbose {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), verboseTimeout)
+	defer cancel()
+
+	cfg, cfgErr := s.restConfig(ctx, target)
+	if cfgErr != nil {
+		return err
+	}
+
+	client, clientErr := rest.HTTPClientFor(cfg)
+	if clientErr != nil {
+		return err
+	}
+
+	body, _, getErr := fetch(ctx, client, cfg.Host+verbosePath(s.path))
+	if getErr != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w\n%s", err, body)
+}
+
+// restConfig assembles the endpoint and TLS settings of the probe
+func (s *apiServerStrategy) restConfig(
+	ctx context.Context,
+	target wait.StrategyTarget,
+) (*rest.Config, error) {
+	host, err := target.Host(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container host: %w", err)
+	}
+
+	port, err := target.MappedPort(ctx, s.port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mapped port: %w", err)
+	}
+
+	cfg := &rest.Config{}
+
+	if s.kubeconfigPath != "" {
+		kubeconfig, err := readFile(ctx, target, s.kubeconfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err = clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", s.kubeconfigPath, err)
+		}
+	}
+
+	// The container host is not necessarily a SAN of the serving certificate (e.g. remote Docker daemons),
+	// keep verifying it for the server name of the kubeconfig
+	if u, err := url.Parse(cfg.Host); err == nil && cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+
+	cfg.Host = "https://" + net.JoinHostPort(host, port.Port())
+
+	if s.caCert != nil {
+		cfg.CAData = s.caCert
+	}
+
+	if s.caPath != "" {
+		cfg.CAFile = ""
+
+		cfg.CAData, err = readFile(ctx, target, s.caPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.insecure {
+		cfg.Insecure = true
+		cfg.CAData = nil
+		cfg.CAFile = ""
+	}
+
+	return cfg, nil
+}
+
+// exited reports an error when the container is no longer running, so the wait fails fast
+func exited(ctx context.Context, target wait.StrategyTarget) error {
+	// A failing state lookup is retried with the next probe
+	state, err := target.State(ctx)
+	if err == nil && state != nil && (state.Status == "exited" || state.Status == "dead") {
+		return fmt.Errorf(
+			"container exited with code %d before the API server became ready",
+			state.ExitCode,
+		)
+	}
+
+	return nil
+}
+
+// get requests the URL and checks the response status
+func get(ctx context.Context, client *http.Client, url string, statusCode int) error {
+	body, code, err := fetch(ctx, client, url)
+	if err != nil {
+		return err
+	}
+
+	if code != statusCode {
+		return fmt.Errorf(
+			"GET %s returned %d, expected %d: %s",
+			url,
+			code,
+			statusCode,
+			strings.TrimSpace(body),
+		)
+	}
+
+	return nil
+}
+
+// fetch requests the URL and returns the (truncated) response body and status code
+func fetch(ctx context.Context, client *http.Client, url string) (string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyLen))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read response of %s: %w", url, err)
+	}
+
+	return string(body), resp.StatusCode, nil
+}
+
+// readFile copies a file out of the container
+func readFile(ctx context.Context, target wait.StrategyTarget, path string) ([]byte, error) {
+	reader, err := target.CopyFileFromContainer(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container: %w", path, err)
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New(path + " is empty")
+	}
+
+	return data, nil
+}
+
+// verbosePath adds the verbose query parameter to a health check path
+func verbosePath(path string) string {
+	if strings.Contains(path, "?") {
+		return path + "&verbose"
+	}
+
+	return path + "?verbose"
+}
@@ -0,0 +1,319 @@
+package waitk8s_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/roma-glushko/testcontainers-envtest/go/waitk8s"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/exec"
+)
+
+const apiServerPort = nat.Port("6443/tcp")
+
+// fakeTarget maps the API server port to an httptest server and serves files from memory
+type fakeTarget struct {
+	host   string
+	port   string
+	files  map[string][]byte
+	status string
+}
+
+func newFakeTarget(t *testing.T, srv *httptest.Server) *fakeTarget {
+	t.Helper()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	return &fakeTarget{host: "127.0.0.1", port: port, files: map[string][]byte{}, status: "running"}
+}
+
+func (f *fakeTarget) Host(context.Context) (string, error) {
+	return f.host, nil
+}
+
+func (f *fakeTarget) Inspect(context.Context) (*container.InspectResponse, error) {
+	return &container.InspectResponse{}, nil
+}
+
+func (f *fakeTarget) Ports(context.Context) (nat.PortMap, error) {
+	return nat.PortMap{}, nil
+}
+
+func (f *fakeTarget) MappedPort(_ context.Context, port nat.Port) (nat.Port, error) {
+	if port != apiServerPort {
+		return "", errors.New("port not exposed")
+	}
+
+	return nat.NewPort("tcp", f.port)
+}
+
+func (f *fakeTarget) Logs(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (f *fakeTarget) Exec(context.Context, []string, ...exec.ProcessOption) (int, io.Reader, error) {
+	return 0, strings.NewReader(""), nil
+}
+
+func (f *fakeTarget) State(context.Context) (*container.State, error) {
+	return &container.State{Status: f.status, ExitCode: 1}, nil
+}
+
+func (f *fakeTarget) CopyFileFromContainer(_ context.Context, path string) (io.ReadCloser, error) {
+	data, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// caPEM returns the PEM-encoded certificate of the httptest server
+func caPEM(srv *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+func TestForAPIServerReady(t *testing.T) {
+	var probes atomic.Int32
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" || probes.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	strategy := waitk8s.ForAPIServer(apiServerPort, waitk8s.WithCACert(caPEM(srv)), waitk8s.WithPollInterval(time.Millisecond))
+
+	require.NoError(t, strategy.WaitUntilReady(t.Context(), newFakeTarget(t, srv)))
+	require.Equal(t, int32(3), probes.Load())
+}
+
+func TestForAPIServerVerifiesCA(t *testing.T) {
+	pki, err := certs.NewWebhookPKI()
+	require.NoError(t, err)
+
+	other, err := certs.NewWebhookPKI()
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	srv.TLS, err = pki.TLSConfig()
+	require.NoError(t, err)
+
+	srv.StartTLS()
+	defer srv.Close()
+
+	target := newFakeTarget(t, srv)
+
+	err = waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithCACert(other.CACert),
+		waitk8s.WithStartupTimeout(200*time.Millisecond),
+	).WaitUntilReady(t.Context(), target)
+	require.ErrorContains(t, err, "certificate")
+
+	err = waitk8s.ForAPIServer(apiServerPort, waitk8s.WithCACert(pki.CACert)).WaitUntilReady(t.Context(), target)
+	require.NoError(t, err)
+
+	err = waitk8s.ForAPIServer(apiServerPort, waitk8s.WithInsecureSkipVerify()).WaitUntilReady(t.Context(), target)
+	require.NoError(t, err)
+}
+
+func TestForAPIServerCAFromContainer(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	target := newFakeTarget(t, srv)
+
+	// The CA is not generated yet
+	err := waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithCAFromContainer("/certs/ca.crt"),
+		waitk8s.WithStartupTimeout(100*time.Millisecond),
+	).WaitUntilReady(t.Context(), target)
+	require.ErrorContains(t, err, "/certs/ca.crt")
+
+	target.files["/certs/ca.crt"] = caPEM(srv)
+
+	err = waitk8s.ForAPIServer(apiServerPort, waitk8s.WithCAFromContainer("/certs/ca.crt")).
+		WaitUntilReady(t.Context(), target)
+	require.NoError(t, err)
+}
+
+func TestForAPIServerCustomPathAndStatus(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/livez" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	strategy := waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithInsecureSkipVerify(),
+		waitk8s.WithPath("/livez"),
+		waitk8s.WithStatusCode(http.StatusAccepted),
+	)
+
+	require.NoError(t, strategy.WaitUntilReady(t.Context(), newFakeTarget(t, srv)))
+}
+
+func TestForAPIServerVerboseOnFailure(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		if r.URL.Query().Has("verbose") {
+			_, _ = w.Write([]byte("[+]ping ok\n[-]etcd failed: reason withheld\nreadyz check failed"))
+
+			return
+		}
+
+		_, _ = w.Write([]byte("readyz check failed"))
+	}))
+	defer srv.Close()
+
+	target := newFakeTarget(t, srv)
+
+	err := waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithInsecureSkipVerify(),
+		waitk8s.WithStartupTimeout(200*time.Millisecond),
+	).WaitUntilReady(t.Context(), target)
+	require.ErrorContains(t, err, "returned 500")
+	require.NotContains(t, err.Error(), "[-]etcd failed")
+
+	err = waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithInsecureSkipVerify(),
+		waitk8s.WithStartupTimeout(200*time.Millisecond),
+		waitk8s.WithVerboseOnFailure(),
+	).WaitUntilReady(t.Context(), target)
+	require.ErrorContains(t, err, "[-]etcd failed")
+}
+
+func TestForAPIServerKubeconfigAndServiceAccount(t *testing.T) {
+	var serviceAccountLookups atomic.Int32
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/readyz":
+			_, _ = w.Write([]byte("ok"))
+		case "/api/v1/namespaces/default/serviceaccounts/default":
+			// The ServiceAccount controller creates the account a bit after readiness
+			if serviceAccountLookups.Add(1) < 3 {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			_, _ = w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	target := newFakeTarget(t, srv)
+	// The certificate is verified for the kubeconfig server, a SAN of the httptest certificate
+	target.files["/tmp/kubeconfig"] = testKubeconfig(srv, "example.com")
+
+	strategy := waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithKubeconfigFromContainer("/tmp/kubeconfig"),
+		waitk8s.WithDefaultServiceAccount(),
+		waitk8s.WithPollInterval(time.Millisecond),
+	)
+
+	require.NoError(t, strategy.WaitUntilReady(t.Context(), target))
+	require.Equal(t, int32(3), serviceAccountLookups.Load())
+}
+
+func TestForAPIServerVerifiesKubeconfigServerName(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// The container host is not a SAN of the httptest certificate, while the kubeconfig server is
+	target := newFakeTarget(t, srv)
+	target.host = "localhost"
+	target.files["/tmp/kubeconfig"] = testKubeconfig(srv, "example.com")
+
+	err := waitk8s.ForAPIServer(apiServerPort, waitk8s.WithKubeconfigFromContainer("/tmp/kubeconfig")).
+		WaitUntilReady(t.Context(), target)
+	require.NoError(t, err)
+
+	// Without a kubeconfig server name the container host is verified
+	err = waitk8s.ForAPIServer(apiServerPort,
+		waitk8s.WithCACert(caPEM(srv)),
+		waitk8s.WithStartupTimeout(200*time.Millisecond),
+	).WaitUntilReady(t.Context(), target)
+	require.ErrorContains(t, err, "certificate")
+}
+
+// testKubeconfig returns a kubeconfig trusting the httptest server under the given server host, with a token user
+func testKubeconfig(srv *httptest.Server, serverHost string) []byte {
+	return []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: ` + base64.StdEncoding.EncodeToString(caPEM(srv)) + `
+    server: https://` + serverHost + `:6443
+  name: envtest
+contexts:
+- context:
+    cluster: envtest
+    user: admin
+  name: envtest
+current-context: envtest
+users:
+- name: admin
+  user:
+    token: secret
+`)
+}
+
+func TestForAPIServerContainerExited(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	target := newFakeTarget(t, srv)
+	target.status = "exited"
+
+	start := time.Now()
+
+	err := waitk8s.ForAPIServer(apiServerPort, waitk8s.WithInsecureSkipVerify()).WaitUntilReady(t.Context(), target)
+	require.ErrorContains(t, err, "exited with code 1")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
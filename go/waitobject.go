@@ -0,0 +1,164 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultWaitInterval is the delay between two Gets of WaitForObject and WaitForDeletion
+	DefaultWaitInterval = 100 * time.Millisecond
+
+	// DefaultWaitTimeout bounds how long WaitForObject and WaitForDeletion wait
+	DefaultWaitTimeout = 30 * time.Second
+)
+
+// waitConfig holds the configuration for WaitForObject and WaitForDeletion
+type waitConfig struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// WaitOption is a functional option for configuring WaitForObject and WaitForDeletion
+type WaitOption func(*waitConfig)
+
+// WithWaitInterval sets the delay between two Gets (default: DefaultWaitInterval)
+func WithWaitInterval(interval time.Duration) WaitOption {
+	return func(c *waitConfig) {
+		c.interval = interval
+	}
+}
+
+// WithWaitTimeout sets how long to wait (default: DefaultWaitTimeout)
+func WithWaitTimeout(timeout time.Duration) WaitOption {
+	return func(c *waitConfig) {
+		c.timeout = timeout
+	}
+}
+
+// newWaitConfig returns the wait configuration with the given options
+func newWaitConfig(opts ...WaitOption) *waitConfig {
+	cfg := &waitConfig{interval: DefaultWaitInterval, timeout: DefaultWaitTimeout}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// WaitForObject polls the object with the given key into obj until cond returns true for it, e.g. until
+// a controller has reconciled it. An object that does not exist yet is waited for. When the wait times out,
+// the error contains the last observed object as YAML.
+func WaitForObject(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	obj client.Object,
+	cond func(client.Object) bool,
+	opts ...WaitOption,
+) error {
+	cfg := newWaitConfig(opts...)
+
+	var (
+		found   bool
+		lastErr error
+	)
+
+	err := wait.PollUntilContextTimeout(
+		ctx,
+		cfg.interval,
+		cfg.timeout,
+		true,
+		func(ctx context.Context) (bool, error) {
+			lastErr = c.Get(ctx, key, obj)
+
+			switch {
+			case apierrors.IsNotFound(lastErr):
+				found = false
+
+				return false, nil
+			case lastErr != nil:
+				// Keep polling through transient API errors
+				return false, nil
+			}
+
+			found = true
+
+			return cond(obj), nil
+		},
+	)
+	if err == nil {
+		return nil
+	}
+
+	if !found {
+		return fmt.Errorf(
+			"timed out after %s waiting for %T %s, last observed: %w",
+			cfg.timeout,
+			obj,
+			key,
+			lastErr,
+		)
+	}
+
+	return fmt.Errorf(
+		"timed out after %s waiting for %T %s, last observed:\n%s",
+		cfg.timeout,
+		obj,
+		key,
+		objectYAML(obj),
+	)
+}
+
+// WaitForDeletion polls the object with the given key into obj until it is not found, e.g. until its finalizers
+// have run. When the wait times out, the error contains the last observed object as YAML.
+func WaitForDeletion(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	obj client.Object,
+	opts ...WaitOption,
+) error {
+	cfg := newWaitConfig(opts...)
+
+	err := wait.PollUntilContextTimeout(
+		ctx,
+		cfg.interval,
+		cfg.timeout,
+		true,
+		func(ctx context.Context) (bool, error) {
+			// Transient API errors are polled through like for WaitForObject
+			return apierrors.IsNotFound(c.Get(ctx, key, obj)), nil
+		},
+	)
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("timed out after %s waiting for deletion of %T %s, last observed:\n%s",
+		cfg.timeout, obj, key, objectYAML(obj))
+}
+
+// objectYAML renders an object for error messages, without its managed fields
+func objectYAML(obj client.Object) string {
+	obj, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Sprintf("%v", obj)
+	}
+
+	obj.SetManagedFields(nil)
+
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Sprintf("%v", obj)
+	}
+
+	return string(out)
+}
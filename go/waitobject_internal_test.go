@@ -0,0 +1,87 @@
+package envtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWaitForObject(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"}}
+	cl := fake.NewClientBuilder().Build()
+	key := client.ObjectKeyFromObject(cm)
+
+	// The object is created and then updated while waiting
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		created := cm.DeepCopy()
+		_ = cl.Create(context.Background(), created)
+
+		time.Sleep(50 * time.Millisecond)
+
+		created.Data = map[string]string{"mode": "fast"}
+		_ = cl.Update(context.Background(), created)
+	}()
+
+	got := &corev1.ConfigMap{}
+	err := WaitForObject(t.Context(), cl, key, got, func(obj client.Object) bool {
+		return obj.(*corev1.ConfigMap).Data["mode"] == "fast"
+	}, WithWaitInterval(10*time.Millisecond), WithWaitTimeout(5*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, "fast", got.Data["mode"])
+
+	// Timeouts report the last observed object
+	err = WaitForObject(t.Context(), cl, key, &corev1.ConfigMap{}, func(obj client.Object) bool {
+		return obj.(*corev1.ConfigMap).Data["mode"] == "slow"
+	}, WithWaitInterval(10*time.Millisecond), WithWaitTimeout(50*time.Millisecond))
+	require.ErrorContains(t, err, "timed out after 50ms waiting for *v1.ConfigMap default/settings, last observed:")
+	require.ErrorContains(t, err, "mode: fast")
+
+	err = WaitForObject(t.Context(), cl, client.ObjectKey{Namespace: "default", Name: "missing"}, &corev1.ConfigMap{},
+		func(client.Object) bool { return true }, WithWaitInterval(10*time.Millisecond), WithWaitTimeout(50*time.Millisecond))
+	require.ErrorContains(t, err, `last observed: configmaps "missing" not found`)
+}
+
+func TestWaitForDeletion(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:       "finalized",
+		Namespace:  "default",
+		Finalizers: []string{"example.com/cleanup"},
+	}}
+	cl := fake.NewClientBuilder().WithObjects(cm).Build()
+	key := client.ObjectKeyFromObject(cm)
+
+	require.NoError(t, cl.Delete(t.Context(), cm))
+
+	// The finalizer holds the object back
+	err := WaitForDeletion(t.Context(), cl, key, &corev1.ConfigMap{},
+		WithWaitInterval(10*time.Millisecond), WithWaitTimeout(50*time.Millisecond))
+	require.ErrorContains(t, err, "timed out after 50ms waiting for deletion of *v1.ConfigMap default/finalized")
+	require.ErrorContains(t, err, "example.com/cleanup")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		current := &corev1.ConfigMap{}
+		_ = cl.Get(context.Background(), key, current)
+		current.Finalizers = nil
+		_ = cl.Update(context.Background(), current)
+	}()
+
+	require.NoError(t, WaitForDeletion(t.Context(), cl, key, &corev1.ConfigMap{}, WithWaitInterval(10*time.Millisecond)))
+}
+
+func TestWaitOptions(t *testing.T) {
+	cfg := newWaitConfig()
+	require.Equal(t, &waitConfig{interval: DefaultWaitInterval, timeout: DefaultWaitTimeout}, cfg)
+
+	cfg = newWaitConfig(WithWaitInterval(time.Second), WithWaitTimeout(time.Minute))
+	require.Equal(t, &waitConfig{interval: time.Second, timeout: time.Minute}, cfg)
+}
@@ -0,0 +1,65 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEnvtestContainerWaitForObject(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:       "waited",
+		Namespace:  "default",
+		Finalizers: []string{"example.com/cleanup"},
+	}}
+	key := client.ObjectKeyFromObject(cm)
+
+	// Play the controller updating the object in the background
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+
+		if err := cl.Create(ctx, cm); err != nil {
+			return
+		}
+
+		cm.Data = map[string]string{"reconciled": "true"}
+		_ = cl.Update(ctx, cm)
+	}()
+
+	got := &corev1.ConfigMap{}
+	require.NoError(t, envtest.WaitForObject(ctx, cl, key, got, func(obj client.Object) bool {
+		return obj.(*corev1.ConfigMap).Data["reconciled"] == "true"
+	}))
+
+	require.NoError(t, cl.Delete(ctx, got))
+
+	err = envtest.WaitForDeletion(ctx, cl, key, &corev1.ConfigMap{}, envtest.WithWaitTimeout(time.Second))
+	require.ErrorContains(t, err, "example.com/cleanup")
+
+	require.NoError(t, cl.Get(ctx, key, got))
+
+	got.Finalizers = nil
+	require.NoError(t, cl.Update(ctx, got))
+	require.NoError(t, envtest.WaitForDeletion(ctx, cl, key, &corev1.ConfigMap{}))
+}
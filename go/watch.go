@@ -0,0 +1,271 @@
+package envtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// errWatchExpired is returned by a watch whose resourceVersion became too old, which requires a re-list
+var errWatchExpired = errors.New("watch expired")
+
+// watchSource lists and watches the objects of a resource
+type watchSource struct {
+	list  func(ctx context.Context) (*unstructured.UnstructuredList, error)
+	watch func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// WatchObjects watches the objects of the given kind in namespace, or in all namespaces if empty, and delivers
+// their events on the returned channel with the objects decoded into T, e.g. *corev1.ConfigMap or
+// *unstructured.Unstructured, until the returned stop func is called or ctx is done. The objects existing
+// when the watch starts are delivered as Added events first, so no state is missed.
+//
+// Bookmarks are consumed, and a watch whose resourceVersion expired (410 Gone) is resumed after a re-list,
+// which delivers the current objects as Modified events. Other watch failures are retried; a failing re-list
+// is delivered as a watch.Error event before the channel is closed.
+func WatchObjects[T client.Object](
+	ctx context.Context,
+	cfg *rest.Config,
+	gvk schema.GroupVersionKind,
+	namespace string,
+) (<-chan watch.Event, func(), error) {
+	resource, err := watchResource(cfg, gvk, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src := watchSource{
+		list: func(ctx context.Context) (*unstructured.UnstructuredList, error) {
+			return resource.List(ctx, metav1.ListOptions{})
+		},
+		watch: resource.Watch,
+	}
+
+	return startWatch[T](ctx, src)
+}
+
+// watchResource returns the dynamic client for the resource of the given kind
+func watchResource(
+	cfg *rest.Config,
+	gvk schema.GroupVersionKind,
+	namespace string,
+) (dynamic.ResourceInterface, error) {
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create REST mapper: %w", err)
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the resource of %s: %w", gvk, err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(cfg, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	if namespace != "" && mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return dynamicClient.Resource(mapping.Resource).Namespace(namespace), nil
+	}
+
+	return dynamicClient.Resource(mapping.Resource), nil
+}
+
+// startWatch lists the objects, so that errors like missing permissions are returned right away,
+// and then streams their events in the background
+func startWatch[T client.Object](
+	ctx context.Context,
+	src watchSource,
+) (<-chan watch.Event, func(), error) {
+	list, err := src.list(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	events := make(chan watch.Event)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(events)
+
+		streamEvents[T](ctx, src, list, events)
+	}()
+
+	stop := func() {
+		cancel()
+		<-done
+	}
+
+	return events, stop, nil
+}
+
+// streamEvents delivers the listed objects and then the watch events from the list resourceVersion,
+// re-listing whenever the watch expires
+func streamEvents[T client.Object](
+	ctx context.Context,
+	src watchSource,
+	list *unstructured.UnstructuredList,
+	events chan<- watch.Event,
+) {
+	eventType := watch.Added
+
+	for {
+		for i := range list.Items {
+			if !sendEvent[T](ctx, events, watch.Event{Type: eventType, Object: &list.Items[i]}) {
+				return
+			}
+		}
+
+		err := watchEvents[T](ctx, src, list.GetResourceVersion(), events)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, errWatchExpired) {
+			list, err = src.list(ctx)
+			eventType = watch.Modified
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				sendError(ctx, events, err)
+			}
+
+			return
+		}
+	}
+}
+
+// watchEvents delivers the events of a watch from resourceVersion, until it expires or fails
+func watchEvents[T client.Object](
+	ctx context.Context,
+	src watchSource,
+	resourceVersion string,
+	events chan<- watch.Event,
+) error {
+	// The retry watcher resumes from the last event on closed connections, and requests and consumes bookmarks
+	watcher, err := watchtools.NewRetryWatcherWithContext(ctx, resourceVersion, &cache.ListWatch{
+		WatchFuncWithContext: src.watch,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch objects: %w", err)
+	}
+
+	defer watcher.Stop()
+
+	for {
+		var (
+			event watch.Event
+			ok    bool
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok = <-watcher.ResultChan():
+		}
+
+		if !ok {
+			return errors.New("watch closed")
+		}
+
+		if event.Type == watch.Error {
+			err := apierrors.FromObject(event.Object)
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				return errWatchExpired
+			}
+
+			return fmt.Errorf("failed to watch objects: %w", err)
+		}
+
+		if !sendEvent[T](ctx, events, event) {
+			return nil
+		}
+	}
+}
+
+// sendEvent delivers an event with its object decoded into T, reporting whether the receiver is still there
+func sendEvent[T client.Object](
+	ctx context.Context,
+	events chan<- watch.Event,
+	event watch.Event,
+) bool {
+	obj, err := typedWatchObject[T](event.Object)
+	if err != nil {
+		sendError(ctx, events, err)
+
+		return false
+	}
+
+	select {
+	case events <- watch.Event{Type: event.Type, Object: obj}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendError delivers an error event
+func sendError(ctx context.Context, events chan<- watch.Event, err error) {
+	var status metav1.Status
+
+	var statusErr apierrors.APIStatus
+	if errors.As(err, &statusErr) {
+		status = statusErr.Status()
+	} else {
+		status = apierrors.NewInternalError(err).ErrStatus
+	}
+
+	select {
+	case events <- watch.Event{Type: watch.Error, Object: &status}:
+	case <-ctx.Done():
+	}
+}
+
+// typedWatchObject decodes an unstructured watch object into T
+func typedWatchObject[T client.Object](obj runtime.Object) (T, error) {
+	var typed T
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return typed, fmt.Errorf("unexpected watch object %T", obj)
+	}
+
+	if typed, ok := any(u).(T); ok {
+		return typed, nil
+	}
+
+	typed, ok = reflect.New(reflect.TypeFor[T]().Elem()).Interface().(T)
+	if !ok {
+		return typed, fmt.Errorf("cannot decode watch objects into %T", typed)
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return typed, fmt.Errorf("failed to decode %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+
+	return typed, nil
+}
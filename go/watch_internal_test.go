@@ -0,0 +1,161 @@
+package envtest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// fakeWatchSource serves lists and watches from the test, recording the resourceVersions watched from
+type fakeWatchSource struct {
+	mu       sync.Mutex
+	lists    []*unstructured.UnstructuredList
+	listErr  error
+	watchers chan *watch.FakeWatcher
+	watched  []string
+}
+
+func (s *fakeWatchSource) source() watchSource {
+	return watchSource{
+		list: func(context.Context) (*unstructured.UnstructuredList, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if len(s.lists) == 0 {
+				return nil, s.listErr
+			}
+
+			list := s.lists[0]
+			s.lists = s.lists[1:]
+
+			return list, nil
+		},
+		watch: func(_ context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			s.mu.Lock()
+			s.watched = append(s.watched, opts.ResourceVersion)
+			s.mu.Unlock()
+
+			return <-s.watchers, nil
+		},
+	}
+}
+
+// watchedConfigMap returns a ConfigMap with the given data as a watch object
+func watchedConfigMap(resourceVersion, mode string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings", "namespace": "default", "resourceVersion": resourceVersion},
+		"data":       map[string]any{"mode": mode},
+	}}
+}
+
+// receiveEvent returns the next event as "Type mode" of the ConfigMap
+func receiveEvent(t *testing.T, events <-chan watch.Event) string {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		cm, ok := event.Object.(*corev1.ConfigMap)
+		require.True(t, ok, "unexpected object %T", event.Object)
+
+		return string(event.Type) + " " + cm.Data["mode"]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch event")
+	}
+
+	return ""
+}
+
+func TestWatchObjects(t *testing.T) {
+	initial := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*watchedConfigMap("1", "initial")}}
+	initial.SetResourceVersion("1")
+
+	relisted := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*watchedConfigMap("5", "relisted")}}
+	relisted.SetResourceVersion("5")
+
+	src := &fakeWatchSource{
+		lists:    []*unstructured.UnstructuredList{initial, relisted},
+		watchers: make(chan *watch.FakeWatcher, 2),
+	}
+
+	first, second := watch.NewFake(), watch.NewFake()
+	src.watchers <- first
+	src.watchers <- second
+
+	events, stop, err := startWatch[*corev1.ConfigMap](t.Context(), src.source())
+	require.NoError(t, err)
+
+	require.Equal(t, "ADDED initial", receiveEvent(t, events))
+
+	first.Modify(watchedConfigMap("2", "transient"))
+	require.Equal(t, "MODIFIED transient", receiveEvent(t, events))
+
+	// Bookmarks are not delivered
+	bookmark := watchedConfigMap("3", "")
+	first.Action(watch.Bookmark, bookmark)
+
+	// An expired watch is resumed after a re-list
+	first.Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+	require.Equal(t, "MODIFIED relisted", receiveEvent(t, events))
+
+	second.Delete(watchedConfigMap("6", "relisted"))
+	require.Equal(t, "DELETED relisted", receiveEvent(t, events))
+
+	stop()
+
+	_, open := <-events
+	require.False(t, open)
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	require.Equal(t, []string{"1", "5"}, src.watched)
+}
+
+func TestWatchObjectsError(t *testing.T) {
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("1")
+
+	src := &fakeWatchSource{
+		lists:    []*unstructured.UnstructuredList{list},
+		listErr:  apierrors.NewForbidden(corev1.Resource("configmaps"), "", nil),
+		watchers: make(chan *watch.FakeWatcher, 1),
+	}
+
+	w := watch.NewFake()
+	src.watchers <- w
+
+	events, stop, err := startWatch[*unstructured.Unstructured](t.Context(), src.source())
+	require.NoError(t, err)
+
+	defer stop()
+
+	w.Add(watchedConfigMap("2", "fast"))
+
+	event := <-events
+	require.Equal(t, watch.Added, event.Type)
+	require.IsType(t, &unstructured.Unstructured{}, event.Object)
+
+	// A failing re-list ends the watch
+	w.Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+
+	event = <-events
+	require.Equal(t, watch.Error, event.Type)
+	require.Equal(t, int32(http.StatusForbidden), event.Object.(*metav1.Status).Code)
+
+	_, open := <-events
+	require.False(t, open)
+
+	_, _, err = startWatch[*corev1.ConfigMap](t.Context(), src.source())
+	require.ErrorContains(t, err, "failed to list objects")
+}
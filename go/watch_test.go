@@ -0,0 +1,87 @@
+package envtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestEnvtestContainerWatchObjects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, getEnvtestOptions()...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	cfg, err := c.RESTConfig(ctx)
+	require.NoError(t, err)
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	existing := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"}}
+	require.NoError(t, cl.Create(ctx, existing))
+
+	events, stop, err := envtest.WatchObjects[*corev1.ConfigMap](ctx, cfg, corev1.SchemeGroupVersion.WithKind("ConfigMap"), "default")
+	require.NoError(t, err)
+
+	defer stop()
+
+	next := func() (watch.EventType, *corev1.ConfigMap) {
+		select {
+		case event := <-events:
+			cm, ok := event.Object.(*corev1.ConfigMap)
+			require.True(t, ok, "unexpected %s event object %v", event.Type, event.Object)
+
+			return event.Type, cm
+		case <-ctx.Done():
+			t.Fatal("no watch event")
+		}
+
+		return "", nil
+	}
+
+	// Existing objects come first
+	eventType, cm := next()
+	require.Equal(t, watch.Added, eventType)
+	require.Equal(t, "existing", cm.Name)
+
+	// Every intermediate state is observed, even when quickly overwritten
+	watched := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "watched", Namespace: "default"}}
+	require.NoError(t, cl.Create(ctx, watched))
+
+	for _, phase := range []string{"pending", "done"} {
+		watched.Data = map[string]string{"phase": phase}
+		require.NoError(t, cl.Update(ctx, watched))
+	}
+
+	require.NoError(t, cl.Delete(ctx, watched))
+
+	var observed []string
+
+	for range 4 {
+		eventType, cm := next()
+		require.Equal(t, "watched", cm.Name)
+
+		observed = append(observed, string(eventType)+" "+cm.Data["phase"])
+	}
+
+	require.Equal(t, []string{"ADDED ", "MODIFIED pending", "MODIFIED done", "DELETED done"}, observed)
+
+	stop()
+
+	_, open := <-events
+	require.False(t, open)
+}
@@ -0,0 +1,290 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/testcontainers/testcontainers-go"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// webhookServingHost is the address the webhook server on the test host listens on.
+	// The container reaches the host via the Docker gateway, so loopback is not enough.
+	webhookServingHost = "0.0.0.0"
+
+	// dockerHostInternal is the name Docker Desktop resolves to the host, added to the serving certificate
+	// for webhook configurations that are pointed at it by hand
+	dockerHostInternal = "host.docker.internal"
+)
+
+// WebhookInstallOptions is where the webhooks installed by WithWebhooks are expected to be served on the test host.
+// The fields are named like the ones of controller-runtime envtest.WebhookInstallOptions, and map to the
+// Host, Port and CertDir of a controller-runtime webhook server.
+type WebhookInstallOptions struct {
+	// LocalServingHost is the address the webhook server has to listen on
+	LocalServingHost string
+	// LocalServingPort is the port the webhook server has to listen on, picked by Run
+	LocalServingPort int
+	// LocalServingCertDir contains the serving certificate (tls.crt, tls.key) and its CA (ca.crt)
+	LocalServingCertDir string
+	// LocalServingCAData is the PEM-encoded CA put into the caBundle of the webhook configurations
+	LocalServingCAData []byte
+	// TargetAlias is the network alias of the webhook server container given to WithWebhookTarget,
+	// empty when the webhooks are served on the test host
+	TargetAlias string
+}
+
+// ManagerOptions returns the options of a controller-runtime webhook server serving the installed webhooks,
+// e.g. ctrl.Options{WebhookServer: webhook.NewServer(opts.ManagerOptions())}
+func (o WebhookInstallOptions) ManagerOptions() webhook.Options {
+	return webhook.Options{
+		Host:    o.LocalServingHost,
+		Port:    o.LocalServingPort,
+		CertDir: o.LocalServingCertDir,
+	}
+}
+
+// webhookTarget is the address the API server calls the webhooks on
+type webhookTarget struct {
+	host string
+	port int
+}
+
+// url returns the webhook URL for the given path
+func (t webhookTarget) url(path string) string {
+	return "https://" + net.JoinHostPort(t.host, strconv.Itoa(t.port)) + path
+}
+
+// webhookManifest is a webhook configuration together with the file it was read from
+type webhookManifest struct {
+	file string
+	obj  client.Object
+}
+
+// WebhookOptions returns where the webhook server has to serve the webhooks installed by WithWebhooks,
+// or the zero value if there are none
+func (c *EnvtestContainer) WebhookOptions() WebhookInstallOptions {
+	return c.webhookOptions
+}
+
+// readWebhooks reads the ValidatingWebhookConfigurations and MutatingWebhookConfigurations
+// in the given files and directories, which are read the same way as for WithCRDs
+func readWebhooks(paths []string) ([]webhookManifest, error) {
+	var webhooks []webhookManifest
+
+	for _, path := range paths {
+		files, err := manifestFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			manifests, err := readWebhookFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			webhooks = append(webhooks, manifests...)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// readWebhookFile decodes the webhook configurations of a possibly multi-document manifest file
+func readWebhookFile(file string) ([]webhookManifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var webhooks []webhookManifest
+
+	err = decodeDocuments(file, f, func(i int, doc *unstructured.Unstructured) error {
+		var obj client.Object
+
+		switch doc.GetKind() {
+		case "ValidatingWebhookConfiguration":
+			obj = &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		case "MutatingWebhookConfiguration":
+			obj = &admissionregistrationv1.MutatingWebhookConfiguration{}
+		default:
+			return fmt.Errorf(
+				"%s document %d is a %s, not a webhook configuration",
+				file,
+				i,
+				doc.GetKind(),
+			)
+		}
+
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(doc.Object, obj)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s document %d: %w", file, i, err)
+		}
+
+		webhooks = append(webhooks, webhookManifest{file: file, obj: obj})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// pointWebhooks rewrites the client config of every webhook, usually a reference to the in-cluster Service
+// of the webhook server, to the given target, keeping the path
+func pointWebhooks(webhooks []webhookManifest, target webhookTarget, caBundle []byte) error {
+	for _, m := range webhooks {
+		var configs []*admissionregistrationv1.WebhookClientConfig
+
+		switch obj := m.obj.(type) {
+		case *admissionregistrationv1.ValidatingWebhookConfiguration:
+			for i := range obj.Webhooks {
+				configs = append(configs, &obj.Webhooks[i].ClientConfig)
+			}
+		case *admissionregistrationv1.MutatingWebhookConfiguration:
+			for i := range obj.Webhooks {
+				configs = append(configs, &obj.Webhooks[i].ClientConfig)
+			}
+		}
+
+		for _, cfg := range configs {
+			path := "/"
+
+			switch {
+			case cfg.Service != nil && cfg.Service.Path != nil:
+				path = *cfg.Service.Path
+			case cfg.URL != nil:
+				u, err := url.Parse(*cfg.URL)
+				if err != nil {
+					return fmt.Errorf(
+						"webhook configuration %s from %s has an invalid URL: %w",
+						m.obj.GetName(),
+						m.file,
+						err,
+					)
+				}
+
+				if u.Path != "" {
+					path = u.Path
+				}
+			}
+
+			webhookURL := target.url(path)
+			*cfg = admissionregistrationv1.WebhookClientConfig{URL: &webhookURL, CABundle: caBundle}
+		}
+	}
+
+	return nil
+}
+
+// setupWebhooks generates the serving certificate for the webhook server at the target,
+// and points the webhook configurations at it. The certificate directory is removed on termination.
+func (c *EnvtestContainer) setupWebhooks(
+	ctx context.Context,
+	webhooks []webhookManifest,
+	target webhookTarget,
+) error {
+	hosts := []string{target.host}
+
+	if target.host == testcontainers.HostInternal {
+		inspect, err := c.Inspect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+
+		// Besides HostAlias, cover the gateway IPs the container reaches the host by
+		hosts = append(hosts, dockerHostInternal)
+		hosts = append(hosts, containerGateways(inspect)...)
+	}
+
+	pki, err := certs.NewWebhookPKI(hosts...)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "envtest-webhook-certs-*")
+	if err != nil {
+		return fmt.Errorf("failed to create webhook cert dir: %w", err)
+	}
+
+	if err := pki.WriteDir(dir); err != nil {
+		_ = os.RemoveAll(dir)
+
+		return err
+	}
+
+	c.onTerminate(func() { _ = os.RemoveAll(dir) })
+
+	c.webhookOptions = WebhookInstallOptions{
+		LocalServingHost:    webhookServingHost,
+		LocalServingPort:    target.port,
+		LocalServingCertDir: dir,
+		LocalServingCAData:  pki.CACert,
+	}
+
+	if target.host != testcontainers.HostInternal {
+		c.webhookOptions.TargetAlias = target.host
+	}
+
+	return pointWebhooks(webhooks, target, pki.CACert)
+}
+
+// containerGateways returns the gateway IPs of the networks the container is attached to, in network name order
+func containerGateways(inspect *container.InspectResponse) []string {
+	if inspect.NetworkSettings == nil {
+		return nil
+	}
+
+	var gateways []string
+
+	for _, name := range slices.Sorted(maps.Keys(inspect.NetworkSettings.Networks)) {
+		if endpoint := inspect.NetworkSettings.Networks[name]; endpoint != nil &&
+			endpoint.Gateway != "" {
+			gateways = append(gateways, endpoint.Gateway)
+		}
+	}
+
+	return gateways
+}
+
+// installWebhooks creates or updates the webhook configurations
+func (c *EnvtestContainer) installWebhooks(ctx context.Context, webhooks []webhookManifest) error {
+	cl, err := c.controllerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range webhooks {
+		err := c.retry(ctx, "install webhook configuration", func(ctx context.Context) error {
+			return createOrUpdate(ctx, cl, m.obj)
+		})
+		if err != nil {
+			return fmt.Errorf(
+				"failed to install webhook configuration %s from %s: %w",
+				m.obj.GetName(),
+				m.file,
+				err,
+			)
+		}
+	}
+
+	return nil
+}
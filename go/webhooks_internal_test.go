@@ -0,0 +1,110 @@
+package envtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func TestReadWebhooks(t *testing.T) {
+	webhooks, err := readWebhooks([]string{"testdata/webhooks"})
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+
+	mutating, ok := webhooks[0].obj.(*admissionregistrationv1.MutatingWebhookConfiguration)
+	require.True(t, ok)
+	require.Equal(t, "mutating-webhook-configuration", mutating.Name)
+	require.Equal(t, "/mutate-v1-configmap", *mutating.Webhooks[0].ClientConfig.Service.Path)
+
+	validating, ok := webhooks[1].obj.(*admissionregistrationv1.ValidatingWebhookConfiguration)
+	require.True(t, ok)
+	require.Equal(t, "vconfigmap.webhooks.example.com", validating.Webhooks[0].Name)
+	require.Equal(t, filepath.Join("testdata", "webhooks", "manifests.yaml"), webhooks[1].file)
+
+	file := filepath.Join(t.TempDir(), "crd.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n"), 0o644))
+
+	_, err = readWebhooks([]string{file})
+	require.ErrorContains(t, err, file+" document 0 is a CustomResourceDefinition")
+}
+
+func TestPointWebhooks(t *testing.T) {
+	webhooks := []webhookManifest{{
+		file: "manifests.yaml",
+		obj: &admissionregistrationv1.ValidatingWebhookConfiguration{
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service:  &admissionregistrationv1.ServiceReference{Namespace: "system", Name: "webhook-service", Path: ptr.To("/validate")},
+					CABundle: []byte("stale"),
+				}},
+				{ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: ptr.To("https://webhook.example.com:8443/check")}},
+				{ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Namespace: "system", Name: "webhook-service"},
+				}},
+			},
+		},
+	}, {
+		obj: &admissionregistrationv1.MutatingWebhookConfiguration{
+			Webhooks: []admissionregistrationv1.MutatingWebhook{{ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{Namespace: "system", Name: "webhook-service", Path: ptr.To("/mutate")},
+			}}},
+		},
+	}}
+
+	require.NoError(t, pointWebhooks(webhooks, webhookTarget{host: testcontainers.HostInternal, port: 9443}, []byte("ca")))
+
+	validating := webhooks[0].obj.(*admissionregistrationv1.ValidatingWebhookConfiguration)
+	for i, path := range []string{"/validate", "/check", "/"} {
+		require.Equal(t, admissionregistrationv1.WebhookClientConfig{
+			URL:      ptr.To(hostURL("https", 9443, path)),
+			CABundle: []byte("ca"),
+		}, validating.Webhooks[i].ClientConfig)
+	}
+
+	mutating := webhooks[1].obj.(*admissionregistrationv1.MutatingWebhookConfiguration)
+	require.Equal(t, hostURL("https", 9443, "/mutate"), *mutating.Webhooks[0].ClientConfig.URL)
+
+	// Webhook servers in sibling containers are called by their network alias
+	require.NoError(t, pointWebhooks(webhooks[1:], webhookTarget{host: "webhook", port: 8443}, nil))
+	require.Equal(t, "https://webhook:8443/mutate", *mutating.Webhooks[0].ClientConfig.URL)
+
+	err := pointWebhooks([]webhookManifest{{
+		file: "broken.yaml",
+		obj: &admissionregistrationv1.ValidatingWebhookConfiguration{
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{{ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: ptr.To("://")}}},
+		},
+	}}, webhookTarget{host: "webhook", port: 8443}, nil)
+	require.ErrorContains(t, err, "broken.yaml")
+}
+
+func TestContainerGateways(t *testing.T) {
+	require.Empty(t, containerGateways(&container.InspectResponse{}))
+
+	inspect := &container.InspectResponse{NetworkSettings: &container.NetworkSettings{
+		Networks: map[string]*network.EndpointSettings{
+			"kind":   {Gateway: "172.18.0.1"},
+			"bridge": {Gateway: "172.17.0.1"},
+			"none":   {},
+		},
+	}}
+	require.Equal(t, []string{"172.17.0.1", "172.18.0.1"}, containerGateways(inspect))
+}
+
+func TestWebhookManagerOptions(t *testing.T) {
+	opts := WebhookInstallOptions{
+		LocalServingHost:    "0.0.0.0",
+		LocalServingPort:    9443,
+		LocalServingCertDir: "/tmp/certs",
+		LocalServingCAData:  []byte("ca"),
+	}
+
+	require.Equal(t, webhook.Options{Host: "0.0.0.0", Port: 9443, CertDir: "/tmp/certs"}, opts.ManagerOptions())
+}
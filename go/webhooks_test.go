@@ -0,0 +1,255 @@
+package envtest_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	envtest "github.com/roma-glushko/testcontainers-envtest/go"
+	"github.com/roma-glushko/testcontainers-envtest/go/certs"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+	"github.com/testcontainers/testcontainers-go/wait"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reviewConfigMap answers an AdmissionReview with the response built by review
+func reviewConfigMap(review func(cm *corev1.ConfigMap) *admissionv1.AdmissionResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ar := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(ar); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		cm := &corev1.ConfigMap{}
+		if err := json.Unmarshal(ar.Request.Object.Raw, cm); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		ar.Response = review(cm)
+		ar.Response.UID = ar.Request.UID
+		ar.Request = nil
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ar)
+	}
+}
+
+func TestEnvtestContainerWithWebhooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Minute)
+	defer cancel()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(), envtest.WithWebhooks("testdata/webhooks"))...)
+	require.NoError(t, err)
+
+	terminated := false
+
+	defer func() {
+		if !terminated {
+			require.NoError(t, testcontainers.TerminateContainer(c))
+		}
+	}()
+
+	opts := c.WebhookOptions()
+	require.NotZero(t, opts.LocalServingPort)
+
+	for _, name := range []string{certs.CertFileName, certs.KeyFileName, certs.CAFileName} {
+		require.FileExists(t, filepath.Join(opts.LocalServingCertDir, name))
+	}
+
+	// The serving certificate is issued by the injected CA for the names the container reaches the host by
+	certPEM, err := os.ReadFile(filepath.Join(opts.LocalServingCertDir, certs.CertFileName))
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+
+	servingCert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	require.Contains(t, servingCert.DNSNames, c.HostAlias())
+	require.Contains(t, servingCert.DNSNames, "host.docker.internal")
+
+	inspect, err := c.Inspect(ctx)
+	require.NoError(t, err)
+
+	gateway := net.ParseIP(inspect.NetworkSettings.Networks["bridge"].Gateway)
+	require.True(t, slices.ContainsFunc(servingCert.IPAddresses, gateway.Equal), "%v does not include %s", servingCert.IPAddresses, gateway)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(opts.LocalServingCAData))
+
+	_, err = servingCert.Verify(x509.VerifyOptions{Roots: roots, DNSName: c.HostAlias()})
+	require.NoError(t, err)
+
+	// Serve the webhooks the way a controller-runtime webhook server configured from WebhookOptions would
+	mux := http.NewServeMux()
+	mux.Handle("/mutate-v1-configmap", reviewConfigMap(func(*corev1.ConfigMap) *admissionv1.AdmissionResponse {
+		patch := `[{"op":"add","path":"/metadata/annotations","value":{"webhooks.example.com/mutated":"true"}}]`
+
+		return &admissionv1.AdmissionResponse{Allowed: true, Patch: []byte(patch), PatchType: ptr.To(admissionv1.PatchTypeJSONPatch)}
+	}))
+	mux.Handle("/validate-v1-configmap", reviewConfigMap(func(cm *corev1.ConfigMap) *admissionv1.AdmissionResponse {
+		if _, forbidden := cm.Data["forbidden"]; forbidden {
+			return &admissionv1.AdmissionResponse{Result: &metav1.Status{Message: "forbidden key"}}
+		}
+
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}))
+
+	cert := filepath.Join(opts.LocalServingCertDir, certs.CertFileName)
+	key := filepath.Join(opts.LocalServingCertDir, certs.KeyFileName)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(opts.LocalServingHost, strconv.Itoa(opts.LocalServingPort)))
+	require.NoError(t, err)
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() { _ = srv.ServeTLS(listener, cert, key) }()
+
+	defer func() { _ = srv.Close() }()
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	labels := map[string]string{"webhooks.example.com/test": "true"}
+
+	allowed := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "allowed", Namespace: "default", Labels: labels},
+		Data:       map[string]string{"key": "value"},
+	}
+	require.NoError(t, cl.Create(ctx, allowed))
+	require.Equal(t, "true", allowed.Annotations["webhooks.example.com/mutated"])
+
+	denied := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "denied", Namespace: "default", Labels: labels},
+		Data:       map[string]string{"forbidden": "value"},
+	}
+	require.ErrorContains(t, cl.Create(ctx, denied), "forbidden key")
+
+	// Objects outside the webhook selectors are not affected
+	unrelated := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}}
+	require.NoError(t, cl.Create(ctx, unrelated))
+	require.NoError(t, cl.Get(ctx, client.ObjectKeyFromObject(unrelated), unrelated))
+	require.Empty(t, unrelated.Annotations)
+
+	// The cert dir is removed on termination
+	require.NoError(t, c.Terminate(ctx))
+
+	terminated = true
+
+	_, err = os.Stat(opts.LocalServingCertDir)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// denyingWebhookServer is a minimal HTTPS admission webhook denying every request, served by a Python container
+const denyingWebhookServer = `
+import http.server, json, ssl
+
+class Handler(http.server.BaseHTTPRequestHandler):
+    def do_POST(self):
+        review = json.loads(self.rfile.read(int(self.headers["Content-Length"])))
+        body = json.dumps({
+            "apiVersion": "admission.k8s.io/v1",
+            "kind": "AdmissionReview",
+            "response": {
+                "uid": review["request"]["uid"],
+                "allowed": False,
+                "status": {"message": "denied by " + self.path},
+            },
+        }).encode()
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+server = http.server.HTTPServer(("0.0.0.0", 8443), Handler)
+context = ssl.SSLContext(ssl.PROTOCOL_TLS_SERVER)
+context.load_cert_chain("/certs/tls.crt", "/certs/tls.key")
+server.socket = context.wrap_socket(server.socket, server_side=True)
+print("serving", flush=True)
+server.serve_forever()
+`
+
+func TestEnvtestContainerWithWebhookTarget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+
+	nw, err := network.New(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, nw.Remove(context.WithoutCancel(ctx)))
+	}()
+
+	c, err := envtest.Run(ctx, append(getEnvtestOptions(),
+		envtest.WithNetwork(nw.Name, "apiserver"),
+		envtest.WithWebhooks("testdata/webhooks"),
+		envtest.WithWebhookTarget("webhook", 8443),
+	)...)
+	require.NoError(t, err)
+
+	defer func() {
+		err := testcontainers.TerminateContainer(c)
+		require.NoError(t, err)
+	}()
+
+	opts := c.WebhookOptions()
+	require.Equal(t, "webhook", opts.TargetAlias)
+	require.Equal(t, 8443, opts.LocalServingPort)
+
+	// The webhook server container gets the serving certificate issued for its alias
+	webhook, err := testcontainers.Run(ctx, "python:3.13-alpine",
+		testcontainers.WithCmd("python", "-c", denyingWebhookServer),
+		testcontainers.WithFiles(
+			testcontainers.ContainerFile{
+				HostFilePath:      filepath.Join(opts.LocalServingCertDir, certs.CertFileName),
+				ContainerFilePath: "/certs/tls.crt",
+				FileMode:          0o644,
+			},
+			testcontainers.ContainerFile{
+				HostFilePath:      filepath.Join(opts.LocalServingCertDir, certs.KeyFileName),
+				ContainerFilePath: "/certs/tls.key",
+				FileMode:          0o644,
+			},
+		),
+		network.WithNetwork([]string{"webhook"}, nw),
+		testcontainers.WithWaitStrategy(wait.ForLog("serving")),
+	)
+
+	defer func() {
+		require.NoError(t, testcontainers.TerminateContainer(webhook))
+	}()
+
+	require.NoError(t, err)
+
+	cl, err := c.GetClient(ctx, nil)
+	require.NoError(t, err)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "reviewed",
+		Namespace: "default",
+		Labels:    map[string]string{"webhooks.example.com/test": "true"},
+	}}
+
+	// The mutating webhook is called first
+	require.ErrorContains(t, cl.Create(ctx, cm), "denied by /mutate-v1-configmap")
+}